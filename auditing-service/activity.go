@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"seta-pkg/logging"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 100
+)
+
+// activityEntry is one change of an asset as shown in its activity feed.
+type activityEntry struct {
+	EventType    string    `json:"eventType"`
+	ActionBy     string    `json:"actionBy"`
+	TargetUserID string    `json:"targetUserId,omitempty"`
+	OccurredAt   time.Time `json:"occurredAt"`
+}
+
+// activityHandler serves GET /internal/activity?assetType=folder|note&assetId=...,
+// the recorded events of one asset, newest first. ?since (RFC 3339) leaves out older
+// events and ?limit (default 50, at most 100) and ?offset page through the rest.
+// Resyncs, which change nothing, events of the smoke test and payloads that could not
+// be parsed are left out. Callers check the reader may see the asset.
+func activityHandler(probes *probes, log logging.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assetType, assetID := query.Get("assetType"), query.Get("assetId")
+		if (assetType != "folder" && assetType != "note") || assetID == "" {
+			writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": "assetType must be folder or note and assetId is required"})
+			return
+		}
+		limit, offset := defaultActivityLimit, 0
+		if raw := query.Get("limit"); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v < 1 || v > maxActivityLimit {
+				writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and 100"})
+				return
+			}
+			limit = v
+		}
+		if raw := query.Get("offset"); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v < 0 {
+				writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": "offset must be at least 0"})
+				return
+			}
+			offset = v
+		}
+
+		db := probes.db.Load()
+		if db == nil {
+			writeJSON(log, w, http.StatusServiceUnavailable, map[string]string{"error": "Database is not connected yet"})
+			return
+		}
+		tx := db.WithContext(r.Context()).Model(&auditLog{}).
+			Select("event_type, action_by, target_user_id, occurred_at").
+			Where("asset_type = ? AND asset_id = ? AND NOT parse_error", assetType, assetID).
+			Where("event_type <> 'ASSET_RESYNC' AND COALESCE((payload->>'synthetic')::boolean, false) = false")
+		if raw := query.Get("since"); raw != "" {
+			since, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 time"})
+				return
+			}
+			tx = tx.Where("occurred_at >= ?", since)
+		}
+
+		// One more than asked tells whether there is a next page.
+		entries := make([]activityEntry, 0, limit+1)
+		if err := tx.Order("occurred_at DESC, id DESC").Limit(limit + 1).Offset(offset).Scan(&entries).Error; err != nil {
+			log.Error("Failed to read asset activity", logging.Fields{logging.FieldError: err, logging.FieldAssetID: assetID})
+			writeJSON(log, w, http.StatusInternalServerError, map[string]string{"error": "Failed to read activity"})
+			return
+		}
+		hasMore := len(entries) > limit
+		if hasMore {
+			entries = entries[:limit]
+		}
+		writeJSON(log, w, http.StatusOK, map[string]any{"events": entries, "hasMore": hasMore})
+	}
+}
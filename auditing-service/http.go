@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"seta-pkg/buildinfo"
+	"seta-pkg/database"
+	"seta-pkg/health"
+	"seta-pkg/logging"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// serveHTTP starts the small operator HTTP listener next to the consumers, with the
+// consumer metrics on /metrics and the probes: /readyz fails while Postgres or every Kafka broker is unreachable, and reports
+// when each consumer last received a message so stalled consumers can be alerted on.
+// /internal/activity answers the seta-service's asset activity feeds and /internal/audit/stats
+// its audit aggregates.
+func serveHTTP(addr string, info buildinfo.Info, probes *probes, log logging.Logger) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", health.LiveHandler())
+	mux.HandleFunc("/readyz", health.ReadyHandler(probes.checks(), probes.details))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/internal/info", requireInternalAPIKey(log, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(log, w, http.StatusOK, info)
+	}))
+	mux.HandleFunc("/internal/activity", requireInternalAPIKey(log, activityHandler(probes, log)))
+	mux.HandleFunc("/internal/audit/stats", requireInternalAPIKey(log, statsHandler(probes, log)))
+
+	log.Info("HTTP listener started", logging.Fields{"addr": addr})
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Error("HTTP listener stopped", logging.Err(err))
+	}
+}
+
+// requireInternalAPIKey mirrors the seta-service InternalAPIKey middleware.
+func requireInternalAPIKey(log logging.Logger, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := os.Getenv("INTERNAL_API_KEY")
+		if expected == "" {
+			writeJSON(log, w, http.StatusServiceUnavailable, map[string]string{"error": "Internal API is not configured"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Internal-API-Key")), []byte(expected)) != 1 {
+			writeJSON(log, w, http.StatusUnauthorized, map[string]string{"error": "Invalid internal API key"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// probes holds what /readyz reports on. The database is set once connected.
+type probes struct {
+	brokers []string
+	db      atomic.Pointer[gorm.DB]
+
+	mu       sync.Mutex
+	consumed map[string]time.Time
+}
+
+// topicActivity is when a consumer last received a message; nil until the first one.
+type topicActivity struct {
+	LastMessageAt           *time.Time `json:"lastMessageAt"`
+	SecondsSinceLastMessage *float64   `json:"secondsSinceLastMessage"`
+}
+
+func newProbes(brokers []string, topics ...string) *probes {
+	p := &probes{brokers: brokers, consumed: make(map[string]time.Time, len(topics))}
+	for _, topic := range topics {
+		p.consumed[topic] = time.Time{}
+	}
+	return p
+}
+
+// consumedFrom records that the consumer of topic just received a message.
+func (p *probes) consumedFrom(topic string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.consumed[topic] = time.Now()
+}
+
+func (p *probes) checks() []health.Check {
+	return []health.Check{
+		{Name: "postgres", Critical: true, Run: func(ctx context.Context) error {
+			db := p.db.Load()
+			if db == nil {
+				return errors.New("not connected yet")
+			}
+			return database.Ping(ctx, db)
+		}},
+		health.TCP("kafka", true, p.brokers),
+	}
+}
+
+func (p *probes) details() any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	consumers := make(map[string]topicActivity, len(p.consumed))
+	for topic, at := range p.consumed {
+		if at.IsZero() {
+			consumers[topic] = topicActivity{}
+			continue
+		}
+		since := time.Since(at).Seconds()
+		consumers[topic] = topicActivity{LastMessageAt: &at, SecondsSinceLastMessage: &since}
+	}
+	return map[string]any{"consumers": consumers}
+}
+
+func writeJSON(log logging.Logger, w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error("Failed to encode HTTP response", logging.Err(err))
+	}
+}
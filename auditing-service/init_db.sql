@@ -0,0 +1,30 @@
+-- =================================================================
+-- Table: audit_logs
+-- One row per consumed Kafka event. (topic, kafka_partition,
+-- kafka_offset) is unique so replayed messages are skipped.
+-- =================================================================
+CREATE TABLE audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    kafka_partition INTEGER NOT NULL,
+    kafka_offset BIGINT NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    action_by TEXT,
+    target_user_id TEXT,
+    asset_type VARCHAR(20),
+    asset_id TEXT,
+    team_id TEXT,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    payload JSONB,
+    raw_payload TEXT,
+    parse_error BOOLEAN NOT NULL DEFAULT FALSE,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (topic, kafka_partition, kafka_offset)
+);
+
+CREATE INDEX idx_audit_logs_team_occurred ON audit_logs(team_id, occurred_at);
+CREATE INDEX idx_audit_logs_actor_occurred ON audit_logs(action_by, occurred_at);
+CREATE INDEX idx_audit_logs_asset_occurred ON audit_logs(asset_id, occurred_at);
+-- For the stats, which count over a range of event times, by type or overall.
+CREATE INDEX idx_audit_logs_occurred ON audit_logs(occurred_at);
+CREATE INDEX idx_audit_logs_event_type_occurred ON audit_logs(event_type, occurred_at);
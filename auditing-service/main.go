@@ -2,15 +2,32 @@ package main
 
 import (
 	"context"
-	"log"
 	"os"
+	"seta-pkg/buildinfo"
+	"seta-pkg/database"
+	"seta-pkg/logging"
+	"seta-pkg/tracing"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
 )
 
+const (
+	teamActivityTopic = "team.activity"
+	assetChangesTopic = "asset.changes"
+	consumerGroupID   = "audit-group"
+)
+
+// dlqSuffix names the dead-letter topic of each consumed topic, AUDIT_DLQ_SUFFIX
+// (default ".dlq") appended to it.
+var dlqSuffix = ".dlq"
+
 func main() {
+	log := logging.New("auditing-service")
+
 	// Default to "kafka:29092" if not set, for Docker networking
 	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
 	if kafkaBrokers == "" {
@@ -18,7 +35,54 @@ func main() {
 	}
 	brokers := strings.Split(kafkaBrokers, ",")
 
-	log.Println("Starting Kafka consumer...")
+	if v := os.Getenv("AUDIT_DLQ_SUFFIX"); v != "" {
+		dlqSuffix = v
+	}
+
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Init(context.Background(), "auditing-service")
+	if err != nil {
+		log.Error("Could not set up tracing", logging.Err(err))
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("Failed to flush traces", logging.Err(err))
+		}
+	}()
+
+	httpAddr := os.Getenv("HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = ":8081"
+	}
+
+	info := buildinfo.New("auditing-service", map[string]string{
+		"KAFKA_BROKERS":                kafkaBrokers,
+		"HTTP_ADDR":                    httpAddr,
+		"INTERNAL_API_KEY":             os.Getenv("INTERNAL_API_KEY"),
+		"DATABASE_URL":                 os.Getenv("DATABASE_URL"),
+		"AUDIT_BATCH_SIZE":             os.Getenv("AUDIT_BATCH_SIZE"),
+		"AUDIT_MAX_CLOCK_SKEW_SECONDS": os.Getenv("AUDIT_MAX_CLOCK_SKEW_SECONDS"),
+		"AUDIT_MAX_ATTEMPTS":           os.Getenv("AUDIT_MAX_ATTEMPTS"),
+		"AUDIT_DLQ_SUFFIX":             dlqSuffix,
+	}, buildinfo.KafkaInfo{
+		Brokers:        brokers,
+		Topics:         []string{teamActivityTopic, assetChangesTopic, teamActivityTopic + dlqSuffix, assetChangesTopic + dlqSuffix},
+		ConsumerGroups: []string{consumerGroupID},
+	})
+	probes := newProbes(brokers, teamActivityTopic, assetChangesTopic)
+	go serveHTTP(httpAddr, info, probes, log)
+
+	db, err := database.Connect(log)
+	if err != nil {
+		log.Error("Could not connect to database", logging.Err(err))
+		os.Exit(1)
+	}
+	probes.db.Store(db)
+
+	log.Info("Starting Kafka consumer...")
 
 	// Use a WaitGroup to run multiple consumers concurrently
 	var wg sync.WaitGroup
@@ -27,20 +91,22 @@ func main() {
 	// Consumer for team.activity
 	go func() {
 		defer wg.Done()
-		consume(brokers, "team.activity", "audit-group")
+		consume(log, db, probes, brokers, teamActivityTopic, consumerGroupID)
 	}()
 
 	// Consumer for asset.changes
 	go func() {
 		defer wg.Done()
-		consume(brokers, "asset.changes", "audit-group")
+		consume(log, db, probes, brokers, assetChangesTopic, consumerGroupID)
 	}()
 
 	// Wait for all consumers to finish (which they won't, they run forever)
 	wg.Wait()
 }
 
-func consume(brokers []string, topic, groupID string) {
+func consume(log logging.Logger, db *gorm.DB, probes *probes, brokers []string, topic, groupID string) {
+	log = log.With(logging.Fields{"topic": topic})
+
 	r := kafka.NewReader(kafka.ReaderConfig{
 		Brokers: brokers,
 		GroupID: groupID, // All instances of this service will join the same consumer group
@@ -49,21 +115,64 @@ func consume(brokers []string, topic, groupID string) {
 		MaxBytes: 10e6, // 10MB
 	})
 
-	log.Printf("Consumer for topic '%s' started", topic)
+	dlq := &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic + dlqSuffix,
+		Balancer:               &kafka.LeastBytes{},
+		AllowAutoTopicCreation: true,
+	}
+
+	store := newAuditStore(db, log, r.CommitMessages, dlq.WriteMessages)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		store.Run(stop)
+		close(stopped)
+	}()
+
+	log.Info("Consumer started")
 
 	for {
-		// The `ReadMessage` method blocks until a new message is available
-		m, err := r.ReadMessage(context.Background())
+		// FetchMessage blocks until a new message is available; the store commits it once stored.
+		m, err := r.FetchMessage(context.Background())
 		if err != nil {
-			log.Printf("Error while reading message from topic %s: %v", topic, err)
+			log.Error("Error while reading message", logging.Err(err))
 			break // Exit on error
 		}
+		probes.consumedFrom(topic)
+		messagesConsumed.WithLabelValues(topic).Inc()
+
+		// Each message is handled in a trace of its own, linked to the one that produced it.
+		_, span := tracing.StartProcess(context.Background(), m, groupID)
+		start := time.Now()
+		store.Add(m)
+		messageProcessing.WithLabelValues(topic).Observe(time.Since(start).Seconds())
 
-		// For our audit log, we just print the event
-		log.Printf("[AUDIT LOG - TOPIC: %s] Key: %s, Value: %s\n", topic, string(m.Key), string(m.Value))
+		row, _ := newAuditLog(m.Topic, m.Value, m.Time)
+		if row.ParseError {
+			unmarshalFailures.WithLabelValues(topic).Inc()
+		}
+		log.Info("Audit event", logging.Fields{
+			"key":                  string(m.Key),
+			logging.FieldEventType: row.EventType,
+			logging.FieldRequestID: requestID(m),
+			logging.FieldTeamID:    row.TeamID,
+			logging.FieldAssetID:   row.AssetID,
+			logging.FieldUserID:    row.ActionBy,
+			"parse_error":          row.ParseError,
+			"payload":              string(m.Value),
+		})
+		span.End()
 	}
 
+	close(stop)
+	<-stopped
+
+	if err := dlq.Close(); err != nil {
+		log.Error("Failed to close dead-letter writer", logging.Err(err))
+	}
 	if err := r.Close(); err != nil {
-		log.Fatalf("Failed to close reader for topic %s: %v", topic, err)
+		log.Error("Failed to close reader", logging.Err(err))
+		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
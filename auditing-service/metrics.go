@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Consumer metrics, served on /metrics of the HTTP listener. All are labeled by topic.
+var (
+	messagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_messages_consumed_total",
+		Help: "Kafka messages read by the audit consumers.",
+	}, []string{"topic"})
+	messageProcessing = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "audit_message_processing_seconds",
+		Help:    "Time from reading a message to handing it to the store, including waiting for a full batch to be stored.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+	unmarshalFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_unmarshal_failures_total",
+		Help: "Messages whose payload was not valid JSON, stored raw with parse_error set.",
+	}, []string{"topic"})
+	storeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_store_errors_total",
+		Help: "Failed inserts of a batch of audit rows.",
+	}, []string{"topic"})
+	deadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_dead_lettered_total",
+		Help: "Messages the database rejected that were sent to the dead-letter topic.",
+	}, []string{"topic"})
+	invalidEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_invalid_events_total",
+		Help: "Events of an unknown schema version or missing required fields, sent to the dead-letter topic.",
+	}, []string{"topic"})
+	clockSkewed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_clock_skewed_total",
+		Help: "Events stamped further ahead of the consumer clock than AUDIT_MAX_CLOCK_SKEW_SECONDS.",
+	}, []string{"topic"})
+)
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"seta-pkg/logging"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxStatsBuckets bounds the buckets of one stats answer; the rest are cut off and the
+// answer says so.
+const maxStatsBuckets = 1000
+
+// statsGroupings maps each accepted groupBy to the column or expression it counts by.
+// Day buckets are the calendar days of the requested time zone, bound to the placeholder.
+var statsGroupings = map[string]string{
+	"eventType": "event_type",
+	"actionBy":  "COALESCE(action_by, '')",
+	"day":       "to_char(occurred_at AT TIME ZONE ?, 'YYYY-MM-DD')",
+}
+
+// statsFilter is what an audit stats query counts. Zero From and To leave the range open.
+type statsFilter struct {
+	GroupBy string
+	From    time.Time
+	To      time.Time
+	TeamID  string
+	TZ      string
+}
+
+// statsBucket is the number of events recorded for one key.
+type statsBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// auditStats counts the recorded events matching filter per bucket with one GROUP BY.
+// Days come in order; other groupings come largest first. It reports whether buckets
+// past maxStatsBuckets were cut off. Payloads that could not be parsed and events of
+// the smoke test are not counted.
+func auditStats(ctx context.Context, db *gorm.DB, filter statsFilter) ([]statsBucket, bool, error) {
+	key := statsGroupings[filter.GroupBy]
+	order := "count DESC, key"
+	var keyArgs []any
+	if filter.GroupBy == "day" {
+		order = "key"
+		keyArgs = append(keyArgs, filter.TZ)
+	}
+
+	// Grouping by the output name keeps the bound time zone out of the GROUP BY.
+	tx := db.WithContext(ctx).Model(&auditLog{}).
+		Select(key+" AS key, COUNT(*) AS count", keyArgs...).
+		Where("NOT parse_error AND COALESCE((payload->>'synthetic')::boolean, false) = false")
+	if !filter.From.IsZero() {
+		tx = tx.Where("occurred_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where("occurred_at < ?", filter.To)
+	}
+	if filter.TeamID != "" {
+		tx = tx.Where("team_id = ?", filter.TeamID)
+	}
+
+	// One more than the cap tells whether buckets were cut off.
+	buckets := make([]statsBucket, 0)
+	if err := tx.Group("key").Order(order).Limit(maxStatsBuckets + 1).Scan(&buckets).Error; err != nil {
+		return nil, false, err
+	}
+	truncated := len(buckets) > maxStatsBuckets
+	if truncated {
+		buckets = buckets[:maxStatsBuckets]
+	}
+	return buckets, truncated, nil
+}
+
+// statsHandler serves GET /internal/audit/stats?groupBy=eventType|actionBy|day, the
+// number of recorded events per event type, actor or day. ?from and ?to (RFC 3339)
+// bound the event times, to excluded; ?teamId counts one team's events only; ?tz (an
+// IANA zone, default UTC) picks the days events fall on. At most 1000 buckets are
+// returned, with truncated set when there were more.
+func statsHandler(probes *probes, log logging.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := statsFilter{GroupBy: query.Get("groupBy"), TeamID: query.Get("teamId"), TZ: "UTC"}
+		if _, ok := statsGroupings[filter.GroupBy]; !ok {
+			writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": "groupBy must be eventType, actionBy or day"})
+			return
+		}
+		bounds := []struct {
+			name string
+			into *time.Time
+		}{{"from", &filter.From}, {"to", &filter.To}}
+		for _, bound := range bounds {
+			raw := query.Get(bound.name)
+			if raw == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": bound.name + " must be an RFC 3339 time"})
+				return
+			}
+			*bound.into = t
+		}
+		if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+			writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": "from must be before to"})
+			return
+		}
+		if raw := query.Get("tz"); raw != "" {
+			// "Local" is this process's zone, which Postgres knows nothing of.
+			if _, err := time.LoadLocation(raw); err != nil || raw == "Local" {
+				writeJSON(log, w, http.StatusBadRequest, map[string]string{"error": "tz must be an IANA time zone such as Europe/Berlin"})
+				return
+			}
+			filter.TZ = raw
+		}
+
+		db := probes.db.Load()
+		if db == nil {
+			writeJSON(log, w, http.StatusServiceUnavailable, map[string]string{"error": "Database is not connected yet"})
+			return
+		}
+		buckets, truncated, err := auditStats(r.Context(), db, filter)
+		if err != nil {
+			log.Error("Failed to compute audit stats", logging.Fields{logging.FieldError: err, "group_by": filter.GroupBy})
+			writeJSON(log, w, http.StatusInternalServerError, map[string]string{"error": "Failed to compute audit stats"})
+			return
+		}
+		writeJSON(log, w, http.StatusOK, map[string]any{
+			"groupBy":   filter.GroupBy,
+			"tz":        filter.TZ,
+			"buckets":   buckets,
+			"truncated": truncated,
+		})
+	}
+}
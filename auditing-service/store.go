@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"seta-pkg/events"
+	"seta-pkg/logging"
+	"seta-pkg/requestid"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// auditLog is one consumed event. Payloads that are not valid JSON are kept in
+// RawPayload with ParseError set instead of being dropped.
+type auditLog struct {
+	ID             int64  `gorm:"primaryKey;autoIncrement"`
+	Topic          string `gorm:"not null"`
+	KafkaPartition int    `gorm:"not null"`
+	KafkaOffset    int64  `gorm:"not null"`
+	EventType      string `gorm:"not null"`
+	ActionBy       string // actor
+	TargetUserID   string
+	AssetType      string
+	AssetID        string
+	TeamID         string
+	OccurredAt     time.Time `gorm:"not null"`
+	Payload        *string   `gorm:"type:jsonb"`
+	RawPayload     *string
+	ParseError     bool      `gorm:"not null;default:false"`
+	RecordedAt     time.Time `gorm:"autoCreateTime"`
+}
+
+func (auditLog) TableName() string {
+	return "audit_logs"
+}
+
+// newAuditLog maps a Kafka message to a row. received is used when the payload carries
+// no timestamp of its own. It also returns why the event fails validation, if it does;
+// such an event is dead-lettered instead of stored.
+func newAuditLog(topic string, value []byte, received time.Time) (auditLog, error) {
+	row := auditLog{Topic: topic, OccurredAt: received}
+
+	var event events.Payload
+	if err := json.Unmarshal(value, &event); err != nil {
+		raw := string(value)
+		row.RawPayload = &raw
+		row.ParseError = true
+		return row, nil
+	}
+
+	payload := string(value)
+	row.Payload = &payload
+	row.EventType = event.EventType
+	row.ActionBy = event.ActionBy
+	row.TargetUserID = event.TargetUserID
+	row.AssetType = event.AssetType
+	row.AssetID = event.AssetID
+	row.TeamID = event.TeamID
+	if events.TimestampKnown(event.Timestamp) {
+		row.OccurredAt = event.Timestamp
+	}
+	return row, event.Validate()
+}
+
+// auditStore batches one topic's rows and inserts them every AUDIT_BATCH_SIZE rows
+// (default 100) or every AUDIT_FLUSH_INTERVAL_MS (default 1000), whichever comes first.
+// Offsets are committed only once their rows are stored, and the unique
+// (topic, partition, offset) index makes a replay after a crash a no-op.
+//
+// A batch that fails AUDIT_MAX_ATTEMPTS times (default 5) while the database is reachable
+// is stored row by row, and rows it still rejects are sent to the dead-letter topic with
+// the error in their headers, so one poisonous message cannot wedge the consumer. Events
+// of an unknown schema version or missing required fields go there directly, to be
+// replayed once this service reads them.
+//
+// Events stamped more than AUDIT_MAX_CLOCK_SKEW_SECONDS (default 5) ahead of this
+// service's clock are logged and counted, but stored with their timestamp as sent.
+type auditStore struct {
+	db          *gorm.DB
+	log         logging.Logger
+	commit      func(context.Context, ...kafka.Message) error
+	deadLetter  func(context.Context, ...kafka.Message) error
+	batchSize   int
+	maxAttempts int
+	interval    time.Duration
+	maxSkew     time.Duration
+	skewed      atomic.Int64
+	dlqd        atomic.Int64
+
+	flushMu  sync.Mutex // one flush at a time
+	failures int        // consecutive failed flushes, guarded by flushMu
+	mu       sync.Mutex // guards pending
+	pending  []pendingLog
+}
+
+type pendingLog struct {
+	row     auditLog
+	msg     kafka.Message
+	invalid error // why the event failed validation
+}
+
+func newAuditStore(db *gorm.DB, log logging.Logger, commit, deadLetter func(context.Context, ...kafka.Message) error) *auditStore {
+	batchSize := 100
+	if v, _ := strconv.Atoi(os.Getenv("AUDIT_BATCH_SIZE")); v > 0 {
+		batchSize = v
+	}
+	interval := time.Second
+	if v, _ := strconv.Atoi(os.Getenv("AUDIT_FLUSH_INTERVAL_MS")); v > 0 {
+		interval = time.Duration(v) * time.Millisecond
+	}
+	maxAttempts := 5
+	if v, _ := strconv.Atoi(os.Getenv("AUDIT_MAX_ATTEMPTS")); v > 0 {
+		maxAttempts = v
+	}
+	maxSkew := 5 * time.Second
+	if v, _ := strconv.Atoi(os.Getenv("AUDIT_MAX_CLOCK_SKEW_SECONDS")); v > 0 {
+		maxSkew = time.Duration(v) * time.Second
+	}
+	return &auditStore{
+		db:          db,
+		log:         log,
+		commit:      commit,
+		deadLetter:  deadLetter,
+		batchSize:   batchSize,
+		maxAttempts: maxAttempts,
+		interval:    interval,
+		maxSkew:     maxSkew,
+	}
+}
+
+// Add queues the row for msg. Once the batch is full it flushes, and while the database
+// is failing it keeps retrying instead of returning, so the consumer stops reading.
+func (s *auditStore) Add(msg kafka.Message) {
+	row, invalid := newAuditLog(msg.Topic, msg.Value, msg.Time)
+	row.KafkaPartition = msg.Partition
+	row.KafkaOffset = msg.Offset
+	s.checkClock(row)
+
+	s.mu.Lock()
+	s.pending = append(s.pending, pendingLog{row: row, msg: msg, invalid: invalid})
+	full := len(s.pending) >= s.batchSize
+	s.mu.Unlock()
+
+	for full && !s.Flush() {
+		time.Sleep(s.interval)
+	}
+}
+
+// checkClock flags rows whose producer clock runs ahead of ours. Rows without a
+// timestamp of their own use the receive time and are never flagged.
+func (s *auditStore) checkClock(row auditLog) {
+	now := events.Now()
+	if !events.TooFarAhead(row.OccurredAt, now, s.maxSkew) {
+		return
+	}
+	s.log.Warn("Event timestamp is ahead of the consumer clock", logging.Fields{
+		logging.FieldEventType: row.EventType,
+		"kafka_offset":         row.KafkaOffset,
+		"ahead_by":             events.AheadBy(row.OccurredAt, now).String(),
+		"skewed_total":         s.skewed.Add(1),
+	})
+	clockSkewed.WithLabelValues(row.Topic).Inc()
+}
+
+// Run flushes on the interval until stop is closed, then flushes what is left.
+func (s *auditStore) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-stop:
+			s.Flush()
+			return
+		}
+	}
+}
+
+// Flush inserts every queued row and commits their offsets. It reports false when the
+// rows could be neither stored nor dead-lettered; they stay queued for the next flush.
+func (s *auditStore) Flush() bool {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return true
+	}
+
+	valid := make([]pendingLog, 0, len(batch))
+	rows := make([]auditLog, 0, len(batch))
+	msgs := make([]kafka.Message, len(batch))
+	for i, p := range batch {
+		if p.invalid == nil {
+			valid = append(valid, p)
+			rows = append(rows, p.row)
+		}
+		msgs[i] = p.msg
+	}
+
+	if len(rows) > 0 {
+		if err := s.insert(rows...); err != nil {
+			storeErrors.WithLabelValues(rows[0].Topic).Inc()
+			s.failures++
+			// While the database itself is down every message would fail, so only a batch that
+			// keeps failing against a reachable database is searched for poisonous rows.
+			if s.failures < s.maxAttempts || !s.databaseReachable() || !s.storeEachOrDeadLetter(valid, err) {
+				s.log.Error("Failed to store audit events, will retry", logging.Fields{
+					logging.FieldError: err,
+					"count":            len(batch),
+					"attempts":         s.failures,
+				})
+				s.requeue(batch)
+				return false
+			}
+		}
+		s.failures = 0
+	}
+
+	// Stored rows are skipped by the conflict clause if the batch has to be retried.
+	if !s.deadLetterInvalid(batch) {
+		s.requeue(batch)
+		return false
+	}
+
+	// The rows are stored; a failed commit only means they are read and skipped again.
+	if err := s.commit(context.Background(), msgs...); err != nil {
+		s.log.Warn("Failed to commit audit offsets", logging.Err(err))
+	}
+	s.log.Debug("Stored audit events", logging.Fields{"count": len(batch)})
+	return true
+}
+
+// requeue puts a batch that could not be flushed back in front of the pending rows.
+func (s *auditStore) requeue(batch []pendingLog) {
+	s.mu.Lock()
+	s.pending = append(batch, s.pending...)
+	s.mu.Unlock()
+}
+
+func (s *auditStore) insert(rows ...auditLog) error {
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, s.batchSize).Error
+}
+
+func (s *auditStore) databaseReachable() bool {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx) == nil
+}
+
+// storeEachOrDeadLetter inserts the batch one row at a time and dead-letters the rows the
+// database rejects. It reports false if a rejected row could not be dead-lettered; rows
+// already stored are then skipped by the conflict clause on the next attempt.
+func (s *auditStore) storeEachOrDeadLetter(batch []pendingLog, batchErr error) bool {
+	for _, p := range batch {
+		err := s.insert(p.row)
+		if err == nil {
+			continue
+		}
+
+		dead := deadLetterMessage(p.msg, err,
+			kafka.Header{Key: "dlq-batch-error", Value: []byte(batchErr.Error())},
+			kafka.Header{Key: "dlq-attempts", Value: []byte(strconv.Itoa(s.failures))},
+		)
+		if dlqErr := s.deadLetter(context.Background(), dead); dlqErr != nil {
+			s.log.Error("Failed to dead-letter audit event", logging.Fields{
+				logging.FieldError: dlqErr,
+				"kafka_offset":     p.msg.Offset,
+			})
+			return false
+		}
+		s.log.Warn("Dead-lettered audit event", logging.Fields{
+			logging.FieldError:     err,
+			logging.FieldEventType: p.row.EventType,
+			logging.FieldRequestID: requestID(p.msg),
+			"kafka_offset":         p.msg.Offset,
+			"dlq_total":            s.dlqd.Add(1),
+		})
+		deadLettered.WithLabelValues(p.row.Topic).Inc()
+	}
+	return true
+}
+
+// deadLetterInvalid sends the events of batch that failed validation to the dead-letter
+// topic and reports false if one of them could not be sent.
+func (s *auditStore) deadLetterInvalid(batch []pendingLog) bool {
+	for _, p := range batch {
+		if p.invalid == nil {
+			continue
+		}
+		if err := s.deadLetter(context.Background(), deadLetterMessage(p.msg, p.invalid)); err != nil {
+			s.log.Error("Failed to dead-letter invalid audit event", logging.Fields{
+				logging.FieldError: err,
+				"kafka_offset":     p.msg.Offset,
+			})
+			return false
+		}
+		s.log.Warn("Dead-lettered invalid audit event", logging.Fields{
+			logging.FieldError:     p.invalid,
+			logging.FieldEventType: p.row.EventType,
+			logging.FieldRequestID: requestID(p.msg),
+			"kafka_offset":         p.msg.Offset,
+			"dlq_total":            s.dlqd.Add(1),
+		})
+		invalidEvents.WithLabelValues(p.row.Topic).Inc()
+	}
+	return true
+}
+
+// requestID returns the ID of the request that caused msg, or "" when the producer did
+// not send one.
+func requestID(msg kafka.Message) string {
+	for _, header := range msg.Headers {
+		if header.Key == requestid.Header {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// deadLetterMessage copies msg for the dead-letter topic, with the error, where it came
+// from and when in its headers.
+func deadLetterMessage(msg kafka.Message, cause error, extra ...kafka.Header) kafka.Message {
+	headers := append(msg.Headers,
+		kafka.Header{Key: "dlq-error", Value: []byte(cause.Error())},
+		kafka.Header{Key: "dlq-topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: "dlq-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: "dlq-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: "dlq-failed-at", Value: []byte(events.Now().Format(time.RFC3339))},
+	)
+	return kafka.Message{Key: msg.Key, Value: msg.Value, Headers: append(headers, extra...)}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"seta-pkg/events"
+	"seta-pkg/logging"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/segmentio/kafka-go"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB returns a connection to a new schema of the database named by
+// TEST_DATABASE_URL, set up with init_db.sql and dropped when the test ends. The test is
+// skipped when TEST_DATABASE_URL is unset.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	base := os.Getenv("TEST_DATABASE_URL")
+	if base == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgx.Connect(ctx, base)
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close(ctx)
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+		admin.Close(ctx)
+	})
+
+	dsn := base + " search_path=" + schema
+	if strings.HasPrefix(base, "postgres://") || strings.HasPrefix(base, "postgresql://") {
+		parsed, err := url.Parse(base)
+		if err != nil {
+			t.Fatalf("TEST_DATABASE_URL: %v", err)
+		}
+		query := parsed.Query()
+		query.Set("search_path", schema)
+		parsed.RawQuery = query.Encode()
+		dsn = parsed.String()
+	}
+
+	script, err := os.ReadFile("init_db.sql")
+	if err != nil {
+		t.Fatalf("read init_db.sql: %v", err)
+	}
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect to test schema: %v", err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, string(script)); err != nil {
+		t.Fatalf("run init_db.sql: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+// fakeKafka records the offsets committed and the messages dead-lettered by a store.
+type fakeKafka struct {
+	mu        sync.Mutex
+	committed []kafka.Message
+	dead      []kafka.Message
+	deadErr   error // returned by deadLetter while set
+}
+
+func (k *fakeKafka) commit(_ context.Context, msgs ...kafka.Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.committed = append(k.committed, msgs...)
+	return nil
+}
+
+func (k *fakeKafka) deadLetter(_ context.Context, msgs ...kafka.Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.deadErr != nil {
+		return k.deadErr
+	}
+	k.dead = append(k.dead, msgs...)
+	return nil
+}
+
+func (k *fakeKafka) offsets() (committed, dead []int64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, msg := range k.committed {
+		committed = append(committed, msg.Offset)
+	}
+	for _, msg := range k.dead {
+		dead = append(dead, dlqOffset(msg))
+	}
+	return committed, dead
+}
+
+// dlqOffset returns the offset a dead-lettered message was read at, -1 if it has none.
+func dlqOffset(msg kafka.Message) int64 {
+	offset, err := strconv.ParseInt(header(msg, "dlq-offset"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return offset
+}
+
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func newTestAuditStore(t *testing.T, db *gorm.DB, batchSize, maxAttempts string) (*auditStore, *fakeKafka) {
+	t.Helper()
+	t.Setenv("AUDIT_BATCH_SIZE", batchSize)
+	t.Setenv("AUDIT_MAX_ATTEMPTS", maxAttempts)
+	t.Setenv("AUDIT_FLUSH_INTERVAL_MS", "10")
+	k := &fakeKafka{}
+	return newAuditStore(db, logging.Nop(), k.commit, k.deadLetter), k
+}
+
+// message returns the Kafka message of an asset event read at offset.
+func message(t *testing.T, offset int64, event events.Payload) kafka.Message {
+	t.Helper()
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("encode event: %v", err)
+	}
+	return kafka.Message{Topic: "asset.changes", Offset: offset, Value: value, Time: time.Now()}
+}
+
+func noteEvent(assetType string) events.Payload {
+	return events.NewAssetEvent("NOTE_UPDATED", assetType, "d5d5d5d5-d5d5-d5d5-d5d5-d5d5d5d5d5d5", "a1a1a1a1-a1a1-a1a1-a1a1-a1a1a1a1a1a1")
+}
+
+func countLogs(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&auditLog{}).Count(&count).Error; err != nil {
+		t.Fatalf("count audit logs: %v", err)
+	}
+	return count
+}
+
+func TestAuditStoreFlushesFullBatches(t *testing.T) {
+	db := openTestDB(t)
+	store, k := newTestAuditStore(t, db, "3", "5")
+
+	store.Add(message(t, 0, noteEvent("note")))
+	store.Add(message(t, 1, noteEvent("note")))
+	if committed, _ := k.offsets(); len(committed) != 0 || countLogs(t, db) != 0 {
+		t.Fatalf("a batch of 2 was flushed: committed %v", committed)
+	}
+
+	store.Add(message(t, 2, noteEvent("note")))
+	if committed, _ := k.offsets(); len(committed) != 3 {
+		t.Fatalf("committed %v, want the full batch", committed)
+	}
+	if got := countLogs(t, db); got != 3 {
+		t.Fatalf("stored %d rows, want 3", got)
+	}
+
+	// The interval flush stores what the batch size has not reached yet.
+	store.Add(message(t, 3, noteEvent("note")))
+	if !store.Flush() {
+		t.Fatal("Flush failed")
+	}
+	if committed, _ := k.offsets(); len(committed) != 4 || committed[3] != 3 {
+		t.Fatalf("committed %v, want offset 3 last", committed)
+	}
+	if !store.Flush() {
+		t.Fatal("Flush of an empty batch failed")
+	}
+	if committed, _ := k.offsets(); len(committed) != 4 {
+		t.Errorf("an empty flush committed offsets: %v", committed)
+	}
+}
+
+// Messages read again after a crash, before their offsets were committed, are stored
+// once and committed again.
+func TestAuditStoreReplayIsNoOp(t *testing.T) {
+	db := openTestDB(t)
+	store, k := newTestAuditStore(t, db, "10", "5")
+
+	for round := 0; round < 2; round++ {
+		store.Add(message(t, 0, noteEvent("note")))
+		store.Add(message(t, 1, noteEvent("note")))
+		if !store.Flush() {
+			t.Fatalf("round %d: Flush failed", round)
+		}
+	}
+	if got := countLogs(t, db); got != 2 {
+		t.Errorf("stored %d rows, want 2", got)
+	}
+	committed, dead := k.offsets()
+	if len(committed) != 4 {
+		t.Errorf("committed %v, want both rounds", committed)
+	}
+	if len(dead) != 0 {
+		t.Errorf("dead-lettered %v, want nothing", dead)
+	}
+}
+
+// A batch the database keeps rejecting is stored row by row once it has failed
+// AUDIT_MAX_ATTEMPTS times, and only the row it still rejects is dead-lettered.
+func TestAuditStoreDeadLettersPoisonousRow(t *testing.T) {
+	db := openTestDB(t)
+	store, k := newTestAuditStore(t, db, "10", "2")
+
+	store.Add(message(t, 0, noteEvent("note")))
+	store.Add(message(t, 1, noteEvent(strings.Repeat("x", 21)))) // longer than asset_type allows
+	store.Add(message(t, 2, noteEvent("note")))
+
+	if store.Flush() {
+		t.Fatal("first Flush succeeded, want the batch to fail")
+	}
+	if committed, dead := k.offsets(); len(committed) != 0 || len(dead) != 0 {
+		t.Fatalf("after one attempt: committed %v, dead-lettered %v, want nothing", committed, dead)
+	}
+
+	if !store.Flush() {
+		t.Fatal("second Flush failed, want the batch stored row by row")
+	}
+	if got := countLogs(t, db); got != 2 {
+		t.Errorf("stored %d rows, want the 2 good ones", got)
+	}
+	committed, dead := k.offsets()
+	if len(committed) != 3 {
+		t.Errorf("committed %v, want the whole batch", committed)
+	}
+	if len(dead) != 1 || dead[0] != 1 {
+		t.Fatalf("dead-lettered %v, want offset 1", dead)
+	}
+	msg := k.dead[0]
+	if header(msg, "dlq-error") == "" || header(msg, "dlq-batch-error") == "" {
+		t.Errorf("dead letter is missing its errors: %v", msg.Headers)
+	}
+	if got := header(msg, "dlq-attempts"); got != "2" {
+		t.Errorf("dlq-attempts = %q, want 2", got)
+	}
+	if got := header(msg, "dlq-topic"); got != "asset.changes" {
+		t.Errorf("dlq-topic = %q, want asset.changes", got)
+	}
+}
+
+// Events that fail validation go to the dead-letter topic without being stored, and
+// are kept queued, offsets uncommitted, while it cannot be reached.
+func TestAuditStoreDeadLettersInvalidEvents(t *testing.T) {
+	db := openTestDB(t)
+	store, k := newTestAuditStore(t, db, "10", "5")
+	k.deadErr = errors.New("dead-letter topic unavailable")
+
+	invalid := noteEvent("note")
+	invalid.ActionBy = ""
+	store.Add(message(t, 0, noteEvent("note")))
+	store.Add(message(t, 1, invalid))
+
+	if store.Flush() {
+		t.Fatal("Flush succeeded while the dead-letter topic was down")
+	}
+	if committed, _ := k.offsets(); len(committed) != 0 {
+		t.Fatalf("committed %v while an event could not be dead-lettered", committed)
+	}
+
+	k.mu.Lock()
+	k.deadErr = nil
+	k.mu.Unlock()
+	if !store.Flush() {
+		t.Fatal("Flush failed")
+	}
+	if got := countLogs(t, db); got != 1 {
+		t.Errorf("stored %d rows, want only the valid event", got)
+	}
+	committed, dead := k.offsets()
+	if len(committed) != 2 {
+		t.Errorf("committed %v, want both events", committed)
+	}
+	if len(dead) != 1 || dead[0] != 1 {
+		t.Errorf("dead-lettered %v, want offset 1", dead)
+	}
+}
+
+// A snapshot too large for Kafka reaches the audit store as its stub, so the stored row
+// keeps the hash the source can be checked against.
+func TestNewAuditLogKeepsTruncatedSnapshotHash(t *testing.T) {
+	event := events.NewAssetEvent("ASSET_RESYNC", "note", "d5d5d5d5-d5d5-d5d5-d5d5-d5d5d5d5d5d5", "ops")
+	event.Snapshot = events.LimitPayload(map[string]string{"body": strings.Repeat("x", events.MaxPayloadBytes())})
+	stub, ok := event.Snapshot.(events.TruncatedPayload)
+	if !ok {
+		t.Fatalf("snapshot = %T, want a truncation stub", event.Snapshot)
+	}
+
+	msg := message(t, 0, event)
+	row, err := newAuditLog(msg.Topic, msg.Value, msg.Time)
+	if err != nil {
+		t.Fatalf("newAuditLog: %v", err)
+	}
+	if row.Payload == nil {
+		t.Fatal("row has no payload")
+	}
+	var stored struct {
+		Snapshot json.RawMessage `json:"snapshot"`
+	}
+	if err := json.Unmarshal([]byte(*row.Payload), &stored); err != nil {
+		t.Fatalf("decode stored payload: %v", err)
+	}
+	var storedStub events.TruncatedPayload
+	if err := json.Unmarshal(stored.Snapshot, &storedStub); err != nil || storedStub != stub {
+		t.Errorf("stored snapshot = %s, want %+v", stored.Snapshot, stub)
+	}
+}
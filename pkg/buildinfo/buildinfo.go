@@ -0,0 +1,85 @@
+package buildinfo
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// These are overridden at build time, e.g.
+//
+//	go build -ldflags "-X seta-pkg/buildinfo.Version=1.2.0 -X seta-pkg/buildinfo.GitSHA=$(git rev-parse HEAD)"
+var (
+	Version   = "dev"
+	GitSHA    = "unknown"
+	BuildTime = "unknown"
+)
+
+const maskedValue = "********"
+
+// sensitiveMarkers are matched against upper-cased config keys; any match masks the value.
+var sensitiveMarkers = []string{"PASSWORD", "SECRET", "KEY", "TOKEN", "CREDENTIAL", "DATABASE_URL", "DSN"}
+
+// KafkaInfo lists the topics and consumer groups a service effectively uses.
+type KafkaInfo struct {
+	Brokers        []string `json:"brokers"`
+	Topics         []string `json:"topics"`
+	ConsumerGroups []string `json:"consumerGroups"`
+}
+
+// Info is the payload served by GET /internal/info in every service.
+type Info struct {
+	Service   string            `json:"service"`
+	Version   string            `json:"version"`
+	GitSHA    string            `json:"gitSha"`
+	BuildTime string            `json:"buildTime"`
+	GoVersion string            `json:"goVersion"`
+	Flags     map[string]string `json:"flags"`
+	Kafka     KafkaInfo         `json:"kafka"`
+}
+
+// New builds the Info payload for a service. Flag values whose key looks sensitive
+// are masked so secrets never leave the process, whatever ends up in the config dump.
+func New(service string, flags map[string]string, kafka KafkaInfo) Info {
+	masked := make(map[string]string, len(flags))
+	for key, value := range flags {
+		masked[key] = maskIfSensitive(key, value)
+	}
+
+	if kafka.Topics == nil {
+		kafka.Topics = []string{}
+	}
+	if kafka.ConsumerGroups == nil {
+		kafka.ConsumerGroups = []string{}
+	}
+	sort.Strings(kafka.Topics)
+	sort.Strings(kafka.ConsumerGroups)
+
+	return Info{
+		Service:   service,
+		Version:   Version,
+		GitSHA:    GitSHA,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		Flags:     masked,
+		Kafka:     kafka,
+	}
+}
+
+// IsSensitive reports whether a config key should never be exposed in clear text.
+func IsSensitive(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range sensitiveMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func maskIfSensitive(key, value string) string {
+	if value == "" || !IsSensitive(key) {
+		return value
+	}
+	return maskedValue
+}
@@ -0,0 +1,58 @@
+package buildinfo
+
+import "testing"
+
+func TestNewMasksSensitiveFlags(t *testing.T) {
+	flags := map[string]string{
+		"DB_PASSWORD":         "hunter2",
+		"JWT_SECRET":          "s3cret",
+		"INTERNAL_API_KEY":    "k3y",
+		"access_token":        "t0ken",
+		"SENTRY_DSN":          "https://abc@sentry.example.com/1",
+		"DATABASE_URL":        "postgres://seta:pw@db:5432/seta",
+		"AWS_CREDENTIALS":     "creds",
+		"SMTP_PASSWORD":       "",
+		"PORT":                "8080",
+		"KAFKA_BROKERS":       "kafka:9092",
+		"AUTH_CACHE_SECONDS":  "60",
+		"NOTE_BODY_THRESHOLD": "4096",
+	}
+	want := map[string]string{
+		"DB_PASSWORD":      maskedValue,
+		"JWT_SECRET":       maskedValue,
+		"INTERNAL_API_KEY": maskedValue,
+		"access_token":     maskedValue,
+		"SENTRY_DSN":       maskedValue,
+		"DATABASE_URL":     maskedValue,
+		"AWS_CREDENTIALS":  maskedValue,
+		// An unset secret is shown as unset.
+		"SMTP_PASSWORD":       "",
+		"PORT":                "8080",
+		"KAFKA_BROKERS":       "kafka:9092",
+		"AUTH_CACHE_SECONDS":  "60",
+		"NOTE_BODY_THRESHOLD": "4096",
+	}
+
+	info := New("seta-service", flags, KafkaInfo{})
+	for key, value := range want {
+		if got := info.Flags[key]; got != value {
+			t.Errorf("Flags[%s] = %q, want %q", key, got, value)
+		}
+	}
+	if len(info.Flags) != len(flags) {
+		t.Errorf("got %d flags, want %d", len(info.Flags), len(flags))
+	}
+	if flags["DB_PASSWORD"] != "hunter2" {
+		t.Error("New changed the flags it was given")
+	}
+}
+
+func TestNewSortsKafkaInfo(t *testing.T) {
+	info := New("seta-service", nil, KafkaInfo{Topics: []string{"team.activity", "asset.changes"}})
+	if got := info.Kafka.Topics; len(got) != 2 || got[0] != "asset.changes" || got[1] != "team.activity" {
+		t.Errorf("Topics = %v, want them sorted", got)
+	}
+	if info.Kafka.ConsumerGroups == nil {
+		t.Error("ConsumerGroups is nil, want an empty list so it is served as []")
+	}
+}
@@ -0,0 +1,50 @@
+// Package database opens the Postgres connection shared by the Go services.
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"seta-pkg/logging"
+	"seta-pkg/tracing"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormtracing "gorm.io/plugin/opentelemetry/tracing"
+)
+
+// Connect connects to the database named by DATABASE_URL and returns a GORM DB instance.
+// Queries run with a context holding a span are traced as its children, without their
+// parameters, which may hold user content.
+func Connect(log logging.Logger) (*gorm.DB, error) {
+	dsn := os.Getenv("DATABASE_URL")
+
+	// close connection when shutdown application
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// To enable sql query execution plan caching - need further testing for verification?
+		PrepareStmt: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := db.Use(gormtracing.NewPlugin(
+		gormtracing.WithTracerProvider(tracing.ChildrenOnly(otel.GetTracerProvider())),
+		gormtracing.WithoutQueryVariables(),
+		gormtracing.WithoutMetrics(),
+	)); err != nil {
+		return nil, fmt.Errorf("failed to set up query tracing: %w", err)
+	}
+
+	log.Info("Database connection successful.")
+	return db, nil
+}
+
+// Ping checks that the connection pool can reach the database, for readiness probes.
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
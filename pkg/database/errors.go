@@ -0,0 +1,10 @@
+package database
+
+import "errors"
+
+// IsForeignKeyViolation reports whether err is a write rejected by a foreign key, such
+// as a row inserted for a parent that was deleted meanwhile.
+func IsForeignKeyViolation(err error) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == "23503"
+}
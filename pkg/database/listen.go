@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"seta-pkg/logging"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Listen opens a connection of its own to the database named by DATABASE_URL and
+// subscribes it to channel. Notifications are read with WaitForNotification; the caller
+// closes the connection. Pooled connections cannot be used, since a LISTEN only lasts as
+// long as the connection that ran it.
+func Listen(ctx context.Context, channel string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+	return conn, nil
+}
+
+// Subscribe hands the payload of every notification on channel to notified until ctx is
+// done, reconnecting retry after the connection is lost. listening is told when
+// notifications start and stop being heard, so that a cache kept in sync by them is only
+// used while none can be missed.
+func Subscribe(ctx context.Context, log logging.Logger, channel string, retry time.Duration, listening func(bool), notified func(payload string)) {
+	for {
+		err := subscribe(ctx, channel, listening, notified)
+		listening(false)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("Notification listener stopped, reconnecting", logging.Fields{logging.FieldError: err, "channel": channel})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+func subscribe(ctx context.Context, channel string, listening func(bool), notified func(payload string)) error {
+	conn, err := Listen(ctx, channel)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	listening(true)
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		notified(notification.Payload)
+	}
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// readRetryDelay is the pause before Read retries a transient failure.
+const readRetryDelay = 50 * time.Millisecond
+
+var readTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// Read runs the queries of a read endpoint in one read-only, repeatable-read transaction,
+// so a response built from several queries comes from a single snapshot and either all
+// of them succeed or the whole read fails. A transient failure is retried once.
+func Read(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	err := db.WithContext(ctx).Transaction(fn, readTxOptions)
+	if err == nil || !IsTransient(err) {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return err
+	case <-time.After(readRetryDelay):
+	}
+	return db.WithContext(ctx).Transaction(fn, readTxOptions)
+}
+
+// IsTransient reports whether err is worth retrying as is: a serialization failure, a
+// deadlock or a dropped connection.
+func IsTransient(err error) bool {
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		code := pgErr.SQLState()
+		// Class 08 is connection exceptions.
+		return code == "40001" || code == "40P01" || strings.HasPrefix(code, "08")
+	}
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
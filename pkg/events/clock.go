@@ -0,0 +1,32 @@
+// Package events holds the helpers shared by the services that produce and consume
+// Kafka events, so every one of them reads event times and limits payload sizes the same way.
+package events
+
+import "time"
+
+// Now is the clock producers stamp events with. Replace it to get deterministic
+// timestamps; nothing else should call time.Now for an event.
+var Now = func() time.Time {
+	return time.Now().UTC()
+}
+
+// TimestampKnown reports whether the producer set ts. Older producers omit the field and
+// it decodes as the zero time; such an event has no time and must never be dropped for it.
+func TimestampKnown(ts time.Time) bool {
+	return !ts.IsZero()
+}
+
+// AheadBy returns how far ts is ahead of now, or 0 when it is not ahead or unknown.
+func AheadBy(ts, now time.Time) time.Duration {
+	if !TimestampKnown(ts) || !ts.After(now) {
+		return 0
+	}
+	return ts.Sub(now)
+}
+
+// TooFarAhead reports whether ts is more than maxSkew ahead of now, which points at a
+// producer whose clock is off rather than an event from the future. An unknown
+// timestamp never is.
+func TooFarAhead(ts, now time.Time, maxSkew time.Duration) bool {
+	return AheadBy(ts, now) > maxSkew
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of Payload that producers write. Bump it when a change
+// would make a consumer misread events, and teach Validate to accept the new version
+// only once every consumer does.
+const SchemaVersion = 1
+
+var (
+	ErrUnknownSchemaVersion = errors.New("unknown event schema version")
+	ErrMissingField         = errors.New("event is missing a required field")
+)
+
+// Payload is the event the seta-service puts on the team and asset topics. Team events
+// have no AssetType; asset events carry the type and ID of a folder or note.
+type Payload struct {
+	// SchemaVersion is the version the producer wrote. Events from before versioning
+	// omit it and decode as 0.
+	SchemaVersion int `json:"schemaVersion"`
+
+	EventType    string    `json:"eventType"`
+	TeamID       string    `json:"teamId,omitempty"`
+	AssetType    string    `json:"assetType,omitempty"`
+	AssetID      string    `json:"assetId,omitempty"`
+	OwnerID      string    `json:"ownerId,omitempty"`
+	ActionBy     string    `json:"actionBy"`
+	TargetUserID string    `json:"targetUserId,omitempty"`
+	ParentID     string    `json:"parentId,omitempty"` // folder of a note event, parent of a subfolder event
+	Timestamp    time.Time `json:"timestamp"`
+
+	// Cacheable is set on note events; consumers must not cache a note for which it is false.
+	Cacheable *bool `json:"cacheable,omitempty"`
+
+	// AssetIDs lists the notes removed by one batch of a FOLDER_NOTES_DELETED event.
+	AssetIDs []string `json:"assetIds,omitempty"`
+
+	// Synthetic marks events caused by the deployment smoke test; consumers should not
+	// act on them or show them to users.
+	Synthetic bool `json:"synthetic,omitempty"`
+
+	// Members and Managers list the users a team starts with on a TEAM_CREATED event, so
+	// consumers can build its membership without waiting for MEMBER_ADDED events, and
+	// the users it had on a TEAM_DELETED event, so they can drop it.
+	Members  []string `json:"members,omitempty"`
+	Managers []string `json:"managers,omitempty"`
+
+	// From and To bound the period covered by an AUDIT_EXPORTED event.
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+
+	// Snapshot and ACL are only set on ASSET_RESYNC events, which carry the
+	// full current state so consumers can overwrite whatever they hold.
+	Snapshot any               `json:"snapshot,omitempty"`
+	ACL      map[string]string `json:"acl,omitempty"` // userId -> "read" | "write"
+}
+
+// NewTeamEvent starts a stamped team event.
+func NewTeamEvent(eventType, teamID, actionBy string) Payload {
+	return Payload{EventType: eventType, TeamID: teamID, ActionBy: actionBy}.Stamped()
+}
+
+// NewAssetEvent starts a stamped asset event.
+func NewAssetEvent(eventType, assetType, assetID, actionBy string) Payload {
+	return Payload{EventType: eventType, AssetType: assetType, AssetID: assetID, ActionBy: actionBy}.Stamped()
+}
+
+// Stamped returns p with the current schema version and time. Producers stamp each event
+// again as they write it, so its time is when it was written rather than built.
+func (p Payload) Stamped() Payload {
+	p.SchemaVersion = SchemaVersion
+	p.Timestamp = Now()
+	return p
+}
+
+// WithTarget sets the user affected by a share, unshare or membership change.
+func (p Payload) WithTarget(userID fmt.Stringer) Payload {
+	p.TargetUserID = userID.String()
+	return p
+}
+
+// Validate reports ErrUnknownSchemaVersion for a version this build cannot read, which
+// consumers should set aside rather than guess at, and ErrMissingField for an event
+// no version allows. Events from before versioning are read as version 1.
+func (p Payload) Validate() error {
+	if p.SchemaVersion != 0 && p.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("%w %d", ErrUnknownSchemaVersion, p.SchemaVersion)
+	}
+
+	missing := func(field string) error {
+		return fmt.Errorf("%w: %s", ErrMissingField, field)
+	}
+	switch {
+	case p.EventType == "":
+		return missing("eventType")
+	case p.ActionBy == "":
+		return missing("actionBy")
+	case p.AssetType == "" && p.TeamID == "":
+		return missing("teamId")
+	case p.AssetType != "" && p.AssetID == "":
+		return missing("assetId")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// defaultMaxPayloadBytes is the largest snapshot an event carries inline.
+const defaultMaxPayloadBytes = 32 << 10
+
+// MaxPayloadBytes returns EVENT_MAX_PAYLOAD_BYTES (default 32KB), read on first use.
+var MaxPayloadBytes = sync.OnceValue(func() int {
+	if v, err := strconv.Atoi(os.Getenv("EVENT_MAX_PAYLOAD_BYTES")); err == nil && v > 0 {
+		return v
+	}
+	return defaultMaxPayloadBytes
+})
+
+// TruncatedPayload stands in for a payload too large to put on Kafka. SHA256 and Size
+// describe the JSON that was left out, so it can still be checked against the source;
+// a consumer that needs the content must fetch it instead of using the event.
+type TruncatedPayload struct {
+	Truncated bool   `json:"truncated"`
+	SHA256    string `json:"sha256"`
+	Size      int    `json:"size"`
+}
+
+// LimitPayload is the size policy every producer applies to snapshots before putting
+// them in an event. A payload of at most MaxPayloadBytes of JSON is returned already
+// encoded; a larger one is replaced by its TruncatedPayload. A payload that cannot be
+// encoded is returned unchanged, for the event encoder to report.
+func LimitPayload(payload any) any {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return payload
+	}
+	if len(encoded) <= MaxPayloadBytes() {
+		return json.RawMessage(encoded)
+	}
+	sum := sha256.Sum256(encoded)
+	return TruncatedPayload{Truncated: true, SHA256: hex.EncodeToString(sum[:]), Size: len(encoded)}
+}
+
+// IsTruncated reports whether a received payload is a TruncatedPayload, in which case
+// the consumer must drop what it holds for the asset rather than apply the event.
+func IsTruncated(raw json.RawMessage) bool {
+	var stub struct {
+		Truncated bool `json:"truncated"`
+	}
+	return json.Unmarshal(raw, &stub) == nil && stub.Truncated
+}
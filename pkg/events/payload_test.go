@@ -0,0 +1,105 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// stringOfSize returns a string that encodes to exactly size bytes of JSON.
+func stringOfSize(size int) string {
+	return strings.Repeat("x", size-2)
+}
+
+func TestLimitPayload(t *testing.T) {
+	limit := MaxPayloadBytes()
+	tests := []struct {
+		name      string
+		payload   string
+		truncated bool
+	}{
+		{"under the limit", stringOfSize(limit - 1), false},
+		{"exactly at the limit", stringOfSize(limit), false},
+		{"over the limit", stringOfSize(limit + 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, _ := json.Marshal(tt.payload)
+			got := LimitPayload(tt.payload)
+
+			if !tt.truncated {
+				raw, ok := got.(json.RawMessage)
+				if !ok || string(raw) != string(encoded) {
+					t.Fatalf("LimitPayload = %T, want the payload encoded", got)
+				}
+				if IsTruncated(raw) {
+					t.Error("IsTruncated of an inline payload = true")
+				}
+				return
+			}
+
+			stub, ok := got.(TruncatedPayload)
+			if !ok {
+				t.Fatalf("LimitPayload = %T, want a TruncatedPayload", got)
+			}
+			sum := sha256.Sum256(encoded)
+			if !stub.Truncated || stub.SHA256 != hex.EncodeToString(sum[:]) || stub.Size != len(encoded) {
+				t.Errorf("stub = %+v, want the hash and size of the %d bytes left out", stub, len(encoded))
+			}
+			raw, _ := json.Marshal(stub)
+			if !IsTruncated(raw) {
+				t.Errorf("IsTruncated(%s) = false", raw)
+			}
+		})
+	}
+}
+
+func TestLimitPayloadUnencodable(t *testing.T) {
+	payload := make(chan int)
+	if got, ok := LimitPayload(payload).(chan int); !ok || got != payload {
+		t.Errorf("LimitPayload = %v, want the payload unchanged", got)
+	}
+}
+
+// A consumer reads the snapshot of a received event raw and drops what it holds for
+// the asset when the snapshot was left out.
+func TestIsTruncatedInReceivedEvent(t *testing.T) {
+	limit := MaxPayloadBytes()
+	tests := []struct {
+		name     string
+		snapshot any
+		want     bool
+	}{
+		{"inline snapshot", map[string]string{"title": "Plan"}, false},
+		{"snapshot with a truncated field of its own", map[string]any{"truncated": false, "body": "short"}, false},
+		{"oversized snapshot", map[string]string{"body": stringOfSize(limit)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := NewAssetEvent("ASSET_RESYNC", "note", "d5d5d5d5-d5d5-d5d5-d5d5-d5d5d5d5d5d5", "ops")
+			event.Snapshot = LimitPayload(tt.snapshot)
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("encode event: %v", err)
+			}
+
+			var received struct {
+				Snapshot json.RawMessage `json:"snapshot"`
+			}
+			if err := json.Unmarshal(encoded, &received); err != nil {
+				t.Fatalf("decode event: %v", err)
+			}
+			if got := IsTruncated(received.Snapshot); got != tt.want {
+				t.Errorf("IsTruncated(%s) = %v, want %v", received.Snapshot, got, tt.want)
+			}
+		})
+	}
+
+	for _, raw := range []string{"", "null", `"truncated"`, "[true]", "{"} {
+		if IsTruncated(json.RawMessage(raw)) {
+			t.Errorf("IsTruncated(%q) = true", raw)
+		}
+	}
+}
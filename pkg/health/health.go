@@ -0,0 +1,124 @@
+// Package health serves the liveness and readiness probes of the Go services.
+//
+// GET /healthz answers 200 while the process is up. GET /readyz runs every check and
+// answers 503 when a critical one fails, with the outcome of each check in the body so
+// an operator can tell which dependency is down.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each check, so a hung dependency fails the probe instead of making
+// it time out.
+const checkTimeout = 2 * time.Second
+
+// Check is one dependency readiness depends on.
+type Check struct {
+	Name string
+	// Critical checks fail readiness when down; the others are only reported.
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    string `json:"status"` // "up" or "down"
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the body of /readyz. Details carries whatever else the service reports,
+// such as consumer activity.
+type Report struct {
+	Status  string            `json:"status"` // "ready" or "unavailable"
+	Checks  map[string]Result `json:"checks"`
+	Details any               `json:"details,omitempty"`
+}
+
+// Run runs the checks concurrently and reports whether every critical one is up.
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: "ready", Checks: make(map[string]Result, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Run(ctx)
+			result := Result{Status: "up", Critical: check.Critical, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = "down"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if err != nil && check.Critical {
+				report.Status = "unavailable"
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// LiveHandler serves /healthz.
+func LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// ReadyHandler serves /readyz from checks. details, when not nil, fills Report.Details.
+func ReadyHandler(checks []Check, details func() any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), checks)
+		if details != nil {
+			report.Details = details()
+		}
+		status := http.StatusOK
+		if report.Status != "ready" {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	}
+}
+
+// TCP checks that at least one of addrs, such as the Kafka brokers, accepts a connection.
+func TCP(name string, critical bool, addrs []string) Check {
+	return Check{Name: name, Critical: critical, Run: func(ctx context.Context) error {
+		if len(addrs) == 0 {
+			return errors.New("no address configured")
+		}
+		var dialer net.Dialer
+		var err error
+		for _, addr := range addrs {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, "tcp", addr); err == nil {
+				return conn.Close()
+			}
+		}
+		return err
+	}}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
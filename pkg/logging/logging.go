@@ -0,0 +1,69 @@
+// Package logging is the structured logger shared by every Go service in the repo, so
+// field names and levels are the same whatever library sits underneath.
+package logging
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Standard field names. Use these instead of ad-hoc spellings so the log pipeline can
+// parse every service the same way.
+const (
+	FieldService   = "service"
+	FieldRequestID = "request_id"
+	FieldUserID    = "user_id"
+	FieldTeamID    = "team_id"
+	FieldAssetID   = "asset_id"
+	FieldEventType = "event_type"
+	FieldError     = "error"
+)
+
+// Fields are structured key/value pairs attached to a log entry.
+type Fields map[string]any
+
+// Logger is the minimal logging interface constructors should accept.
+type Logger interface {
+	// With returns a child logger that adds fields to every entry.
+	With(fields Fields) Logger
+	Debug(msg string, fields ...Fields)
+	Info(msg string, fields ...Fields)
+	Warn(msg string, fields ...Fields)
+	Error(msg string, fields ...Fields)
+}
+
+// Err is shorthand for a Fields value holding only an error.
+func Err(err error) Fields {
+	return Fields{FieldError: err}
+}
+
+// New builds the service's root logger. LOG_FORMAT=console switches from JSON to a
+// human-friendly format for development, LOG_LEVEL (debug, info, warn, error; default
+// info) sets the minimum level.
+func New(service string) Logger {
+	return NewWithWriter(service, os.Stdout)
+}
+
+// NewWithWriter is New with an explicit output.
+func NewWithWriter(service string, w io.Writer) Logger {
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "console") {
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
+	}
+
+	level, err := zerolog.ParseLevel(strings.ToLower(os.Getenv("LOG_LEVEL")))
+	if err != nil || level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+
+	zl := zerolog.New(w).Level(level).With().Timestamp().Str(FieldService, service).Logger()
+	return FromZerolog(zl)
+}
+
+// Nop returns a Logger that discards everything.
+func Nop() Logger {
+	return FromZerolog(zerolog.Nop())
+}
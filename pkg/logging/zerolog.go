@@ -0,0 +1,43 @@
+package logging
+
+import "github.com/rs/zerolog"
+
+type zerologLogger struct {
+	zl zerolog.Logger
+}
+
+// FromZerolog adapts an existing zerolog logger.
+func FromZerolog(zl zerolog.Logger) Logger {
+	return zerologLogger{zl: zl}
+}
+
+func (l zerologLogger) With(fields Fields) Logger {
+	return zerologLogger{zl: l.zl.With().Fields(map[string]any(fields)).Logger()}
+}
+
+func (l zerologLogger) Debug(msg string, fields ...Fields) {
+	write(l.zl.Debug(), msg, fields)
+}
+
+func (l zerologLogger) Info(msg string, fields ...Fields) {
+	write(l.zl.Info(), msg, fields)
+}
+
+func (l zerologLogger) Warn(msg string, fields ...Fields) {
+	write(l.zl.Warn(), msg, fields)
+}
+
+func (l zerologLogger) Error(msg string, fields ...Fields) {
+	write(l.zl.Error(), msg, fields)
+}
+
+// write is a no-op when the level is filtered out (zerolog returns a nil event).
+func write(e *zerolog.Event, msg string, fields []Fields) {
+	if e == nil {
+		return
+	}
+	for _, f := range fields {
+		e = e.Fields(map[string]any(f))
+	}
+	e.Msg(msg)
+}
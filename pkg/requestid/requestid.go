@@ -0,0 +1,34 @@
+// Package requestid carries the ID of the request that caused some work along with it, so
+// the lines every service logs about that work can be matched up.
+package requestid
+
+import (
+	"context"
+	"time"
+)
+
+// Header names the request ID, both in HTTP requests and responses and in Kafka messages.
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// With returns a copy of ctx carrying id. An empty id leaves ctx as it is.
+func With(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// From returns the request ID ctx carries, or "" when it has none.
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Detached returns a context that keeps the values of ctx, the request ID among them, but
+// not its cancellation, and that times out after timeout instead. Use it for work that
+// must finish even when the client that asked for it goes away, but not hang forever.
+func Detached(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderCarrier reads and writes trace context in the headers of a Kafka message.
+type HeaderCarrier struct {
+	Message *kafka.Message
+}
+
+func (c HeaderCarrier) Get(key string) string {
+	for _, header := range c.Message.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces the header named key, so a message published twice carries one context.
+func (c HeaderCarrier) Set(key, value string) {
+	for i, header := range c.Message.Headers {
+		if header.Key == key {
+			c.Message.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.Message.Headers = append(c.Message.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, len(c.Message.Headers))
+	for i, header := range c.Message.Headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
+
+// StartPublish starts the span of publishing msg, a child of the span in ctx, and puts
+// its context in the headers of msg. msg.Topic must be set.
+func StartPublish(ctx context.Context, msg *kafka.Message) trace.Span {
+	ctx, span := Tracer().Start(ctx, "publish "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingKafkaMessageKey(string(msg.Key)),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{Message: msg})
+	return span
+}
+
+// StartProcess starts the span of a consumer in group handling msg. It begins a trace of
+// its own linked to the producer's span rather than joining that trace: the message may
+// be handled long after the request that published it ended, or handled again.
+func StartProcess(ctx context.Context, msg kafka.Message, group string) (context.Context, trace.Span) {
+	producer := otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier{Message: &msg})
+	return Tracer().Start(ctx, "process "+msg.Topic,
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(producer)),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingKafkaConsumerGroup(group),
+			semconv.MessagingKafkaMessageKey(string(msg.Key)),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+		),
+	)
+}
+
+// End ends span, marking it failed when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
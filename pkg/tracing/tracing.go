@@ -0,0 +1,109 @@
+// Package tracing sets up OpenTelemetry for the Go services and carries trace context
+// across Kafka, so a request can be followed from the API through its queries to the
+// events it publishes and the consumers that handle them.
+package tracing
+
+import (
+	"context"
+	"os"
+	"seta-pkg/buildinfo"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// instrumentationName names the tracer of the spans this repo starts itself.
+const instrumentationName = "seta-pkg/tracing"
+
+// Enabled reports whether spans are exported: OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set and OTEL_SDK_DISABLED is not "true".
+func Enabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Init installs the W3C trace context propagator and, when tracing is enabled, a tracer
+// provider exporting spans of service over OTLP/HTTP. The exporter, sampler and resource
+// read the standard OTEL_* variables, so OTEL_SERVICE_NAME overrides service. Call the
+// returned function on shutdown to flush spans not yet exported.
+//
+// When tracing is not enabled the global provider stays a no-op. The propagator is still
+// installed, so trace context received from callers is passed on to Kafka.
+func Init(ctx context.Context, service string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(service), semconv.ServiceVersion(buildinfo.Version)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer for spans started by the services themselves.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject returns the trace context of ctx in its text form, header name to value, for
+// work picked up later, such as an outbox row. It is empty when ctx has no span.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract returns ctx carrying the trace context Inject returned.
+func Extract(ctx context.Context, fields map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(fields))
+}
+
+// ChildrenOnly wraps provider so its tracers only start spans under an existing one.
+// Instrumentation that would otherwise start a trace for every background query, such
+// as the outbox dispatcher polling, then stays quiet outside of traced work.
+func ChildrenOnly(provider trace.TracerProvider) trace.TracerProvider {
+	return childrenOnlyProvider{provider: provider}
+}
+
+type childrenOnlyProvider struct {
+	embedded.TracerProvider
+	provider trace.TracerProvider
+}
+
+func (p childrenOnlyProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return childrenOnlyTracer{tracer: p.provider.Tracer(name, opts...)}
+}
+
+type childrenOnlyTracer struct {
+	embedded.Tracer
+	tracer trace.Tracer
+}
+
+func (t childrenOnlyTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx) // a span that records nothing
+	}
+	return t.tracer.Start(ctx, name, opts...)
+}
@@ -0,0 +1,376 @@
+// Command event-loadgen produces synthetic team.activity and asset.changes events at a
+// steady rate, to find how many events per second the consumers sustain before their
+// lag starts to grow.
+//
+//	go run ./cmd/event-loadgen --rate 2000 --duration 1m
+//	go run ./cmd/event-loadgen --mix NOTE_UPDATED=80,MEMBER_ADDED=20 --teams 10 --assets 100
+//	go run ./cmd/event-loadgen --dry-run --count 5
+//
+// Payloads are built with the constructors the server itself uses, so they keep the
+// production shapes. Watch the consumer groups' lag while it runs; the command itself
+// reports the throughput it achieved and the produce latency percentiles.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"seta-pkg/events"
+	"seta/internal/pkg/config"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/logger"
+	"seta/internal/pkg/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// defaultMix roughly follows production traffic: mostly note edits, some sharing and
+// a little team churn.
+const defaultMix = "NOTE_UPDATED=40,NOTE_CREATED=15,NOTE_SHARED=8,NOTE_UNSHARED=4,NOTE_DELETED=3," +
+	"FOLDER_CREATED=5,FOLDER_UPDATED=8,FOLDER_SHARED=4,FOLDER_UNSHARED=2," +
+	"MEMBER_ADDED=4,MEMBER_REMOVED=3,MANAGER_ADDED=1,MANAGER_REMOVED=1,ASSET_RESYNC=2"
+
+// teamEventTypes go to team.activity; everything else goes to asset.changes.
+var teamEventTypes = map[string]bool{
+	"TEAM_CREATED": true, "TEAM_DELETED": true, "TEAM_RENAMED": true, "MEMBER_ADDED": true, "MEMBER_REMOVED": true,
+	"MANAGER_ADDED": true, "MANAGER_REMOVED": true, "LEAD_TRANSFERRED": true,
+}
+
+var assetEventTypes = map[string]bool{
+	"FOLDER_CREATED": true, "FOLDER_UPDATED": true, "FOLDER_DELETED": true, "FOLDER_SHARED": true, "FOLDER_UNSHARED": true,
+	"NOTE_CREATED": true, "NOTE_UPDATED": true, "NOTE_DELETED": true, "NOTE_SHARED": true, "NOTE_UNSHARED": true,
+	"ASSET_RESYNC": true,
+}
+
+type options struct {
+	rate         int
+	duration     time.Duration
+	count        int
+	mix          string
+	teams        int
+	assets       int
+	bodyBytes    int
+	workers      int
+	batchSize    int
+	batchTimeout time.Duration
+	seed         int64
+	dryRun       bool
+}
+
+func main() {
+	var opts options
+	flag.IntVar(&opts.rate, "rate", 500, "events per second to produce")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to produce")
+	flag.IntVar(&opts.count, "count", 0, "stop after this many events (0: run for --duration)")
+	flag.StringVar(&opts.mix, "mix", defaultMix, "comma-separated EVENT_TYPE=weight pairs")
+	flag.IntVar(&opts.teams, "teams", 50, "number of distinct teams")
+	flag.IntVar(&opts.assets, "assets", 1000, "number of distinct notes; a fifth as many folders")
+	flag.IntVar(&opts.bodyBytes, "body-bytes", 512, "size of the note body carried by ASSET_RESYNC snapshots")
+	flag.IntVar(&opts.workers, "workers", 64, "concurrent produce calls")
+	flag.IntVar(&opts.batchSize, "batch-size", 100, "Kafka writer batch size")
+	flag.DurationVar(&opts.batchTimeout, "batch-timeout", 10*time.Millisecond, "Kafka writer batch timeout")
+	flag.Int64Var(&opts.seed, "seed", 1, "random seed for the generated IDs and event order")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "print the generated events instead of producing them")
+	flag.Parse()
+
+	log := logger.New()
+	config.LoadConfig()
+
+	mix, err := parseMix(opts.mix)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid --mix")
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid options")
+	}
+
+	gen := newGenerator(opts, mix)
+	producer := kafka.ConfigFromEnv()
+
+	if opts.dryRun {
+		pace(opts, func() {
+			eventType, payload := gen.next()
+			msg, topic, err := encode(eventType, payload, producer)
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to encode event")
+			}
+			fmt.Printf("%s\t%s\t%s\n", topic, msg.Key, msg.Value)
+		})
+		return
+	}
+
+	writers := map[string]*kafkago.Writer{}
+	for _, topic := range []string{producer.TeamTopic, producer.AssetTopic} {
+		writers[topic] = &kafkago.Writer{
+			Addr:         kafkago.TCP(producer.Brokers...),
+			Topic:        topic,
+			Balancer:     &kafkago.Hash{},
+			BatchSize:    opts.batchSize,
+			BatchTimeout: opts.batchTimeout,
+		}
+	}
+
+	type job struct {
+		topic string
+		msg   kafkago.Message
+	}
+	queue := make(chan job, opts.workers)
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, opts.rate*int(opts.duration.Seconds()+1))
+		sent      atomic.Int64
+		failed    atomic.Int64
+	)
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				start := time.Now()
+				err := writers[j.topic].WriteMessages(context.Background(), j.msg)
+				elapsed := time.Since(start)
+				if err != nil {
+					if failed.Add(1) == 1 {
+						log.Error().Err(err).Msg("Failed to produce event; further failures are only counted")
+					}
+					continue
+				}
+				sent.Add(1)
+				mu.Lock()
+				latencies = append(latencies, elapsed)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	stopProgress := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				log.Info().Int64("sent", sent.Load()).Int64("failed", failed.Load()).Msg("Progress")
+			case <-stopProgress:
+				return
+			}
+		}
+	}()
+
+	log.Info().Int("rate", opts.rate).Dur("duration", opts.duration).Int("count", opts.count).Msg("Producing events")
+	started := time.Now()
+	pace(opts, func() {
+		eventType, payload := gen.next()
+		msg, topic, err := encode(eventType, payload, producer)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to encode event")
+		}
+		queue <- job{topic: topic, msg: msg}
+	})
+	close(queue)
+	wg.Wait()
+	elapsed := time.Since(started)
+	close(stopProgress)
+
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			log.Error().Err(err).Msg("failed to close Kafka writer")
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	log.Info().
+		Int64("sent", sent.Load()).
+		Int64("failed", failed.Load()).
+		Dur("elapsed", elapsed).
+		Str("achieved_rate", strconv.FormatFloat(float64(sent.Load())/elapsed.Seconds(), 'f', 1, 64)+"/s").
+		Dur("p50", percentile(latencies, 50)).
+		Dur("p90", percentile(latencies, 90)).
+		Dur("p99", percentile(latencies, 99)).
+		Dur("max", percentile(latencies, 100)).
+		Msg("Load generation finished")
+}
+
+func (o options) validate() error {
+	switch {
+	case o.rate < 1:
+		return errors.New("--rate must be at least 1")
+	case o.duration <= 0 && o.count <= 0:
+		return errors.New("--duration or --count must be positive")
+	case o.teams < 1 || o.assets < 5:
+		return errors.New("--teams must be at least 1 and --assets at least 5")
+	case o.workers < 1 || o.batchSize < 1:
+		return errors.New("--workers and --batch-size must be at least 1")
+	case o.bodyBytes < 0:
+		return errors.New("--body-bytes must not be negative")
+	}
+	return nil
+}
+
+// pace calls emit --rate times a second until --count events or --duration have passed.
+// Emission is scheduled against the start time, so if emit falls behind (the workers
+// cannot keep up) the achieved rate drops instead of the schedule drifting silently.
+func pace(opts options, emit func()) {
+	interval := time.Second / time.Duration(opts.rate)
+	start := time.Now()
+	deadline := start.Add(opts.duration)
+	for i := 0; opts.count <= 0 || i < opts.count; i++ {
+		due := start.Add(time.Duration(i) * interval)
+		if opts.count <= 0 && !due.Before(deadline) {
+			return
+		}
+		if wait := time.Until(due); wait > 0 {
+			time.Sleep(wait)
+		}
+		emit()
+	}
+}
+
+type weightedType struct {
+	eventType string
+	weight    int
+}
+
+func parseMix(raw string) ([]weightedType, error) {
+	var mix []weightedType
+	for _, part := range strings.Split(raw, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not EVENT_TYPE=weight", part)
+		}
+		if !teamEventTypes[name] && !assetEventTypes[name] {
+			return nil, fmt.Errorf("unknown event type %q", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("weight of %s must be a non-negative integer", name)
+		}
+		if w > 0 {
+			mix = append(mix, weightedType{eventType: name, weight: w})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, errors.New("at least one event type needs a positive weight")
+	}
+	return mix, nil
+}
+
+// generator draws events from fixed pools of teams, users, folders and notes so that the
+// same IDs recur and consumers see updates to existing keys, not only new ones.
+type generator struct {
+	rng         *rand.Rand
+	mix         []weightedType
+	totalWeight int
+	teams       []uuid.UUID
+	users       []uuid.UUID
+	folders     []models.Folder
+	notes       []models.Note
+}
+
+func newGenerator(opts options, mix []weightedType) *generator {
+	rng := rand.New(rand.NewSource(opts.seed))
+	// IDs are derived from the seed so repeated runs hit the same keys.
+	id := func(kind string, i int) uuid.UUID {
+		return uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("loadgen/%d/%s/%d", opts.seed, kind, i)))
+	}
+
+	g := &generator{rng: rng, mix: mix}
+	for _, w := range mix {
+		g.totalWeight += w.weight
+	}
+	for i := 0; i < opts.teams; i++ {
+		g.teams = append(g.teams, id("team", i))
+	}
+	for i := 0; i < opts.teams*8; i++ {
+		g.users = append(g.users, id("user", i))
+	}
+	for i := 0; i < opts.assets/5; i++ {
+		g.folders = append(g.folders, models.Folder{FolderID: id("folder", i), Name: "Load test folder", OwnerID: g.user()})
+	}
+	body := strings.Repeat("x", opts.bodyBytes)
+	for i := 0; i < opts.assets; i++ {
+		folder := g.folders[rng.Intn(len(g.folders))]
+		g.notes = append(g.notes, models.Note{
+			NoteID:    id("note", i),
+			Title:     "Load test note",
+			Body:      body,
+			FolderID:  folder.FolderID,
+			OwnerID:   folder.OwnerID,
+			Cacheable: true,
+			Active:    true,
+		})
+	}
+	return g
+}
+
+func (g *generator) user() uuid.UUID {
+	return g.users[g.rng.Intn(len(g.users))]
+}
+
+func (g *generator) next() (string, kafka.EventPayload) {
+	pick := g.rng.Intn(g.totalWeight)
+	eventType := g.mix[len(g.mix)-1].eventType
+	for _, w := range g.mix {
+		if pick < w.weight {
+			eventType = w.eventType
+			break
+		}
+		pick -= w.weight
+	}
+
+	actor := g.user()
+	if teamEventTypes[eventType] {
+		payload := kafka.NewTeamEvent(eventType, g.teams[g.rng.Intn(len(g.teams))], actor)
+		if eventType != "TEAM_CREATED" && eventType != "TEAM_DELETED" && eventType != "TEAM_RENAMED" {
+			payload = payload.WithTarget(g.user())
+		}
+		return eventType, payload
+	}
+
+	folder := g.folders[g.rng.Intn(len(g.folders))]
+	note := g.notes[g.rng.Intn(len(g.notes))]
+	switch eventType {
+	case "FOLDER_SHARED", "FOLDER_UNSHARED":
+		return eventType, kafka.NewFolderEvent(eventType, folder, actor).WithTarget(g.user())
+	case "FOLDER_CREATED", "FOLDER_UPDATED", "FOLDER_DELETED":
+		return eventType, kafka.NewFolderEvent(eventType, folder, actor)
+	case "NOTE_SHARED", "NOTE_UNSHARED":
+		return eventType, kafka.NewNoteEvent(eventType, note, actor).WithTarget(g.user())
+	case "ASSET_RESYNC":
+		payload := events.NewAssetEvent(eventType, "note", note.NoteID.String(), "event-loadgen")
+		payload.OwnerID = note.OwnerID.String()
+		payload.Snapshot = events.LimitPayload(note)
+		payload.ACL = map[string]string{g.user().String(): "read"}
+		return eventType, payload
+	default:
+		return eventType, kafka.NewNoteEvent(eventType, note, actor)
+	}
+}
+
+func encode(eventType string, payload kafka.EventPayload, producer kafka.Config) (kafkago.Message, string, error) {
+	if teamEventTypes[eventType] {
+		msg, err := kafka.NewTeamMessage(payload)
+		return msg, producer.TeamTopic, err
+	}
+	msg, err := kafka.NewAssetMessage(payload)
+	return msg, producer.AssetTopic, err
+}
+
+// percentile expects sorted durations; p=100 is the maximum.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	return sorted[max(i-1, 0)]
+}
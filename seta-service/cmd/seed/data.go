@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/rand"
+	"seta/internal/pkg/userclient"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var teamNames = []string{"Platform", "Mobile", "Data", "Growth", "Design", "Support"}
+
+var folderNames = []string{
+	"Meeting Notes", "Roadmap", "Runbooks", "Design Docs", "Retrospectives",
+	"Onboarding", "Research", "Incident Reports", "Drafts", "Reading List",
+}
+
+var titleSubjects = []string{"Sprint", "Quarterly", "Architecture", "Customer", "Release", "Hiring", "Budget", "Migration"}
+var titleKinds = []string{"Plan", "Review", "Summary", "Checklist", "Proposal", "Notes", "Follow-ups", "Decisions"}
+
+var words = strings.Fields(`the team agreed to ship the new api behind a feature flag before the end of
+the quarter while we keep monitoring latency and error budgets for the legacy endpoints customers
+reported slow imports so we will batch writes add retries and document the rollout plan next week
+owners should review open questions about storage costs access control and the migration timeline`)
+
+func noteTitle(rng *rand.Rand) string {
+	return titleSubjects[rng.Intn(len(titleSubjects))] + " " + titleKinds[rng.Intn(len(titleKinds))]
+}
+
+// noteBody produces between a short paragraph and roughly 20KB of text; most notes are
+// small, a few are long.
+func noteBody(rng *rand.Rand) string {
+	paragraphs := 1 + rng.Intn(3)
+	if rng.Intn(10) == 0 {
+		paragraphs = 20 + rng.Intn(100)
+	}
+
+	var b strings.Builder
+	for p := 0; p < paragraphs; p++ {
+		if p > 0 {
+			b.WriteString("\n\n")
+		}
+		sentences := 2 + rng.Intn(5)
+		for s := 0; s < sentences; s++ {
+			length := 6 + rng.Intn(12)
+			for w := 0; w < length; w++ {
+				word := words[rng.Intn(len(words))]
+				if w == 0 {
+					word = strings.ToUpper(word[:1]) + word[1:]
+				} else {
+					b.WriteByte(' ')
+				}
+				b.WriteString(word)
+			}
+			b.WriteString(". ")
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func access(rng *rand.Rand) string {
+	if rng.Intn(3) == 0 {
+		return "write"
+	}
+	return "read"
+}
+
+// pick returns up to n distinct users chosen by rng.
+func pick(rng *rand.Rand, users []userclient.User, n int) []userclient.User {
+	if n > len(users) {
+		n = len(users)
+	}
+	picked := make([]userclient.User, 0, n)
+	for _, i := range rng.Perm(len(users))[:n] {
+		picked = append(picked, users[i])
+	}
+	return picked
+}
+
+func sortUsers(users []userclient.User) {
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+}
+
+func mustUUID(id string) uuid.UUID {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		panic("user service returned a non-UUID user ID: " + id)
+	}
+	return parsed
+}
@@ -0,0 +1,418 @@
+// Command seed fills a development database with users, teams, folders, notes and shares.
+//
+//	go run ./cmd/seed --users 20 --teams 3 --seed 42
+//	go run ./cmd/seed --wipe
+//
+// Users are created through the CSV import path against the user service. Everything
+// else is written directly with deterministic IDs, so re-running with the same --seed
+// is a no-op. Seeded rows are recognisable by the "[seed] " prefix on team, folder and
+// note names, which is also what --wipe deletes.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"seta-pkg/database"
+	"seta-pkg/logging"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/config"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/logger"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/userclient"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// seedPrefix marks every team, folder and note created by this command.
+const seedPrefix = "[seed] "
+
+// seedEmailDomain marks every user created by this command.
+const seedEmailDomain = "@seed.local"
+
+type options struct {
+	users          int
+	teams          int
+	foldersPerUser int
+	notesPerFolder int
+	seed           int64
+	wipe           bool
+	events         bool
+	force          bool
+}
+
+func main() {
+	var opts options
+	flag.IntVar(&opts.users, "users", 20, "number of users to create (a quarter become managers)")
+	flag.IntVar(&opts.teams, "teams", 3, "number of teams to create")
+	flag.IntVar(&opts.foldersPerUser, "folders", 2, "folders per user")
+	flag.IntVar(&opts.notesPerFolder, "notes", 5, "notes per folder")
+	flag.Int64Var(&opts.seed, "seed", 1, "random seed; the same seed produces the same data")
+	flag.BoolVar(&opts.wipe, "wipe", false, "delete previously seeded teams, folders and notes, then exit")
+	flag.BoolVar(&opts.events, "events", true, "produce Kafka events for the seeded data")
+	flag.BoolVar(&opts.force, "force", false, "skip the development database check")
+	flag.Parse()
+
+	log := logger.New()
+	config.LoadConfig()
+
+	dsn := os.Getenv("DATABASE_URL")
+	if !opts.force && !looksLikeDevDSN(dsn) {
+		log.Fatal().Msg("DATABASE_URL does not look like a development database (expected a local host or a database name containing dev/local/test); pass --force to override")
+	}
+
+	db, err := database.Connect(logging.FromZerolog(*log))
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not connect to database")
+	}
+
+	ctx := context.Background()
+
+	if opts.wipe {
+		if err := wipe(ctx, db, log); err != nil {
+			log.Fatal().Err(err).Msg("wipe failed")
+		}
+		return
+	}
+
+	if opts.users < 2 {
+		log.Fatal().Msg("--users must be at least 2")
+	}
+
+	if opts.events {
+		kafka.InitProducers(kafka.ConfigFromEnv(), logging.FromZerolog(*log))
+		defer func() {
+			if err := kafka.CloseProducers(); err != nil {
+				log.Error().Err(err).Msg("failed to flush Kafka producers")
+			}
+		}()
+	}
+
+	s := &seeder{
+		db:     db,
+		log:    log,
+		opts:   opts,
+		rng:    rand.New(rand.NewSource(opts.seed)),
+		events: make([]event, 0),
+	}
+	if err := s.run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("seed failed")
+	}
+}
+
+// looksLikeDevDSN accepts URL and key=value DSNs pointing at a local host, the compose
+// service names, or a database whose name says it is for development.
+func looksLikeDevDSN(dsn string) bool {
+	lower := strings.ToLower(dsn)
+	for _, marker := range []string{"localhost", "127.0.0.1", "@postgres:", "host=postgres", "_dev", "-dev", "dev_", "local", "test"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+type event struct {
+	team    bool
+	payload kafka.EventPayload
+}
+
+type seeder struct {
+	db     *gorm.DB
+	log    *zerolog.Logger
+	opts   options
+	rng    *rand.Rand
+	events []event
+}
+
+// newID derives the next ID from the seed, so a re-run produces the same rows and
+// every insert hits ON CONFLICT DO NOTHING.
+func (s *seeder) newID() uuid.UUID {
+	id, err := uuid.NewRandomFromReader(s.rng)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func (s *seeder) run(ctx context.Context) error {
+	managers, members, err := s.seedUsers(ctx)
+	if err != nil {
+		return err
+	}
+	s.log.Info().Int("managers", len(managers)).Int("members", len(members)).Msg("Seed users ready")
+
+	everyone := append(append([]userclient.User{}, managers...), members...)
+
+	if len(managers) > 0 {
+		if err := s.seedTeams(ctx, managers, members); err != nil {
+			return err
+		}
+	}
+	if err := s.seedAssets(ctx, everyone); err != nil {
+		return err
+	}
+
+	if s.opts.events {
+		s.produceEvents(ctx)
+	}
+	s.log.Info().Int("events", len(s.events)).Msg("Seeding complete")
+	return nil
+}
+
+// seedUsers creates the users through UserService.ImportUsers, then reads every seeded
+// user back from the user service. Users left over from earlier runs fail to import as
+// duplicates and are simply picked up again.
+func (s *seeder) seedUsers(ctx context.Context) ([]userclient.User, []userclient.User, error) {
+	var csv strings.Builder
+	csv.WriteString("username,email,password,role\n")
+	managerCount := max(1, s.opts.users/4)
+	for i := 0; i < s.opts.users; i++ {
+		role := "MEMBER"
+		if i < managerCount {
+			role = "MANAGER"
+		}
+		fmt.Fprintf(&csv, "seed-user-%03d,seed-user-%03d%s,seed-password,%s\n", i, i, seedEmailDomain, role)
+	}
+
+	summary, err := services.NewUserService(s.db, logging.FromZerolog(*s.log)).ImportUsers(ctx, uuid.Nil, uuid.New(), strings.NewReader(csv.String()), false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("import users: %w", err)
+	}
+	s.log.Info().Int("created", summary.Succeeded).Int("skipped", summary.Failed).Msg("Imported seed users")
+
+	client := userclient.Shared()
+	var managers, members []userclient.User
+	for role, out := range map[string]*[]userclient.User{"MANAGER": &managers, "MEMBER": &members} {
+		users, err := client.GetUsers(ctx, role)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list %s users: %w", role, err)
+		}
+		for _, u := range users {
+			if strings.HasSuffix(u.Email, seedEmailDomain) {
+				*out = append(*out, u)
+			}
+		}
+	}
+
+	// The user service returns users in no particular order; sort so the seed decides.
+	sortUsers(managers)
+	sortUsers(members)
+	return managers, members, nil
+}
+
+func (s *seeder) seedTeams(ctx context.Context, managers, members []userclient.User) error {
+	for t := 0; t < s.opts.teams; t++ {
+		team := models.Team{ID: s.newID(), TeamName: fmt.Sprintf("%s%s", seedPrefix, teamNames[t%len(teamNames)])}
+		if t >= len(teamNames) {
+			team.TeamName = fmt.Sprintf("%s %d", team.TeamName, t/len(teamNames)+1)
+		}
+
+		lead := managers[t%len(managers)]
+		teamManagers := []models.TeamManager{{TeamID: team.ID, UserID: mustUUID(lead.UserID), IsLead: true}}
+		if len(managers) > 1 && s.rng.Intn(2) == 0 {
+			co := managers[(t+1)%len(managers)]
+			teamManagers = append(teamManagers, models.TeamManager{TeamID: team.ID, UserID: mustUUID(co.UserID)})
+		}
+
+		teamMembers := make([]models.TeamMember, 0)
+		for _, m := range pick(s.rng, members, 3+s.rng.Intn(4)) {
+			teamMembers = append(teamMembers, models.TeamMember{TeamID: team.ID, UserID: mustUUID(m.UserID)})
+		}
+
+		created := false
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&team)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			created = true
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&teamManagers).Error; err != nil {
+				return err
+			}
+			if len(teamMembers) > 0 {
+				return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&teamMembers).Error
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("create team %q: %w", team.TeamName, err)
+		}
+		if !created {
+			continue // Seeded by an earlier run.
+		}
+
+		leadID := mustUUID(lead.UserID)
+		s.teamEvent(kafka.NewTeamEvent("TEAM_CREATED", team.ID, leadID))
+		for _, tm := range teamManagers[1:] {
+			s.teamEvent(kafka.NewTeamEvent("MANAGER_ADDED", team.ID, leadID).WithTarget(tm.UserID))
+		}
+		for _, tm := range teamMembers {
+			s.teamEvent(kafka.NewTeamEvent("MEMBER_ADDED", team.ID, leadID).WithTarget(tm.UserID))
+		}
+	}
+	return nil
+}
+
+func (s *seeder) seedAssets(ctx context.Context, users []userclient.User) error {
+	for _, owner := range users {
+		ownerID := mustUUID(owner.UserID)
+		for f := 0; f < s.opts.foldersPerUser; f++ {
+			folder := models.Folder{
+				FolderID: s.newID(),
+				Name:     seedPrefix + folderNames[s.rng.Intn(len(folderNames))],
+				OwnerID:  ownerID,
+			}
+			notes := make([]models.Note, s.opts.notesPerFolder)
+			for n := range notes {
+				notes[n] = models.Note{
+					NoteID:   s.newID(),
+					Title:    seedPrefix + noteTitle(s.rng),
+					Body:     noteBody(s.rng),
+					FolderID: folder.FolderID,
+					OwnerID:  ownerID,
+				}
+			}
+			folderShares, noteShares := s.shares(folder, notes, ownerID, users)
+
+			created := false
+			err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Owner").Create(&folder)
+				if result.Error != nil || result.RowsAffected == 0 {
+					return result.Error
+				}
+				created = true
+				if len(notes) > 0 {
+					if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Owner", "Folder").Create(&notes).Error; err != nil {
+						return err
+					}
+				}
+				if len(folderShares) > 0 {
+					if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&folderShares).Error; err != nil {
+						return err
+					}
+				}
+				if len(noteShares) > 0 {
+					return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&noteShares).Error
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("create folder %q: %w", folder.Name, err)
+			}
+			if !created {
+				continue // Seeded by an earlier run.
+			}
+
+			s.assetEvent(kafka.NewFolderEvent("FOLDER_CREATED", folder, ownerID))
+			for _, note := range notes {
+				s.assetEvent(kafka.NewNoteEvent("NOTE_CREATED", note, ownerID))
+			}
+			for _, share := range folderShares {
+				s.assetEvent(kafka.NewFolderEvent("FOLDER_SHARED", folder, ownerID).WithTarget(share.UserID))
+			}
+			for _, share := range noteShares {
+				for _, note := range notes {
+					if note.NoteID == share.NoteID {
+						s.assetEvent(kafka.NewNoteEvent("NOTE_SHARED", note, ownerID).WithTarget(share.UserID))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// shares mixes unshared folders, folders shared read or write with one or two users,
+// and individual notes shared on top of (or instead of) the folder share.
+func (s *seeder) shares(folder models.Folder, notes []models.Note, ownerID uuid.UUID, users []userclient.User) ([]models.FolderShare, []models.NoteShare) {
+	others := make([]userclient.User, 0, len(users))
+	for _, u := range users {
+		if u.UserID != ownerID.String() {
+			others = append(others, u)
+		}
+	}
+
+	folderShares := make([]models.FolderShare, 0)
+	if s.rng.Intn(3) > 0 {
+		for _, u := range pick(s.rng, others, 1+s.rng.Intn(2)) {
+			folderShares = append(folderShares, models.FolderShare{FolderID: folder.FolderID, UserID: mustUUID(u.UserID), Access: access(s.rng)})
+		}
+	}
+
+	noteShares := make([]models.NoteShare, 0)
+	for _, note := range notes {
+		if s.rng.Intn(4) == 0 {
+			u := others[s.rng.Intn(len(others))]
+			noteShares = append(noteShares, models.NoteShare{NoteID: note.NoteID, UserID: mustUUID(u.UserID), Access: access(s.rng)})
+		}
+	}
+	return folderShares, noteShares
+}
+
+func (s *seeder) teamEvent(p kafka.EventPayload) {
+	s.events = append(s.events, event{team: true, payload: p})
+}
+
+func (s *seeder) assetEvent(p kafka.EventPayload) {
+	s.events = append(s.events, event{payload: p})
+}
+
+// produceEvents writes the collected events with a few concurrent writers; kafka-go
+// batches concurrent WriteMessages calls, a single synchronous loop would take a second
+// per event.
+func (s *seeder) produceEvents(ctx context.Context) {
+	queue := make(chan event)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range queue {
+				var err error
+				if e.team {
+					err = kafka.ProduceTeamEvent(ctx, e.payload)
+				} else {
+					err = kafka.ProduceAssetEvent(ctx, e.payload)
+				}
+				if err != nil {
+					s.log.Error().Err(err).Str(logging.FieldEventType, e.payload.EventType).Msg("Failed to produce seed event")
+				}
+			}
+		}()
+	}
+	for _, e := range s.events {
+		queue <- e
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// wipe deletes seeded teams and folders; members, managers, notes and shares go with
+// them through ON DELETE CASCADE. Users stay, the user service has no delete mutation.
+func wipe(ctx context.Context, db *gorm.DB, log *zerolog.Logger) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		notes := tx.Unscoped().Where("title LIKE ? OR folder_id IN (SELECT folder_id FROM folders WHERE name LIKE ?)", seedPrefix+"%", seedPrefix+"%").Delete(&models.Note{})
+		if notes.Error != nil {
+			return notes.Error
+		}
+		folders := tx.Where("name LIKE ?", seedPrefix+"%").Delete(&models.Folder{})
+		if folders.Error != nil {
+			return folders.Error
+		}
+		teams := tx.Where("team_name LIKE ?", seedPrefix+"%").Delete(&models.Team{})
+		if teams.Error != nil {
+			return teams.Error
+		}
+		log.Info().Int64("teams", teams.RowsAffected).Int64("folders", folders.RowsAffected).Int64("notes", notes.RowsAffected).Msg("Wiped seeded data")
+		return nil
+	})
+}
@@ -1,22 +1,52 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"seta-pkg/database"
+	"seta-pkg/logging"
+	"seta-pkg/tracing"
+	"seta/internal/app/server/middlewares"
 	"seta/internal/app/server/routes"
+	"seta/internal/app/server/services"
 	"seta/internal/pkg/config"
-	"seta/internal/pkg/database"
 	"seta/internal/pkg/kafka"
 	"seta/internal/pkg/logger"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout is how long in-flight requests get to finish once the server is told to stop.
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	// Initialize logger
 	log := logger.New()
 
 	// Load configuration from .env file
 	config.LoadConfig()
+	if err := config.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid configuration")
+	}
+
+	// Export traces when an OTLP endpoint is configured, flushing them last on shutdown
+	shutdownTracing, err := tracing.Init(context.Background(), "seta-service")
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not set up tracing")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to flush traces")
+		}
+	}()
 
 	// Connect to the database
-	db, err := database.Connect(log)
+	db, err := database.Connect(logging.FromZerolog(*log))
 	if err != nil {
 		log.Fatal().Err(err).Msg("could not connect to database")
 	}
@@ -28,16 +58,71 @@ func main() {
 
 	defer sqlDB.Close()
 
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize Kafka Producers
-	kafka.InitProducers()
+	kafka.InitProducers(kafka.ConfigFromEnv(), logging.FromZerolog(*log))
+	defer func() {
+		if err := kafka.CloseProducers(); err != nil {
+			log.Error().Err(err).Msg("failed to flush Kafka producers")
+		}
+	}()
+
+	// Background workers use the producers and the database, so both are closed only once
+	// every worker has returned
+	var workers sync.WaitGroup
+	run := func(worker func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker(ctx)
+		}()
+	}
+
+	// Publish events written to the outbox by request handlers
+	run(kafka.NewOutboxDispatcher(db, logging.FromZerolog(*log)).Run)
+
+	// Fan asset changes out to folder webhooks and deliver them
+	run(services.NewWebhookDispatcher(db, logging.FromZerolog(*log)).Run)
+
+	// Fail the folder deletion jobs that died with a previous instance, so their folders can be deleted again
+	services.NewFolderDeletionService(db, logging.FromZerolog(*log)).FailStaleJobs(ctx)
+
+	// Remove notes whose time in the trash is up
+	run(services.NewNotePurger(db, logging.FromZerolog(*log)).Run)
+
+	// Cache access decisions, purging them whenever any instance changes who can see what
+	authorization := services.NewCachedAuthorizationService(db, logging.FromZerolog(*log))
+	run(authorization.ListenForPurges)
+
+	// Cache token verifications, dropping them whenever any instance hears of a revocation
+	run(func(ctx context.Context) {
+		middlewares.ListenForTokenRevocations(ctx, logging.FromZerolog(*log))
+	})
 
 	// Set up the router
-	router := routes.SetupRouter(db, log)
+	router := routes.SetupRouter(db, authorization, logging.FromZerolog(*log))
 
 	// Start the server
-	// add graceful shutdown
-	log.Info().Msg("Starting server on port 8080")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatal().Err(err).Msg("could not start server")
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		log.Info().Msg("Starting server on port 8080")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("could not start server")
+		}
+	}()
+
+	// Let in-flight requests finish and the workers stop, then flush the producers and
+	// close the database
+	<-ctx.Done()
+	log.Info().Msg("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("server did not shut down cleanly")
 	}
+	stop()
+	workers.Wait()
 }
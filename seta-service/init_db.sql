@@ -1,10 +1,14 @@
+-- The script can be run again on an existing database: tables and indexes are only
+-- created when missing, columns added since a table was first created are added below
+-- its CREATE TABLE, and the mock data is only inserted once.
+
 -- Enable UUID generation function if not already enabled
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
 
 -- =================================================================
 -- Table: teams
 -- =================================================================
-CREATE TABLE teams (
+CREATE TABLE IF NOT EXISTS teams (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     team_name VARCHAR(255) NOT NULL,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
@@ -14,57 +18,116 @@ CREATE TABLE teams (
 -- =================================================================
 -- Mapping Table: team_managers
 -- =================================================================
-CREATE TABLE team_managers (
+CREATE TABLE IF NOT EXISTS team_managers (
     team_id UUID NOT NULL,
     user_id UUID NOT NULL,
+    is_lead BOOLEAN NOT NULL DEFAULT FALSE,
     PRIMARY KEY (team_id, user_id),
     FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
 );
 
+-- At most one lead per team; TransferLead demotes before it promotes.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_managers_one_lead ON team_managers(team_id) WHERE is_lead;
+
 -- =================================================================
 -- Mapping Table: team_members
 -- =================================================================
-CREATE TABLE team_members (
+CREATE TABLE IF NOT EXISTS team_members (
     team_id UUID NOT NULL,
     user_id UUID NOT NULL,
     PRIMARY KEY (team_id, user_id),
     FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
 );
 
+-- =================================================================
+-- Table: team_membership_changes
+-- =================================================================
+CREATE TABLE IF NOT EXISTS team_membership_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    change VARCHAR(10) NOT NULL CHECK (change IN ('added', 'removed')),
+    changed_by UUID,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_membership_changes_team ON team_membership_changes(team_id, change, changed_at);
+
 -- =================================================================
 -- Table: folders
 -- =================================================================
-CREATE TABLE folders (
+CREATE TABLE IF NOT EXISTS folders (
     folder_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     name VARCHAR(255) NOT NULL,
     owner_id UUID NOT NULL,
+    deletion_pending BOOLEAN NOT NULL DEFAULT FALSE,
+    allow_note_sharing BOOLEAN NOT NULL DEFAULT FALSE,
+    team_id UUID,
+    parent_folder_id UUID,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_modified_by UUID NOT NULL,
+    FOREIGN KEY (team_id) REFERENCES teams(id),
+    -- No cascade: DeleteFolder removes subfolders itself so each one gets its events.
+    FOREIGN KEY (parent_folder_id) REFERENCES folders(folder_id),
+    CHECK (parent_folder_id <> folder_id)
 );
 
-CREATE INDEX idx_folders_owner_id ON folders(owner_id);
+-- Folders created before edits were attributed were last modified by their owner as far
+-- as anyone knows. The column is only required once every row has a value.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS last_modified_by UUID;
+UPDATE folders SET last_modified_by = owner_id WHERE last_modified_by IS NULL;
+ALTER TABLE folders ALTER COLUMN last_modified_by SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_folders_owner_id ON folders(owner_id);
+CREATE INDEX IF NOT EXISTS idx_folders_team_id ON folders(team_id) WHERE team_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_folders_parent_folder_id ON folders(parent_folder_id) WHERE parent_folder_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_folders_updated_at ON folders(updated_at DESC, folder_id DESC);
 
 -- =================================================================
 -- Table: notes
 -- =================================================================
-CREATE TABLE notes (
+CREATE TABLE IF NOT EXISTS notes (
     note_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     title VARCHAR(255) NOT NULL,
     body TEXT,
+    -- Set instead of body for bodies over NOTE_BODY_COMPRESSION_THRESHOLD_BYTES.
+    body_compressed BYTEA,
     folder_id UUID NOT NULL,
     owner_id UUID NOT NULL,
+    cacheable BOOLEAN NOT NULL DEFAULT TRUE,
+    is_announcement BOOLEAN NOT NULL DEFAULT FALSE,
+    team_id UUID,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Bumped by every edit of the title or body; edits name the version they were made on.
+    version INTEGER NOT NULL DEFAULT 1,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_modified_by UUID NOT NULL,
+    -- Set while the note is in the trash; purged NOTE_TRASH_RETENTION_DAYS later.
+    deleted_at TIMESTAMPTZ,
     FOREIGN KEY (folder_id) REFERENCES folders(folder_id) ON DELETE CASCADE
 );
 
-CREATE INDEX idx_notes_folder_id ON notes(folder_id);
-CREATE INDEX idx_notes_owner_id ON notes(owner_id);
+-- Existing bodies stay in body; they are compressed on their next write.
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS body_compressed BYTEA;
+
+-- Backfilled like folders.last_modified_by.
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS last_modified_by UUID;
+UPDATE notes SET last_modified_by = owner_id WHERE last_modified_by IS NULL;
+ALTER TABLE notes ALTER COLUMN last_modified_by SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_notes_folder_id ON notes(folder_id);
+CREATE INDEX IF NOT EXISTS idx_notes_owner_id ON notes(owner_id);
+CREATE INDEX IF NOT EXISTS idx_notes_updated_at ON notes(updated_at DESC, note_id DESC);
+CREATE INDEX IF NOT EXISTS idx_notes_team_announcements ON notes(team_id, created_at DESC) WHERE is_announcement;
+CREATE INDEX IF NOT EXISTS idx_notes_deleted_at ON notes(deleted_at) WHERE deleted_at IS NOT NULL;
 
 -- =================================================================
 -- Sharing Table: folder_shares
 -- =================================================================
-CREATE TABLE folder_shares (
+CREATE TABLE IF NOT EXISTS folder_shares (
     folder_id UUID NOT NULL,
     user_id UUID NOT NULL,
     access VARCHAR(10) NOT NULL CHECK (access IN ('read', 'write')),
@@ -75,7 +138,7 @@ CREATE TABLE folder_shares (
 -- =================================================================
 -- Sharing Table: note_shares
 -- =================================================================
-CREATE TABLE note_shares (
+CREATE TABLE IF NOT EXISTS note_shares (
     note_id UUID NOT NULL,
     user_id UUID NOT NULL,
     access VARCHAR(10) NOT NULL CHECK (access IN ('read', 'write')),
@@ -83,6 +146,170 @@ CREATE TABLE note_shares (
     FOREIGN KEY (note_id) REFERENCES notes(note_id) ON DELETE CASCADE
 );
 
+-- =================================================================
+-- Table: folder_deletion_jobs
+-- =================================================================
+CREATE TABLE IF NOT EXISTS folder_deletion_jobs (
+    job_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    folder_id UUID NOT NULL,
+    requested_by UUID NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    total_notes BIGINT NOT NULL,
+    deleted_notes BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- =================================================================
+-- Table: user_import_jobs
+-- =================================================================
+CREATE TABLE IF NOT EXISTS user_import_jobs (
+    job_id UUID PRIMARY KEY,
+    requested_by UUID NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    total_rows INT NOT NULL,
+    processed_rows INT NOT NULL DEFAULT 0,
+    succeeded INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    failures JSONB,
+    failures_truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_import_jobs_requested_by ON user_import_jobs(requested_by);
+
+-- =================================================================
+-- Table: user_import_leases
+-- =================================================================
+CREATE TABLE IF NOT EXISTS user_import_leases (
+    user_id UUID PRIMARY KEY,
+    import_id UUID NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- =================================================================
+-- Table: note_templates
+-- =================================================================
+CREATE TABLE IF NOT EXISTS note_templates (
+    template_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL,
+    team_id UUID,
+    name VARCHAR(255) NOT NULL,
+    title_template VARCHAR(255) NOT NULL,
+    body_template TEXT,
+    usage_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_templates_owner_id ON note_templates(owner_id);
+CREATE INDEX IF NOT EXISTS idx_note_templates_team_id ON note_templates(team_id);
+
+-- =================================================================
+-- Tables: feature_flags, feature_flag_subjects, feature_flag_changes
+-- =================================================================
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    rollout_percentage INTEGER NOT NULL DEFAULT 0 CHECK (rollout_percentage BETWEEN 0 AND 100),
+    updated_by VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS feature_flag_subjects (
+    flag_name VARCHAR(100) NOT NULL,
+    subject_type VARCHAR(10) NOT NULL CHECK (subject_type IN ('user', 'team')),
+    subject_id UUID NOT NULL,
+    PRIMARY KEY (flag_name, subject_type, subject_id),
+    FOREIGN KEY (flag_name) REFERENCES feature_flags(name) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS feature_flag_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    flag_name VARCHAR(100) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    before JSONB,
+    after JSONB NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_feature_flag_changes_flag ON feature_flag_changes(flag_name, changed_at);
+
+-- =================================================================
+-- Table: outbox_events
+-- =================================================================
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(100) NOT NULL,
+    message_key TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    trace_parent TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_sent_at ON outbox_events(sent_at) WHERE sent_at IS NOT NULL;
+
+-- =================================================================
+-- Table: dispatch_pauses
+-- =================================================================
+CREATE TABLE IF NOT EXISTS dispatch_pauses (
+    dispatcher VARCHAR(50) PRIMARY KEY,
+    paused_by TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    paused_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- =================================================================
+-- Table: folder_webhooks
+-- =================================================================
+CREATE TABLE IF NOT EXISTS folder_webhooks (
+    webhook_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    folder_id UUID NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT NOT NULL DEFAULT '',
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_count BIGINT NOT NULL DEFAULT 0,
+    failed_count BIGINT NOT NULL DEFAULT 0,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    last_delivery_at TIMESTAMPTZ,
+    last_error TEXT,
+    disabled_at TIMESTAMPTZ,
+    FOREIGN KEY (folder_id) REFERENCES folders(folder_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_webhooks_folder_id ON folder_webhooks(folder_id);
+
+-- =================================================================
+-- Table: webhook_deliveries
+-- =================================================================
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (webhook_id) REFERENCES folder_webhooks(webhook_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+
 
 -- =================================================================
 -- MOCK DATA INSERTION
@@ -108,15 +335,20 @@ DECLARE
     note_carol_id UUID    := 'e6e6e6e6-e6e6-e6e6-e6e6-e6e6e6e6e6e6';
 BEGIN
 
+-- Inserted by an earlier run
+IF EXISTS (SELECT 1 FROM teams WHERE id = team_eng_id) THEN
+    RETURN;
+END IF;
+
 -- Insert Teams
 INSERT INTO teams (id, team_name) VALUES
 (team_eng_id, 'Engineering'),
 (team_mkt_id, 'Marketing');
 
 -- Assign Managers to Teams
-INSERT INTO team_managers (team_id, user_id) VALUES
-(team_eng_id, manager_alice_id),
-(team_mkt_id, manager_bob_id);
+INSERT INTO team_managers (team_id, user_id, is_lead) VALUES
+(team_eng_id, manager_alice_id, TRUE),
+(team_mkt_id, manager_bob_id, TRUE);
 
 -- Assign Members to Teams
 INSERT INTO team_members (team_id, user_id) VALUES
@@ -125,14 +357,14 @@ INSERT INTO team_members (team_id, user_id) VALUES
 (team_mkt_id, member_eve_id);
 
 -- Insert Folders
-INSERT INTO folders (folder_id, name, owner_id) VALUES
-(folder_alice_id, 'Project Phoenix Docs', manager_alice_id),
-(folder_carol_id, 'Personal Notes', member_carol_id);
+INSERT INTO folders (folder_id, name, owner_id, last_modified_by) VALUES
+(folder_alice_id, 'Project Phoenix Docs', manager_alice_id, manager_alice_id),
+(folder_carol_id, 'Personal Notes', member_carol_id, member_carol_id);
 
 -- Insert Notes
-INSERT INTO notes (note_id, title, body, folder_id, owner_id) VALUES
-(note_alice_id, 'Q3 Architecture Plan', 'The plan is to use microservices...', folder_alice_id, manager_alice_id),
-(note_carol_id, 'Meeting Summary', 'Discussed project timelines.', folder_carol_id, member_carol_id);
+INSERT INTO notes (note_id, title, body, folder_id, owner_id, last_modified_by) VALUES
+(note_alice_id, 'Q3 Architecture Plan', 'The plan is to use microservices...', folder_alice_id, manager_alice_id, manager_alice_id),
+(note_carol_id, 'Meeting Summary', 'Discussed project timelines.', folder_carol_id, member_carol_id, member_carol_id);
 
 -- Insert Folder and Note Shares
 INSERT INTO folder_shares (folder_id, user_id, access) VALUES
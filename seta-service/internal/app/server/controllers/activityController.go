@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"seta/internal/pkg/auditclient"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
+	"seta/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityController serves the activity feeds of folders and notes, read from the events
+// the auditing-service recorded. Routes check the caller can read the asset.
+type ActivityController struct {
+	audit *auditclient.Client
+}
+
+// NewActivityController creates an ActivityController on the shared audit client.
+func NewActivityController() *ActivityController {
+	return &ActivityController{audit: auditclient.Shared()}
+}
+
+// GetNoteActivity lists the changes of a note, see assetActivity.
+func (ac *ActivityController) GetNoteActivity(c *gin.Context) {
+	ac.assetActivity(c, "note", "noteId")
+}
+
+// GetFolderActivity lists the changes of the folder itself, not of the notes in it.
+func (ac *ActivityController) GetFolderActivity(c *gin.Context) {
+	ac.assetActivity(c, "folder", "folderId")
+}
+
+// assetActivity answers with the asset's events newest first: who created, updated,
+// shared, unshared or deleted it and when. ?since leaves out older events, ?limit
+// (default 50, at most 100) and ?offset page through the rest. Events take a moment to
+// reach the auditing-service, so the latest change may not be listed yet.
+func (ac *ActivityController) assetActivity(c *gin.Context, assetType, param string) {
+	assetID, err := utils.GetUUIDFromParam(c, param)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query := httpquery.New(c.Request.URL.Query())
+	page := query.Pagination(50, 100)
+	since, _ := query.Time("since")
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	activity, err := ac.audit.AssetActivity(c.Request.Context(), assetType, assetID, since, page.Limit, page.Offset)
+	if errors.Is(err, auditclient.ErrNotConfigured) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Activity is not configured"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Activity is unavailable right now"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":  activity.Events,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+		"hasMore": activity.HasMore,
+	})
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/auditclient"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxExportUpload bounds the export accepted by VerifyAuditExport.
+const maxExportUpload = 32 << 20
+
+type AuditController struct {
+	db     *gorm.DB
+	export *services.AuditExportService
+	audit  *auditclient.Client
+}
+
+// NewAuditController creates a new AuditController, injecting the db dependency.
+func NewAuditController(db *gorm.DB) *AuditController {
+	return &AuditController{db: db, export: services.NewAuditExportService(db), audit: auditclient.Shared()}
+}
+
+// ExportTeamAudit streams the team's membership history between ?from and ?to (RFC 3339
+// or YYYY-MM-DD; to defaults to now) as a signed CSV. Only format=csv is supported.
+func (ac *AuditController) ExportTeamAudit(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query := httpquery.New(c.Request.URL.Query())
+	query.Enum("format", "csv", "csv")
+	query.Required("from")
+	from, hasFrom := query.Time("from")
+	to, hasTo := query.Time("to")
+	if !hasTo {
+		to = time.Now().UTC()
+	}
+	if hasFrom && !from.Before(to) {
+		query.Fail("from", "ltfield", "to", "from must be before to")
+	}
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := ac.export.Reserve(userID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrExportRateLimited):
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusTooManyRequests, Message: err.Error()})
+		default:
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Audit export is not configured"})
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("team-%s-audit-%s-%s.csv", teamID, from.Format("20060102"), to.Format("20060102"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure here can only cut the stream short; the
+	// missing signature line makes the truncated file fail verification.
+	if err := ac.export.WriteCSV(c.Request.Context(), c.Writer, teamID, from, to); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// The response is already sent, so the event is only logged if it cannot be recorded.
+	event := kafka.NewTeamEvent("AUDIT_EXPORTED", teamID, userID)
+	event.From, event.To = &from, &to
+	if err := kafka.EnqueueTeamEvent(ac.db.WithContext(c.Request.Context()), event); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// VerifyAuditExport checks the signature of an export uploaded in the "file" form field.
+func (ac *AuditController) VerifyAuditExport(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "File not provided in 'file' form field"})
+		return
+	}
+	if file.Size > maxExportUpload {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusRequestEntityTooLarge, Message: "Export file is too large"})
+		return
+	}
+
+	openedFile, err := file.Open()
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to open uploaded file"})
+		return
+	}
+	defer openedFile.Close()
+
+	contents, err := io.ReadAll(openedFile)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Failed to read uploaded file"})
+		return
+	}
+
+	valid, err := ac.export.Verify(contents)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Audit export is not configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"valid": valid})
+}
+
+// GetAuditStats counts the events the auditing-service recorded per bucket:
+// ?groupBy=eventType, actionBy or day. ?from and ?to (to excluded) bound the event times,
+// ?teamId counts one team's events and ?tz (an IANA zone, default UTC) picks the days
+// events fall on. At most 1000 buckets are returned; truncated is set when there were more.
+func (ac *AuditController) GetAuditStats(c *gin.Context) {
+	query := httpquery.New(c.Request.URL.Query())
+	query.Required("groupBy")
+	stats := auditclient.StatsQuery{GroupBy: query.Enum("groupBy", "", "eventType", "actionBy", "day")}
+	stats.From, _ = query.Time("from")
+	stats.To, _ = query.Time("to")
+	if teamID, ok := query.UUID("teamId"); ok {
+		stats.TeamID = &teamID
+	}
+	if !stats.From.IsZero() && !stats.To.IsZero() && !stats.From.Before(stats.To) {
+		query.Fail("from", "ltfield", "to", "from must be before to")
+	}
+	if tz := c.Query("tz"); tz != "" {
+		// "Local" is this process's zone, not one the auditing-service can know.
+		if _, err := time.LoadLocation(tz); err != nil || tz == "Local" {
+			query.Fail("tz", "timezone", "", "tz must be an IANA time zone such as Europe/Berlin")
+		}
+		stats.TZ = tz
+	}
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result, err := ac.audit.AuditStats(c.Request.Context(), stats)
+	if errors.Is(err, auditclient.ErrNotConfigured) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Audit stats are not configured"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Audit stats are unavailable right now"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
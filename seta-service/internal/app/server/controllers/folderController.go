@@ -1,39 +1,55 @@
 package controllers
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"seta-pkg/database"
+	"seta-pkg/logging"
+	"seta/internal/app/server/services"
 	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
 	"seta/internal/pkg/kafka"
 	"seta/internal/pkg/models"
+	"seta/internal/pkg/userclient"
 	"seta/internal/pkg/utils" // Import the new utils package
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // FolderController no longer embeds BaseController.
 // It now holds its own database connection.
 type FolderController struct {
-	db *gorm.DB
+	db            *gorm.DB
+	authorization *services.AuthorizationService
+	deletion      *services.FolderDeletionService
+	users         *userclient.Client
 }
 
 // NewFolderController creates a new FolderController, injecting the db dependency.
-func NewFolderController(db *gorm.DB) *FolderController {
+func NewFolderController(db *gorm.DB, authorization *services.AuthorizationService, log logging.Logger) *FolderController {
 	return &FolderController{
-		db: db,
+		db:            db,
+		authorization: authorization,
+		deletion:      services.NewFolderDeletionService(db, log),
+		users:         userclient.Shared(),
 	}
 }
 
 type CreateFolderInput struct {
-	Name string `json:"name" binding:"required"`
+	Name           string     `json:"name" binding:"required"`
+	ParentFolderID *uuid.UUID `json:"parentFolderId"`
 }
 
+// CreateFolder creates a folder at the top level or, with parentFolderId, inside a folder
+// the user can write to.
 func (fc *FolderController) CreateFolder(c *gin.Context) {
 	var input CreateFolderInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
@@ -44,28 +60,66 @@ func (fc *FolderController) CreateFolder(c *gin.Context) {
 		return
 	}
 
-	folder := models.Folder{
-		Name:    input.Name,
-		OwnerID: userID,
+	if input.ParentFolderID != nil {
+		if customErr := fc.checkParentWritable(userID, *input.ParentFolderID); customErr != nil {
+			_ = c.Error(customErr)
+			return
+		}
 	}
 
-	if err := fc.db.WithContext(c.Request.Context()).Create(&folder).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to create folder"})
+	folder := models.Folder{
+		Name:           input.Name,
+		OwnerID:        userID,
+		ParentFolderID: input.ParentFolderID,
+	}
+
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if folder.ParentFolderID != nil {
+			if err := lockFolderForNewNote(tx, *folder.ParentFolderID); err != nil {
+				return err
+			}
+			if err := services.LockAndCheckNewSubfolder(tx, *folder.ParentFolderID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&folder).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_CREATED", folder, userID))
+	})
+	if err != nil {
+		_ = c.Error(parentFolderError(err, "Failed to create folder"))
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType: "FOLDER_CREATED",
-		AssetType: "folder",
-		AssetID:   folder.FolderID.String(),
-		OwnerID:   folder.OwnerID.String(),
-		ActionBy:  userID.String(),
-	})
-
 	c.JSON(http.StatusCreated, folder)
 }
 
-// GetFolder retrieves a single folder. Now simplified with utils and auth middleware.
+// FolderNoteSummary is a note as listed inside its folder, without the body.
+type FolderNoteSummary struct {
+	NoteID    uuid.UUID `json:"noteId"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// FolderChildSummary is a subfolder as listed inside its parent.
+type FolderChildSummary struct {
+	FolderID  uuid.UUID `json:"folderId"`
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// FolderWithNotes leaves out the notes key entirely when notes were not requested.
+type FolderWithNotes struct {
+	models.Folder
+	Children []FolderChildSummary `json:"children"`
+	Notes    *[]FolderNoteSummary `json:"notes,omitempty"`
+}
+
+// GetFolder retrieves a single folder with its subfolders by name and a summary of its
+// notes, most recently updated first. ?includeNotes=false leaves out the notes. The
+// response carries an ETag that changes with the folder, its subfolders and its note
+// summaries, for use with If-None-Match.
 func (fc *FolderController) GetFolder(c *gin.Context) {
 	folderID, err := utils.GetUUIDFromParam(c, "folderId")
 	if err != nil {
@@ -73,20 +127,62 @@ func (fc *FolderController) GetFolder(c *gin.Context) {
 		return
 	}
 
-	var folder models.Folder
-	if err := fc.db.WithContext(c.Request.Context()).First(&folder, "folder_id = ?", folderID).Error; err != nil {
+	query := httpquery.New(c.Request.URL.Query())
+	includeNotes := query.Bool("includeNotes", true)
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result := FolderWithNotes{}
+	err = database.Read(c.Request.Context(), fc.db, func(tx *gorm.DB) error {
+		if err := tx.First(&result.Folder, "folder_id = ?", folderID).Error; err != nil {
+			return err
+		}
+		// Reading a folder grants reading its subfolders, so they are all listed.
+		result.Children = make([]FolderChildSummary, 0)
+		if err := tx.Model(&models.Folder{}).
+			Select("folder_id", "name", "updated_at").
+			Where("parent_folder_id = ? AND deletion_pending = ?", folderID, false).
+			Order("name, folder_id").
+			Scan(&result.Children).Error; err != nil {
+			return err
+		}
+		if !includeNotes {
+			return nil
+		}
+		// Anyone who can read the folder can read every note in it, so no per-note check.
+		notes := make([]FolderNoteSummary, 0)
+		if err := tx.Model(&models.Note{}).
+			Select("note_id", "title", "updated_at").
+			Where("folder_id = ?", folderID).
+			Order("updated_at DESC, note_id DESC").
+			Scan(&notes).Error; err != nil {
+			return err
+		}
+		result.Notes = &notes
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
 		return
 	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve folder"})
+		return
+	}
 
-	c.JSON(http.StatusOK, folder)
+	utils.JSONWithETag(c, http.StatusOK, result)
 }
 
 type UpdateFolderInput struct {
-	Name string `json:"name" binding:"required"`
+	Name *string `json:"name" binding:"omitempty,min=1"`
+	// ParentFolderID moves the folder under another one, or to the top level when null.
+	ParentFolderID utils.OptionalUUID `json:"parentFolderId"`
 }
 
-// UpdateFolder updates a folder's name. Simplified with utils and auth middleware.
+// UpdateFolder renames and/or moves a folder. Only the owner may move it, into a folder
+// they can write to that is not the folder itself or below it.
 func (fc *FolderController) UpdateFolder(c *gin.Context) {
 	folderID, err := utils.GetUUIDFromParam(c, "folderId")
 	if err != nil {
@@ -107,28 +203,119 @@ func (fc *FolderController) UpdateFolder(c *gin.Context) {
 	}
 
 	var input UpdateFolderInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
 		return
 	}
-
-	if err := fc.db.WithContext(c.Request.Context()).Model(&folder).Update("name", input.Name).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to update folder"})
+	if input.Name == nil && !input.ParentFolderID.Set {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Nothing to update: pass name or parentFolderId"})
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType: "FOLDER_UPDATED",
-		AssetType: "folder",
-		AssetID:   folderID.String(),
-		OwnerID:   userID.String(),
-		ActionBy:  userID.String(),
+	updates := map[string]any{}
+	if input.Name != nil {
+		updates["name"] = *input.Name
+	}
+	newParent := input.ParentFolderID.Value
+	moving := input.ParentFolderID.Set && !sameFolderID(newParent, folder.ParentFolderID)
+	if moving {
+		if folder.OwnerID != userID {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "Only the folder owner can move it"})
+			return
+		}
+		if newParent != nil {
+			if *newParent == folder.FolderID {
+				_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "A folder cannot be moved inside itself or its subfolders"})
+				return
+			}
+			if customErr := fc.checkParentWritable(userID, *newParent); customErr != nil {
+				_ = c.Error(customErr)
+				return
+			}
+		}
+		updates["parent_folder_id"] = newParent
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusOK, folder)
+		return
+	}
+	updates["last_modified_by"] = userID
+
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if moving && newParent != nil {
+			if err := lockFolderForNewNote(tx, *newParent); err != nil {
+				return err
+			}
+			if err := services.LockAndCheckFolderMove(tx, folder.FolderID, *newParent); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&folder).Updates(updates).Error; err != nil {
+			return err
+		}
+		if input.Name != nil {
+			folder.Name = *input.Name
+		}
+		if moving {
+			folder.ParentFolderID = newParent
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_UPDATED", folder, userID))
 	})
+	if err != nil {
+		_ = c.Error(parentFolderError(err, "Failed to update folder"))
+		return
+	}
 
+	// Access to a folder comes with its parents, so moving it can change who has any.
+	if moving {
+		fc.authorization.Purge()
+	}
 	c.JSON(http.StatusOK, folder)
 }
 
-// DeleteFolder deletes a folder. Simplified with utils and auth middleware.
+// checkParentWritable reports an error unless the user can write to the folder a new or
+// moved folder goes into.
+func (fc *FolderController) checkParentWritable(userID, parentID uuid.UUID) *errorHandling.CustomError {
+	canWrite, customErr := fc.authorization.CanWriteAsset(userID, "folder", parentID)
+	if customErr != nil {
+		if customErr.Code == http.StatusNotFound {
+			return &errorHandling.CustomError{Code: http.StatusNotFound, Message: "Parent folder not found"}
+		}
+		return customErr
+	}
+	if !canWrite {
+		return &errorHandling.CustomError{Code: http.StatusForbidden, Message: "You do not have write access to the parent folder"}
+	}
+	return nil
+}
+
+// parentFolderError turns the error of a transaction that put a folder into a parent
+// locked with lockFolderForNewNote into a response, reporting anything else with message.
+func parentFolderError(err error, message string) *errorHandling.CustomError {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), database.IsForeignKeyViolation(err):
+		return &errorHandling.CustomError{Code: http.StatusNotFound, Message: "Parent folder not found"}
+	case errors.Is(err, errFolderPendingDeletion):
+		return &errorHandling.CustomError{Code: http.StatusConflict, Message: "Parent folder is being deleted"}
+	case errors.Is(err, services.ErrFolderCycle):
+		return &errorHandling.CustomError{Code: http.StatusConflict, Message: "A folder cannot be moved inside itself or its subfolders"}
+	case errors.Is(err, services.ErrFolderTooDeep):
+		return &errorHandling.CustomError{Code: http.StatusConflict, Message: "Folder would be nested too deep"}
+	default:
+		return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: message}
+	}
+}
+
+func sameFolderID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// DeleteFolder deletes a folder with its notes and shares. A folder with subfolders is
+// only deleted with ?recursive=true, which removes the whole subtree in one transaction;
+// the background job is for large folders without subfolders.
 func (fc *FolderController) DeleteFolder(c *gin.Context) {
 	folderID, err := utils.GetUUIDFromParam(c, "folderId")
 	if err != nil {
@@ -136,6 +323,13 @@ func (fc *FolderController) DeleteFolder(c *gin.Context) {
 		return
 	}
 
+	query := httpquery.New(c.Request.URL.Query())
+	recursive := query.Bool("recursive", false)
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
 	actorUserID, err := utils.GetUserUUIDFromContext(c)
 	if err != nil {
 		_ = c.Error(err)
@@ -148,43 +342,183 @@ func (fc *FolderController) DeleteFolder(c *gin.Context) {
 		return
 	}
 
+	// A job whose heartbeat stopped is marked failed here, so deleting again retries it.
+	if folder.DeletionPending {
+		running, err := fc.deletion.RunningJob(c.Request.Context(), folderID)
+		if err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to check folder state"})
+			return
+		}
+		if running != nil {
+			_ = c.Error(folderDeletionRunningError(*running))
+			return
+		}
+	}
+
+	var childCount int64
+	if err := fc.db.WithContext(c.Request.Context()).Model(&models.Folder{}).Where("parent_folder_id = ?", folderID).Count(&childCount).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to check folder state"})
+		return
+	}
+	if childCount > 0 && !recursive {
+		_ = c.Error(folderHasSubfoldersError())
+		return
+	}
+
+	var noteCount int64
+	if err := fc.db.WithContext(c.Request.Context()).Model(&models.Note{}).Where("folder_id = ?", folderID).Count(&noteCount).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to count folder notes"})
+		return
+	}
+
+	// Large folders (and retries of a failed job) are deleted in batches by a background job.
+	if childCount == 0 && (folder.DeletionPending || fc.deletion.ShouldRunAsync(noteCount)) {
+		job, err := fc.deletion.Start(c.Request.Context(), folder, noteCount, actorUserID)
+		if errors.Is(err, services.ErrFolderDeletionRunning) {
+			_ = c.Error(folderDeletionRunningError(job))
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
+			return
+		}
+		if err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to start folder deletion"})
+			return
+		}
+		fc.authorization.Purge()
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
 	tx := fc.db.WithContext(c.Request.Context()).Begin()
-	// ... (transaction logic remains the same)
-	if err := tx.Where("folder_id = ?", folder.FolderID).Delete(&models.Note{}).Error; err != nil {
+	// Locking the folder first makes a concurrent CreateNote or CreateFolder into it either
+	// commit before the contents are listed below or wait and then find the folder gone.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&folder, "folder_id = ?", folder.FolderID).Error; err != nil {
 		tx.Rollback()
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete associated notes"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
+			return
+		}
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete folder"})
 		return
 	}
-	if err := tx.Where("folder_id = ?", folder.FolderID).Delete(&models.FolderShare{}).Error; err != nil {
+	descendants, err := services.FolderDescendants(tx, folder.FolderID)
+	if err != nil {
 		tx.Rollback()
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete associated shares"})
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to list subfolders"})
 		return
 	}
-	if err := tx.Delete(&folder).Error; err != nil {
+	if len(descendants) > 0 && !recursive {
 		tx.Rollback()
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete folder"})
+		_ = c.Error(folderHasSubfoldersError())
 		return
 	}
 
+	// Subfolders go first, deepest first, so no folder is deleted while it has children.
+	subtree := make([]models.Folder, 0, len(descendants)+1)
+	for _, descendant := range descendants {
+		if descendant.DeletionPending {
+			tx.Rollback()
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "A subfolder is being deleted"})
+			return
+		}
+		var sub models.Folder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sub, "folder_id = ?", descendant.FolderID).Error; err != nil {
+			tx.Rollback()
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete folder"})
+			return
+		}
+		subtree = append(subtree, sub)
+	}
+	subtree = append(subtree, folder)
+
+	for _, doomed := range subtree {
+		if customErr := deleteFolderTx(tx, doomed, actorUserID); customErr != nil {
+			tx.Rollback()
+			_ = c.Error(customErr)
+			return
+		}
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to commit transaction"})
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType: "FOLDER_DELETED",
-		AssetType: "folder",
-		AssetID:   folderID.String(),
-		OwnerID:   folder.OwnerID.String(),
-		ActionBy:  actorUserID.String(),
-	})
-
+	fc.authorization.Purge()
 	c.Status(http.StatusNoContent)
 }
 
+func folderDeletionRunningError(job models.FolderDeletionJob) *errorHandling.CustomError {
+	return &errorHandling.CustomError{Code: http.StatusConflict, Message: "Folder deletion already in progress (jobId: " + job.JobID.String() + ")"}
+}
+
+func folderHasSubfoldersError() *errorHandling.CustomError {
+	return &errorHandling.CustomError{Code: http.StatusConflict, Message: "Folder has subfolders; pass recursive=true to delete them too"}
+}
+
+// deleteFolderTx removes one locked folder with its notes and shares inside tx and records
+// the FOLDER_NOTES_DELETED and FOLDER_DELETED events.
+func deleteFolderTx(tx *gorm.DB, folder models.Folder, actorUserID uuid.UUID) *errorHandling.CustomError {
+	// The note IDs go out in a FOLDER_NOTES_DELETED event so consumers drop the notes too.
+	// Notes in the trash go with the folder, so Unscoped.
+	var noteIDs []uuid.UUID
+	if err := tx.Unscoped().Model(&models.Note{}).Where("folder_id = ?", folder.FolderID).Pluck("note_id", &noteIDs).Error; err != nil {
+		return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to list associated notes"}
+	}
+	if err := tx.Unscoped().Where("folder_id = ?", folder.FolderID).Delete(&models.Note{}).Error; err != nil {
+		return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete associated notes"}
+	}
+	if len(noteIDs) > 0 {
+		if err := kafka.EnqueueAssetEvent(tx, kafka.NewFolderNotesDeletedEvent(folder, noteIDs, actorUserID)); err != nil {
+			return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to record folder event"}
+		}
+	}
+	if err := tx.Where("folder_id = ?", folder.FolderID).Delete(&models.FolderShare{}).Error; err != nil {
+		return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete associated shares"}
+	}
+	if err := tx.Delete(&folder).Error; err != nil {
+		return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete folder"}
+	}
+	if err := kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_DELETED", folder, actorUserID)); err != nil {
+		return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to record folder event"}
+	}
+	return nil
+}
+
+// GetDeletionJob reports the progress of a background folder deletion to the user who started it.
+func (fc *FolderController) GetDeletionJob(c *gin.Context) {
+	jobID, err := utils.GetUUIDFromParam(c, "jobId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	job, err := fc.deletion.Job(c.Request.Context(), userID, jobID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Deletion job not found"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to load deletion job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ShareFolderInput names the user by userId or by email.
 type ShareFolderInput struct {
-	UserID uuid.UUID `json:"userId" binding:"required"`
-	Access string    `json:"access" binding:"required"`
+	UserID uuid.UUID `json:"userId"`
+	Email  string    `json:"email" binding:"omitempty,email"`
+	Access string    `json:"access" binding:"required,oneof=read write"`
 }
 
 // ShareFolder shares a folder. Simplified with utils and auth middleware.
@@ -201,33 +535,124 @@ func (fc *FolderController) ShareFolder(c *gin.Context) {
 		return
 	}
 
+	var folder models.Folder
+	if err := fc.db.WithContext(c.Request.Context()).First(&folder, "folder_id = ?", folderID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
+		return
+	}
+
 	var input ShareFolderInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !models.ValidAccess(input.Access) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Access must be read or write"})
+		return
+	}
+	targetUserID, customErr := shareUser(c.Request.Context(), fc.users, input.UserID, input.Email)
+	if customErr != nil {
+		_ = c.Error(customErr)
 		return
 	}
 
 	share := models.FolderShare{
 		FolderID: folderID,
-		UserID:   input.UserID,
+		UserID:   targetUserID,
 		Access:   input.Access,
 	}
 
-	if err := fc.db.WithContext(c.Request.Context()).Create(&share).Error; err != nil {
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		// Sharing again with the same user changes their access level.
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "folder_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"access"}),
+		}).Create(&share).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_SHARED", folder, actorUserID).WithTarget(targetUserID))
+	})
+	if err != nil {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to share folder"})
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType:    "FOLDER_SHARED",
-		AssetType:    "folder",
-		AssetID:      folderID.String(),
-		OwnerID:      actorUserID.String(), // The actor is the owner
-		ActionBy:     actorUserID.String(),
-		TargetUserID: input.UserID.String(),
+	fc.authorization.Purge()
+	c.Status(http.StatusNoContent)
+}
+
+// ShareFolderBatch shares a folder with several users at once and reports the outcome
+// for each of them. One FOLDER_SHARED event is emitted per user whose access changed.
+func (fc *FolderController) ShareFolderBatch(c *gin.Context) {
+	folderID, err := utils.GetUUIDFromParam(c, "folderId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var folder models.Folder
+	if err := fc.db.WithContext(c.Request.Context()).First(&folder, "folder_id = ?", folderID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
+		return
+	}
+
+	var input ShareBatchInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if customErr := resolveBatchEmails(c.Request.Context(), fc.users, input.Shares); customErr != nil {
+		_ = c.Error(customErr)
+		return
+	}
+
+	var summary ShareBatchSummary
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var current []models.FolderShare
+		if err := tx.Where("folder_id = ? AND user_id IN ?", folderID, batchUserIDs(input.Shares)).Find(&current).Error; err != nil {
+			return err
+		}
+		existing := make(map[uuid.UUID]string, len(current))
+		for _, share := range current {
+			existing[share.UserID] = share.Access
+		}
+
+		var writes []ShareBatchItem
+		summary, writes = planShareBatch(input.Shares, existing)
+		if len(writes) == 0 {
+			return nil
+		}
+
+		shares := make([]models.FolderShare, len(writes))
+		for i, item := range writes {
+			shares[i] = models.FolderShare{FolderID: folderID, UserID: item.UserID, Access: item.Access}
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "folder_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"access"}),
+		}).Create(&shares).Error; err != nil {
+			return err
+		}
+		for _, item := range writes {
+			if err := kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_SHARED", folder, actorUserID).WithTarget(item.UserID)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to share folder"})
+		return
+	}
 
-	c.Status(http.StatusNoContent)
+	fc.authorization.Purge()
+	c.JSON(http.StatusOK, summary)
 }
 
 // RevokeFolderSharing removes a user's access. Simplified with utils and auth middleware.
@@ -249,38 +674,185 @@ func (fc *FolderController) RevokeFolderSharing(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
-	
-	result := fc.db.WithContext(c.Request.Context()).
-		Where("folder_id = ? AND user_id = ?", folderID, targetUserID).
-		Delete(&models.FolderShare{})
 
-	if result.Error != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to revoke folder share"})
+	var folder models.Folder
+	if err := fc.db.WithContext(c.Request.Context()).First(&folder, "folder_id = ?", folderID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
 		return
 	}
-	if result.RowsAffected == 0 {
+
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("folder_id = ? AND user_id = ?", folderID, targetUserID).Delete(&models.FolderShare{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_UNSHARED", folder, actorUserID).WithTarget(targetUserID))
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Sharing record not found for this user and folder"})
 		return
 	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to revoke folder share"})
+		return
+	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType:    "FOLDER_UNSHARED",
-		AssetType:    "folder",
-		AssetID:      folderID.String(),
-		OwnerID:      actorUserID.String(), // The actor is the owner
-		ActionBy:     actorUserID.String(),
-		TargetUserID: targetUserID.String(),
+	fc.authorization.Purge()
+	c.Status(http.StatusNoContent)
+}
+
+type UpdateFolderSettingsInput struct {
+	AllowNoteSharing *bool `json:"allowNoteSharing" binding:"required"`
+}
+
+// UpdateFolderSettings lets the owner change per-folder settings, currently only whether
+// owners of notes inside the folder may share them.
+func (fc *FolderController) UpdateFolderSettings(c *gin.Context) {
+	folderID, err := utils.GetUUIDFromParam(c, "folderId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var input UpdateFolderSettingsInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var folder models.Folder
+	if err := fc.db.WithContext(c.Request.Context()).First(&folder, "folder_id = ?", folderID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
+		return
+	}
+
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&folder).Updates(map[string]any{"allow_note_sharing": *input.AllowNoteSharing, "last_modified_by": userID}).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_UPDATED", folder, userID))
 	})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to update folder settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// NoteShareInFolder is a note-level share on a note inside the folder.
+type NoteShareInFolder struct {
+	NoteID      uuid.UUID `json:"noteId"`
+	NoteTitle   string    `json:"noteTitle"`
+	NoteOwnerID uuid.UUID `json:"noteOwnerId"`
+	UserID      uuid.UUID `json:"userId"`
+	Access      string    `json:"access"`
+}
+
+// ListFolderShares lists who the folder is shared with and, so the folder owner can see
+// and revoke them, every note-level share on notes inside it, whoever the note owner is.
+func (fc *FolderController) ListFolderShares(c *gin.Context) {
+	folderID, err := utils.GetUUIDFromParam(c, "folderId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 
+	folderShares := make([]models.FolderShare, 0)
+	if err := fc.db.WithContext(c.Request.Context()).Where("folder_id = ?", folderID).Find(&folderShares).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to list folder shares"})
+		return
+	}
+
+	noteShares := make([]NoteShareInFolder, 0)
+	err = fc.db.WithContext(c.Request.Context()).
+		Table("note_shares ns").
+		Select("ns.note_id, n.title AS note_title, n.owner_id AS note_owner_id, ns.user_id, ns.access").
+		Joins("JOIN notes n ON n.note_id = ns.note_id").
+		Where("n.folder_id = ? AND n.deleted_at IS NULL", folderID).
+		Order("n.title, ns.user_id").
+		Scan(&noteShares).Error
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to list note shares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"folderShares": folderShares, "noteShares": noteShares})
+}
+
+// RevokeNoteShareInFolder lets the folder owner remove a note-level share on a note
+// inside their folder, even when someone else owns the note.
+func (fc *FolderController) RevokeNoteShareInFolder(c *gin.Context) {
+	folderID, err := utils.GetUUIDFromParam(c, "folderId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	noteID, err := utils.GetUUIDFromParam(c, "noteId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	targetUserID, err := utils.GetUUIDFromParam(c, "userId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var note models.Note
+	if err := fc.db.WithContext(c.Request.Context()).First(&note, "note_id = ? AND folder_id = ?", noteID, folderID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Note not found in this folder"})
+		return
+	}
+
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("note_id = ? AND user_id = ?", noteID, targetUserID).Delete(&models.NoteShare{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_UNSHARED", note, actorUserID).WithTarget(targetUserID))
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Sharing record not found for this user and note"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to revoke note share"})
+		return
+	}
+
+	fc.authorization.Purge()
 	c.Status(http.StatusNoContent)
 }
 
 type CreateNoteInput struct {
-	Title string `json:"title" binding:"required"`
-	Body  string `json:"body"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	TemplateID *uuid.UUID `json:"templateId"`
 }
 
 // CreateNote creates a new note inside a folder. Simplified with utils and auth middleware.
+// With a templateId, missing title/body are rendered from the template.
 func (fc *FolderController) CreateNote(c *gin.Context) {
 	folderID, err := utils.GetUUIDFromParam(c, "folderId")
 	if err != nil {
@@ -289,8 +861,8 @@ func (fc *FolderController) CreateNote(c *gin.Context) {
 	}
 
 	var input CreateNoteInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
@@ -307,18 +879,106 @@ func (fc *FolderController) CreateNote(c *gin.Context) {
 		OwnerID:  userID,
 	}
 
-	if err := fc.db.WithContext(c.Request.Context()).Create(&note).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to create note"})
+	if input.TemplateID != nil {
+		if customErr := fc.applyTemplate(c, *input.TemplateID, userID, &note); customErr != nil {
+			_ = c.Error(customErr)
+			return
+		}
+	}
+
+	if note.Title == "" {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "title is required"})
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType: "NOTE_CREATED",
-		AssetType: "note",
-		AssetID:   note.NoteID.String(),
-		OwnerID:   note.OwnerID.String(),
-		ActionBy:  userID.String(),
+	err = fc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := lockFolderForNewNote(tx, folderID); err != nil {
+			return err
+		}
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+		if input.TemplateID != nil {
+			err := tx.Model(&models.NoteTemplate{}).
+				Where("template_id = ?", *input.TemplateID).
+				Update("usage_count", gorm.Expr("usage_count + 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_CREATED", note, userID))
 	})
+	if err != nil {
+		_ = c.Error(newNoteError(err, "Failed to create note"))
+		return
+	}
 
 	c.JSON(http.StatusCreated, note)
-}
\ No newline at end of file
+}
+
+// errFolderPendingDeletion is returned inside a transaction for a folder that a
+// background job is deleting.
+var errFolderPendingDeletion = errors.New("folder is pending deletion")
+
+// lockFolderForNewNote holds a share lock on the folder row until tx ends and checks the
+// folder can still take notes. DeleteFolder locks the row for update before listing the
+// notes, so a note is never created into a folder while it is being deleted.
+func lockFolderForNewNote(tx *gorm.DB, folderID uuid.UUID) error {
+	var folder models.Folder
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("folder_id", "deletion_pending").First(&folder, "folder_id = ?", folderID).Error; err != nil {
+		return err
+	}
+	if folder.DeletionPending {
+		return errFolderPendingDeletion
+	}
+	return nil
+}
+
+// newNoteError turns the error of a transaction that created a note in a folder locked
+// with lockFolderForNewNote into a response, reporting anything else with message.
+func newNoteError(err error, message string) *errorHandling.CustomError {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), database.IsForeignKeyViolation(err):
+		return &errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"}
+	case errors.Is(err, errFolderPendingDeletion):
+		return &errorHandling.CustomError{Code: http.StatusConflict, Message: "Folder is being deleted"}
+	default:
+		return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: message}
+	}
+}
+
+// applyTemplate fills the note's empty title/body from a template the user can read.
+func (fc *FolderController) applyTemplate(c *gin.Context, templateID, userID uuid.UUID, note *models.Note) *errorHandling.CustomError {
+	canRead, customErr := fc.authorization.CanReadTemplate(userID, templateID)
+	if customErr != nil {
+		return customErr
+	}
+	if !canRead {
+		return &errorHandling.CustomError{Code: http.StatusForbidden, Message: "You are not authorized to use this template"}
+	}
+
+	var template models.NoteTemplate
+	if err := fc.db.WithContext(c.Request.Context()).First(&template, "template_id = ?", templateID).Error; err != nil {
+		return &errorHandling.CustomError{Code: http.StatusNotFound, Message: "Template not found"}
+	}
+
+	vars := map[string]string{
+		"date":     time.Now().UTC().Format("2006-01-02"),
+		"username": c.GetString("username"),
+		"teamName": "",
+	}
+	if template.TeamID != nil {
+		var team models.Team
+		if err := fc.db.WithContext(c.Request.Context()).First(&team, "id = ?", *template.TeamID).Error; err == nil {
+			vars["teamName"] = team.TeamName
+		}
+	}
+
+	if note.Title == "" {
+		note.Title = services.RenderTemplate(template.TitleTemplate, vars)
+	}
+	if note.Body == "" {
+		note.Body = services.RenderTemplate(template.BodyTemplate, vars)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"seta-pkg/events"
+	"seta-pkg/logging"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// newTestRouter returns a router that treats every request as made by userID, as the
+// auth middleware would after checking a token.
+func newTestRouter(userID uuid.UUID) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(errorHandling.ErrorHandler(logging.Nop()))
+	r.Use(func(c *gin.Context) {
+		c.Set("userId", userID.String())
+		c.Set("role", "MANAGER")
+		c.Next()
+	})
+	return r
+}
+
+// serve sends a request with body encoded as JSON, unless it is nil, and returns the
+// response. It is called from goroutines, so it reports errors without stopping the test.
+func serve(t testing.TB, r http.Handler, method, path string, body any, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	var encoded bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&encoded).Encode(body); err != nil {
+			t.Errorf("encode request body: %v", err)
+			return httptest.NewRecorder()
+		}
+	}
+	req := httptest.NewRequest(method, path, &encoded)
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func createTestFolder(t testing.TB, db *gorm.DB, ownerID uuid.UUID) models.Folder {
+	t.Helper()
+	folder := models.Folder{Name: "Race", OwnerID: ownerID, LastModifiedBy: ownerID}
+	if err := db.Omit("Owner").Create(&folder).Error; err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+	return folder
+}
+
+// A note created while its folder is deleted must either be refused or be deleted with
+// the folder and listed in its FOLDER_NOTES_DELETED event; otherwise the cascade removes
+// it behind the consumers' backs.
+func TestCreateNoteRacingDeleteFolder(t *testing.T) {
+	db := testdb.Open(t)
+	ownerID := uuid.New()
+	fc := NewFolderController(db, services.NewCachedAuthorizationService(db, logging.Nop()), logging.Nop())
+	r := newTestRouter(ownerID)
+	r.POST("/folders/:folderId/notes", fc.CreateNote)
+	r.DELETE("/folders/:folderId", fc.DeleteFolder)
+
+	const rounds, creators = 20, 8
+	for round := 0; round < rounds; round++ {
+		folder := createTestFolder(t, db, ownerID)
+		path := "/folders/" + folder.FolderID.String()
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			created []uuid.UUID
+		)
+		start := make(chan struct{})
+		for i := 0; i < creators; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				rec := serve(t, r, http.MethodPost, path+"/notes", gin.H{"title": "Racing"}, nil)
+				switch rec.Code {
+				case http.StatusCreated:
+					var note models.Note
+					if err := json.Unmarshal(rec.Body.Bytes(), &note); err != nil {
+						t.Errorf("decode created note: %v", err)
+						return
+					}
+					mu.Lock()
+					created = append(created, note.NoteID)
+					mu.Unlock()
+				case http.StatusNotFound, http.StatusConflict:
+				default:
+					t.Errorf("CreateNote: status %d, body %s", rec.Code, rec.Body)
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if rec := serve(t, r, http.MethodDelete, path, nil, nil); rec.Code != http.StatusNoContent {
+				t.Errorf("DeleteFolder: status %d, body %s", rec.Code, rec.Body)
+			}
+		}()
+		close(start)
+		wg.Wait()
+		if t.Failed() {
+			return
+		}
+
+		var left int64
+		if err := db.Unscoped().Model(&models.Note{}).Where("folder_id = ?", folder.FolderID).Count(&left).Error; err != nil {
+			t.Fatalf("count notes: %v", err)
+		}
+		if left != 0 {
+			t.Fatalf("round %d: %d notes outlived their folder", round, left)
+		}
+
+		var payloads []string
+		err := db.Model(&models.OutboxEvent{}).
+			Where("message_key = ? AND payload->>'eventType' = ?", folder.FolderID.String(), "FOLDER_NOTES_DELETED").
+			Pluck("payload", &payloads).Error
+		if err != nil {
+			t.Fatalf("load folder events: %v", err)
+		}
+		announced := make(map[string]bool)
+		for _, payload := range payloads {
+			var event struct {
+				AssetIDs []string `json:"assetIds"`
+			}
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				t.Fatalf("decode folder event: %v", err)
+			}
+			for _, id := range event.AssetIDs {
+				announced[id] = true
+			}
+		}
+		for _, id := range created {
+			if !announced[id.String()] {
+				t.Errorf("round %d: note %s was deleted without a FOLDER_NOTES_DELETED event", round, id)
+			}
+		}
+	}
+}
+
+func TestGetFolderETag(t *testing.T) {
+	db := testdb.Open(t)
+	ownerID := uuid.New()
+	folder := createTestFolder(t, db, ownerID)
+	fc := NewFolderController(db, services.NewCachedAuthorizationService(db, logging.Nop()), logging.Nop())
+	r := newTestRouter(ownerID)
+	r.GET("/folders/:folderId", fc.GetFolder)
+	r.POST("/folders/:folderId/notes", fc.CreateNote)
+	path := "/folders/" + folder.FolderID.String()
+
+	rec := serve(t, r, http.MethodGet, path, nil, nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status %d, ETag %q; want 200 with an ETag", rec.Code, etag)
+	}
+	ifNoneMatch := http.Header{"If-None-Match": {etag}}
+	if rec := serve(t, r, http.MethodGet, path, nil, ifNoneMatch); rec.Code != http.StatusNotModified {
+		t.Fatalf("unchanged folder: status %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	// A new note changes the note summaries, and so the ETag.
+	if rec := serve(t, r, http.MethodPost, path+"/notes", gin.H{"title": "New"}, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("create note: status %d, body %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, r, http.MethodGet, path, nil, ifNoneMatch)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("changed folder with the old ETag: status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("ETag"); got == "" || got == etag {
+		t.Errorf("ETag after a new note = %q, want a new one (was %q)", got, etag)
+	}
+}
+
+// checkShareAccess shares an asset at path with a new user again and again, checking
+// that only read and write are accepted and that sharing again changes the user's one
+// share. accesses lists the access levels of the user's shares of the asset.
+func checkShareAccess(t *testing.T, r http.Handler, path string, accesses func(userID uuid.UUID) []string) {
+	t.Helper()
+	userID := uuid.New()
+	steps := []struct {
+		access any
+		code   int
+		want   []string
+	}{
+		{"banana", http.StatusBadRequest, nil},
+		{"", http.StatusBadRequest, nil},
+		{"READ", http.StatusBadRequest, nil},
+		{"owner", http.StatusBadRequest, nil},
+		{"read ", http.StatusBadRequest, nil},
+		{nil, http.StatusBadRequest, nil},
+		{7, http.StatusBadRequest, nil},
+		{"read", http.StatusNoContent, []string{"read"}},
+		{"write", http.StatusNoContent, []string{"write"}}, // upgrade
+		{"write", http.StatusNoContent, []string{"write"}}, // same again
+		{"read", http.StatusNoContent, []string{"read"}},   // downgrade
+		{"banana", http.StatusBadRequest, []string{"read"}},
+	}
+	for i, step := range steps {
+		body := gin.H{"userId": userID}
+		if step.access != nil {
+			body["access"] = step.access
+		}
+		rec := serve(t, r, http.MethodPost, path, body, nil)
+		if rec.Code != step.code {
+			t.Fatalf("step %d: sharing with access %#v answered %d, want %d, body %s", i, step.access, rec.Code, step.code, rec.Body)
+		}
+		if got := accesses(userID); !slices.Equal(got, step.want) {
+			t.Fatalf("step %d: after sharing with access %#v the user has shares %v, want %v", i, step.access, got, step.want)
+		}
+	}
+}
+
+func TestShareFolderAccess(t *testing.T) {
+	db := testdb.Open(t)
+	ownerID := uuid.New()
+	folder := createTestFolder(t, db, ownerID)
+	fc := NewFolderController(db, services.NewCachedAuthorizationService(db, logging.Nop()), logging.Nop())
+	r := newTestRouter(ownerID)
+	r.POST("/folders/:folderId/share", fc.ShareFolder)
+
+	checkShareAccess(t, r, "/folders/"+folder.FolderID.String()+"/share", func(userID uuid.UUID) []string {
+		var accesses []string
+		if err := db.Model(&models.FolderShare{}).Where("folder_id = ? AND user_id = ?", folder.FolderID, userID).Pluck("access", &accesses).Error; err != nil {
+			t.Fatalf("list shares: %v", err)
+		}
+		return accesses
+	})
+}
+
+// lastOutboxEvent returns the latest event of eventType the outbox holds for key, the ID
+// of the asset or team it describes.
+func lastOutboxEvent(t testing.TB, db *gorm.DB, key uuid.UUID, eventType string) events.Payload {
+	t.Helper()
+	var rows []models.OutboxEvent
+	if err := db.Where("message_key = ?", key.String()).Order("id DESC").Find(&rows).Error; err != nil {
+		t.Fatalf("list outbox events: %v", err)
+	}
+	for _, row := range rows {
+		var event events.Payload
+		if err := json.Unmarshal([]byte(row.Payload), &event); err != nil {
+			t.Fatalf("decode outbox event %d: %v", row.ID, err)
+		}
+		if event.EventType == eventType {
+			return event
+		}
+	}
+	t.Fatalf("outbox has no %s event for %s", eventType, key)
+	return events.Payload{}
+}
+
+// A folder edited by a user it is shared with names its owner as owner and the editor
+// as the one who acted.
+func TestUpdateFolderEventNamesOwnerAndActor(t *testing.T) {
+	db := testdb.Open(t)
+	ownerID, writerID := uuid.New(), uuid.New()
+	folder := createTestFolder(t, db, ownerID)
+	if err := db.Create(&models.FolderShare{FolderID: folder.FolderID, UserID: writerID, Access: models.AccessWrite}).Error; err != nil {
+		t.Fatalf("share folder: %v", err)
+	}
+	fc := NewFolderController(db, services.NewCachedAuthorizationService(db, logging.Nop()), logging.Nop())
+	r := newTestRouter(writerID)
+	r.PUT("/folders/:folderId", fc.UpdateFolder)
+
+	if rec := serve(t, r, http.MethodPut, "/folders/"+folder.FolderID.String(), gin.H{"name": "Renamed"}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("update folder: status %d, body %s", rec.Code, rec.Body)
+	}
+	event := lastOutboxEvent(t, db, folder.FolderID, "FOLDER_UPDATED")
+	if event.OwnerID != ownerID.String() {
+		t.Errorf("OwnerID = %s, want the folder's owner %s", event.OwnerID, ownerID)
+	}
+	if event.ActionBy != writerID.String() {
+		t.Errorf("ActionBy = %s, want the writer %s", event.ActionBy, writerID)
+	}
+	if event.TargetUserID != "" {
+		t.Errorf("TargetUserID = %q, want none", event.TargetUserID)
+	}
+}
@@ -0,0 +1,487 @@
+package controllers
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"seta-pkg/buildinfo"
+	"seta-pkg/events"
+	"seta-pkg/logging"
+	"seta/internal/app/server/middlewares"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/config"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxReplayBatchSize bounds how many assets a single replay request may touch.
+const maxReplayBatchSize = 100
+
+// InternalController serves operator-only endpoints mounted under /internal.
+type InternalController struct {
+	db    *gorm.DB
+	log   logging.Logger
+	flags *services.FeatureFlagService
+	smoke *services.SmokeTest
+}
+
+// NewInternalController creates a new InternalController, injecting the db and logger
+// dependencies. The smoke test checks access through authorization.
+func NewInternalController(db *gorm.DB, authorization *services.AuthorizationService, log logging.Logger) *InternalController {
+	return &InternalController{db: db, log: log, flags: services.NewFeatureFlagService(db), smoke: services.NewSmokeTest(db, authorization)}
+}
+
+// RunSmokeTest runs a synthetic end-to-end cycle through the database and outbox and
+// reports each step, with 503 if any failed. See services.SmokeTest.
+func (ic *InternalController) RunSmokeTest(c *gin.Context) {
+	report := ic.smoke.Run(c.Request.Context())
+	if !report.Passed {
+		ic.log.Warn("Smoke test failed", logging.Fields{"run_id": report.RunID.String(), "steps": report.Steps})
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetInfo reports the build, effective settings and Kafka wiring of this instance.
+func (ic *InternalController) GetInfo(c *gin.Context) {
+	producer := kafka.ConfigFromEnv()
+	info := buildinfo.New("seta-service", config.EffectiveSettings(), buildinfo.KafkaInfo{
+		Brokers: producer.Brokers,
+		Topics:  []string{producer.TeamTopic, producer.AssetTopic},
+	})
+
+	c.JSON(http.StatusOK, info)
+}
+
+// GetDispatchStatus reports the outbox backlog, whether publishing is paused, the recent
+// publish error rate and latencies of this instance, and the webhook delivery queue.
+func (ic *InternalController) GetDispatchStatus(c *gin.Context) {
+	outbox, err := kafka.ReadOutboxStatus(c.Request.Context(), ic.db)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to read dispatch status"})
+		return
+	}
+	webhookQueue, err := services.WebhookQueueDepth(c.Request.Context(), ic.db)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to read dispatch status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"outbox":            outbox,
+		"webhookQueueDepth": webhookQueue,
+	})
+}
+
+type PauseDispatchInput struct {
+	Actor  string `json:"actor" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// PauseDispatch stops outbox publishing on every instance, e.g. for a Kafka maintenance
+// window. Events accumulate in the outbox until ResumeDispatch.
+func (ic *InternalController) PauseDispatch(c *gin.Context) {
+	var input PauseDispatchInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	pause, err := kafka.PauseOutbox(c.Request.Context(), ic.db, input.Actor, input.Reason)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to pause dispatch"})
+		return
+	}
+
+	ic.log.Warn("Outbox publishing paused", logging.Fields{"actor": pause.PausedBy, "reason": pause.Reason})
+	c.JSON(http.StatusOK, pause)
+}
+
+// ResumeDispatch lifts a pause; the backlog then drains at OUTBOX_DRAIN_RATE_PER_SECOND.
+func (ic *InternalController) ResumeDispatch(c *gin.Context) {
+	resumed, err := kafka.ResumeOutbox(c.Request.Context(), ic.db)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to resume dispatch"})
+		return
+	}
+
+	if resumed {
+		ic.log.Info("Outbox publishing resumed")
+	}
+	c.JSON(http.StatusOK, gin.H{"resumed": resumed})
+}
+
+type TokenRevocationInput struct {
+	TokenSHA256 string `json:"tokenSha256" binding:"omitempty,len=64,hexadecimal"`
+	UserID      string `json:"userId"`
+}
+
+// RevokeTokens drops cached token verifications the user service has revoked, so the
+// next request with them is verified again: one token, by the hex SHA-256 of it, or
+// every token of a user. The other instances are told through Postgres; forgotten
+// counts the verifications dropped on this one.
+func (ic *InternalController) RevokeTokens(c *gin.Context) {
+	var input TokenRevocationInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if input.TokenSHA256 == "" && input.UserID == "" {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "tokenSha256 or userId is required"})
+		return
+	}
+
+	forgotten := 0
+	if input.TokenSHA256 != "" {
+		var key [32]byte
+		// The binding already checked it is 64 hex digits.
+		_, _ = hex.Decode(key[:], []byte(input.TokenSHA256))
+		dropped, err := middlewares.RevokeToken(c.Request.Context(), ic.db, key)
+		if err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to broadcast token revocation"})
+			return
+		}
+		if dropped {
+			forgotten++
+		}
+	}
+	if input.UserID != "" {
+		dropped, err := middlewares.RevokeUserTokens(c.Request.Context(), ic.db, input.UserID)
+		if err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to broadcast token revocation"})
+			return
+		}
+		forgotten += dropped
+	}
+
+	c.JSON(http.StatusOK, gin.H{"forgotten": forgotten})
+}
+
+type ReplayEventsInput struct {
+	AssetType string      `json:"assetType" binding:"required,oneof=folder note"`
+	AssetIDs  []uuid.UUID `json:"assetIds" binding:"required,min=1"`
+	Actor     string      `json:"actor" binding:"required"`
+}
+
+// ReplayAssetEvents re-emits the current DB state of the given assets as ASSET_RESYNC
+// events so downstream consumers (cache, audit) can reconverge after a consumer bug.
+func (ic *InternalController) ReplayAssetEvents(c *gin.Context) {
+	var input ReplayEventsInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if len(input.AssetIDs) > maxReplayBatchSize {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Too many assets in one replay request (max 100)"})
+		return
+	}
+
+	var payloads []kafka.EventPayload
+	var err error
+	switch input.AssetType {
+	case "folder":
+		payloads, err = ic.folderResyncPayloads(c, input.AssetIDs)
+	case "note":
+		payloads, err = ic.noteResyncPayloads(c, input.AssetIDs)
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to load asset state"})
+		return
+	}
+
+	found := make(map[string]bool, len(payloads))
+	replayed, failed := 0, 0
+	for _, payload := range payloads {
+		payload.ActionBy = input.Actor
+		found[payload.AssetID] = true
+
+		// Produced synchronously so the operator learns about delivery failures.
+		if err := kafka.ProduceAssetEvent(c.Request.Context(), payload); err != nil {
+			ic.log.Error("Failed to produce ASSET_RESYNC event", logging.Fields{
+				logging.FieldError:     err,
+				logging.FieldAssetID:   payload.AssetID,
+				logging.FieldEventType: payload.EventType,
+			})
+			failed++
+			continue
+		}
+		replayed++
+	}
+
+	notFound := make([]string, 0)
+	for _, id := range input.AssetIDs {
+		if !found[id.String()] {
+			notFound = append(notFound, id.String())
+		}
+	}
+
+	// The auditing-service records every ASSET_RESYNC message with ActionBy set to the actor,
+	// this log line ties the whole batch together.
+	ic.log.Info("Asset events replayed", logging.Fields{
+		"actor":      input.Actor,
+		"asset_type": input.AssetType,
+		"requested":  len(input.AssetIDs),
+		"replayed":   replayed,
+		"failed":     failed,
+		"not_found":  len(notFound),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"replayed": replayed,
+		"failed":   failed,
+		"notFound": notFound,
+	})
+}
+
+// GetAssetSnapshot returns the current state and ACL of one folder or note, for consumers
+// that receive an event about state they do not hold. Folders also carry their note count.
+func (ic *InternalController) GetAssetSnapshot(c *gin.Context) {
+	assetType := c.Param("type")
+	if assetType != "folder" && assetType != "note" {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Asset type must be folder or note"})
+		return
+	}
+
+	assetID, err := utils.GetUUIDFromParam(c, "id")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var payloads []kafka.EventPayload
+	if assetType == "folder" {
+		payloads, err = ic.folderResyncPayloads(c, []uuid.UUID{assetID})
+	} else {
+		payloads, err = ic.noteResyncPayloads(c, []uuid.UUID{assetID})
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to load asset state"})
+		return
+	}
+	if len(payloads) == 0 {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Asset not found"})
+		return
+	}
+
+	snapshot := payloads[0]
+	acl := snapshot.ACL
+	if acl == nil {
+		acl = map[string]string{}
+	}
+	response := gin.H{
+		"assetType": snapshot.AssetType,
+		"assetId":   snapshot.AssetID,
+		"ownerId":   snapshot.OwnerID,
+		"snapshot":  snapshot.Snapshot,
+		"acl":       acl,
+	}
+
+	if assetType == "folder" {
+		var noteCount int64
+		if err := ic.db.WithContext(c.Request.Context()).Model(&models.Note{}).Where("folder_id = ?", assetID).Count(&noteCount).Error; err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to count folder notes"})
+			return
+		}
+		response["noteCount"] = noteCount
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetTeamMembers lists the managers and members of a team.
+func (ic *InternalController) GetTeamMembers(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "id")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	db := ic.db.WithContext(c.Request.Context())
+
+	var team models.Team
+	if err := db.First(&team, "id = ?", teamID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Team not found"})
+		return
+	}
+
+	var managers []models.TeamManager
+	if err := db.Where("team_id = ?", teamID).Find(&managers).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve team managers"})
+		return
+	}
+
+	memberIDs := make([]uuid.UUID, 0)
+	if err := db.Model(&models.TeamMember{}).Where("team_id = ?", teamID).Pluck("user_id", &memberIDs).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve team members"})
+		return
+	}
+
+	managerList := make([]gin.H, 0, len(managers))
+	for _, manager := range managers {
+		managerList = append(managerList, gin.H{"userId": manager.UserID, "isLead": manager.IsLead})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"teamId":   team.ID,
+		"teamName": team.TeamName,
+		"managers": managerList,
+		"members":  memberIDs,
+	})
+}
+
+// ListFeatureFlags returns every feature flag with its rollout rules.
+func (ic *InternalController) ListFeatureFlags(c *gin.Context) {
+	rules, err := ic.flags.Rules(c.Request.Context())
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to load feature flags"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+type SaveFeatureFlagInput struct {
+	Description       string      `json:"description"`
+	Enabled           *bool       `json:"enabled" binding:"required"`
+	RolloutPercentage int         `json:"rolloutPercentage" binding:"min=0,max=100"`
+	AllowedUserIDs    []uuid.UUID `json:"allowedUserIds"`
+	AllowedTeamIDs    []uuid.UUID `json:"allowedTeamIds"`
+	Actor             string      `json:"actor" binding:"required"`
+}
+
+// SaveFeatureFlag creates or replaces a flag's rules. Every change is recorded in
+// feature_flag_changes with the actor and the rule before and after.
+func (ic *InternalController) SaveFeatureFlag(c *gin.Context) {
+	var input SaveFeatureFlagInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	name := c.Param("name")
+	if len(name) > 100 {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Flag name must be at most 100 characters"})
+		return
+	}
+
+	rule, err := ic.flags.SaveRule(c.Request.Context(), services.FlagRule{
+		Name:              name,
+		Description:       input.Description,
+		Enabled:           *input.Enabled,
+		RolloutPercentage: input.RolloutPercentage,
+		AllowedUserIDs:    input.AllowedUserIDs,
+		AllowedTeamIDs:    input.AllowedTeamIDs,
+	}, input.Actor)
+	if errors.Is(err, services.ErrInvalidRollout) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to save feature flag"})
+		return
+	}
+
+	ic.log.Info("Feature flag updated", logging.Fields{
+		"actor":              input.Actor,
+		"flag":               rule.Name,
+		"enabled":            rule.Enabled,
+		"rollout_percentage": rule.RolloutPercentage,
+	})
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// EvaluateFeatureFlag reports whether a flag is on for ?userId, for checking a rollout.
+func (ic *InternalController) EvaluateFeatureFlag(c *gin.Context) {
+	query := httpquery.New(c.Request.URL.Query())
+	query.Required("userId")
+	userID, _ := query.UUID("userId")
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	enabled, err := ic.flags.Evaluate(c.Request.Context(), c.Param("name"), services.Principal{UserID: userID})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to evaluate feature flag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"flag": c.Param("name"), "userId": userID, "enabled": enabled})
+}
+
+func (ic *InternalController) folderResyncPayloads(c *gin.Context, ids []uuid.UUID) ([]kafka.EventPayload, error) {
+	db := ic.db.WithContext(c.Request.Context())
+
+	var folders []models.Folder
+	if err := db.Where("folder_id IN ?", ids).Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	var shares []models.FolderShare
+	if err := db.Where("folder_id IN ?", ids).Find(&shares).Error; err != nil {
+		return nil, err
+	}
+
+	acls := make(map[uuid.UUID]map[string]string)
+	for _, share := range shares {
+		if acls[share.FolderID] == nil {
+			acls[share.FolderID] = make(map[string]string)
+		}
+		acls[share.FolderID][share.UserID.String()] = share.Access
+	}
+
+	payloads := make([]kafka.EventPayload, 0, len(folders))
+	for _, folder := range folders {
+		payloads = append(payloads, resyncPayload("folder", folder.FolderID, folder.OwnerID, folder, acls[folder.FolderID]))
+	}
+	return payloads, nil
+}
+
+func (ic *InternalController) noteResyncPayloads(c *gin.Context, ids []uuid.UUID) ([]kafka.EventPayload, error) {
+	db := ic.db.WithContext(c.Request.Context())
+
+	var notes []models.Note
+	if err := db.Where("note_id IN ?", ids).Find(&notes).Error; err != nil {
+		return nil, err
+	}
+
+	var shares []models.NoteShare
+	if err := db.Where("note_id IN ?", ids).Find(&shares).Error; err != nil {
+		return nil, err
+	}
+
+	acls := make(map[uuid.UUID]map[string]string)
+	for _, share := range shares {
+		if acls[share.NoteID] == nil {
+			acls[share.NoteID] = make(map[string]string)
+		}
+		acls[share.NoteID][share.UserID.String()] = share.Access
+	}
+
+	payloads := make([]kafka.EventPayload, 0, len(notes))
+	for _, note := range notes {
+		payloads = append(payloads, resyncPayload("note", note.NoteID, note.OwnerID, note, acls[note.NoteID]))
+	}
+	return payloads, nil
+}
+
+// resyncPayload builds an ASSET_RESYNC event. An asset without shares has no "acl" key
+// in the message; consumers must treat that as an empty ACL and drop any stale entries.
+// A snapshot over the event size limit is sent as a truncation stub. The caller sets
+// ActionBy to the operator.
+func resyncPayload(assetType string, assetID, ownerID uuid.UUID, snapshot any, acl map[string]string) kafka.EventPayload {
+	event := events.NewAssetEvent("ASSET_RESYNC", assetType, assetID.String(), "")
+	event.OwnerID = ownerID.String()
+	event.Snapshot = events.LimitPayload(snapshot)
+	event.ACL = acl
+	return event
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"seta-pkg/events"
+	"seta-pkg/logging"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestResyncPayload(t *testing.T) {
+	ownerID, readerID := uuid.New(), uuid.New()
+	note := models.Note{NoteID: uuid.New(), Title: "Plan", Body: "First draft", OwnerID: ownerID}
+
+	event := resyncPayload("note", note.NoteID, ownerID, note, map[string]string{readerID.String(): "read"})
+	if event.EventType != "ASSET_RESYNC" || event.AssetType != "note" || event.AssetID != note.NoteID.String() {
+		t.Errorf("event = %+v, want an ASSET_RESYNC of the note", event)
+	}
+	if event.OwnerID != ownerID.String() {
+		t.Errorf("OwnerID = %q, want the note's owner", event.OwnerID)
+	}
+	if event.SchemaVersion != events.SchemaVersion || event.Timestamp.IsZero() {
+		t.Errorf("event is not stamped: %+v", event)
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("encode event: %v", err)
+	}
+	var decoded struct {
+		Snapshot models.Note       `json:"snapshot"`
+		ACL      map[string]string `json:"acl"`
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if decoded.Snapshot.Title != "Plan" || decoded.Snapshot.Body != "First draft" {
+		t.Errorf("snapshot = %+v, want the note", decoded.Snapshot)
+	}
+	if len(decoded.ACL) != 1 || decoded.ACL[readerID.String()] != "read" {
+		t.Errorf("acl = %v, want the reader's share", decoded.ACL)
+	}
+
+	// An asset without shares has no ACL, which consumers read as empty.
+	event = resyncPayload("note", note.NoteID, ownerID, note, nil)
+	if encoded, _ := json.Marshal(event); strings.Contains(string(encoded), `"acl"`) {
+		t.Errorf("event without shares has an acl: %s", encoded)
+	}
+
+	// A snapshot over the size limit is sent as a stub.
+	note.Body = strings.Repeat("x", events.MaxPayloadBytes())
+	event = resyncPayload("note", note.NoteID, ownerID, note, nil)
+	if stub, ok := event.Snapshot.(events.TruncatedPayload); !ok || !stub.Truncated || stub.SHA256 == "" {
+		t.Errorf("snapshot = %T, want a truncation stub", event.Snapshot)
+	}
+}
+
+// The snapshot endpoint builds its answer with the replay's payloads, so this checks
+// the state and ACL an ASSET_RESYNC carries.
+func TestGetAssetSnapshot(t *testing.T) {
+	db := testdb.Open(t)
+	ownerID, readerID, writerID := uuid.New(), uuid.New(), uuid.New()
+	folder := createTestFolder(t, db, ownerID)
+	note := models.Note{Title: "Plan", Body: "First draft", FolderID: folder.FolderID, OwnerID: ownerID, LastModifiedBy: ownerID}
+	if err := db.Omit("Folder", "Owner").Create(&note).Error; err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	for _, share := range []any{
+		&models.FolderShare{FolderID: folder.FolderID, UserID: readerID, Access: models.AccessRead},
+		&models.FolderShare{FolderID: folder.FolderID, UserID: writerID, Access: models.AccessWrite},
+	} {
+		if err := db.Create(share).Error; err != nil {
+			t.Fatalf("share: %v", err)
+		}
+	}
+
+	ic := NewInternalController(db, nil, logging.Nop())
+	r := newTestRouter(ownerID)
+	r.GET("/internal/assets/:type/:id/snapshot", ic.GetAssetSnapshot)
+
+	rec := serve(t, r, http.MethodGet, "/internal/assets/folder/"+folder.FolderID.String()+"/snapshot", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("folder snapshot: status %d, body %s", rec.Code, rec.Body)
+	}
+	var folderSnapshot struct {
+		OwnerID   string            `json:"ownerId"`
+		Snapshot  models.Folder     `json:"snapshot"`
+		ACL       map[string]string `json:"acl"`
+		NoteCount int64             `json:"noteCount"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &folderSnapshot); err != nil {
+		t.Fatalf("decode folder snapshot: %v", err)
+	}
+	if folderSnapshot.OwnerID != ownerID.String() || folderSnapshot.Snapshot.FolderID != folder.FolderID {
+		t.Errorf("folder snapshot = %+v, want the folder and its owner", folderSnapshot)
+	}
+	wantACL := map[string]string{readerID.String(): "read", writerID.String(): "write"}
+	if len(folderSnapshot.ACL) != len(wantACL) || folderSnapshot.ACL[readerID.String()] != "read" || folderSnapshot.ACL[writerID.String()] != "write" {
+		t.Errorf("folder acl = %v, want %v", folderSnapshot.ACL, wantACL)
+	}
+	if folderSnapshot.NoteCount != 1 {
+		t.Errorf("noteCount = %d, want 1", folderSnapshot.NoteCount)
+	}
+
+	rec = serve(t, r, http.MethodGet, "/internal/assets/note/"+note.NoteID.String()+"/snapshot", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("note snapshot: status %d, body %s", rec.Code, rec.Body)
+	}
+	var noteSnapshot struct {
+		Snapshot models.Note       `json:"snapshot"`
+		ACL      map[string]string `json:"acl"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &noteSnapshot); err != nil {
+		t.Fatalf("decode note snapshot: %v", err)
+	}
+	if noteSnapshot.Snapshot.Body != "First draft" {
+		t.Errorf("note snapshot = %+v, want its body", noteSnapshot.Snapshot)
+	}
+	// Folder shares are not copied into the note's ACL; an unshared asset has an empty one.
+	if noteSnapshot.ACL == nil || len(noteSnapshot.ACL) != 0 {
+		t.Errorf("note acl = %v, want empty", noteSnapshot.ACL)
+	}
+
+	for path, want := range map[string]int{
+		"/internal/assets/note/" + uuid.NewString() + "/snapshot": http.StatusNotFound,
+		"/internal/assets/team/" + uuid.NewString() + "/snapshot": http.StatusBadRequest,
+		"/internal/assets/folder/" + "not-a-uuid" + "/snapshot":   http.StatusBadRequest,
+	} {
+		if rec := serve(t, r, http.MethodGet, path, nil, nil); rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+// Requests are checked before any asset is loaded or event produced.
+func TestReplayAssetEventsValidation(t *testing.T) {
+	ic := NewInternalController(nil, nil, logging.Nop())
+	r := newTestRouter(uuid.New())
+	r.POST("/internal/events/replay", ic.ReplayAssetEvents)
+
+	tooMany := make([]uuid.UUID, maxReplayBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+	tests := []struct {
+		name string
+		body gin.H
+	}{
+		{"too many assets", gin.H{"assetType": "note", "assetIds": tooMany, "actor": "ops"}},
+		{"no assets", gin.H{"assetType": "note", "assetIds": []uuid.UUID{}, "actor": "ops"}},
+		{"unknown asset type", gin.H{"assetType": "team", "assetIds": []uuid.UUID{uuid.New()}, "actor": "ops"}},
+		{"no actor", gin.H{"assetType": "note", "assetIds": []uuid.UUID{uuid.New()}}},
+		{"invalid asset ID", gin.H{"assetType": "note", "assetIds": []string{"not-a-uuid"}, "actor": "ops"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, r, http.MethodPost, "/internal/events/replay", tt.body, nil); rec.Code != http.StatusBadRequest {
+				t.Errorf("status %d, want 400, body %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
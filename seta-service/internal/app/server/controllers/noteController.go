@@ -1,29 +1,41 @@
 package controllers
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"seta-pkg/database"
+	"seta/internal/app/server/services"
 	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
 	"seta/internal/pkg/kafka"
 	"seta/internal/pkg/models"
+	"seta/internal/pkg/userclient"
 	"seta/internal/pkg/utils" // Import the new utils package
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // NoteController no longer embeds BaseController.
 type NoteController struct {
-	db *gorm.DB
+	db             *gorm.DB
+	authorization  *services.AuthorizationService
+	trashRetention time.Duration
+	users          *userclient.Client
 }
 
 // NewNoteController creates a new NoteController, injecting the db dependency.
-func NewNoteController(db *gorm.DB) *NoteController {
-	return &NoteController{db: db}
+func NewNoteController(db *gorm.DB, authorization *services.AuthorizationService) *NoteController {
+	return &NoteController{db: db, authorization: authorization, trashRetention: services.NoteTrashRetention(), users: userclient.Shared()}
 }
 
-// GetNote retrieves a single note. Simplified with utils and auth middleware.
+// GetNote retrieves a single note. Clients polling it can send If-None-Match to get a 304
+// while the note is unchanged.
 func (nc *NoteController) GetNote(c *gin.Context) {
 	noteID, err := utils.GetUUIDFromParam(c, "noteId")
 	if err != nil {
@@ -32,20 +44,37 @@ func (nc *NoteController) GetNote(c *gin.Context) {
 	}
 
 	var note models.Note
-	if err := nc.db.WithContext(c.Request.Context()).First(&note, "note_id = ?", noteID).Error; err != nil {
+	err = database.Read(c.Request.Context(), nc.db, func(tx *gorm.DB) error {
+		return tx.First(&note, "note_id = ?", noteID).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Note not found"})
 		return
 	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve note"})
+		return
+	}
 
-	c.JSON(http.StatusOK, note)
+	utils.JSONWithETag(c, http.StatusOK, note)
 }
 
+// UpdateNoteInput changes the fields it carries; an empty string sets a field empty.
 type UpdateNoteInput struct {
-	Title string `json:"title"`
-	Body  string `json:"body"`
+	Title *string `json:"title"`
+	Body  *string `json:"body"`
+	// Version is the note version the edit was made on, for clients not sending If-Match.
+	Version *int `json:"version"`
 }
 
-// UpdateNote updates a note's title or body. Simplified with utils and auth middleware.
+// errNoteVersionConflict means another edit bumped the note version first.
+var errNoteVersionConflict = errors.New("note version changed")
+
+// UpdateNote updates a note's title, body or both. The edit must name the note it was made
+// on: either If-Match with the ETag GetNote returned, as HTTP clients send it, or the
+// note version in the version field (or bare in If-Match). A stale ETag is refused with
+// a 412 and a stale version with a 409; the details of both carry the current version,
+// so the client can merge.
 func (nc *NoteController) UpdateNote(c *gin.Context) {
 	noteID, err := utils.GetUUIDFromParam(c, "noteId")
 	if err != nil {
@@ -66,28 +95,144 @@ func (nc *NoteController) UpdateNote(c *gin.Context) {
 	}
 
 	var input UpdateNoteInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if input.Title == nil && input.Body == nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Nothing to update: pass title or body"})
 		return
 	}
 
-	if err := nc.db.WithContext(c.Request.Context()).Model(&note).Updates(input).Error; err != nil {
+	version, err := editedNoteVersion(c, input.Version, note)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	columns := []string{"version", "last_modified_by"}
+	if input.Title != nil {
+		note.Title = *input.Title
+		columns = append(columns, "title")
+	}
+	if input.Body != nil {
+		// Both body columns are written, so the body is stored in the current format.
+		note.Body = *input.Body
+		columns = append(columns, "body", "body_compressed")
+	}
+	note.LastModifiedBy = actorUserID
+	note.Version = version + 1
+
+	err = nc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&note).Where("version = ?", version).Select(columns).Updates(&note)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNoteVersionConflict
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_UPDATED", note, actorUserID))
+	})
+	if errors.Is(err, errNoteVersionConflict) {
+		var current models.Note
+		if err := nc.db.WithContext(c.Request.Context()).Select("version").First(&current, "note_id = ?", noteID).Error; err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Note not found"})
+			return
+		}
+		_ = c.Error(&errorHandling.CustomError{
+			Code:    http.StatusConflict,
+			Message: "Note was changed by someone else; reload it and merge your edit",
+			Details: map[string]any{"currentVersion": current.Version},
+		})
+		return
+	}
+	if err != nil {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to update note"})
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType: "NOTE_UPDATED",
-		AssetType: "note",
-		AssetID:   note.NoteID.String(),
-		OwnerID:   note.OwnerID.String(),
-		ActionBy:  actorUserID.String(),
+	c.JSON(http.StatusOK, note)
+}
+
+// editedNoteVersion returns the note version an edit was made on: the version field of
+// the body, or else If-Match. If-Match holds either the version, bare or quoted, or the
+// ETag of the note as GetNote returns it; an ETag that matches the current note stands
+// for its current version, and the update's version check covers the time until it is
+// written.
+func editedNoteVersion(c *gin.Context, fromBody *int, current models.Note) (int, error) {
+	if fromBody != nil {
+		return *fromBody, nil
+	}
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return 0, &errorHandling.CustomError{Code: http.StatusPreconditionRequired, Message: "Send the note ETag or version in If-Match, or the version field"}
+	}
+	// An ETag is 32 hex digits, too long to parse as a version.
+	if version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`)); err == nil {
+		return version, nil
+	}
+	etag, err := utils.ETag(current)
+	if err != nil {
+		return 0, err
+	}
+	if !utils.ETagMatches(header, etag) {
+		return 0, &errorHandling.CustomError{
+			Code:    http.StatusPreconditionFailed,
+			Message: "Note was changed by someone else; reload it and merge your edit",
+			Details: map[string]any{"currentVersion": current.Version},
+		}
+	}
+	return current.Version, nil
+}
+
+type UpdateNoteSettingsInput struct {
+	Cacheable *bool `json:"cacheable" binding:"required"`
+}
+
+// UpdateNoteSettings lets the owner change per-note settings, currently only whether
+// the note may be cached.
+func (nc *NoteController) UpdateNoteSettings(c *gin.Context) {
+	noteID, err := utils.GetUUIDFromParam(c, "noteId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var input UpdateNoteSettingsInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var note models.Note
+	if err := nc.db.WithContext(c.Request.Context()).First(&note, "note_id = ?", noteID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Note not found"})
+		return
+	}
+
+	err = nc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&note).Updates(map[string]any{"cacheable": *input.Cacheable, "last_modified_by": actorUserID}).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_UPDATED", note, actorUserID))
 	})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to update note settings"})
+		return
+	}
 
 	c.JSON(http.StatusOK, note)
 }
 
-// DeleteNote deletes a note. Simplified with utils and auth middleware.
+// DeleteNote moves a note to its owner's trash. Its shares are kept for a restore but
+// grant nothing meanwhile; NotePurger removes both after the retention window.
 func (nc *NoteController) DeleteNote(c *gin.Context) {
 	noteID, err := utils.GetUUIDFromParam(c, "noteId")
 	if err != nil {
@@ -108,15 +253,15 @@ func (nc *NoteController) DeleteNote(c *gin.Context) {
 	}
 
 	tx := nc.db.WithContext(c.Request.Context()).Begin()
-	// ... (transaction logic remains the same)
-	if err := tx.Where("note_id = ?", note.NoteID).Delete(&models.NoteShare{}).Error; err != nil {
+	// With DeletedAt on the model this sets deleted_at instead of removing the row.
+	if err := tx.Delete(&note).Error; err != nil {
 		tx.Rollback()
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete associated shares"})
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete note"})
 		return
 	}
-	if err := tx.Delete(&note).Error; err != nil {
+	if err := kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_DELETED", note, actorUserID)); err != nil {
 		tx.Rollback()
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete note"})
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to record note event"})
 		return
 	}
 	if err := tx.Commit().Error; err != nil {
@@ -124,20 +269,100 @@ func (nc *NoteController) DeleteNote(c *gin.Context) {
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType: "NOTE_DELETED",
-		AssetType: "note",
-		AssetID:   note.NoteID.String(),
-		OwnerID:   note.OwnerID.String(),
-		ActionBy:  actorUserID.String(),
+	nc.authorization.Purge()
+	c.Status(http.StatusNoContent)
+}
+
+// errNoteNotInTrash is returned inside a transaction for a note that is not in the
+// caller's trash, or has been there longer than the retention window.
+var errNoteNotInTrash = errors.New("note is not in the trash")
+
+// ListTrash lists the caller's deleted notes that can still be restored, most recently
+// deleted first.
+func (nc *NoteController) ListTrash(c *gin.Context) {
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query := httpquery.New(c.Request.URL.Query())
+	page := query.Pagination(50, 100)
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	notes := make([]models.Note, 0)
+	err = database.Read(c.Request.Context(), nc.db, func(tx *gorm.DB) error {
+		return tx.Unscoped().
+			Where("owner_id = ? AND deleted_at > ?", userID, time.Now().Add(-nc.trashRetention)).
+			Order("deleted_at DESC, note_id").Limit(page.Limit).Offset(page.Offset).
+			Find(&notes).Error
 	})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to list trash"})
+		return
+	}
 
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, gin.H{
+		"notes":         notes,
+		"retentionDays": int(nc.trashRetention.Hours() / 24),
+		"limit":         page.Limit,
+		"offset":        page.Offset,
+	})
 }
 
+// RestoreNote takes a note out of the caller's trash, with the shares it had. The folder
+// must still be there and not being deleted.
+func (nc *NoteController) RestoreNote(c *gin.Context) {
+	noteID, err := utils.GetUUIDFromParam(c, "noteId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var note models.Note
+	err = nc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		// Only the owner's own trash is searched, so other users get a 404 and learn nothing.
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&note, "note_id = ? AND owner_id = ? AND deleted_at > ?", noteID, actorUserID, time.Now().Add(-nc.trashRetention)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errNoteNotInTrash
+			}
+			return err
+		}
+		if err := lockFolderForNewNote(tx, note.FolderID); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&note).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_RESTORED", note, actorUserID))
+	})
+	if errors.Is(err, errNoteNotInTrash) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Note not found in trash"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(newNoteError(err, "Failed to restore note"))
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+// ShareNoteInput names the user by userId or by email.
 type ShareNoteInput struct {
-	UserID uuid.UUID `json:"userId" binding:"required"`
-	Access string    `json:"access" binding:"required"`
+	UserID uuid.UUID `json:"userId"`
+	Email  string    `json:"email" binding:"omitempty,email"`
+	Access string    `json:"access" binding:"required,oneof=read write"`
 }
 
 // ShareNote shares a note with another user. Simplified with utils and auth middleware.
@@ -161,32 +386,134 @@ func (nc *NoteController) ShareNote(c *gin.Context) {
     }
 
 	var input ShareNoteInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !models.ValidAccess(input.Access) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Access must be read or write"})
+		return
+	}
+	targetUserID, customErr := shareUser(c.Request.Context(), nc.users, input.UserID, input.Email)
+	if customErr != nil {
+		_ = c.Error(customErr)
 		return
 	}
 
 	share := models.NoteShare{
 		NoteID: noteID,
-		UserID: input.UserID,
+		UserID: targetUserID,
 		Access: input.Access,
 	}
 
-	if err := nc.db.WithContext(c.Request.Context()).Create(&share).Error; err != nil {
+	err = nc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		// Sharing again with the same user changes their access level.
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "note_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"access"}),
+		}).Create(&share).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_SHARED", note, actorUserID).WithTarget(targetUserID))
+	})
+	if err != nil {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to share note"})
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType:    "NOTE_SHARED",
-		AssetType:    "note",
-		AssetID:      noteID.String(),
-		OwnerID:      note.OwnerID.String(),
-		ActionBy:     actorUserID.String(),
-		TargetUserID: input.UserID.String(),
+	nc.authorization.Purge()
+	c.Status(http.StatusNoContent)
+}
+
+// ShareNoteBatch shares a note with several users at once and reports the outcome for
+// each of them. One NOTE_SHARED event is emitted per user whose access changed.
+func (nc *NoteController) ShareNoteBatch(c *gin.Context) {
+	noteID, err := utils.GetUUIDFromParam(c, "noteId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var note models.Note
+	if err := nc.db.WithContext(c.Request.Context()).First(&note, "note_id = ?", noteID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Note not found"})
+		return
+	}
+
+	var input ShareBatchInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if customErr := resolveBatchEmails(c.Request.Context(), nc.users, input.Shares); customErr != nil {
+		_ = c.Error(customErr)
+		return
+	}
+
+	var summary ShareBatchSummary
+	err = nc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var current []models.NoteShare
+		if err := tx.Where("note_id = ? AND user_id IN ?", noteID, batchUserIDs(input.Shares)).Find(&current).Error; err != nil {
+			return err
+		}
+		existing := make(map[uuid.UUID]string, len(current))
+		for _, share := range current {
+			existing[share.UserID] = share.Access
+		}
+
+		var writes []ShareBatchItem
+		summary, writes = planShareBatch(input.Shares, existing)
+		if len(writes) == 0 {
+			return nil
+		}
+
+		shares := make([]models.NoteShare, len(writes))
+		for i, item := range writes {
+			shares[i] = models.NoteShare{NoteID: noteID, UserID: item.UserID, Access: item.Access}
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "note_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"access"}),
+		}).Create(&shares).Error; err != nil {
+			return err
+		}
+		for _, item := range writes {
+			if err := kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_SHARED", note, actorUserID).WithTarget(item.UserID)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to share note"})
+		return
+	}
 
-	c.Status(http.StatusNoContent)
+	nc.authorization.Purge()
+	c.JSON(http.StatusOK, summary)
+}
+
+// ListNoteShares lists who the note is shared with, for its owner to review and revoke.
+func (nc *NoteController) ListNoteShares(c *gin.Context) {
+	noteID, err := utils.GetUUIDFromParam(c, "noteId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	shares := make([]models.NoteShare, 0)
+	if err := nc.db.WithContext(c.Request.Context()).Where("note_id = ?", noteID).Order("user_id").Find(&shares).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to list note shares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
 }
 
 // RevokeNoteSharing removes a user's access to a shared note. Simplified.
@@ -215,27 +542,25 @@ func (nc *NoteController) RevokeNoteSharing(c *gin.Context) {
         return
     }
 
-	result := nc.db.WithContext(c.Request.Context()).
-		Where("note_id = ? AND user_id = ?", noteID, targetUserID).
-		Delete(&models.NoteShare{})
-
-	if result.Error != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to revoke note share"})
+	err = nc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("note_id = ? AND user_id = ?", noteID, targetUserID).Delete(&models.NoteShare{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_UNSHARED", note, actorUserID).WithTarget(targetUserID))
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Sharing record not found for this user and note"})
 		return
 	}
-	if result.RowsAffected == 0 {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Sharing record not found for this user and note"})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to revoke note share"})
 		return
 	}
 
-	go kafka.ProduceAssetEvent(context.Background(), kafka.EventPayload{
-		EventType:    "NOTE_UNSHARED",
-		AssetType:    "note",
-		AssetID:      noteID.String(),
-		OwnerID:      note.OwnerID.String(),
-		ActionBy:     actorUserID.String(),
-		TargetUserID: targetUserID.String(),
-	})
-
+	nc.authorization.Purge()
 	c.Status(http.StatusNoContent)
 }
\ No newline at end of file
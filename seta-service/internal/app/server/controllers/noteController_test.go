@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"seta-pkg/logging"
+	"seta/internal/app/server/middlewares"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func newNoteTestRouter(t testing.TB) (*gin.Engine, *gorm.DB, models.Note) {
+	t.Helper()
+	db := testdb.Open(t)
+	ownerID := uuid.New()
+	folder := createTestFolder(t, db, ownerID)
+	note := models.Note{Title: "Plan", Body: "First draft", FolderID: folder.FolderID, OwnerID: ownerID, LastModifiedBy: ownerID}
+	if err := db.Omit("Folder", "Owner").Create(&note).Error; err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+
+	nc := NewNoteController(db, services.NewCachedAuthorizationService(db, logging.Nop()))
+	r := newTestRouter(ownerID)
+	r.GET("/notes/:noteId", nc.GetNote)
+	r.PUT("/notes/:noteId", nc.UpdateNote)
+	return r, db, note
+}
+
+func reloadNote(t testing.TB, db *gorm.DB, noteID uuid.UUID) models.Note {
+	t.Helper()
+	var note models.Note
+	if err := db.First(&note, "note_id = ?", noteID).Error; err != nil {
+		t.Fatalf("reload note: %v", err)
+	}
+	return note
+}
+
+// currentVersion returns the currentVersion a refused edit reports in its details.
+func currentVersion(t testing.TB, rec *httptest.ResponseRecorder) int {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Details struct {
+				CurrentVersion int `json:"currentVersion"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	return body.Error.Details.CurrentVersion
+}
+
+func TestUpdateNoteRefusesStaleVersion(t *testing.T) {
+	r, db, note := newNoteTestRouter(t)
+	path := "/notes/" + note.NoteID.String()
+
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Mine", "version": 1}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("first edit: status %d, body %s", rec.Code, rec.Body)
+	}
+	rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Theirs", "version": 1}, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("edit on version 1: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if got := currentVersion(t, rec); got != 2 {
+		t.Errorf("currentVersion = %d, want 2", got)
+	}
+	if got := reloadNote(t, db, note.NoteID); got.Title != "Mine" || got.Version != 2 {
+		t.Errorf("note = %q at version %d, want %q at version 2", got.Title, got.Version, "Mine")
+	}
+}
+
+func TestUpdateNoteAcceptsETagFromGetNote(t *testing.T) {
+	r, db, note := newNoteTestRouter(t)
+	path := "/notes/" + note.NoteID.String()
+
+	etag := serve(t, r, http.MethodGet, path, nil, nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GetNote sent no ETag")
+	}
+	ifMatch := http.Header{"If-Match": {etag}}
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Mine"}, ifMatch); rec.Code != http.StatusOK {
+		t.Fatalf("edit with the current ETag: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Theirs"}, ifMatch)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("edit with a stale ETag: status %d, want %d", rec.Code, http.StatusPreconditionFailed)
+	}
+	if got := currentVersion(t, rec); got != 2 {
+		t.Errorf("currentVersion = %d, want 2", got)
+	}
+
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Quoted"}, http.Header{"If-Match": {`"2"`}}); rec.Code != http.StatusOK {
+		t.Errorf("edit with a quoted version: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Blind"}, nil); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("edit naming no version: status %d, want %d", rec.Code, http.StatusPreconditionRequired)
+	}
+	if got := reloadNote(t, db, note.NoteID); got.Title != "Quoted" || got.Version != 3 {
+		t.Errorf("note = %q at version %d, want %q at version 3", got.Title, got.Version, "Quoted")
+	}
+}
+
+// Edits made on the same version at once are decided by the version = ? guard of the
+// update: exactly one is written, the others are refused.
+func TestUpdateNoteConcurrentEditsOnOneVersion(t *testing.T) {
+	r, db, note := newNoteTestRouter(t)
+	path := "/notes/" + note.NoteID.String()
+
+	const editors = 10
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = make(map[int]int)
+		start    = make(chan struct{})
+	)
+	for i := 0; i < editors; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			rec := serve(t, r, http.MethodPut, path, gin.H{"body": "Edit", "version": 1}, nil)
+			mu.Lock()
+			statuses[rec.Code]++
+			mu.Unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if statuses[http.StatusOK] != 1 || statuses[http.StatusConflict] != editors-1 {
+		t.Errorf("statuses = %v, want one %d and %d %d", statuses, http.StatusOK, editors-1, http.StatusConflict)
+	}
+	if got := reloadNote(t, db, note.NoteID); got.Version != 2 {
+		t.Errorf("version = %d, want 2", got.Version)
+	}
+}
+
+func TestUpdateNotePartialEdits(t *testing.T) {
+	r, db, note := newNoteTestRouter(t)
+	path := "/notes/" + note.NoteID.String()
+
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Renamed", "version": 1}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("title only: status %d, body %s", rec.Code, rec.Body)
+	}
+	if got := reloadNote(t, db, note.NoteID); got.Title != "Renamed" || got.Body != "First draft" {
+		t.Errorf("after a title-only edit note = %q/%q, want the body kept", got.Title, got.Body)
+	}
+
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"body": "", "version": 2}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("clear body: status %d, body %s", rec.Code, rec.Body)
+	}
+	if got := reloadNote(t, db, note.NoteID); got.Title != "Renamed" || got.Body != "" {
+		t.Errorf("after clearing the body note = %q/%q, want an empty body and the title kept", got.Title, got.Body)
+	}
+
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"version": 3}, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("no fields: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if got := reloadNote(t, db, note.NoteID); got.Version != 3 {
+		t.Errorf("version = %d after a refused no-op, want 3", got.Version)
+	}
+}
+
+func TestGetNoteETag(t *testing.T) {
+	r, _, note := newNoteTestRouter(t)
+	path := "/notes/" + note.NoteID.String()
+
+	rec := serve(t, r, http.MethodGet, path, nil, nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status %d, ETag %q; want 200 with an ETag", rec.Code, etag)
+	}
+
+	ifNoneMatch := http.Header{"If-None-Match": {etag}}
+	rec = serve(t, r, http.MethodGet, path, nil, ifNoneMatch)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("unchanged note: status %d with %d bytes, want an empty %d", rec.Code, rec.Body.Len(), http.StatusNotModified)
+	}
+
+	if rec := serve(t, r, http.MethodPut, path, gin.H{"title": "Revised"}, http.Header{"If-Match": {etag}}); rec.Code != http.StatusOK {
+		t.Fatalf("edit: status %d, body %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, r, http.MethodGet, path, nil, ifNoneMatch)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("edited note with the old ETag: status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("ETag"); got == "" || got == etag {
+		t.Errorf("ETag after an edit = %q, want a new one (was %q)", got, etag)
+	}
+}
+
+// The read check comes before the ETag one, so a user who lost access is told so rather
+// than that their cached copy is still current.
+func TestGetNoteETagAfterRevokedShare(t *testing.T) {
+	db := testdb.Open(t)
+	ownerID, readerID := uuid.New(), uuid.New()
+	folder := createTestFolder(t, db, ownerID)
+	note := models.Note{Title: "Plan", Body: "First draft", FolderID: folder.FolderID, OwnerID: ownerID, LastModifiedBy: ownerID}
+	if err := db.Omit("Folder", "Owner").Create(&note).Error; err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	share := models.NoteShare{NoteID: note.NoteID, UserID: readerID, Access: "read"}
+	if err := db.Create(&share).Error; err != nil {
+		t.Fatalf("share note: %v", err)
+	}
+
+	authorization := services.NewCachedAuthorizationService(db, logging.Nop())
+	nc := NewNoteController(db, authorization)
+	r := newTestRouter(readerID)
+	r.GET("/notes/:noteId", middlewares.CanReadNote(authorization), nc.GetNote)
+	path := "/notes/" + note.NoteID.String()
+
+	rec := serve(t, r, http.MethodGet, path, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("shared note: status %d, body %s", rec.Code, rec.Body)
+	}
+	ifNoneMatch := http.Header{"If-None-Match": {rec.Header().Get("ETag")}}
+
+	if err := db.Delete(&share).Error; err != nil {
+		t.Fatalf("revoke share: %v", err)
+	}
+	authorization.Purge()
+	if rec := serve(t, r, http.MethodGet, path, nil, ifNoneMatch); rec.Code != http.StatusForbidden {
+		t.Errorf("revoked reader with a current ETag: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestShareNoteAccess(t *testing.T) {
+	_, db, note := newNoteTestRouter(t)
+	nc := NewNoteController(db, services.NewCachedAuthorizationService(db, logging.Nop()))
+	r := newTestRouter(note.OwnerID)
+	r.POST("/notes/:noteId/share", nc.ShareNote)
+
+	checkShareAccess(t, r, "/notes/"+note.NoteID.String()+"/share", func(userID uuid.UUID) []string {
+		var accesses []string
+		if err := db.Model(&models.NoteShare{}).Where("note_id = ? AND user_id = ?", note.NoteID, userID).Pluck("access", &accesses).Error; err != nil {
+			t.Fatalf("list shares: %v", err)
+		}
+		return accesses
+	})
+}
+
+// A note shared by the owner of its folder, who does not own the note, still names the
+// note's owner; the sharer is the one who acted and the user shared with the target.
+func TestShareNoteEventNamesOwnerActorAndTarget(t *testing.T) {
+	_, db, note := newNoteTestRouter(t)
+	folder := models.Folder{FolderID: note.FolderID}
+	if err := db.First(&folder).Error; err != nil {
+		t.Fatalf("load folder: %v", err)
+	}
+	authorID, readerID := uuid.New(), uuid.New()
+	if err := db.Model(&note).Update("owner_id", authorID).Error; err != nil {
+		t.Fatalf("hand the note to another author: %v", err)
+	}
+	nc := NewNoteController(db, services.NewCachedAuthorizationService(db, logging.Nop()))
+	r := newTestRouter(folder.OwnerID)
+	r.POST("/notes/:noteId/share", nc.ShareNote)
+
+	body := gin.H{"userId": readerID, "access": "read"}
+	if rec := serve(t, r, http.MethodPost, "/notes/"+note.NoteID.String()+"/share", body, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("share note: status %d, body %s", rec.Code, rec.Body)
+	}
+	event := lastOutboxEvent(t, db, note.NoteID, "NOTE_SHARED")
+	if event.OwnerID != authorID.String() {
+		t.Errorf("OwnerID = %s, want the note's owner %s", event.OwnerID, authorID)
+	}
+	if event.ActionBy != folder.OwnerID.String() {
+		t.Errorf("ActionBy = %s, want the folder's owner %s", event.ActionBy, folder.OwnerID)
+	}
+	if event.TargetUserID != readerID.String() {
+		t.Errorf("TargetUserID = %s, want the reader %s", event.TargetUserID, readerID)
+	}
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/userclient"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a ShareBatchResult.
+const (
+	shareStatusShared    = "shared"
+	shareStatusUpdated   = "updated"
+	shareStatusUnchanged = "unchanged"
+	shareStatusFailed    = "failed"
+)
+
+// ShareBatchItem names the user by userId or by email.
+type ShareBatchItem struct {
+	UserID uuid.UUID `json:"userId"`
+	Email  string    `json:"email"`
+	Access string    `json:"access"`
+
+	// unresolved is why the email could not be turned into a user, see resolveBatchEmails.
+	unresolved string
+}
+
+// ShareBatchInput shares an asset with up to 100 users at once.
+type ShareBatchInput struct {
+	Shares []ShareBatchItem `json:"shares" binding:"required,min=1,max=100"`
+}
+
+// ShareBatchResult reports what happened to one item of a batch share, in request order.
+type ShareBatchResult struct {
+	UserID uuid.UUID `json:"userId"`
+	Email  string    `json:"email,omitempty"`
+	Access string    `json:"access"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// ShareBatchSummary is the response of a batch share. Items that fail validation are
+// reported and skipped; all other items are written in a single transaction, so they
+// either all take effect or the request fails as a whole.
+type ShareBatchSummary struct {
+	Shared    int                `json:"shared"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Failed    int                `json:"failed"`
+	Results   []ShareBatchResult `json:"results"`
+}
+
+// batchUserIDs returns the distinct user IDs in a batch, for looking up existing shares.
+func batchUserIDs(items []ShareBatchItem) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(items))
+	ids := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		if item.UserID != uuid.Nil && !seen[item.UserID] {
+			seen[item.UserID] = true
+			ids = append(ids, item.UserID)
+		}
+	}
+	return ids
+}
+
+// planShareBatch validates a batch against the users' current access and returns the
+// summary along with the items that need writing. A user listed more than once only
+// counts the first time; sharing with the access a user already has is a no-op.
+func planShareBatch(items []ShareBatchItem, existing map[uuid.UUID]string) (ShareBatchSummary, []ShareBatchItem) {
+	summary := ShareBatchSummary{Results: make([]ShareBatchResult, 0, len(items))}
+	writes := make([]ShareBatchItem, 0, len(items))
+	seen := make(map[uuid.UUID]bool, len(items))
+
+	for _, item := range items {
+		result := ShareBatchResult{UserID: item.UserID, Email: item.Email, Access: item.Access}
+		switch {
+		case item.unresolved != "":
+			result.Status, result.Reason = shareStatusFailed, item.unresolved
+		case item.UserID == uuid.Nil:
+			result.Status, result.Reason = shareStatusFailed, "userId or email is required"
+		case !models.ValidAccess(item.Access):
+			result.Status, result.Reason = shareStatusFailed, "access must be read or write"
+		case seen[item.UserID]:
+			result.Status, result.Reason = shareStatusFailed, "user is listed more than once"
+		case existing[item.UserID] == item.Access:
+			result.Status = shareStatusUnchanged
+		case existing[item.UserID] != "":
+			result.Status = shareStatusUpdated
+		default:
+			result.Status = shareStatusShared
+		}
+		if item.UserID != uuid.Nil {
+			seen[item.UserID] = true
+		}
+
+		switch result.Status {
+		case shareStatusShared:
+			summary.Shared++
+		case shareStatusUpdated:
+			summary.Updated++
+		case shareStatusUnchanged:
+			summary.Unchanged++
+		case shareStatusFailed:
+			summary.Failed++
+		}
+		if result.Status == shareStatusShared || result.Status == shareStatusUpdated {
+			writes = append(writes, item)
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	return summary, writes
+}
+
+// shareUser returns the user a share is for, given by ID or by email. Emails are looked up
+// in the user service; the ID found is what the share row and its event carry.
+func shareUser(ctx context.Context, users *userclient.Client, userID uuid.UUID, email string) (uuid.UUID, *errorHandling.CustomError) {
+	switch {
+	case userID != uuid.Nil && email != "":
+		return uuid.Nil, &errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Pass either userId or email, not both"}
+	case userID != uuid.Nil:
+		return userID, nil
+	case email == "":
+		return uuid.Nil, &errorHandling.CustomError{Code: http.StatusBadRequest, Message: "userId or email is required"}
+	}
+
+	user, err := users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return uuid.Nil, &errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Failed to connect to user service"}
+	}
+	if user == nil {
+		return uuid.Nil, &errorHandling.CustomError{Code: http.StatusNotFound, Message: "No user has this email"}
+	}
+	id, err := uuid.Parse(user.UserID)
+	if err != nil {
+		return uuid.Nil, &errorHandling.CustomError{Code: http.StatusBadGateway, Message: "User service returned an invalid user ID"}
+	}
+	return id, nil
+}
+
+// resolveBatchEmails fills in the user ID of every item given by email. Items naming
+// nobody, or both an ID and an email, are left for planShareBatch to report as failed;
+// only a user service that cannot be reached fails the whole batch.
+func resolveBatchEmails(ctx context.Context, users *userclient.Client, items []ShareBatchItem) *errorHandling.CustomError {
+	for i := range items {
+		if items[i].Email == "" {
+			continue
+		}
+		id, customErr := shareUser(ctx, users, items[i].UserID, items[i].Email)
+		if customErr == nil {
+			items[i].UserID = id
+			continue
+		}
+		switch customErr.Code {
+		case http.StatusBadRequest:
+			items[i].unresolved = "pass either userId or email, not both"
+		case http.StatusNotFound:
+			items[i].unresolved = "no user has this email"
+		case http.StatusBadGateway:
+			items[i].unresolved = "user service returned an invalid user ID"
+		default:
+			return customErr
+		}
+	}
+	return nil
+}
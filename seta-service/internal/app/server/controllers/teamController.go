@@ -1,26 +1,49 @@
 package controllers
 
 import (
-	"context"
+	"errors"
+	"math"
 	"net/http"
+	"seta-pkg/database"
+	"seta-pkg/logging"
+	"seta/internal/app/server/services"
 	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
 	"seta/internal/pkg/kafka"
 	"seta/internal/pkg/models"
+	"seta/internal/pkg/userclient"
 	"seta/internal/pkg/utils" // Import the new utils package
+	"slices"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TeamController now has its own db field and no longer embeds BaseController.
 type TeamController struct {
-	db *gorm.DB
+	db            *gorm.DB
+	authorization *services.AuthorizationService
+	hygiene       *services.AssetHygieneService
+	collaboration *services.CollaborationService
+	stats         *services.StatsService
+	teams         *services.TeamService
+	users         *userclient.Client
 }
 
 // NewTeamController creates a new TeamController, injecting the db dependency.
-func NewTeamController(db *gorm.DB) *TeamController {
-	return &TeamController{db: db}
+func NewTeamController(db *gorm.DB, authorization *services.AuthorizationService, log logging.Logger) *TeamController {
+	return &TeamController{
+		db:            db,
+		authorization: authorization,
+		hygiene:       services.NewAssetHygieneService(db, log),
+		collaboration: services.NewCollaborationService(db),
+		stats:         services.NewStatsService(db),
+		teams:         services.NewTeamService(db),
+		users:         userclient.Shared(),
+	}
 }
 
 type ManagerInput struct {
@@ -38,13 +61,16 @@ type CreateTeamInput struct {
 	TeamName string         `json:"teamName" binding:"required"`
 	Managers []ManagerInput `json:"managers" binding:"required,min=1"`
 	Members  []MemberInput  `json:"members"`
+
+	// CreateDefaultFolder also creates a team folder named after the team, owned by the creator.
+	CreateDefaultFolder bool `json:"createDefaultFolder"`
 }
 
-// CreateTeam creates a new team.
+// CreateTeam creates a new team and, on request, its default team folder.
 func (tc *TeamController) CreateTeam(c *gin.Context) {
 	var input CreateTeamInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Invalid request body: " + err.Error()})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
@@ -84,7 +110,10 @@ func (tc *TeamController) CreateTeam(c *gin.Context) {
 	}
 
 	team := models.Team{TeamName: input.TeamName}
+	var defaultFolder *models.Folder
 
+	managerIDs := make([]string, 0, len(input.Managers))
+	memberIDs := make([]string, 0, len(input.Members))
 	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&team).Error; err != nil {
 			return err
@@ -94,34 +123,149 @@ func (tc *TeamController) CreateTeam(c *gin.Context) {
 			if err := tx.Create(&teamManager).Error; err != nil {
 				return err
 			}
+			managerIDs = append(managerIDs, manager.ManagerID.String())
 		}
 		for _, member := range input.Members {
 			teamMember := models.TeamMember{TeamID: team.ID, UserID: member.MemberID}
 			if err := tx.Create(&teamMember).Error; err != nil {
 				return err
 			}
+			memberIDs = append(memberIDs, member.MemberID.String())
 		}
-		return nil
+		if input.CreateDefaultFolder {
+			defaultFolder = &models.Folder{Name: team.TeamName, OwnerID: creatorUserID, TeamID: &team.ID}
+			if err := tx.Create(defaultFolder).Error; err != nil {
+				return err
+			}
+			if err := kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_CREATED", *defaultFolder, creatorUserID)); err != nil {
+				return err
+			}
+		}
+		event := kafka.NewTeamEvent("TEAM_CREATED", team.ID, creatorUserID)
+		event.Members, event.Managers = memberIDs, managerIDs
+		return kafka.EnqueueTeamEvent(tx, event)
 	})
 
 	if err != nil {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to create team: " + err.Error()})
 		return
 	}
-	
-	go kafka.ProduceTeamEvent(context.Background(), kafka.EventPayload{
-		EventType: "TEAM_CREATED",
-		TeamID:    team.ID.String(),
-		ActionBy:  creatorUserID.String(),
-	})
 
-
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message": "Team created successfully",
 		"team":    team,
+	}
+	if defaultFolder != nil {
+		response["defaultFolderId"] = defaultFolder.FolderID
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+const (
+	defaultTeamPageSize = 50
+	maxTeamPageSize     = 200
+)
+
+// ListMyTeams lists the teams the caller manages or belongs to, with their role in each,
+// a page at a time with ?limit (default 50, max 200) and ?offset.
+func (tc *TeamController) ListMyTeams(c *gin.Context) {
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query := httpquery.New(c.Request.URL.Query())
+	page := query.Pagination(defaultTeamPageSize, maxTeamPageSize)
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	teams, total, err := tc.teams.FindTeamsByUser(c.Request.Context(), userID, page.Limit, page.Offset)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve teams"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  teams,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
 	})
 }
 
+// TeamManagerDetail is a manager as listed by GetTeam.
+type TeamManagerDetail struct {
+	UserID uuid.UUID `json:"userId"`
+	IsLead bool      `json:"isLead"`
+}
+
+// TeamMemberDetail is a member as listed by GetTeam.
+type TeamMemberDetail struct {
+	UserID uuid.UUID `json:"userId"`
+}
+
+// TeamDetail is the team returned by GetTeam.
+type TeamDetail struct {
+	TeamID    uuid.UUID           `json:"teamId"`
+	TeamName  string              `json:"teamName"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Managers  []TeamManagerDetail `json:"managers"`
+	Members   []TeamMemberDetail  `json:"members"`
+}
+
+// GetTeam returns the team with its managers (lead first) and members. Only people on
+// the team can read it; everyone else gets a 404 so team IDs cannot be probed.
+func (tc *TeamController) GetTeam(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	onTeam, customErr := tc.authorization.IsOnTeam(userID, teamID)
+	if customErr != nil {
+		_ = c.Error(customErr)
+		return
+	}
+
+	var team models.Team
+	if !onTeam || tc.db.WithContext(c.Request.Context()).First(&team, "id = ?", teamID).Error != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Team not found"})
+		return
+	}
+
+	detail := TeamDetail{
+		TeamID:    team.ID,
+		TeamName:  team.TeamName,
+		CreatedAt: team.CreatedAt,
+		Managers:  make([]TeamManagerDetail, 0),
+		Members:   make([]TeamMemberDetail, 0),
+	}
+	if err := tc.db.WithContext(c.Request.Context()).Model(&models.TeamManager{}).
+		Select("user_id", "is_lead").Where("team_id = ?", teamID).
+		Order("is_lead DESC, user_id").Scan(&detail.Managers).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve team managers"})
+		return
+	}
+	if err := tc.db.WithContext(c.Request.Context()).Model(&models.TeamMember{}).
+		Select("user_id").Where("team_id = ?", teamID).
+		Order("user_id").Scan(&detail.Members).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve team members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
 type AddRemoveMemberInput struct {
 	UserID uuid.UUID `json:"userId" binding:"required"`
 }
@@ -135,28 +279,74 @@ func (tc *TeamController) AddMember(c *gin.Context) {
 	}
 
 	var input AddRemoveMemberInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Invalid request body"})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	teamMember := models.TeamMember{TeamID: teamID, UserID: input.UserID}
-	if err := tc.db.WithContext(c.Request.Context()).Create(&teamMember).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to add member to team"})
+	actorUserID, _ := utils.GetUserUUIDFromContext(c) // Error already handled by auth middleware
+
+	idempotent, ok := tc.checkUserToAdd(c, input.UserID)
+	if !ok {
 		return
 	}
 
-	actorUserID, _ := utils.GetUserUUIDFromContext(c) // Error already handled by auth middleware
-	go kafka.ProduceTeamEvent(context.Background(), kafka.EventPayload{
-		EventType:    "MEMBER_ADDED",
-		TeamID:       teamID.String(),
-		ActionBy:     actorUserID.String(),
-		TargetUserID: input.UserID.String(),
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		teamMember := models.TeamMember{TeamID: teamID, UserID: input.UserID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&teamMember)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyOnTeam
+		}
+		if err := tx.Create(&models.TeamMembershipChange{TeamID: teamID, UserID: input.UserID, Change: "added", ChangedBy: actorUserID}).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("MEMBER_ADDED", teamID, actorUserID).WithTarget(input.UserID))
 	})
+	if errors.Is(err, errAlreadyOnTeam) {
+		if idempotent {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "User is already a member of this team"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to add member to team"})
+		return
+	}
 
+	tc.authorization.Purge()
 	c.Status(http.StatusNoContent)
 }
 
+// errAlreadyOnTeam aborts an add whose member or manager row already exists.
+var errAlreadyOnTeam = errors.New("user is already on the team")
+
+// checkUserToAdd reads the idempotent query parameter and confirms the user exists in the
+// user service. When ok is false the error has already been reported.
+func (tc *TeamController) checkUserToAdd(c *gin.Context, userID uuid.UUID) (idempotent bool, ok bool) {
+	query := httpquery.New(c.Request.URL.Query())
+	idempotent = query.Bool("idempotent", false)
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return false, false
+	}
+
+	user, err := tc.users.GetUser(c.Request.Context(), userID.String())
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Failed to connect to user service"})
+		return false, false
+	}
+	if user == nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "User not found"})
+		return false, false
+	}
+	return idempotent, true
+}
+
 // RemoveMember removes a member from a team.
 func (tc *TeamController) RemoveMember(c *gin.Context) {
 	teamID, err := utils.GetUUIDFromParam(c, "teamId")
@@ -171,19 +361,86 @@ func (tc *TeamController) RemoveMember(c *gin.Context) {
 		return
 	}
 
-	if err := tc.db.WithContext(c.Request.Context()).Delete(&models.TeamMember{TeamID: teamID, UserID: memberID}).Error; err != nil {
+	actorUserID, _ := utils.GetUserUUIDFromContext(c)
+
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.TeamMember{TeamID: teamID, UserID: memberID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Create(&models.TeamMembershipChange{TeamID: teamID, UserID: memberID, Change: "removed", ChangedBy: actorUserID}).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("MEMBER_REMOVED", teamID, actorUserID).WithTarget(memberID))
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Member not found in this team"})
+		return
+	}
+	if err != nil {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to remove member from team"})
 		return
 	}
 
-	actorUserID, _ := utils.GetUserUUIDFromContext(c)
-	go kafka.ProduceTeamEvent(context.Background(), kafka.EventPayload{
-		EventType:    "MEMBER_REMOVED",
-		TeamID:       teamID.String(),
-		ActionBy:     actorUserID.String(),
-		TargetUserID: memberID.String(),
+	tc.authorization.Purge()
+	c.Status(http.StatusNoContent)
+}
+
+var errManagerCannotLeave = errors.New("managers cannot leave their team")
+
+// LeaveTeam removes the requester's own membership. Managers are refused: they have to
+// be removed through the manager routes first. The MEMBER_REMOVED event has the member
+// as both actor and target, which marks the departure as voluntary.
+func (tc *TeamController) LeaveTeam(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var managerCount int64
+		if err := tx.Model(&models.TeamManager{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&managerCount).Error; err != nil {
+			return err
+		}
+		if managerCount > 0 {
+			return errManagerCannotLeave
+		}
+
+		result := tx.Delete(&models.TeamMember{TeamID: teamID, UserID: userID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Create(&models.TeamMembershipChange{TeamID: teamID, UserID: userID, Change: "removed", ChangedBy: userID}).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("MEMBER_REMOVED", teamID, userID).WithTarget(userID))
 	})
+	switch {
+	case errors.Is(err, errManagerCannotLeave):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "Managers cannot leave a team; ask the lead manager to remove you"})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "You are not a member of this team"})
+		return
+	case err != nil:
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to leave team"})
+		return
+	}
 
+	tc.authorization.Purge()
 	c.Status(http.StatusNoContent)
 }
 
@@ -196,29 +453,56 @@ func (tc *TeamController) AddManager(c *gin.Context) {
 	}
 
 	var input AddRemoveMemberInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Invalid request body"})
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	teamManager := models.TeamManager{TeamID: teamID, UserID: input.UserID}
-	if err := tc.db.WithContext(c.Request.Context()).Create(&teamManager).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to add manager to team"})
+	actorUserID, _ := utils.GetUserUUIDFromContext(c)
+
+	idempotent, ok := tc.checkUserToAdd(c, input.UserID)
+	if !ok {
 		return
 	}
 
-	actorUserID, _ := utils.GetUserUUIDFromContext(c)
-	go kafka.ProduceTeamEvent(context.Background(), kafka.EventPayload{
-		EventType:    "MANAGER_ADDED",
-		TeamID:       teamID.String(),
-		ActionBy:     actorUserID.String(),
-		TargetUserID: input.UserID.String(),
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		teamManager := models.TeamManager{TeamID: teamID, UserID: input.UserID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&teamManager)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyOnTeam
+		}
+		return kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("MANAGER_ADDED", teamID, actorUserID).WithTarget(input.UserID))
 	})
+	if errors.Is(err, errAlreadyOnTeam) {
+		if idempotent {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "User is already a manager of this team"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to add manager to team"})
+		return
+	}
 
+	tc.authorization.Purge()
 	c.Status(http.StatusNoContent)
 }
 
-// RemoveManager removes a manager from a team.
+var (
+	errLastManager        = errors.New("team would be left without a manager")
+	errLeadNeedsSuccessor = errors.New("removing the lead needs a successor")
+	errNotAManager        = errors.New("user is not a manager of the team")
+)
+
+// RemoveManager removes a manager from a team. The last manager can never be removed,
+// and the lead only with ?successorId naming another manager, who becomes lead in the
+// same transaction. The team row is locked while the managers are counted, so two
+// removals cannot each see a manager left and together remove both.
 func (tc *TeamController) RemoveManager(c *gin.Context) {
 	teamID, err := utils.GetUUIDFromParam(c, "teamId")
 	if err != nil {
@@ -232,23 +516,315 @@ func (tc *TeamController) RemoveManager(c *gin.Context) {
 		return
 	}
 
-	if err := tc.db.WithContext(c.Request.Context()).Delete(&models.TeamManager{TeamID: teamID, UserID: managerID}).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to remove manager from team"})
+	query := httpquery.New(c.Request.URL.Query())
+	successorID, hasSuccessor := query.UUID("successorId")
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
 	actorUserID, _ := utils.GetUserUUIDFromContext(c)
-	go kafka.ProduceTeamEvent(context.Background(), kafka.EventPayload{
-		EventType:    "MANAGER_REMOVED",
-		TeamID:       teamID.String(),
-		ActionBy:     actorUserID.String(),
-		TargetUserID: managerID.String(),
+
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, "id = ?", teamID).Error; err != nil {
+			return err
+		}
+
+		var manager models.TeamManager
+		if err := tx.First(&manager, "team_id = ? AND user_id = ?", teamID, managerID).Error; err != nil {
+			return err
+		}
+		var managerCount int64
+		if err := tx.Model(&models.TeamManager{}).Where("team_id = ?", teamID).Count(&managerCount).Error; err != nil {
+			return err
+		}
+		if managerCount <= 1 {
+			return errLastManager
+		}
+		if manager.IsLead && !hasSuccessor {
+			return errLeadNeedsSuccessor
+		}
+
+		if err := tx.Delete(&models.TeamManager{TeamID: teamID, UserID: managerID}).Error; err != nil {
+			return err
+		}
+		if err := kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("MANAGER_REMOVED", teamID, actorUserID).WithTarget(managerID)); err != nil {
+			return err
+		}
+		if !manager.IsLead {
+			return nil
+		}
+
+		promoted := tx.Model(&models.TeamManager{}).
+			Where("team_id = ? AND user_id = ?", teamID, successorID).
+			Update("is_lead", true)
+		if promoted.Error != nil {
+			return promoted.Error
+		}
+		if promoted.RowsAffected == 0 {
+			return errNotAManager
+		}
+		return kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("LEAD_TRANSFERRED", teamID, actorUserID).WithTarget(successorID))
 	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Manager not found in this team"})
+		return
+	case errors.Is(err, errLastManager):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "The last manager of a team cannot be removed"})
+		return
+	case errors.Is(err, errLeadNeedsSuccessor):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "Removing the lead manager requires successorId naming the new lead"})
+		return
+	case errors.Is(err, errNotAManager):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "The new lead must already be a manager of this team"})
+		return
+	case err != nil:
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to remove manager from team"})
+		return
+	}
 
+	tc.authorization.Purge()
 	c.Status(http.StatusNoContent)
 }
 
-// GetTeamAssets retrieves all assets belonging to or shared with a team's members.
+type RenameTeamInput struct {
+	TeamName string `json:"teamName" binding:"required"`
+}
+
+// RenameTeam changes a team's name.
+func (tc *TeamController) RenameTeam(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var input RenameTeamInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var team models.Team
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&team, "id = ?", teamID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&team).Update("team_name", input.TeamName).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("TEAM_RENAMED", teamID, actorUserID))
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Team not found"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to rename team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+var errNotLeadManager = errors.New("requester is no longer the lead manager")
+
+// TransferLead hands the lead role to another manager of the team. The team row is
+// locked while the current lead is demoted and the new one promoted, so concurrent
+// transfers run one after the other and the second one finds its requester no longer
+// lead. The one-lead index on team_managers backs this up: the team always has exactly
+// one lead. Transferring the role to the current lead changes nothing, but still answers
+// 404 for a missing team and 403 for a requester who is not its lead.
+func (tc *TeamController) TransferLead(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var input AddRemoveMemberInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, "id = ?", teamID).Error; err != nil {
+			return err
+		}
+
+		if input.UserID == actorUserID {
+			var lead int64
+			if err := tx.Model(&models.TeamManager{}).
+				Where("team_id = ? AND user_id = ? AND is_lead", teamID, actorUserID).
+				Count(&lead).Error; err != nil {
+				return err
+			}
+			if lead == 0 {
+				return errNotLeadManager
+			}
+			return nil
+		}
+
+		demoted := tx.Model(&models.TeamManager{}).
+			Where("team_id = ? AND user_id = ? AND is_lead", teamID, actorUserID).
+			Update("is_lead", false)
+		if demoted.Error != nil {
+			return demoted.Error
+		}
+		if demoted.RowsAffected == 0 {
+			return errNotLeadManager
+		}
+
+		promoted := tx.Model(&models.TeamManager{}).
+			Where("team_id = ? AND user_id = ?", teamID, input.UserID).
+			Update("is_lead", true)
+		if promoted.Error != nil {
+			return promoted.Error
+		}
+		if promoted.RowsAffected == 0 {
+			return errNotAManager
+		}
+
+		return kafka.EnqueueTeamEvent(tx, kafka.NewTeamEvent("LEAD_TRANSFERRED", teamID, actorUserID).WithTarget(input.UserID))
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Team not found"})
+		return
+	case errors.Is(err, errNotLeadManager):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "You must be a lead manager to perform this action"})
+		return
+	case errors.Is(err, errNotAManager):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "The new lead must already be a manager of this team"})
+		return
+	case err != nil:
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to transfer lead manager role"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteTeam deletes a team with its managers, members and membership history in one
+// transaction. The team's folders and announcements are kept for their owners: team
+// folders become ordinary folders and announcements ordinary notes, each with an update
+// event, so no content goes with the team. TEAM_DELETED lists the users who were on it.
+func (tc *TeamController) DeleteTeam(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		// Locking the team row makes concurrent membership changes wait for the delete
+		// and then fail on the missing team instead of leaving rows behind.
+		var team models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, "id = ?", teamID).Error; err != nil {
+			return err
+		}
+
+		var managerIDs, memberIDs []string
+		if err := tx.Model(&models.TeamManager{}).Where("team_id = ?", teamID).Pluck("user_id", &managerIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TeamMember{}).Where("team_id = ?", teamID).Pluck("user_id", &memberIDs).Error; err != nil {
+			return err
+		}
+
+		var folders []models.Folder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("team_id = ?", teamID).Find(&folders).Error; err != nil {
+			return err
+		}
+		if len(folders) > 0 {
+			if err := tx.Model(&models.Folder{}).Where("team_id = ?", teamID).Update("team_id", nil).Error; err != nil {
+				return err
+			}
+			for _, folder := range folders {
+				folder.TeamID = nil
+				if err := kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_UPDATED", folder, actorUserID)); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Unscoped: announcements in the trash would otherwise keep the dangling team ID.
+		var announcements []models.Note
+		if err := tx.Unscoped().Select("note_id", "folder_id", "owner_id", "cacheable", "deleted_at").
+			Where("team_id = ?", teamID).Find(&announcements).Error; err != nil {
+			return err
+		}
+		if len(announcements) > 0 {
+			if err := tx.Unscoped().Model(&models.Note{}).Where("team_id = ?", teamID).
+				Updates(map[string]any{"team_id": nil, "is_announcement": false}).Error; err != nil {
+				return err
+			}
+			for _, note := range announcements {
+				if note.DeletedAt.Valid {
+					continue
+				}
+				if err := kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_UPDATED", note, actorUserID)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamManager{}).Error; err != nil {
+			return err
+		}
+		// Membership history and team templates go with the team through ON DELETE CASCADE.
+		if err := tx.Delete(&team).Error; err != nil {
+			return err
+		}
+		event := kafka.NewTeamEvent("TEAM_DELETED", teamID, actorUserID)
+		event.Members, event.Managers = memberIDs, managerIDs
+		return kafka.EnqueueTeamEvent(tx, event)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Team not found"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete team"})
+		return
+	}
+
+	tc.authorization.Purge()
+	c.Status(http.StatusNoContent)
+}
+
+// GetTeamAssets retrieves the assets belonging to or shared with a team's members, a
+// page at a time (?limit, ?cursor), most recently updated first with the ID as tiebreaker
+// so every row has one place in the order. Active announcements lead the first page.
+//
+// ?memberId narrows the listing to the assets of one member, ?type to folders or notes
+// and ?updatedSince to assets updated at or after that time. Announcements are only
+// listed when none of these is given.
 func (tc *TeamController) GetTeamAssets(c *gin.Context) {
 	teamID, err := utils.GetUUIDFromParam(c, "teamId")
 	if err != nil {
@@ -256,37 +832,390 @@ func (tc *TeamController) GetTeamAssets(c *gin.Context) {
 		return
 	}
 
-	var memberIDs []uuid.UUID
-	if err := tc.db.Model(&models.TeamMember{}).Where("team_id = ?", teamID).Pluck("user_id", &memberIDs).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve team members"})
+	limit, cursor, err := utils.GetAssetPageParams(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
+	withCounts, err := getWithCounts(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if withCounts {
+		limit = min(limit, maxCountedPageSize)
+	}
+	filters, err := getTeamAssetFilters(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	actorUserID, _ := utils.GetUserUUIDFromContext(c)
+	var (
+		announcements        []models.Note
+		listedFolders        any
+		notes                []models.Note
+		nextFolder, nextNote *utils.CursorPosition
+	)
+	err = database.Read(c.Request.Context(), tc.db, func(tx *gorm.DB) error {
+		var memberIDs []uuid.UUID
+		if err := tx.Model(&models.TeamMember{}).Where("team_id = ?", teamID).Pluck("user_id", &memberIDs).Error; err != nil {
+			return err
+		}
+		if filters.hasMember {
+			if !slices.Contains(memberIDs, filters.memberID) {
+				return &errorHandling.CustomError{Code: http.StatusBadRequest, Message: "memberId is not a member of this team"}
+			}
+			memberIDs = []uuid.UUID{filters.memberID}
+		}
+
+		announcements = make([]models.Note, 0)
+		if cursor.IsFirstPage() && filters.none() {
+			var err error
+			if announcements, err = announcementsOf(tx, teamID, true); err != nil {
+				return err
+			}
+		}
+
+		folders := make([]models.Folder, 0)
+		notes = make([]models.Note, 0)
+		if len(memberIDs) == 0 {
+			listedFolders = folders
+			return nil
+		}
+
+		if !cursor.FoldersDone && filters.assetType != "note" {
+			query := tx.Joins("LEFT JOIN folder_shares ON folders.folder_id = folder_shares.folder_id").
+				Where("folders.owner_id IN (?) OR folder_shares.user_id IN (?)", memberIDs, memberIDs).
+				Where("folders.deletion_pending = ?", false).
+				Group("folders.folder_id")
+			if filters.hasUpdatedSince {
+				query = query.Where("folders.updated_at >= ?", filters.updatedSince)
+			}
+			if err := utils.KeysetPage(query, "folders.updated_at", "folders.folder_id", cursor.Folders, limit).Find(&folders).Error; err != nil {
+				return err
+			}
+		}
+		folders, nextFolder = utils.TrimPage(folders, limit, folderUpdatedCursorKey)
 
-	if len(memberIDs) == 0 {
-		c.JSON(http.StatusOK, gin.H{"folders": []models.Folder{}, "notes": []models.Note{}})
+		if !cursor.NotesDone && filters.assetType != "folder" {
+			query := tx.Joins("LEFT JOIN note_shares ON notes.note_id = note_shares.note_id").
+				Where("notes.owner_id IN (?) OR note_shares.user_id IN (?)", memberIDs, memberIDs).
+				Where("notes.folder_id NOT IN (SELECT folder_id FROM folders WHERE deletion_pending)").
+				Group("notes.note_id")
+			if filters.hasUpdatedSince {
+				query = query.Where("notes.updated_at >= ?", filters.updatedSince)
+			}
+			if err := utils.KeysetPage(query, "notes.updated_at", "notes.note_id", cursor.Notes, limit).Find(&notes).Error; err != nil {
+				return err
+			}
+		}
+		notes, nextNote = utils.TrimPage(notes, limit, noteUpdatedCursorKey)
+
+		listedFolders = folders
+		if withCounts {
+			counted, err := foldersWithCounts(tx, actorUserID, folders)
+			if err != nil {
+				return err
+			}
+			listedFolders = counted
+		}
+		return nil
+	})
+	if err != nil {
+		_ = c.Error(readError(err, "Failed to retrieve team assets"))
 		return
 	}
 
-	var assets struct {
-		Folders []models.Folder `json:"folders"`
-		Notes   []models.Note   `json:"notes"`
+	c.JSON(http.StatusOK, gin.H{
+		"announcements": announcements,
+		"folders":       listedFolders,
+		"notes":         notes,
+		"nextCursor":    cursor.Next(nextFolder, nextNote),
+	})
+}
+
+// teamAssetFilters are the optional filters of GetTeamAssets.
+type teamAssetFilters struct {
+	memberID        uuid.UUID
+	hasMember       bool
+	assetType       string // "folder", "note" or "" for both
+	updatedSince    time.Time
+	hasUpdatedSince bool
+}
+
+func (f teamAssetFilters) none() bool {
+	return !f.hasMember && f.assetType == "" && !f.hasUpdatedSince
+}
+
+// getTeamAssetFilters reads ?memberId, ?type and ?updatedSince.
+func getTeamAssetFilters(c *gin.Context) (teamAssetFilters, error) {
+	query := httpquery.New(c.Request.URL.Query())
+	var filters teamAssetFilters
+	filters.memberID, filters.hasMember = query.UUID("memberId")
+	filters.assetType = query.Enum("type", "", "folder", "note")
+	filters.updatedSince, filters.hasUpdatedSince = query.Time("updatedSince")
+	return filters, query.Err()
+}
+
+func folderUpdatedCursorKey(folder models.Folder) utils.CursorPosition {
+	return utils.CursorPosition{At: folder.UpdatedAt, ID: folder.FolderID}
+}
+
+func noteUpdatedCursorKey(note models.Note) utils.CursorPosition {
+	return utils.CursorPosition{At: note.UpdatedAt, ID: note.NoteID}
+}
+
+type CreateAnnouncementInput struct {
+	Title    string    `json:"title" binding:"required"`
+	Body     string    `json:"body"`
+	FolderID uuid.UUID `json:"folderId" binding:"required"`
+}
+
+// CreateAnnouncement posts a note pinned for the whole team. It is stored in a folder the
+// manager can write to, but members read it through their membership, not share rows.
+func (tc *TeamController) CreateAnnouncement(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	if err := tc.db.Joins("LEFT JOIN folder_shares ON folders.folder_id = folder_shares.folder_id").
-		Where("folders.owner_id IN (?) OR folder_shares.user_id IN (?)", memberIDs, memberIDs).
-		Group("folders.folder_id").
-		Find(&assets.Folders).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve folders"})
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	if err := tc.db.Joins("LEFT JOIN note_shares ON notes.note_id = note_shares.note_id").
-		Where("notes.owner_id IN (?) OR note_shares.user_id IN (?)", memberIDs, memberIDs).
-		Group("notes.note_id").
-		Find(&assets.Notes).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve notes"})
+	var input CreateAnnouncementInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, assets)
-}
\ No newline at end of file
+	canWrite, customErr := tc.authorization.CanWriteAsset(userID, "folder", input.FolderID)
+	if customErr != nil {
+		_ = c.Error(customErr)
+		return
+	}
+	if !canWrite {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "You are not authorized to write to this folder"})
+		return
+	}
+
+	note := models.Note{
+		Title:          input.Title,
+		Body:           input.Body,
+		FolderID:       input.FolderID,
+		OwnerID:        userID,
+		IsAnnouncement: true,
+		TeamID:         &teamID,
+		Active:         true,
+	}
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := lockFolderForNewNote(tx, input.FolderID); err != nil {
+			return err
+		}
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_CREATED", note, userID))
+	})
+	if err != nil {
+		_ = c.Error(newNoteError(err, "Failed to create announcement"))
+		return
+	}
+
+	tc.authorization.Purge()
+	c.JSON(http.StatusCreated, note)
+}
+
+// ListAnnouncements lists the team's announcements, newest first. Managers also see
+// inactive ones.
+func (tc *TeamController) ListAnnouncements(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	isManager, customErr := tc.authorization.IsTeamManager(userID, teamID)
+	if customErr != nil {
+		_ = c.Error(customErr)
+		return
+	}
+
+	announcements, err := announcementsOf(tc.db.WithContext(c.Request.Context()), teamID, !isManager)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve announcements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, announcements)
+}
+
+type UpdateAnnouncementInput struct {
+	Active *bool `json:"active" binding:"required"`
+}
+
+// UpdateAnnouncement lets a manager show or hide an announcement.
+func (tc *TeamController) UpdateAnnouncement(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	noteID, err := utils.GetUUIDFromParam(c, "noteId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var input UpdateAnnouncementInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var note models.Note
+	if err := tc.db.WithContext(c.Request.Context()).First(&note, "note_id = ? AND team_id = ? AND is_announcement", noteID, teamID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Announcement not found"})
+		return
+	}
+
+	err = tc.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&note).Updates(map[string]any{"active": *input.Active, "last_modified_by": userID}).Error; err != nil {
+			return err
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_UPDATED", note, userID))
+	})
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to update announcement"})
+		return
+	}
+
+	tc.authorization.Purge()
+	c.JSON(http.StatusOK, note)
+}
+
+func announcementsOf(db *gorm.DB, teamID uuid.UUID, activeOnly bool) ([]models.Note, error) {
+	announcements := make([]models.Note, 0)
+	query := db.
+		Where("team_id = ? AND is_announcement", teamID).
+		Where("folder_id NOT IN (SELECT folder_id FROM folders WHERE deletion_pending)")
+	if activeOnly {
+		query = query.Where("active")
+	}
+	err := query.Order("created_at DESC").Find(&announcements).Error
+	return announcements, err
+}
+
+const (
+	defaultHygienePageSize = 50
+	maxHygienePageSize     = 200
+)
+
+// GetAssetHygiene reports team assets that likely need cleanup: unshared assets not
+// updated in ?staleDays days, assets of members removed in the last 90 days and assets
+// whose owner no longer exists in the user directory. Each list is paged on its own with
+// ?limit and ?staleOffset, ?formerMemberOffset, ?unresolvedOwnerOffset.
+func (tc *TeamController) GetAssetHygiene(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query := httpquery.New(c.Request.URL.Query())
+	staleDays := query.Int("staleDays", tc.hygiene.DefaultStaleDays(), 1, math.MaxInt)
+	limit := query.Int("limit", defaultHygienePageSize, 1, maxHygienePageSize)
+	offsets := make(map[string]int, 3)
+	for _, param := range []string{"staleOffset", "formerMemberOffset", "unresolvedOwnerOffset"} {
+		offsets[param] = query.Int(param, 0, 0, math.MaxInt)
+	}
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	report, err := tc.hygiene.Report(c.Request.Context(), teamID, staleDays)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to build asset hygiene report"})
+		return
+	}
+
+	unresolved := hygienePage(report.UnresolvedOwner, limit, offsets["unresolvedOwnerOffset"])
+	if report.UnresolvedOwnerError != "" {
+		unresolved["error"] = report.UnresolvedOwnerError
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"staleDays":         report.StaleDays,
+		"generatedAt":       report.GeneratedAt,
+		"staleUnshared":     hygienePage(report.StaleUnshared, limit, offsets["staleOffset"]),
+		"formerMemberOwned": hygienePage(report.FormerMemberOwned, limit, offsets["formerMemberOffset"]),
+		"unresolvedOwner":   unresolved,
+	})
+}
+
+func hygienePage(assets []services.HygieneAsset, limit, offset int) gin.H {
+	start := min(offset, len(assets))
+	end := min(start+limit, len(assets))
+	return gin.H{
+		"items":  assets[start:end],
+		"total":  len(assets),
+		"limit":  limit,
+		"offset": offset,
+	}
+}
+
+// GetCollaboration reports who shares with whom inside the team: folder and note counts
+// per ordered pair of members plus per-member totals. Refreshed at most every 15 minutes.
+func (tc *TeamController) GetCollaboration(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	report, err := tc.collaboration.Report(c.Request.Context(), teamID)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to build collaboration report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetTeamStats reports how many folders and notes each manager and member of the team
+// owns, how many assets are shared with them and the size of their note bodies, with
+// totals for the team. Refreshed at most every TEAM_STATS_CACHE_SECONDS.
+func (tc *TeamController) GetTeamStats(c *gin.Context) {
+	teamID, err := utils.GetUUIDFromParam(c, "teamId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	stats, err := tc.stats.TeamStats(c.Request.Context(), teamID)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to compute team stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
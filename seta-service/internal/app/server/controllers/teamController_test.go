@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"net/http"
+	"seta-pkg/logging"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// createTestTeam creates a team led by leadID with the other managers given.
+func createTestTeam(t testing.TB, db *gorm.DB, leadID uuid.UUID, managerIDs ...uuid.UUID) models.Team {
+	t.Helper()
+	team := models.Team{TeamName: "Race"}
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+	managers := []models.TeamManager{{TeamID: team.ID, UserID: leadID, IsLead: true}}
+	for _, managerID := range managerIDs {
+		managers = append(managers, models.TeamManager{TeamID: team.ID, UserID: managerID})
+	}
+	if err := db.Create(&managers).Error; err != nil {
+		t.Fatalf("create managers: %v", err)
+	}
+	return team
+}
+
+func newTeamTestRouter(db *gorm.DB, userID uuid.UUID) *gin.Engine {
+	tc := NewTeamController(db, services.NewCachedAuthorizationService(db, logging.Nop()), logging.Nop())
+	r := newTestRouter(userID)
+	r.POST("/teams/:teamId/lead", tc.TransferLead)
+	r.DELETE("/teams/:teamId/managers/:managerId", tc.RemoveManager)
+	r.DELETE("/teams/:teamId/members/:memberId", tc.RemoveMember)
+	r.DELETE("/teams/:teamId/members/me", tc.LeaveTeam)
+	return r
+}
+
+// Two transfers by the lead at once run one after the other: the first hands the role
+// on and the second finds its requester no longer lead, so the team keeps exactly one.
+func TestTransferLeadRacingTransferLead(t *testing.T) {
+	db := testdb.Open(t)
+	leadID, firstID, secondID := uuid.New(), uuid.New(), uuid.New()
+	r := newTeamTestRouter(db, leadID)
+
+	const rounds = 20
+	for round := 0; round < rounds; round++ {
+		team := createTestTeam(t, db, leadID, firstID, secondID)
+		path := "/teams/" + team.ID.String() + "/lead"
+
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			codes = make(map[uuid.UUID]int)
+		)
+		start := make(chan struct{})
+		for _, newLeadID := range []uuid.UUID{firstID, secondID} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				rec := serve(t, r, http.MethodPost, path, gin.H{"userId": newLeadID}, nil)
+				mu.Lock()
+				codes[newLeadID] = rec.Code
+				mu.Unlock()
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		var leads []uuid.UUID
+		if err := db.Model(&models.TeamManager{}).Where("team_id = ? AND is_lead", team.ID).Pluck("user_id", &leads).Error; err != nil {
+			t.Fatalf("list leads: %v", err)
+		}
+		if len(leads) != 1 {
+			t.Fatalf("round %d: team has %d leads, want one", round, len(leads))
+		}
+		for newLeadID, code := range codes {
+			want := http.StatusForbidden
+			if newLeadID == leads[0] {
+				want = http.StatusNoContent
+			}
+			if code != want {
+				t.Fatalf("round %d: transfer to %s answered %d, want %d (leads %v)", round, newLeadID, code, want, leads)
+			}
+		}
+	}
+}
+
+// Transferring the role to oneself is checked like any other transfer before it is a no-op.
+func TestTransferLeadToSelf(t *testing.T) {
+	db := testdb.Open(t)
+	leadID, managerID := uuid.New(), uuid.New()
+	team := createTestTeam(t, db, leadID, managerID)
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		teamID uuid.UUID
+		want   int
+	}{
+		{"lead", leadID, team.ID, http.StatusNoContent},
+		{"manager who is not the lead", managerID, team.ID, http.StatusForbidden},
+		{"missing team", leadID, uuid.New(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTeamTestRouter(db, tt.userID)
+			rec := serve(t, r, http.MethodPost, "/teams/"+tt.teamID.String()+"/lead", gin.H{"userId": tt.userID}, nil)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	var lead models.TeamManager
+	if err := db.Where("team_id = ? AND is_lead", team.ID).First(&lead).Error; err != nil {
+		t.Fatalf("load lead: %v", err)
+	}
+	if lead.UserID != leadID {
+		t.Errorf("lead is %s, want %s", lead.UserID, leadID)
+	}
+}
+
+// managersOf lists the team's managers, the lead first.
+func managersOf(t testing.TB, db *gorm.DB, teamID uuid.UUID) []models.TeamManager {
+	t.Helper()
+	var managers []models.TeamManager
+	if err := db.Where("team_id = ?", teamID).Order("is_lead DESC, user_id").Find(&managers).Error; err != nil {
+		t.Fatalf("list managers: %v", err)
+	}
+	return managers
+}
+
+func TestRemoveManager(t *testing.T) {
+	db := testdb.Open(t)
+	leadID, managerID := uuid.New(), uuid.New()
+	r := newTeamTestRouter(db, leadID)
+
+	tests := []struct {
+		name     string
+		managers []uuid.UUID // besides the lead
+		remove   uuid.UUID
+		query    string
+		want     int
+		// wantLead and wantManagers describe the team afterwards.
+		wantLead     uuid.UUID
+		wantManagers int
+	}{
+		{"last manager", nil, leadID, "", http.StatusConflict, leadID, 1},
+		{"last manager naming a successor", nil, leadID, "?successorId=" + managerID.String(), http.StatusConflict, leadID, 1},
+		{"lead without a successor", []uuid.UUID{managerID}, leadID, "", http.StatusConflict, leadID, 2},
+		{"lead with a successor", []uuid.UUID{managerID}, leadID, "?successorId=" + managerID.String(), http.StatusNoContent, managerID, 1},
+		{"lead with a successor who is not a manager", []uuid.UUID{managerID}, leadID, "?successorId=" + uuid.NewString(), http.StatusConflict, leadID, 2},
+		{"lead naming themself", []uuid.UUID{managerID}, leadID, "?successorId=" + leadID.String(), http.StatusConflict, leadID, 2},
+		{"invalid successor", []uuid.UUID{managerID}, leadID, "?successorId=banana", http.StatusUnprocessableEntity, leadID, 2},
+		{"manager who is not the lead", []uuid.UUID{managerID}, managerID, "", http.StatusNoContent, leadID, 1},
+		{"user who is not a manager", []uuid.UUID{managerID}, uuid.New(), "", http.StatusNotFound, leadID, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			team := createTestTeam(t, db, leadID, tt.managers...)
+			path := "/teams/" + team.ID.String() + "/managers/" + tt.remove.String() + tt.query
+			if rec := serve(t, r, http.MethodDelete, path, nil, nil); rec.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+
+			managers := managersOf(t, db, team.ID)
+			if len(managers) != tt.wantManagers {
+				t.Fatalf("team has %d managers, want %d", len(managers), tt.wantManagers)
+			}
+			if !managers[0].IsLead || managers[0].UserID != tt.wantLead {
+				t.Errorf("lead is %+v, want %s", managers[0], tt.wantLead)
+			}
+			if len(managers) > 1 && managers[1].IsLead {
+				t.Errorf("team has two leads: %+v", managers)
+			}
+		})
+	}
+}
+
+// Removing the other manager while the lead is removed in their favour leaves one of
+// the removals to find the last manager, whichever runs first.
+func TestRemoveManagerRacingRemoveManager(t *testing.T) {
+	db := testdb.Open(t)
+	leadID, managerID := uuid.New(), uuid.New()
+	r := newTeamTestRouter(db, leadID)
+
+	const rounds = 20
+	for round := 0; round < rounds; round++ {
+		team := createTestTeam(t, db, leadID, managerID)
+		base := "/teams/" + team.ID.String() + "/managers/"
+		paths := []string{base + managerID.String(), base + leadID.String() + "?successorId=" + managerID.String()}
+
+		var (
+			wg    sync.WaitGroup
+			codes = make([]int, len(paths))
+		)
+		start := make(chan struct{})
+		for i, path := range paths {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				codes[i] = serve(t, r, http.MethodDelete, path, nil, nil).Code
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		managers := managersOf(t, db, team.ID)
+		if len(managers) != 1 || !managers[0].IsLead {
+			t.Fatalf("round %d: managers %+v (statuses %v), want one lead", round, managers, codes)
+		}
+		removed := map[uuid.UUID]int{managerID: codes[0], leadID: codes[1]}
+		for userID, code := range removed {
+			want := http.StatusNoContent
+			if userID == managers[0].UserID {
+				want = http.StatusConflict
+			}
+			if code != want {
+				t.Fatalf("round %d: removing %s answered %d, want %d", round, userID, code, want)
+			}
+		}
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	db := testdb.Open(t)
+	leadID, memberID := uuid.New(), uuid.New()
+	team := createTestTeam(t, db, leadID)
+	if err := db.Create(&models.TeamMember{TeamID: team.ID, UserID: memberID}).Error; err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	r := newTeamTestRouter(db, leadID)
+	path := "/teams/" + team.ID.String() + "/members/"
+
+	if rec := serve(t, r, http.MethodDelete, path+uuid.NewString(), nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("removing a user who is not a member: status %d, want 404", rec.Code)
+	}
+	if rec := serve(t, r, http.MethodDelete, path+memberID.String(), nil, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("removing the member: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, r, http.MethodDelete, path+memberID.String(), nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("removing the member again: status %d, want 404", rec.Code)
+	}
+
+	var changes int64
+	if err := db.Model(&models.TeamMembershipChange{}).Where("team_id = ? AND user_id = ?", team.ID, memberID).Count(&changes).Error; err != nil {
+		t.Fatalf("count membership changes: %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("recorded %d membership changes, want 1", changes)
+	}
+}
+
+func TestLeaveTeam(t *testing.T) {
+	db := testdb.Open(t)
+	leadID, managerID, memberID := uuid.New(), uuid.New(), uuid.New()
+	team := createTestTeam(t, db, leadID, managerID)
+	// A manager who is also listed as a member still has to be removed as a manager.
+	for _, userID := range []uuid.UUID{memberID, managerID} {
+		if err := db.Create(&models.TeamMember{TeamID: team.ID, UserID: userID}).Error; err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+	}
+	path := "/teams/" + team.ID.String() + "/members/me"
+
+	for _, tt := range []struct {
+		name   string
+		userID uuid.UUID
+		want   int
+	}{
+		{"lead", leadID, http.StatusConflict},
+		{"manager", managerID, http.StatusConflict},
+		{"user who is not a member", uuid.New(), http.StatusNotFound},
+	} {
+		if rec := serve(t, newTeamTestRouter(db, tt.userID), http.MethodDelete, path, nil, nil); rec.Code != tt.want {
+			t.Errorf("%s leaving: status %d, want %d, body %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	r := newTeamTestRouter(db, memberID)
+	if rec := serve(t, r, http.MethodDelete, path, nil, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("member leaving: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, r, http.MethodDelete, path, nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("member leaving again: status %d, want 404", rec.Code)
+	}
+
+	var members []uuid.UUID
+	if err := db.Model(&models.TeamMember{}).Where("team_id = ?", team.ID).Pluck("user_id", &members).Error; err != nil {
+		t.Fatalf("list members: %v", err)
+	}
+	if len(members) != 1 || members[0] != managerID {
+		t.Errorf("members = %v, want only the manager %s", members, managerID)
+	}
+
+	var change models.TeamMembershipChange
+	if err := db.Where("team_id = ? AND user_id = ?", team.ID, memberID).First(&change).Error; err != nil {
+		t.Fatalf("load membership change: %v", err)
+	}
+	if change.Change != "removed" || change.ChangedBy != memberID {
+		t.Errorf("membership change = %+v, want removed by the member", change)
+	}
+
+	// The member acting on themself marks the departure as voluntary.
+	event := lastOutboxEvent(t, db, team.ID, "MEMBER_REMOVED")
+	if event.ActionBy != memberID.String() || event.TargetUserID != memberID.String() {
+		t.Errorf("MEMBER_REMOVED by %s of %s, want both %s", event.ActionBy, event.TargetUserID, memberID)
+	}
+}
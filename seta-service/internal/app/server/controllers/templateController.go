@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"net/http"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TemplateController handles CRUD for note templates.
+type TemplateController struct {
+	db            *gorm.DB
+	authorization *services.AuthorizationService
+}
+
+// NewTemplateController creates a new TemplateController, injecting the db dependency.
+func NewTemplateController(db *gorm.DB, authorization *services.AuthorizationService) *TemplateController {
+	return &TemplateController{
+		db:            db,
+		authorization: authorization,
+	}
+}
+
+type CreateTemplateInput struct {
+	Name          string     `json:"name" binding:"required"`
+	TitleTemplate string     `json:"titleTemplate" binding:"required"`
+	BodyTemplate  string     `json:"bodyTemplate"`
+	TeamID        *uuid.UUID `json:"teamId"`
+}
+
+// CreateTemplate creates a personal template, or a team template when teamId is set.
+// Only managers of the team may create team templates.
+func (tc *TemplateController) CreateTemplate(c *gin.Context) {
+	var input CreateTemplateInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if input.TeamID != nil {
+		isManager, customErr := tc.authorization.IsTeamManager(userID, *input.TeamID)
+		if customErr != nil {
+			_ = c.Error(customErr)
+			return
+		}
+		if !isManager {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "Only team managers can create team templates"})
+			return
+		}
+	}
+
+	template := models.NoteTemplate{
+		OwnerID:       userID,
+		TeamID:        input.TeamID,
+		Name:          input.Name,
+		TitleTemplate: input.TitleTemplate,
+		BodyTemplate:  input.BodyTemplate,
+	}
+
+	if err := tc.db.WithContext(c.Request.Context()).Create(&template).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to create template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// ListTemplates returns the caller's own templates plus those of every team they belong to.
+// ?sort=popular orders by usage count, the default is newest first.
+func (tc *TemplateController) ListTemplates(c *gin.Context) {
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	query := httpquery.New(c.Request.URL.Query())
+	order := "created_at DESC"
+	if query.Enum("sort", "newest", "newest", "popular") == "popular" {
+		order = "usage_count DESC, created_at DESC"
+	}
+	if err := query.Err(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	templates := make([]models.NoteTemplate, 0)
+	if err := tc.db.WithContext(c.Request.Context()).
+		Where("owner_id = ? OR team_id IN (SELECT team_id FROM team_managers WHERE user_id = ? UNION SELECT team_id FROM team_members WHERE user_id = ?)", userID, userID, userID).
+		Order(order).
+		Find(&templates).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate retrieves a single template. Access is checked by the route middleware.
+func (tc *TemplateController) GetTemplate(c *gin.Context) {
+	templateID, err := utils.GetUUIDFromParam(c, "templateId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var template models.NoteTemplate
+	if err := tc.db.WithContext(c.Request.Context()).First(&template, "template_id = ?", templateID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+type UpdateTemplateInput struct {
+	Name          string `json:"name" binding:"required"`
+	TitleTemplate string `json:"titleTemplate" binding:"required"`
+	BodyTemplate  string `json:"bodyTemplate"`
+}
+
+// UpdateTemplate replaces a template's name and content.
+func (tc *TemplateController) UpdateTemplate(c *gin.Context) {
+	templateID, err := utils.GetUUIDFromParam(c, "templateId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var template models.NoteTemplate
+	if err := tc.db.WithContext(c.Request.Context()).First(&template, "template_id = ?", templateID).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Template not found"})
+		return
+	}
+
+	var input UpdateTemplateInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := tc.db.WithContext(c.Request.Context()).Model(&template).Updates(map[string]interface{}{
+		"name":           input.Name,
+		"title_template": input.TitleTemplate,
+		"body_template":  input.BodyTemplate,
+	}).Error; err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate deletes a template. Notes created from it are unaffected.
+func (tc *TemplateController) DeleteTemplate(c *gin.Context) {
+	templateID, err := utils.GetUUIDFromParam(c, "templateId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result := tc.db.WithContext(c.Request.Context()).Where("template_id = ?", templateID).Delete(&models.NoteTemplate{})
+	if result.Error != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete template"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Template not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,13 +1,19 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"seta-pkg/database"
 	"seta/internal/app/server/services"
 	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
 	"seta/internal/pkg/models"
 	"seta/internal/pkg/utils" // Import the new utils package
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -15,6 +21,7 @@ import (
 type UserController struct {
 	db          *gorm.DB
 	userService *services.UserService
+	stats       *services.StatsService
 }
 
 // NewUserController creates a new UserController.
@@ -22,12 +29,76 @@ func NewUserController(db *gorm.DB, userService *services.UserService) *UserCont
 	return &UserController{
 		db:          db,
 		userService: userService,
+		stats:       services.NewStatsService(db),
 	}
 }
 
-// ImportUsers handles the file upload and calls the user service to process it.
+// ImportUsers handles the file upload and calls the user service to process it. With the
+// dryRun form field set to true every row is validated but no user is created; dry runs
+// do not use an import slot since they never call the user service.
+//
+// With the async form field set to true the import runs as a background job instead and
+// the response is 202 with the job, to poll at GET /users/import/:importId.
 func (uc *UserController) ImportUsers(c *gin.Context) {
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Reading any form field parses the whole upload, so the limit goes on first.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uc.userService.MaxUploadBytes())
+
+	dryRun := false
+	if raw := c.PostForm("dryRun"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "dryRun must be true or false"})
+			return
+		}
+	}
+	async := false
+	if raw := c.PostForm("async"); raw != "" {
+		if async, err = strconv.ParseBool(raw); err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "async must be true or false"})
+			return
+		}
+	}
+	if async && dryRun {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "A dry run cannot be asynchronous"})
+		return
+	}
+
+	importID := uuid.New()
+	release := func() {}
+	if !dryRun {
+		importID, release, err = uc.userService.BeginImport(c.Request.Context(), userID)
+		if err != nil {
+			var inProgress *services.ImportInProgressError
+			if errors.As(err, &inProgress) {
+				_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: fmt.Sprintf("You already have an import running (importId: %s)", inProgress.ImportID)})
+				return
+			}
+			if errors.Is(err, services.ErrImportCapacity) {
+				_ = c.Error(&errorHandling.CustomError{Code: http.StatusTooManyRequests, Message: err.Error()})
+				return
+			}
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to reserve an import slot"})
+			return
+		}
+	}
+	// A background job takes the slot over and releases it when it ends.
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
 	file, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusRequestEntityTooLarge, Message: "File exceeds the maximum upload size"})
+		return
+	}
 	if err != nil {
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "File not provided in 'file' form field"})
 		return
@@ -40,22 +111,131 @@ func (uc *UserController) ImportUsers(c *gin.Context) {
 	}
 	defer openedFile.Close()
 
-	summary, err := uc.userService.ImportUsers(c.Request.Context(), openedFile)
+	if async {
+		job, err := uc.userService.StartImportJob(c.Request.Context(), userID, importID, openedFile, release)
+		if errors.Is(err, services.ErrTooManyImportRows) {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusRequestEntityTooLarge, Message: "CSV has more rows than an import allows"})
+			return
+		}
+		if err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to start user import"})
+			return
+		}
+		release = nil
+		c.Header("Location", "/api/users/import/"+importID.String())
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	summary, err := uc.userService.ImportUsers(c.Request.Context(), userID, importID, openedFile, dryRun)
+	if errors.Is(err, services.ErrTooManyImportRows) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusRequestEntityTooLarge, Message: "CSV has more rows than an import allows"})
+		return
+	}
 	if err != nil {
 		// Pass the error from the service to the error handling middleware
 		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "User import process completed.",
-		"succeeded": summary.Succeeded,
-		"failed":    summary.Failed,
-		"failures":  summary.Failures,
-	})
+	message := "User import process completed."
+	if dryRun {
+		message = "User import dry run completed; no users were created."
+	}
+	response := gin.H{
+		"message":           message,
+		"dryRun":            dryRun,
+		"importId":          importID,
+		"succeeded":         summary.Succeeded,
+		"failed":            summary.Failed,
+		"failures":          summary.Failures,
+		"failuresTruncated": summary.FailuresTruncated,
+	}
+	if summary.FailuresTruncated {
+		response["failuresReport"] = "/api/users/import/" + importID.String() + "/failures"
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetImportJob reports the progress of a background import to the user who started it,
+// with the failures once it has finished and the import slots of the deployment.
+func (uc *UserController) GetImportJob(c *gin.Context) {
+	jobID, err := utils.GetUUIDFromParam(c, "importId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	job, failures, err := uc.userService.ImportJob(c.Request.Context(), userID, jobID)
+	if errors.Is(err, services.ErrImportJobNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Import job not found"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve import job"})
+		return
+	}
+
+	slots, err := uc.userService.ImportSlots(c.Request.Context())
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve import job"})
+		return
+	}
+
+	response := gin.H{
+		"job":      job,
+		"failures": failures,
+		"slots":    slots,
+	}
+	if job.FailuresTruncated {
+		response["failuresReport"] = "/api/users/import/" + job.JobID.String() + "/failures"
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetImportFailures downloads the full failure list of an import whose response only
+// carried the first failures, as JSON lines. Only the user who ran the import can.
+func (uc *UserController) GetImportFailures(c *gin.Context) {
+	importID, err := utils.GetUUIDFromParam(c, "importId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	report, err := uc.userService.OpenFailureReport(userID, importID)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Import failure report not found"})
+		return
+	}
+	defer report.Close()
+
+	size := int64(-1)
+	if info, err := report.Stat(); err == nil {
+		size = info.Size()
+	}
+	c.Header("Content-Disposition", `attachment; filename="import-`+importID.String()+`-failures.jsonl"`)
+	c.DataFromReader(http.StatusOK, size, "application/x-ndjson", report, nil)
 }
 
-// GetUserAssets retrieves all assets owned by or shared with a specific user.
+// GetUserAssets retrieves all assets owned by or shared with a specific user. A shared
+// folder brings its subfolders and their notes along; each folder carries its
+// parentFolderId so clients can rebuild the tree.
+//
+// Each asset also carries the user's access to it, owner, read or write, and a
+// viaFolderId naming the shared folder that access comes from when it is not the asset's
+// own. ?verbose=false leaves both out and lists the plain rows as before.
 func (uc *UserController) GetUserAssets(c *gin.Context) {
 	// Use the utility function to get the target user's ID from the URL param.
 	targetUserID, err := utils.GetUUIDFromParam(c, "userId")
@@ -77,29 +257,200 @@ func (uc *UserController) GetUserAssets(c *gin.Context) {
 		return
 	}
 
-	var folders []models.Folder
-	if err := uc.db.WithContext(c.Request.Context()).
-		Joins("LEFT JOIN folder_shares ON folders.folder_id = folder_shares.folder_id").
-		Where("folders.owner_id = ? OR folder_shares.user_id = ?", targetUserID, targetUserID).
-		Group("folders.folder_id").
-		Find(&folders).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve folders for the user"})
+	limit, cursor, err := utils.GetAssetPageParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	withCounts, err := getWithCounts(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
+	if withCounts {
+		limit = min(limit, maxCountedPageSize)
+	}
+	verbose, err := getVerbose(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// EXISTS instead of joining the share tables: an asset reachable through several
+	// shares is one row, so counts over these queries stay exact without GROUP BY.
+	var (
+		listedFolders, listedNotes any
+		nextFolder, nextNote       *utils.CursorPosition
+	)
+	err = database.Read(c.Request.Context(), uc.db, func(tx *gorm.DB) error {
+		folders := make([]models.Folder, 0)
+		if !cursor.FoldersDone {
+			query := tx.
+				Where("folders.owner_id = ? OR folders.folder_id IN (?)", targetUserID, services.SharedFolderSubtree(tx, targetUserID)).
+				Where("folders.deletion_pending = ?", false)
+			if err := utils.KeysetPage(query, "folders.created_at", "folders.folder_id", cursor.Folders, limit).Find(&folders).Error; err != nil {
+				return err
+			}
+		}
+		folders, nextFolder = utils.TrimPage(folders, limit, folderCursorKey)
+
+		notes := make([]models.Note, 0)
+		if !cursor.NotesDone {
+			query := tx.
+				Where("notes.owner_id = ? OR EXISTS (SELECT 1 FROM note_shares ns WHERE ns.note_id = notes.note_id AND ns.user_id = ?) OR notes.folder_id IN (?)", targetUserID, targetUserID, services.SharedFolderSubtree(tx, targetUserID)).
+				Where("notes.folder_id NOT IN (SELECT folder_id FROM folders WHERE deletion_pending)")
+			if err := utils.KeysetPage(query, "notes.created_at", "notes.note_id", cursor.Notes, limit).Find(&notes).Error; err != nil {
+				return err
+			}
+		}
+		notes, nextNote = utils.TrimPage(notes, limit, noteCursorKey)
+
+		var counted []FolderWithCount
+		if withCounts {
+			if counted, err = foldersWithCounts(tx, authUserID, folders); err != nil {
+				return err
+			}
+		}
+		if !verbose {
+			listedFolders, listedNotes = folders, notes
+			if withCounts {
+				listedFolders = counted
+			}
+			return nil
+		}
 
-	var notes []models.Note
-	if err := uc.db.WithContext(c.Request.Context()).
-		Joins("LEFT JOIN note_shares ON notes.note_id = note_shares.note_id").
-		Joins("LEFT JOIN folder_shares ON notes.folder_id = folder_shares.folder_id").
-		Where("notes.owner_id = ? OR note_shares.user_id = ? OR folder_shares.user_id = ?", targetUserID, targetUserID, targetUserID).
-		Group("notes.note_id").
-		Find(&notes).Error; err != nil {
-		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to retrieve notes for the user"})
+		folderAccess, err := services.FolderAccess(tx, targetUserID, folders)
+		if err != nil {
+			return err
+		}
+		noteAccess, err := services.NoteAccess(tx, targetUserID, notes)
+		if err != nil {
+			return err
+		}
+		verboseFolders := make([]UserAssetFolder, len(folders))
+		for i, folder := range folders {
+			verboseFolders[i] = UserAssetFolder{Folder: folder, AssetAccess: folderAccess[folder.FolderID]}
+			if withCounts {
+				verboseFolders[i].VisibleNoteCount = &counted[i].VisibleNoteCount
+			}
+		}
+		verboseNotes := make([]UserAssetNote, len(notes))
+		for i, note := range notes {
+			verboseNotes[i] = UserAssetNote{Note: note, AssetAccess: noteAccess[note.NoteID]}
+		}
+		listedFolders, listedNotes = verboseFolders, verboseNotes
+		return nil
+	})
+	if err != nil {
+		_ = c.Error(readError(err, "Failed to retrieve assets for the user"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"folders": folders,
-		"notes":   notes,
+		"folders":    listedFolders,
+		"notes":      listedNotes,
+		"nextCursor": cursor.Next(nextFolder, nextNote),
 	})
+}
+
+// readError passes on the errors a read already turned into a response and reports
+// any other failure with message.
+func readError(err error, message string) error {
+	var customErr *errorHandling.CustomError
+	if errors.As(err, &customErr) {
+		return customErr
+	}
+	return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: message}
+}
+
+// GetUserStats reports how many folders and notes the user owns, how many assets are
+// shared with them and the size of their note bodies. Users can only see their own.
+func (uc *UserController) GetUserStats(c *gin.Context) {
+	targetUserID, err := utils.GetUUIDFromParam(c, "userId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	authUserID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if authUserID != targetUserID {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "You are not authorized to view these stats"})
+		return
+	}
+
+	stats, err := uc.stats.UserStats(c.Request.Context(), targetUserID)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to compute user stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// maxCountedPageSize caps asset pages whose folders carry visibleNoteCount, since the
+// count query grows with the number of folders on the page.
+const maxCountedPageSize = 50
+
+// FolderWithCount is a listed folder with the number of its notes the requester can read.
+type FolderWithCount struct {
+	models.Folder
+	VisibleNoteCount int64 `json:"visibleNoteCount"`
+}
+
+// UserAssetFolder is a folder of GetUserAssets with the user's access to it.
+type UserAssetFolder struct {
+	models.Folder
+	services.AssetAccess
+	VisibleNoteCount *int64 `json:"visibleNoteCount,omitempty"`
+}
+
+// UserAssetNote is a note of GetUserAssets with the user's access to it.
+type UserAssetNote struct {
+	models.Note
+	services.AssetAccess
+}
+
+// getVerbose reads the ?verbose flag of GetUserAssets, on unless set to false.
+func getVerbose(c *gin.Context) (bool, error) {
+	query := httpquery.New(c.Request.URL.Query())
+	verbose := query.Bool("verbose", true)
+	return verbose, query.Err()
+}
+
+// getWithCounts reads the optional ?withCounts flag of the asset listings.
+func getWithCounts(c *gin.Context) (bool, error) {
+	query := httpquery.New(c.Request.URL.Query())
+	withCounts := query.Bool("withCounts", false)
+	return withCounts, query.Err()
+}
+
+// foldersWithCounts adds visibleNoteCount to a page of folders with a single query.
+func foldersWithCounts(db *gorm.DB, userID uuid.UUID, folders []models.Folder) ([]FolderWithCount, error) {
+	ids := make([]uuid.UUID, len(folders))
+	for i, folder := range folders {
+		ids[i] = folder.FolderID
+	}
+	counts, customErr := services.NewAuthorizationService(db).VisibleNoteCounts(userID, ids)
+	if customErr != nil {
+		return nil, customErr
+	}
+
+	counted := make([]FolderWithCount, len(folders))
+	for i, folder := range folders {
+		counted[i] = FolderWithCount{Folder: folder, VisibleNoteCount: counts[folder.FolderID]}
+	}
+	return counted, nil
+}
+
+func folderCursorKey(folder models.Folder) utils.CursorPosition {
+	return utils.CursorPosition{At: folder.CreatedAt, ID: folder.FolderID}
+}
+
+func noteCursorKey(note models.Note) utils.CursorPosition {
+	return utils.CursorPosition{At: note.CreatedAt, ID: note.NoteID}
 }
\ No newline at end of file
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"seta-pkg/logging"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// An asset the user reaches in several ways at once is listed, and counted, once.
+func TestGetUserAssetsListsEachAssetOnce(t *testing.T) {
+	db := testdb.Open(t)
+	userID, otherID := uuid.New(), uuid.New()
+
+	// The user's note sits in a folder of someone else, shared with the user directly and
+	// through its parent, and the note is shared with the user too.
+	parent := createTestFolder(t, db, otherID)
+	folder := models.Folder{Name: "Shared", OwnerID: otherID, LastModifiedBy: otherID, ParentFolderID: &parent.FolderID}
+	if err := db.Omit("Owner").Create(&folder).Error; err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+	note := models.Note{Title: "Mine", FolderID: folder.FolderID, OwnerID: userID, LastModifiedBy: userID}
+	if err := db.Omit("Folder", "Owner").Create(&note).Error; err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	for _, share := range []any{
+		&models.FolderShare{FolderID: parent.FolderID, UserID: userID, Access: models.AccessRead},
+		&models.FolderShare{FolderID: folder.FolderID, UserID: userID, Access: models.AccessRead},
+		&models.NoteShare{NoteID: note.NoteID, UserID: userID, Access: models.AccessWrite},
+	} {
+		if err := db.Create(share).Error; err != nil {
+			t.Fatalf("share: %v", err)
+		}
+	}
+
+	uc := NewUserController(db, services.NewUserService(db, logging.Nop()))
+	r := newTestRouter(userID)
+	r.GET("/users/:userId/assets", uc.GetUserAssets)
+
+	rec := serve(t, r, http.MethodGet, "/users/"+userID.String()+"/assets?withCounts=true", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var page struct {
+		Folders []struct {
+			FolderID         uuid.UUID `json:"folderId"`
+			VisibleNoteCount *int64    `json:"visibleNoteCount"`
+		} `json:"folders"`
+		Notes []struct {
+			NoteID uuid.UUID `json:"noteId"`
+			Access string    `json:"access"`
+		} `json:"notes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+
+	if len(page.Notes) != 1 || page.Notes[0].NoteID != note.NoteID {
+		t.Fatalf("notes = %+v, want the note once", page.Notes)
+	}
+	if page.Notes[0].Access != "owner" {
+		t.Errorf("access = %q, want owner", page.Notes[0].Access)
+	}
+	if len(page.Folders) != 2 {
+		t.Fatalf("got %d folders, want the parent and the folder once each", len(page.Folders))
+	}
+	for _, listed := range page.Folders {
+		want := int64(0)
+		if listed.FolderID == folder.FolderID {
+			want = 1
+		}
+		if listed.VisibleNoteCount == nil || *listed.VisibleNoteCount != want {
+			t.Errorf("folder %s visibleNoteCount = %v, want %d", listed.FolderID, listed.VisibleNoteCount, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"seta/internal/app/server/services"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WebhookController manages folder webhooks. Delivery is done by services.WebhookDispatcher.
+type WebhookController struct {
+	webhooks *services.WebhookService
+}
+
+// NewWebhookController creates a new WebhookController, injecting the db dependency.
+func NewWebhookController(db *gorm.DB) *WebhookController {
+	return &WebhookController{webhooks: services.NewWebhookService(db)}
+}
+
+type CreateWebhookInput struct {
+	URL        string   `json:"url" binding:"required,url"`
+	Secret     string   `json:"secret" binding:"required,min=16"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+// CreateWebhook registers a webhook for changes to the folder and the notes in it.
+func (wc *WebhookController) CreateWebhook(c *gin.Context) {
+	folderID, err := utils.GetUUIDFromParam(c, "folderId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userID, err := utils.GetUserUUIDFromContext(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var input CreateWebhookInput
+	if err := utils.BindJSON(c, &input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	webhook, err := wc.webhooks.Register(c.Request.Context(), folderID, userID, input.URL, input.Secret, input.EventTypes)
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhookURL):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Webhook URL must be an absolute http or https URL"})
+		return
+	case errors.Is(err, services.ErrWebhookAddressBlocked):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Webhook URL must not point to an internal address"})
+		return
+	case errors.Is(err, services.ErrWebhookHostUnresolvable):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Webhook host could not be resolved"})
+		return
+	case errors.Is(err, services.ErrUnknownWebhookFilter):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Unknown event type in eventTypes"})
+		return
+	case errors.Is(err, services.ErrTooManyWebhooks):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "Folder already has the maximum number of webhooks"})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Folder not found"})
+		return
+	case err != nil:
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// ListWebhooks lists the folder's webhooks with their delivery statistics.
+func (wc *WebhookController) ListWebhooks(c *gin.Context) {
+	folderID, err := utils.GetUUIDFromParam(c, "folderId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	webhooks, err := wc.webhooks.List(c.Request.Context(), folderID)
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to list webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// DeleteWebhook removes a webhook and drops its queued deliveries. Deleting and
+// registering again is also how a disabled webhook is turned back on.
+func (wc *WebhookController) DeleteWebhook(c *gin.Context) {
+	folderID, err := utils.GetUUIDFromParam(c, "folderId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	webhookID, err := utils.GetUUIDFromParam(c, "webhookId")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = wc.webhooks.Delete(c.Request.Context(), folderID, webhookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusNotFound, Message: "Webhook not found"})
+		return
+	}
+	if err != nil {
+		_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to delete webhook"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,18 +1,32 @@
 package middlewares
 
 import (
-	"bytes"
-	"encoding/json"
 	"net/http"
-	"os"
 	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/userclient"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// verifiedTokens is shared by every AuthMiddleware instance.
+var verifiedTokens = newTokenCache()
+
+// AuthConfig holds what AuthMiddleware verifies tokens with.
+type AuthConfig struct {
+	// Users answers verifyToken. Its userclient.Config sets the user-service URL, the
+	// timeout, the retries on transient failures and, for tests, the *http.Client.
+	Users *userclient.Client
+}
+
+// AuthConfigFromEnv verifies tokens with the process-wide user-service client,
+// configured from USER_SERVICE_URL and the other variables of userclient.ConfigFromEnv.
+func AuthConfigFromEnv() AuthConfig {
+	return AuthConfig{Users: userclient.Shared()}
+}
+
 // AuthMiddleware creates a gin middleware for JWT authentication.
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(cfg AuthConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -30,78 +44,38 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString := parts[1]
 
-		// Prepare the GraphQL query
-		type GQLVariables struct {
-			Token string `json:"token"`
-		}
-
-		// Prepare the GraphQL query using a struct that will marshal correctly
-		query := gin.H{
-			"query": `
-                query VerifyToken($token: String!) {
-                    verifyToken(token: $token) {
-                        success
-                        user {
-                            userId
-                            role
-                        }
-                    }
-                }
-            `,
-			"variables": GQLVariables{
-				Token: tokenString,
-			},
-		}
-
-		jsonQuery, err := json.Marshal(query)
-		if err != nil {
-			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to create GraphQL query"})
-			c.Abort()
-			return
-		}
-
-		userServiceURL := os.Getenv("USER_SERVICE_URL")
-		
-		if userServiceURL == "" {
-			userServiceURL = "http://localhost:4000/users" // Default for local dev
-		}
-
-		// Make the request to the user-service
-		resp, err := http.Post(userServiceURL, "application/json", bytes.NewBuffer(jsonQuery))
-		if err != nil {
-			_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Failed to connect to user service"})
+		user, valid, ok := verifiedTokens.get(tokenString)
+		if ok && !valid {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusUnauthorized, Message: "Invalid token"})
 			c.Abort()
 			return
 		}
-		defer resp.Body.Close()
+		if !ok {
+			verification, err := cfg.Users.VerifyToken(c.Request.Context(), tokenString)
+			if err != nil {
+				_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Failed to connect to user service"})
+				c.Abort()
+				return
+			}
 
-		var result struct {
-			Data struct {
-				VerifyToken struct {
-					Success bool `json:"success"`
-					User    struct {
-						UserID string `json:"userId"`
-						Role   string `json:"role"`
-					} `json:"user"`
-				} `json:"verifyToken"`
-			} `json:"data"`
-		}
+			if !verification.Valid {
+				verifiedTokens.putInvalid(tokenString)
+				_ = c.Error(&errorHandling.CustomError{Code: http.StatusUnauthorized, Message: "Invalid token"})
+				c.Abort()
+				return
+			}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to decode user service response"})
-			c.Abort()
-			return
+			user = verification.User
+			verifiedTokens.put(tokenString, user, verification.TTL)
 		}
 
-		if !result.Data.VerifyToken.Success {
-			_ = c.Error(&errorHandling.CustomError{Code: http.StatusUnauthorized, Message: "Invalid token"})
-			c.Abort()
-			return
-		}
+		// Verification itself is never memoized, but its answer saves a later GetUser of the caller.
+		userclient.Remember(c.Request.Context(), user)
 
 		// If successful, set user info and continue
-		c.Set("userId", result.Data.VerifyToken.User.UserID)
-		c.Set("role", result.Data.VerifyToken.User.Role)
+		c.Set("userId", user.UserID)
+		c.Set("role", user.Role)
+		c.Set("username", user.Username)
 
 		c.Next()
 	}
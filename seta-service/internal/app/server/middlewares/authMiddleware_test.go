@@ -0,0 +1,136 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"seta-pkg/logging"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/userclient"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeUserService answers verifyToken like the user service: tokens starting with
+// "valid" belong to a MEMBER, tokens starting with "slow" are answered after the client
+// timed out, and every other token is invalid. It counts the calls it gets.
+func fakeUserService(t *testing.T, cacheControl string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var request struct {
+			Variables struct {
+				Token string `json:"token"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		token := request.Variables.Token
+
+		var result map[string]any
+		switch {
+		case strings.HasPrefix(token, "slow"):
+			time.Sleep(200 * time.Millisecond)
+			return
+		case strings.HasPrefix(token, "valid"):
+			result = map[string]any{"success": true, "user": map[string]any{
+				"userId": "user-" + token, "username": "carol", "email": "carol@example.com", "role": "MEMBER",
+			}}
+		default:
+			result = map[string]any{"success": false, "user": nil}
+		}
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"verifyToken": result}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func newAuthTestRouter(srv *httptest.Server) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	users := userclient.New(userclient.Config{
+		URL:              srv.URL,
+		Timeout:          50 * time.Millisecond,
+		MaxRetries:       2,
+		RetryBackoff:     time.Millisecond,
+		BreakerThreshold: 100,
+		BreakerCooldown:  time.Second,
+	})
+	r := gin.New()
+	r.Use(errorHandling.ErrorHandler(logging.Nop()))
+	r.Use(AuthMiddleware(AuthConfig{Users: users}))
+	r.GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"userId": c.GetString("userId"), "role": c.GetString("role")})
+	})
+	return r
+}
+
+// get calls /me with token, which is made unique to the test: verifications are cached
+// process-wide.
+func get(r http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddlewareValidToken(t *testing.T) {
+	srv, _ := fakeUserService(t, "")
+	r := newAuthTestRouter(srv)
+	token := "valid-" + uuid.NewString()
+
+	rec := get(r, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var principal struct {
+		UserID string `json:"userId"`
+		Role   string `json:"role"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &principal); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if principal.UserID != "user-"+token || principal.Role != "MEMBER" {
+		t.Errorf("principal = %+v, want the user the user service answered with", principal)
+	}
+}
+
+func TestAuthMiddlewareInvalidToken(t *testing.T) {
+	srv, _ := fakeUserService(t, "")
+	r := newAuthTestRouter(srv)
+
+	if rec := get(r, "forged-"+uuid.NewString()); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthMiddlewareUserServiceTimeout(t *testing.T) {
+	srv, calls := fakeUserService(t, "")
+	r := newAuthTestRouter(srv)
+
+	if rec := get(r, "slow-"+uuid.NewString()); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("user service called %d times, want 2: the timeout is retried once", got)
+	}
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"seta/internal/pkg/errorHandling"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalAPIKey protects operator-only endpoints under /internal.
+// Requests must carry the INTERNAL_API_KEY value in the X-Internal-API-Key header.
+func InternalAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := os.Getenv("INTERNAL_API_KEY")
+		if expected == "" {
+			// Refuse everything rather than leaving internal endpoints open.
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusServiceUnavailable, Message: "Internal API is not configured"})
+			c.Abort()
+			return
+		}
+
+		provided := c.GetHeader("X-Internal-API-Key")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusUnauthorized, Message: "Invalid internal API key"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
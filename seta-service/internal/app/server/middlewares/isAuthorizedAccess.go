@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"seta/internal/app/server/services"
 	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/models"
 	"seta/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
@@ -12,9 +13,8 @@ import (
 )
 
 // AssetAccessMiddleware now uses the centralized utility functions for all ID parsing.
-func AssetAccessMiddleware(assetType string, assetIDParamName string, checkFunc func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError), db *gorm.DB) gin.HandlerFunc {
-	authorization := services.NewAuthorizationService(db)
-
+// Every route shares the router's authorization service, and with it its decision cache.
+func AssetAccessMiddleware(assetType string, assetIDParamName string, checkFunc func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError), authorization *services.AuthorizationService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		
 		assetID, err := utils.GetUUIDFromParam(c, assetIDParamName)
@@ -40,7 +40,7 @@ func AssetAccessMiddleware(assetType string, assetIDParamName string, checkFunc
 		// }
 
 		hasPermission, customErr := checkFunc(authorization, userID, assetID)
-		if customErr.Code != 0 {
+		if customErr != nil {
 			_ = c.Error(customErr)
 			c.Abort()
 			return
@@ -59,44 +59,91 @@ func AssetAccessMiddleware(assetType string, assetIDParamName string, checkFunc
 }
 
 
-func CanReadNote(db *gorm.DB) gin.HandlerFunc {
+func CanReadNote(authorization *services.AuthorizationService) gin.HandlerFunc {
 	return AssetAccessMiddleware("note", "noteId",
 		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
 			return authorization.CanAccessAsset(userID, "note", assetID)
-		}, db)
+		}, authorization)
 }
 
-func CanWriteNote(db *gorm.DB) gin.HandlerFunc {
+func CanWriteNote(authorization *services.AuthorizationService) gin.HandlerFunc {
 	return AssetAccessMiddleware("note", "noteId",
 		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
 			return authorization.CanWriteAsset(userID, "note", assetID)
-		}, db)
+		}, authorization)
 }
 
-func IsNoteOwner(db *gorm.DB) gin.HandlerFunc {
+func IsNoteOwner(authorization *services.AuthorizationService) gin.HandlerFunc {
 	return AssetAccessMiddleware("note", "noteId",
 		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
 			return authorization.IsAssetOwner(userID, "note", assetID)
-		}, db)
+		}, authorization)
+}
+
+func CanShareNote(authorization *services.AuthorizationService) gin.HandlerFunc {
+	return AssetAccessMiddleware("note", "noteId",
+		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
+			return authorization.CanShareNote(userID, assetID)
+		}, authorization)
 }
 
-func CanReadFolder(db *gorm.DB) gin.HandlerFunc {
+func CanReadFolder(authorization *services.AuthorizationService) gin.HandlerFunc {
 	return AssetAccessMiddleware("folder", "folderId",
 		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
 			return authorization.CanAccessAsset(userID, "folder", assetID)
-		}, db)
+		}, authorization)
 }
 
-func CanWriteFolder(db *gorm.DB) gin.HandlerFunc {
+func CanWriteFolder(authorization *services.AuthorizationService) gin.HandlerFunc {
 	return AssetAccessMiddleware("folder", "folderId",
 		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
 			return authorization.CanWriteAsset(userID, "folder", assetID)
-		}, db)
+		}, authorization)
 }
 
-func IsFolderOwner(db *gorm.DB) gin.HandlerFunc {
+func IsFolderOwner(authorization *services.AuthorizationService) gin.HandlerFunc {
 	return AssetAccessMiddleware("folder", "folderId",
 		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
 			return authorization.IsAssetOwner(userID, "folder", assetID)
-		}, db)
-}
\ No newline at end of file
+		}, authorization)
+}
+
+func CanReadTemplate(authorization *services.AuthorizationService) gin.HandlerFunc {
+	return AssetAccessMiddleware("template", "templateId",
+		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
+			return authorization.CanReadTemplate(userID, assetID)
+		}, authorization)
+}
+
+func CanManageTemplate(authorization *services.AuthorizationService) gin.HandlerFunc {
+	return AssetAccessMiddleware("template", "templateId",
+		func(authorization *services.AuthorizationService, userID, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
+			return authorization.CanManageTemplate(userID, assetID)
+		}, authorization)
+}
+
+// FolderNotPendingDeletion rejects writes to a folder that a background job is deleting.
+func FolderNotPendingDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		folderID, err := utils.GetUUIDFromParam(c, "folderId")
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		var pending bool
+		if err := db.Model(&models.Folder{}).Where("folder_id = ?", folderID).Pluck("deletion_pending", &pending).Error; err != nil {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to check folder state"})
+			c.Abort()
+			return
+		}
+		if pending {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusConflict, Message: "Folder is being deleted"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
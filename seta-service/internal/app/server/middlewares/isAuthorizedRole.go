@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"net/http"
+	"seta/internal/pkg/errorHandling"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,7 +11,8 @@ func IsAuthorizedRole(authorizedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
 		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "User role not found in token"})
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "User role not found in token"})
+			c.Abort()
 			return
 		}
 
@@ -24,7 +26,8 @@ func IsAuthorizedRole(authorizedRoles ...string) gin.HandlerFunc {
 		}
 
 		if !IsAuthorizedRole {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not authorized to perform this action"})
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "You are not authorized to perform this action"})
+			c.Abort()
 			return
 		}
 
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"errors"
 	"net/http"
+	"seta/internal/app/server/services"
 	"seta/internal/pkg/errorHandling"
 	"seta/internal/pkg/models"
 	"seta/internal/pkg/utils"
@@ -18,19 +19,22 @@ func IsTeamManager(db *gorm.DB) gin.HandlerFunc {
 		teamIDStr := c.Param("teamId")
 		teamID, err := uuid.Parse(teamIDStr)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Invalid team ID"})
+			c.Abort()
 			return
 		}
 
 		userIDStr, exists := c.Get("userId")
 		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "User ID not found in token"})
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "User ID not found in token"})
+			c.Abort()
 			return
 		}
 
 		userID, err := uuid.Parse(userIDStr.(string))
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user ID"})
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to parse user ID"})
+			c.Abort()
 			return
 		}
 
@@ -38,10 +42,12 @@ func IsTeamManager(db *gorm.DB) gin.HandlerFunc {
 		err = db.Where("team_id = ? AND user_id = ?", teamID, userID).First(&teamManager).Error
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not a manager of this team"})
+				_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "You are not a manager of this team"})
+				c.Abort()
 				return
 			}
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify team manager status"})
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Failed to verify team manager status"})
+			c.Abort()
 			return
 		}
 
@@ -78,4 +84,47 @@ func IsLeadManager(db *gorm.DB) gin.HandlerFunc {
         }
         c.Next()
     }
-}
\ No newline at end of file
+}
+
+// IsOnTeam lets any member or manager of the team through.
+func IsOnTeam(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teamID, err := utils.GetUUIDFromParam(c, "teamId")
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		userID, err := utils.GetUserUUIDFromContext(c)
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		onTeam, customErr := services.NewAuthorizationService(db).IsOnTeam(userID, teamID)
+		if customErr != nil {
+			_ = c.Error(customErr)
+			c.Abort()
+			return
+		}
+		if !onTeam {
+			_ = c.Error(&errorHandling.CustomError{Code: http.StatusForbidden, Message: "You are not on this team"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CanExportTeamAudit lets COMPLIANCE users and the team's lead managers through.
+func CanExportTeamAudit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") == "COMPLIANCE" {
+			c.Next()
+			return
+		}
+		IsLeadManager(db)(c)
+	}
+}
@@ -26,6 +26,16 @@ var (
 		},
 		[]string{"method", "path", "status"},
 	)
+
+	// cacheLookups counts cache-aside lookups by cache and result (hit or miss), so each
+	// cache's hit ratio can be graphed.
+	cacheLookups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Cache lookups by cache and result.",
+		},
+		[]string{"cache", "result"},
+	)
 )
 
 // PrometheusMiddleware creates a gin middleware for Prometheus metrics.
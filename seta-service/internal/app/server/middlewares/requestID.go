@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"seta-pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestIDLength bounds a caller-supplied X-Request-ID.
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID, stored in the context as "requestId" and sent
+// back in X-Request-ID. A caller's own X-Request-ID is kept so one ID follows a request
+// across services; one that is too long or has characters other than letters, digits,
+// '-', '_' and '.' is replaced with a new UUID. The request's context carries the ID
+// too, so events enqueued while handling it are published with it, and the request's
+// span is tagged with it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set("requestId", id)
+		c.Request = c.Request.WithContext(requestid.With(c.Request.Context(), id))
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+		c.Header(requestid.Header, id)
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
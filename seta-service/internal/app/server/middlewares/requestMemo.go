@@ -0,0 +1,16 @@
+package middlewares
+
+import (
+	"seta/internal/pkg/userclient"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestUserMemo gives every request its own bounded memo of user-service lookups, so
+// looking up the same user twice while serving one request only calls out once.
+func RequestUserMemo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(userclient.WithRequestMemo(c.Request.Context()))
+		c.Next()
+	}
+}
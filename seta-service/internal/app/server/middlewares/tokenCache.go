@@ -0,0 +1,208 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"seta-pkg/database"
+	"seta-pkg/logging"
+	"seta/internal/pkg/userclient"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tokenRevocationChannel is the Postgres notification channel that carries token
+// revocations between instances. A payload is "token:" and the hex SHA-256 of one token,
+// or "user:" and the ID of a user whose tokens are all revoked.
+const tokenRevocationChannel = "auth_token_revoked"
+
+// tokenRevocationRetry is the wait before the revocation listener reconnects.
+const tokenRevocationRetry = 5 * time.Second
+
+// maxTokenCacheEntries bounds memory use; expired entries are swept once it is exceeded.
+const maxTokenCacheEntries = 10000
+
+type tokenCacheEntry struct {
+	user      userclient.User
+	valid     bool
+	expiresAt time.Time
+}
+
+// tokenCache keeps token verifications so most requests skip the user service. A valid
+// token is kept for as long as the user service's Cache-Control hint allows, but no
+// longer than AUTH_TOKEN_CACHE_SECONDS (default 60); an invalid one for
+// AUTH_TOKEN_NEGATIVE_CACHE_SECONDS (default 5), so repeated attempts with a bad token
+// do not all reach the user service. Tokens are keyed by their SHA-256, never stored in
+// clear.
+//
+// Revocations reach every instance through a Postgres notification, see RevokeToken. An
+// instance that is not listening for them does not cache at all, since it would go on
+// accepting a token revoked elsewhere.
+type tokenCache struct {
+	mu          sync.Mutex
+	listening   bool
+	entries     map[[sha256.Size]byte]tokenCacheEntry
+	maxTTL      time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+}
+
+func newTokenCache() *tokenCache {
+	tc := &tokenCache{
+		entries:     make(map[[sha256.Size]byte]tokenCacheEntry),
+		maxTTL:      60 * time.Second,
+		negativeTTL: 5 * time.Second,
+		now:         time.Now,
+	}
+	if v, _ := strconv.Atoi(os.Getenv("AUTH_TOKEN_CACHE_SECONDS")); v > 0 {
+		tc.maxTTL = time.Duration(v) * time.Second
+	}
+	if v, _ := strconv.Atoi(os.Getenv("AUTH_TOKEN_NEGATIVE_CACHE_SECONDS")); v > 0 {
+		tc.negativeTTL = time.Duration(v) * time.Second
+	}
+	return tc
+}
+
+// get returns the cached verification of token: the user and whether the token was
+// valid. ok is false when the token has to be verified.
+func (tc *tokenCache) get(token string) (user userclient.User, valid bool, ok bool) {
+	key := sha256.Sum256([]byte(token))
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if !tc.listening {
+		return userclient.User{}, false, false
+	}
+	entry, ok := tc.entries[key]
+	if !ok {
+		cacheLookups.WithLabelValues("token", "miss").Inc()
+		return userclient.User{}, false, false
+	}
+	if !tc.now().Before(entry.expiresAt) {
+		delete(tc.entries, key)
+		cacheLookups.WithLabelValues("token", "miss").Inc()
+		return userclient.User{}, false, false
+	}
+	cacheLookups.WithLabelValues("token", "hit").Inc()
+	return entry.user, entry.valid, true
+}
+
+func (tc *tokenCache) put(token string, user userclient.User, ttl time.Duration) {
+	tc.store(token, tokenCacheEntry{user: user, valid: true}, min(ttl, tc.maxTTL))
+}
+
+func (tc *tokenCache) putInvalid(token string) {
+	tc.store(token, tokenCacheEntry{}, tc.negativeTTL)
+}
+
+func (tc *tokenCache) store(token string, entry tokenCacheEntry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	key := sha256.Sum256([]byte(token))
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if !tc.listening {
+		return
+	}
+	now := tc.now()
+	if len(tc.entries) >= maxTokenCacheEntries {
+		for k, entry := range tc.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(tc.entries, k)
+			}
+		}
+		if len(tc.entries) >= maxTokenCacheEntries {
+			return
+		}
+	}
+	entry.expiresAt = now.Add(ttl)
+	tc.entries[key] = entry
+}
+
+// forgetToken drops the verification of the token whose SHA-256 is key.
+func (tc *tokenCache) forgetToken(key [sha256.Size]byte) bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	_, ok := tc.entries[key]
+	delete(tc.entries, key)
+	return ok
+}
+
+// forgetUser drops the verifications of every token of the user and returns how many.
+func (tc *tokenCache) forgetUser(userID string) int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	forgotten := 0
+	for k, entry := range tc.entries {
+		if entry.user.UserID == userID {
+			delete(tc.entries, k)
+			forgotten++
+		}
+	}
+	return forgotten
+}
+
+// setListening turns caching on once revocations from other instances are heard, and
+// off when they may be missed. Either way it drops every entry, as a revocation may
+// have been missed meanwhile.
+func (tc *tokenCache) setListening(listening bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.listening = listening
+	tc.entries = make(map[[sha256.Size]byte]tokenCacheEntry)
+}
+
+// revoked applies a revocation notification.
+func (tc *tokenCache) revoked(payload string) {
+	kind, value, _ := strings.Cut(payload, ":")
+	switch kind {
+	case "token":
+		var key [sha256.Size]byte
+		if n, err := hex.Decode(key[:], []byte(value)); err == nil && n == len(key) {
+			tc.forgetToken(key)
+		}
+	case "user":
+		tc.forgetUser(value)
+	}
+}
+
+func (tc *tokenCache) listen(ctx context.Context, log logging.Logger) {
+	database.Subscribe(ctx, log, tokenRevocationChannel, tokenRevocationRetry, tc.setListening, tc.revoked)
+}
+
+// ListenForTokenRevocations applies the revocations of every instance to the token
+// cache until ctx is done. Tokens are only cached while it is connected.
+func ListenForTokenRevocations(ctx context.Context, log logging.Logger) {
+	verifiedTokens.listen(ctx, log)
+}
+
+func notifyTokenRevocation(ctx context.Context, db *gorm.DB, payload string) error {
+	return db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", tokenRevocationChannel, payload).Error
+}
+
+// RevokeToken makes AuthMiddleware verify the token whose SHA-256 is key again on its
+// next use, here and on every other instance, for a token revoked at the user service.
+// It reports whether the token was cached here.
+func RevokeToken(ctx context.Context, db *gorm.DB, key [sha256.Size]byte) (bool, error) {
+	forgotten := verifiedTokens.forgetToken(key)
+	return forgotten, notifyTokenRevocation(ctx, db, "token:"+hex.EncodeToString(key[:]))
+}
+
+// RevokeUserTokens does the same for every token of the user and returns how many were
+// dropped here.
+func RevokeUserTokens(ctx context.Context, db *gorm.DB, userID string) (int, error) {
+	forgotten := verifiedTokens.forgetUser(userID)
+	return forgotten, notifyTokenRevocation(ctx, db, "user:"+userID)
+}
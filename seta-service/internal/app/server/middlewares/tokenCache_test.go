@@ -0,0 +1,180 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"seta-pkg/logging"
+	"seta/internal/pkg/testdb"
+	"seta/internal/pkg/userclient"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestTokenCache returns a listening cache on a clock the test moves.
+func newTestTokenCache() (*tokenCache, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	tc := newTokenCache()
+	tc.maxTTL = time.Minute
+	tc.negativeTTL = 5 * time.Second
+	tc.now = func() time.Time { return now }
+	tc.setListening(true)
+	return tc, &now
+}
+
+func tokenKey(token string) string {
+	key := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(key[:])
+}
+
+func TestTokenCacheCapsTTL(t *testing.T) {
+	tc, now := newTestTokenCache()
+	user := userclient.User{UserID: "u1", Role: "MEMBER"}
+
+	tc.put("long", user, 10*time.Minute)
+	tc.put("short", user, 10*time.Second)
+	tc.put("uncacheable", user, 0)
+
+	if _, _, ok := tc.get("uncacheable"); ok {
+		t.Error("cached a verification the user service said not to cache")
+	}
+	*now = now.Add(10 * time.Second)
+	if _, _, ok := tc.get("short"); ok {
+		t.Error("kept a verification past the user service's max-age")
+	}
+	*now = now.Add(49 * time.Second)
+	if got, valid, ok := tc.get("long"); !ok || !valid || got != user {
+		t.Errorf("get = %+v, %v, %v before AUTH_TOKEN_CACHE_SECONDS, want the cached user", got, valid, ok)
+	}
+	*now = now.Add(time.Second)
+	if _, _, ok := tc.get("long"); ok {
+		t.Error("kept a verification past AUTH_TOKEN_CACHE_SECONDS")
+	}
+}
+
+func TestTokenCacheRemembersInvalidTokensBriefly(t *testing.T) {
+	tc, now := newTestTokenCache()
+
+	tc.putInvalid("forged")
+	if _, valid, ok := tc.get("forged"); !ok || valid {
+		t.Errorf("get = %v, %v, want a cached invalid verification", valid, ok)
+	}
+	*now = now.Add(5 * time.Second)
+	if _, _, ok := tc.get("forged"); ok {
+		t.Error("kept an invalid verification past AUTH_TOKEN_NEGATIVE_CACHE_SECONDS")
+	}
+}
+
+func TestTokenCacheRevocations(t *testing.T) {
+	tc, _ := newTestTokenCache()
+	carol := userclient.User{UserID: "carol"}
+	dave := userclient.User{UserID: "dave"}
+	tc.put("carol-laptop", carol, time.Minute)
+	tc.put("carol-phone", carol, time.Minute)
+	tc.put("dave-laptop", dave, time.Minute)
+
+	tc.revoked("token:" + tokenKey("carol-laptop"))
+	if _, _, ok := tc.get("carol-laptop"); ok {
+		t.Error("kept a revoked token")
+	}
+	if _, _, ok := tc.get("carol-phone"); !ok {
+		t.Error("revoking one token dropped another of the same user")
+	}
+
+	tc.revoked("user:carol")
+	if _, _, ok := tc.get("carol-phone"); ok {
+		t.Error("kept a token of a user whose tokens were all revoked")
+	}
+
+	tc.revoked("token:not-hex")
+	tc.revoked("garbage")
+	if _, _, ok := tc.get("dave-laptop"); !ok {
+		t.Error("a malformed revocation dropped an unrelated token")
+	}
+}
+
+func TestTokenCacheIsOffWhileNotListening(t *testing.T) {
+	tc, _ := newTestTokenCache()
+	tc.put("token", userclient.User{UserID: "u1"}, time.Minute)
+
+	tc.setListening(false)
+	if _, _, ok := tc.get("token"); ok {
+		t.Error("kept verifications after the revocation listener stopped")
+	}
+	tc.put("token", userclient.User{UserID: "u1"}, time.Minute)
+	tc.putInvalid("forged")
+	tc.setListening(true)
+	if _, _, ok := tc.get("token"); ok {
+		t.Error("cached a verification while not listening for revocations")
+	}
+	if _, _, ok := tc.get("forged"); ok {
+		t.Error("cached an invalid verification while not listening for revocations")
+	}
+}
+
+func TestAuthMiddlewareVerifiesRevokedTokensAgain(t *testing.T) {
+	verifiedTokens.setListening(true)
+	t.Cleanup(func() { verifiedTokens.setListening(false) })
+	srv, calls := fakeUserService(t, "private, max-age=60")
+	r := newAuthTestRouter(srv)
+	token := "valid-" + uuid.NewString()
+
+	for i := 0; i < 3; i++ {
+		if rec := get(r, token); rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("user service called %d times for three requests, want 1", got)
+	}
+
+	verifiedTokens.revoked("token:" + tokenKey(token))
+	if rec := get(r, token); rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("user service called %d times, want the revoked token verified again", got)
+	}
+}
+
+// A revocation sent on one instance must reach the cache of another, or a logged-out
+// token would be accepted there until its entry expires.
+func TestTokenRevocationReachesOtherInstances(t *testing.T) {
+	db := testdb.Open(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	other := newTokenCache()
+	go other.listen(ctx, logging.Nop())
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		other.mu.Lock()
+		listening := other.listening
+		other.mu.Unlock()
+		if listening {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the revocation listener to connect")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	token := "valid-" + uuid.NewString()
+	other.put(token, userclient.User{UserID: "carol"}, time.Minute)
+	if _, err := RevokeToken(ctx, db, sha256.Sum256([]byte(token))); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	for {
+		if _, _, ok := other.get(token); !ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the revocation to reach the other instance")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
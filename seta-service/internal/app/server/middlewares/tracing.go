@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// untracedPaths are polled by Prometheus and the orchestrator; a trace for each poll
+// would bury the requests worth looking at.
+var untracedPaths = map[string]bool{"/metrics": true, "/healthz": true, "/readyz": true}
+
+// Tracing starts a span for each request, named after its route, and puts it in the
+// request context so queries and published events become its children. A caller's
+// traceparent header is continued. It records nothing unless tracing is enabled.
+func Tracing() gin.HandlerFunc {
+	return otelgin.Middleware("seta-service", otelgin.WithFilter(func(r *http.Request) bool {
+		return !untracedPaths[r.URL.Path]
+	}))
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"seta/internal/app/server/controllers"
+	"seta/internal/app/server/middlewares"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterAuditRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	auditController := controllers.NewAuditController(db)
+
+	// Exports sit outside the MANAGER-only team group so COMPLIANCE users can reach them.
+	rg.GET("/teams/:teamId/audit/export", middlewares.CanExportTeamAudit(db), auditController.ExportTeamAudit)
+	rg.POST("/audit/verify", auditController.VerifyAuditExport)
+	rg.GET("/audit/stats", middlewares.IsAuthorizedRole("COMPLIANCE"), auditController.GetAuditStats)
+}
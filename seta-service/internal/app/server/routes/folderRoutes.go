@@ -1,28 +1,43 @@
 package routes
 
 import (
+	"seta-pkg/logging"
 	"seta/internal/app/server/controllers"
 	"seta/internal/app/server/middlewares"
+	"seta/internal/app/server/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func RegisterFolderRoutes(rg *gin.RouterGroup, db *gorm.DB) {
-	folderController := controllers.NewFolderController(db)
+func RegisterFolderRoutes(rg *gin.RouterGroup, db *gorm.DB, authorization *services.AuthorizationService, log logging.Logger) {
+	folderController := controllers.NewFolderController(db, authorization, log)
+	webhookController := controllers.NewWebhookController(db)
+	activityController := controllers.NewActivityController()
 	folders := rg.Group("/folders")
 	{
 		// No asset auth needed, just auth from the parent router group.
 		folders.POST("", folderController.CreateFolder)
+		folders.GET("/deletions/:jobId", folderController.GetDeletionJob)
 
 		// Routes requiring specific permissions on an existing folder.
-		folders.GET("/:folderId", middlewares.CanReadFolder(db), folderController.GetFolder)
-		folders.PUT("/:folderId", middlewares.CanWriteFolder(db), folderController.UpdateFolder)
-		folders.DELETE("/:folderId", middlewares.IsFolderOwner(db), folderController.DeleteFolder)
-		folders.POST("/:folderId/share", middlewares.IsFolderOwner(db), folderController.ShareFolder)
-		folders.DELETE("/:folderId/share/:userId", middlewares.IsFolderOwner(db), folderController.RevokeFolderSharing)
+		folders.GET("/:folderId", middlewares.CanReadFolder(authorization), folderController.GetFolder)
+		folders.PUT("/:folderId", middlewares.CanWriteFolder(authorization), middlewares.FolderNotPendingDeletion(db), folderController.UpdateFolder)
+		folders.PATCH("/:folderId", middlewares.IsFolderOwner(authorization), folderController.UpdateFolderSettings)
+		folders.DELETE("/:folderId", middlewares.IsFolderOwner(authorization), folderController.DeleteFolder)
+		folders.GET("/:folderId/shares", middlewares.IsFolderOwner(authorization), folderController.ListFolderShares)
+		folders.GET("/:folderId/activity", middlewares.CanReadFolder(authorization), activityController.GetFolderActivity)
+		folders.POST("/:folderId/share", middlewares.IsFolderOwner(authorization), middlewares.FolderNotPendingDeletion(db), folderController.ShareFolder)
+		folders.POST("/:folderId/share/batch", middlewares.IsFolderOwner(authorization), middlewares.FolderNotPendingDeletion(db), folderController.ShareFolderBatch)
+		folders.DELETE("/:folderId/share/:userId", middlewares.IsFolderOwner(authorization), folderController.RevokeFolderSharing)
+		folders.DELETE("/:folderId/notes/:noteId/share/:userId", middlewares.IsFolderOwner(authorization), folderController.RevokeNoteShareInFolder)
+
+		// Webhooks are managed by the folder owner.
+		folders.POST("/:folderId/webhooks", middlewares.IsFolderOwner(authorization), webhookController.CreateWebhook)
+		folders.GET("/:folderId/webhooks", middlewares.IsFolderOwner(authorization), webhookController.ListWebhooks)
+		folders.DELETE("/:folderId/webhooks/:webhookId", middlewares.IsFolderOwner(authorization), webhookController.DeleteWebhook)
 
 		// To create a note in a folder, the user needs write access to it.
-		folders.POST("/:folderId/notes", middlewares.CanWriteFolder(db), folderController.CreateNote)
+		folders.POST("/:folderId/notes", middlewares.CanWriteFolder(authorization), middlewares.FolderNotPendingDeletion(db), folderController.CreateNote)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"seta-pkg/logging"
+	"seta/internal/app/server/controllers"
+	"seta/internal/app/server/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterInternalRoutes(rg *gin.RouterGroup, db *gorm.DB, authorization *services.AuthorizationService, log logging.Logger) {
+	internalController := controllers.NewInternalController(db, authorization, log)
+	rg.GET("/info", internalController.GetInfo)
+	rg.POST("/smoke", internalController.RunSmokeTest)
+	rg.POST("/auth/revocations", internalController.RevokeTokens)
+
+	rg.GET("/assets/:type/:id/snapshot", internalController.GetAssetSnapshot)
+	rg.GET("/teams/:id/members", internalController.GetTeamMembers)
+
+	flags := rg.Group("/flags")
+	{
+		flags.GET("", internalController.ListFeatureFlags)
+		flags.PUT("/:name", internalController.SaveFeatureFlag)
+		flags.GET("/:name/evaluate", internalController.EvaluateFeatureFlag)
+	}
+
+	dispatch := rg.Group("/dispatch")
+	{
+		dispatch.GET("/status", internalController.GetDispatchStatus)
+		dispatch.POST("/pause", internalController.PauseDispatch)
+		dispatch.POST("/resume", internalController.ResumeDispatch)
+	}
+
+	events := rg.Group("/events")
+	{
+		events.POST("/replay", internalController.ReplayAssetEvents)
+	}
+}
@@ -3,20 +3,28 @@ package routes
 import (
 	"seta/internal/app/server/controllers"
 	"seta/internal/app/server/middlewares"
+	"seta/internal/app/server/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func RegisterNoteRoutes(rg *gin.RouterGroup, db *gorm.DB) {
-	noteController := controllers.NewNoteController(db)
+func RegisterNoteRoutes(rg *gin.RouterGroup, db *gorm.DB, authorization *services.AuthorizationService) {
+	noteController := controllers.NewNoteController(db, authorization)
+	activityController := controllers.NewActivityController()
 	notes := rg.Group("/notes")
 	{
 		// Note creation is now under folder routes.
-		notes.GET("/:noteId", middlewares.CanReadNote(db), noteController.GetNote)
-		notes.PUT("/:noteId", middlewares.CanWriteNote(db), noteController.UpdateNote)
-		notes.DELETE("/:noteId", middlewares.IsNoteOwner(db), noteController.DeleteNote)
-		notes.POST("/:noteId/share", middlewares.IsNoteOwner(db), noteController.ShareNote)
-		notes.DELETE("/:noteId/share/:userId", middlewares.IsNoteOwner(db), noteController.RevokeNoteSharing)
+		notes.GET("/trash", noteController.ListTrash)
+		notes.POST("/:noteId/restore", noteController.RestoreNote)
+		notes.GET("/:noteId", middlewares.CanReadNote(authorization), noteController.GetNote)
+		notes.PUT("/:noteId", middlewares.CanWriteNote(authorization), noteController.UpdateNote)
+		notes.PATCH("/:noteId", middlewares.IsNoteOwner(authorization), noteController.UpdateNoteSettings)
+		notes.DELETE("/:noteId", middlewares.IsNoteOwner(authorization), noteController.DeleteNote)
+		notes.GET("/:noteId/shares", middlewares.IsNoteOwner(authorization), noteController.ListNoteShares)
+		notes.GET("/:noteId/activity", middlewares.CanReadNote(authorization), activityController.GetNoteActivity)
+		notes.POST("/:noteId/share", middlewares.CanShareNote(authorization), noteController.ShareNote)
+		notes.POST("/:noteId/share/batch", middlewares.CanShareNote(authorization), noteController.ShareNoteBatch)
+		notes.DELETE("/:noteId/share/:userId", middlewares.IsNoteOwner(authorization), noteController.RevokeNoteSharing)
 	}
 }
\ No newline at end of file
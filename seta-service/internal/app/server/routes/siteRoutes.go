@@ -1,38 +1,70 @@
 package routes
 
 import (
+	"context"
+	"os"
+	"seta-pkg/database"
+	"seta-pkg/health"
+	"seta-pkg/logging"
 	"seta/internal/app/server/middlewares"
+	"seta/internal/app/server/services"
 	"seta/internal/pkg/errorHandling"
 	"seta/internal/pkg/logger"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 )
 
-// SetupRouter initializes the Gin router and sets up all application routes.
-func SetupRouter(db *gorm.DB, log *zerolog.Logger) *gin.Engine {
+// SetupRouter initializes the Gin router and sets up all application routes. Every route
+// shares the one authorization service, so that the purges made by the handlers clear
+// the decisions cached on behalf of the middlewares.
+func SetupRouter(db *gorm.DB, authorization *services.AuthorizationService, log logging.Logger) *gin.Engine {
     r := gin.Default()
 
     // Global Middleware
+    r.Use(middlewares.Tracing())
+    r.Use(middlewares.RequestID())
     r.Use(logger.RequestLogger(log))
     r.Use(middlewares.PrometheusMiddleware())
-    r.Use(errorHandling.ErrorHandler())
+    r.Use(middlewares.RequestUserMemo())
+    r.Use(errorHandling.ErrorHandler(log))
 
     // Public Routes (No Auth Required)
     r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+    r.GET("/healthz", gin.WrapF(health.LiveHandler()))
+    r.GET("/readyz", gin.WrapF(health.ReadyHandler(readinessChecks(db), nil)))
+
+    // Internal Routes (Operator API Key Required)
+    internal := r.Group("/internal")
+    internal.Use(middlewares.InternalAPIKey())
+    {
+        RegisterInternalRoutes(internal, db, authorization, log)
+    }
 
     // API Group with Authentication Middleware
     api := r.Group("/api")
-    api.Use(middlewares.AuthMiddleware())
+    api.Use(middlewares.AuthMiddleware(middlewares.AuthConfigFromEnv()))
     {
         // Register modularized routes
-        RegisterTeamRoutes(api, db)
-        RegisterUserRoutes(api, db)
-        RegisterFolderRoutes(api, db)
-        RegisterNoteRoutes(api, db)
+        RegisterTeamRoutes(api, db, authorization, log)
+        RegisterUserRoutes(api, db, log)
+        RegisterFolderRoutes(api, db, authorization, log)
+        RegisterNoteRoutes(api, db, authorization)
+        RegisterTemplateRoutes(api, db, authorization)
+        RegisterAuditRoutes(api, db)
     }
 
     return r
-}
\ No newline at end of file
+}
+// readinessChecks are the dependencies /readyz reports. Only Postgres is critical: events
+// go through the outbox, so requests are served while Kafka is unreachable.
+func readinessChecks(db *gorm.DB) []health.Check {
+    return []health.Check{
+        {Name: "postgres", Critical: true, Run: func(ctx context.Context) error {
+            return database.Ping(ctx, db)
+        }},
+        health.TCP("kafka", false, strings.Split(os.Getenv("KAFKA_BROKERS"), ",")),
+    }
+}
@@ -1,23 +1,39 @@
 package routes
 
 import (
+	"seta-pkg/logging"
 	"seta/internal/app/server/controllers"
 	"seta/internal/app/server/middlewares"
+	"seta/internal/app/server/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func RegisterTeamRoutes(rg *gin.RouterGroup, db *gorm.DB) {
-	teamController := controllers.NewTeamController(db)
+func RegisterTeamRoutes(rg *gin.RouterGroup, db *gorm.DB, authorization *services.AuthorizationService, log logging.Logger) {
+	teamController := controllers.NewTeamController(db, authorization, log)
 	teams := rg.Group("/teams")
 	teams.Use(middlewares.IsAuthorizedRole("MANAGER"))
 	{
 		teams.POST("", teamController.CreateTeam)
+		teams.PUT("/:teamId", middlewares.IsLeadManager(db), teamController.RenameTeam)
+		teams.DELETE("/:teamId", middlewares.IsLeadManager(db), teamController.DeleteTeam)
+		teams.POST("/:teamId/lead", middlewares.IsLeadManager(db), teamController.TransferLead)
 		teams.POST("/:teamId/members", middlewares.IsTeamManager(db), teamController.AddMember)
 		teams.DELETE("/:teamId/members/:memberId", middlewares.IsTeamManager(db), teamController.RemoveMember)
 		teams.POST("/:teamId/managers", middlewares.IsLeadManager(db), teamController.AddManager)
 		teams.DELETE("/:teamId/managers/:managerId", middlewares.IsLeadManager(db), teamController.RemoveManager)
 		teams.GET("/:teamId/assets", middlewares.IsTeamManager(db), teamController.GetTeamAssets)
+		teams.GET("/:teamId/assets/hygiene", middlewares.IsTeamManager(db), teamController.GetAssetHygiene)
+		teams.GET("/:teamId/collaboration", middlewares.IsTeamManager(db), teamController.GetCollaboration)
+		teams.GET("/:teamId/stats", middlewares.IsTeamManager(db), teamController.GetTeamStats)
+		teams.POST("/:teamId/announcements", middlewares.IsTeamManager(db), teamController.CreateAnnouncement)
+		teams.PATCH("/:teamId/announcements/:noteId", middlewares.IsTeamManager(db), teamController.UpdateAnnouncement)
 	}
+
+	// Members read their teams and announcements too, so these routes skip the MANAGER role check.
+	rg.GET("/teams", teamController.ListMyTeams)
+	rg.GET("/teams/:teamId", teamController.GetTeam)
+	rg.GET("/teams/:teamId/announcements", middlewares.IsOnTeam(db), teamController.ListAnnouncements)
+	rg.DELETE("/teams/:teamId/members/me", teamController.LeaveTeam)
 }
\ No newline at end of file
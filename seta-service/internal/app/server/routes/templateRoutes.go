@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"seta/internal/app/server/controllers"
+	"seta/internal/app/server/middlewares"
+	"seta/internal/app/server/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterTemplateRoutes(rg *gin.RouterGroup, db *gorm.DB, authorization *services.AuthorizationService) {
+	templateController := controllers.NewTemplateController(db, authorization)
+	templates := rg.Group("/templates")
+	{
+		// Team templates are checked against team management inside the handler.
+		templates.POST("", templateController.CreateTemplate)
+		templates.GET("", templateController.ListTemplates)
+
+		templates.GET("/:templateId", middlewares.CanReadTemplate(authorization), templateController.GetTemplate)
+		templates.PUT("/:templateId", middlewares.CanManageTemplate(authorization), templateController.UpdateTemplate)
+		templates.DELETE("/:templateId", middlewares.CanManageTemplate(authorization), templateController.DeleteTemplate)
+	}
+}
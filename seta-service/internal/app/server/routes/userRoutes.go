@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"seta-pkg/logging"
 	"seta/internal/app/server/controllers"
 	"seta/internal/app/server/middlewares"
 	"seta/internal/app/server/services"
@@ -9,13 +10,16 @@ import (
 	"gorm.io/gorm"
 )
 
-func RegisterUserRoutes(rg *gin.RouterGroup, db *gorm.DB) {
-	userService := services.NewUserService()
+func RegisterUserRoutes(rg *gin.RouterGroup, db *gorm.DB, log logging.Logger) {
+	userService := services.NewUserService(db, log)
 	userController := controllers.NewUserController(db, userService)
 
 	users := rg.Group("/users")
 	{
 		users.GET("/:userId/assets", userController.GetUserAssets)
+		users.GET("/:userId/stats", userController.GetUserStats)
 		users.POST("/import", middlewares.IsAuthorizedRole("MANAGER"), userController.ImportUsers)
+		users.GET("/import/:importId", middlewares.IsAuthorizedRole("MANAGER"), userController.GetImportJob)
+		users.GET("/import/:importId/failures", middlewares.IsAuthorizedRole("MANAGER"), userController.GetImportFailures)
 	}
 }
\ No newline at end of file
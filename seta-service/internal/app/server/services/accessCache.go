@@ -0,0 +1,138 @@
+package services
+
+import (
+	"container/list"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var accessCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "authz_cache_lookups_total",
+	Help: "Access decision cache lookups by result (hit or miss).",
+}, []string{"result"})
+
+// accessKey identifies one decision: which check a user asked for on which asset.
+type accessKey struct {
+	check   string
+	userID  uuid.UUID
+	assetID uuid.UUID
+}
+
+type accessEntry struct {
+	key       accessKey
+	allowed   bool
+	expiresAt time.Time
+}
+
+// accessCache is a least recently used cache of access decisions, kept for
+// AUTHZ_CACHE_SECONDS (default 30) and bounded to AUTHZ_CACHE_ENTRIES (default 10000).
+//
+// Every change to shares, folder parents or team membership purges it whole rather than
+// working out which decisions it affects: a share reaches down a folder tree and a team
+// reaches every team folder, and such changes are rare next to reads. A purge bumps the
+// generation, so a check that read the database before the change cannot store its
+// outdated answer after it. Purges reach the other instances through a Postgres
+// notification; an instance that is not listening for them does not cache at all, since
+// it would not hear of a revoke made elsewhere.
+type accessCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	mu         sync.Mutex
+	listening  bool
+	generation uint64
+	entries    map[accessKey]*list.Element
+	order      *list.List // of *accessEntry, most recently used first
+}
+
+func newAccessCache() *accessCache {
+	cache := &accessCache{
+		ttl:     30 * time.Second,
+		maxSize: 10000,
+		entries: make(map[accessKey]*list.Element),
+		order:   list.New(),
+	}
+	if v, _ := strconv.Atoi(os.Getenv("AUTHZ_CACHE_SECONDS")); v > 0 {
+		cache.ttl = time.Duration(v) * time.Second
+	}
+	if v, _ := strconv.Atoi(os.Getenv("AUTHZ_CACHE_ENTRIES")); v > 0 {
+		cache.maxSize = v
+	}
+	return cache
+}
+
+// get returns the cached decision for key. When ok is false the decision has to be made
+// and handed to put along with the generation returned here.
+func (c *accessCache) get(key accessKey) (allowed, ok bool, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.listening {
+		return false, false, c.generation
+	}
+	element, ok := c.entries[key]
+	if !ok {
+		accessCacheLookups.WithLabelValues("miss").Inc()
+		return false, false, c.generation
+	}
+	entry := element.Value.(*accessEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		accessCacheLookups.WithLabelValues("miss").Inc()
+		return false, false, c.generation
+	}
+	c.order.MoveToFront(element)
+	accessCacheLookups.WithLabelValues("hit").Inc()
+	return entry.allowed, true, c.generation
+}
+
+// put stores a decision made at generation, unless the cache was purged since.
+func (c *accessCache) put(key accessKey, allowed bool, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.listening || generation != c.generation {
+		return
+	}
+	entry := &accessEntry{key: key, allowed: allowed, expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*accessEntry).key)
+	}
+}
+
+// purge drops every decision.
+func (c *accessCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[accessKey]*list.Element)
+	c.order.Init()
+}
+
+// setListening turns caching on once purges from other instances are heard, and off when
+// they may be missed. Either way it purges, as a purge may have been missed meanwhile.
+func (c *accessCache) setListening(listening bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.listening = listening
+	c.generation++
+	c.entries = make(map[accessKey]*list.Element)
+	c.order.Init()
+}
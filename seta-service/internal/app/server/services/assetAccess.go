@@ -0,0 +1,146 @@
+package services
+
+import (
+	"seta/internal/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AssetAccess is what a user may do with an asset listed for them and, when that comes
+// from a folder above the asset, which folder. ViaFolderID is nil for assets the user
+// owns or that were shared with them directly.
+type AssetAccess struct {
+	Access      string     `json:"access"`
+	ViaFolderID *uuid.UUID `json:"viaFolderId,omitempty"`
+}
+
+// folderGrantsSQL finds, for each of the given folders, the strongest grant the user has
+// on it or one of its ancestors: owning a folder or a write share on it allows writing, a
+// read share reading. Of equally strong grants the nearest folder wins.
+const folderGrantsSQL = `
+WITH RECURSIVE chain AS (
+	SELECT folder_id AS target, folder_id, parent_folder_id, 0 AS depth
+	FROM folders WHERE folder_id IN @folders
+	UNION ALL
+	SELECT c.target, f.folder_id, f.parent_folder_id, c.depth + 1
+	FROM folders f JOIN chain c ON f.folder_id = c.parent_folder_id
+	WHERE c.depth < @maxDepth
+)
+SELECT DISTINCT ON (c.target) c.target AS folder_id, c.folder_id AS via_folder_id,
+	CASE WHEN f.owner_id = @user OR fs.access = 'write' THEN 'write' ELSE 'read' END AS access
+FROM chain c
+JOIN folders f ON f.folder_id = c.folder_id
+LEFT JOIN folder_shares fs ON fs.folder_id = c.folder_id AND fs.user_id = @user
+WHERE f.owner_id = @user OR fs.user_id IS NOT NULL
+ORDER BY c.target, (f.owner_id = @user OR fs.access = 'write') DESC, c.depth`
+
+type folderGrant struct {
+	FolderID    uuid.UUID
+	ViaFolderID uuid.UUID
+	Access      string
+}
+
+func folderGrants(db *gorm.DB, userID uuid.UUID, folderIDs []uuid.UUID) (map[uuid.UUID]folderGrant, error) {
+	grants := make(map[uuid.UUID]folderGrant, len(folderIDs))
+	if len(folderIDs) == 0 {
+		return grants, nil
+	}
+	var rows []folderGrant
+	if err := db.Raw(folderGrantsSQL, map[string]any{"folders": folderIDs, "user": userID, "maxDepth": MaxFolderDepth}).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		grants[row.FolderID] = row
+	}
+	return grants, nil
+}
+
+// FolderAccess returns the access of userID to each folder of a listing: owner for their
+// own, otherwise the strongest share on the folder or on a folder above it.
+func FolderAccess(db *gorm.DB, userID uuid.UUID, folders []models.Folder) (map[uuid.UUID]AssetAccess, error) {
+	access := make(map[uuid.UUID]AssetAccess, len(folders))
+	var others []uuid.UUID
+	for _, folder := range folders {
+		if folder.OwnerID == userID {
+			access[folder.FolderID] = AssetAccess{Access: models.AccessOwner}
+		} else {
+			others = append(others, folder.FolderID)
+		}
+	}
+
+	grants, err := folderGrants(db, userID, others)
+	if err != nil {
+		return nil, err
+	}
+	for _, folderID := range others {
+		grant, ok := grants[folderID]
+		if !ok {
+			access[folderID] = AssetAccess{Access: models.AccessRead}
+			continue
+		}
+		entry := AssetAccess{Access: grant.Access}
+		if grant.ViaFolderID != folderID {
+			entry.ViaFolderID = &grant.ViaFolderID
+		}
+		access[folderID] = entry
+	}
+	return access, nil
+}
+
+// NoteAccess returns the access of userID to each note of a listing: owner for their
+// own, otherwise the stronger of a share on the note and the grant of its folder chain.
+// A share on the note wins a tie, so viaFolderId is only set when the folder gives more.
+func NoteAccess(db *gorm.DB, userID uuid.UUID, notes []models.Note) (map[uuid.UUID]AssetAccess, error) {
+	access := make(map[uuid.UUID]AssetAccess, len(notes))
+	var others, folderIDs []uuid.UUID
+	for _, note := range notes {
+		if note.OwnerID == userID {
+			access[note.NoteID] = AssetAccess{Access: models.AccessOwner}
+		} else {
+			others = append(others, note.NoteID)
+			folderIDs = append(folderIDs, note.FolderID)
+		}
+	}
+	if len(others) == 0 {
+		return access, nil
+	}
+
+	var shares []models.NoteShare
+	if err := db.Where("note_id IN ? AND user_id = ?", others, userID).Find(&shares).Error; err != nil {
+		return nil, err
+	}
+	shared := make(map[uuid.UUID]string, len(shares))
+	for _, share := range shares {
+		shared[share.NoteID] = share.Access
+	}
+	grants, err := folderGrants(db, userID, folderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, note := range notes {
+		if note.OwnerID == userID {
+			continue
+		}
+		entry := AssetAccess{Access: shared[note.NoteID]}
+		if grant, ok := grants[note.FolderID]; ok && accessRank(grant.Access) > accessRank(entry.Access) {
+			entry = AssetAccess{Access: grant.Access, ViaFolderID: &grant.ViaFolderID}
+		}
+		if entry.Access == "" {
+			entry.Access = models.AccessRead
+		}
+		access[note.NoteID] = entry
+	}
+	return access, nil
+}
+
+func accessRank(access string) int {
+	switch access {
+	case models.AccessWrite:
+		return 2
+	case models.AccessRead:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"seta-pkg/logging"
+	"seta/internal/pkg/userclient"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	hygieneReportTTL = 10 * time.Minute
+	// formerMemberWindow is how far back a removed member's assets are reported.
+	formerMemberWindow = 90 * 24 * time.Hour
+)
+
+// HygieneAsset is a folder or note listed in a hygiene report.
+type HygieneAsset struct {
+	AssetType string    `json:"assetType"`
+	AssetID   uuid.UUID `json:"assetId"`
+	Name      string    `json:"name"`
+	OwnerID   uuid.UUID `json:"ownerId"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// HygieneReport lists a team's assets that likely need cleaning up.
+// UnresolvedOwnerError is set when the user directory could not be queried; the
+// other lists are still valid then, but the report is not cached.
+type HygieneReport struct {
+	StaleUnshared        []HygieneAsset
+	FormerMemberOwned    []HygieneAsset
+	UnresolvedOwner      []HygieneAsset
+	UnresolvedOwnerError string
+	StaleDays            int
+	GeneratedAt          time.Time
+}
+
+type cachedHygieneReport struct {
+	report    HygieneReport
+	expiresAt time.Time
+}
+
+// AssetHygieneService builds team asset hygiene reports and caches them for ten minutes.
+type AssetHygieneService struct {
+	db               *gorm.DB
+	log              logging.Logger
+	users            *userclient.Client
+	defaultStaleDays int
+
+	mu    sync.Mutex
+	cache map[string]cachedHygieneReport
+}
+
+// NewAssetHygieneService reads ASSET_HYGIENE_STALE_DAYS (default 90).
+func NewAssetHygieneService(db *gorm.DB, log logging.Logger) *AssetHygieneService {
+	staleDays := 90
+	if v, _ := strconv.Atoi(os.Getenv("ASSET_HYGIENE_STALE_DAYS")); v > 0 {
+		staleDays = v
+	}
+
+	return &AssetHygieneService{
+		db:               db,
+		log:              log,
+		users:            userclient.Shared(),
+		defaultStaleDays: staleDays,
+		cache:            make(map[string]cachedHygieneReport),
+	}
+}
+
+// DefaultStaleDays is the staleness threshold used when the caller does not pass one.
+func (s *AssetHygieneService) DefaultStaleDays() int {
+	return s.defaultStaleDays
+}
+
+// Report returns the hygiene report for a team, from cache when fresh enough.
+func (s *AssetHygieneService) Report(ctx context.Context, teamID uuid.UUID, staleDays int) (HygieneReport, error) {
+	key := teamID.String() + ":" + strconv.Itoa(staleDays)
+
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.report, nil
+	}
+
+	report, err := s.build(ctx, teamID, staleDays)
+	if err != nil {
+		return HygieneReport{}, err
+	}
+
+	if report.UnresolvedOwnerError == "" {
+		s.mu.Lock()
+		s.cache[key] = cachedHygieneReport{report: report, expiresAt: report.GeneratedAt.Add(hygieneReportTTL)}
+		for k, entry := range s.cache {
+			if !report.GeneratedAt.Before(entry.expiresAt) {
+				delete(s.cache, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	return report, nil
+}
+
+// teamOwnersSQL selects the current members and managers of @team.
+const teamOwnersSQL = `SELECT user_id FROM team_members WHERE team_id = @team
+	UNION SELECT user_id FROM team_managers WHERE team_id = @team`
+
+// formerMembersSQL selects users removed from @team since @since who have not rejoined.
+const formerMembersSQL = `SELECT user_id FROM team_membership_changes
+	WHERE team_id = @team AND change = 'removed' AND changed_at >= @since
+	AND user_id NOT IN (` + teamOwnersSQL + `)`
+
+const staleUnsharedSQL = `
+SELECT 'folder' AS asset_type, f.folder_id AS asset_id, f.name, f.owner_id, f.updated_at
+FROM folders f
+WHERE f.owner_id IN (` + teamOwnersSQL + `)
+  AND NOT f.deletion_pending
+  AND f.updated_at < @cutoff
+  AND NOT EXISTS (SELECT 1 FROM folder_shares fs WHERE fs.folder_id = f.folder_id)
+UNION ALL
+SELECT 'note', n.note_id, n.title, n.owner_id, n.updated_at
+FROM notes n
+JOIN folders f ON f.folder_id = n.folder_id
+WHERE n.owner_id IN (` + teamOwnersSQL + `)
+  AND NOT f.deletion_pending
+  AND n.deleted_at IS NULL
+  AND n.updated_at < @cutoff
+  AND NOT EXISTS (SELECT 1 FROM note_shares ns WHERE ns.note_id = n.note_id)
+ORDER BY updated_at, asset_id`
+
+// ownedAssetsSQL lists every asset whose owner is selected by the owners expression.
+func ownedAssetsSQL(owners string) string {
+	return fmt.Sprintf(`
+SELECT 'folder' AS asset_type, f.folder_id AS asset_id, f.name, f.owner_id, f.updated_at
+FROM folders f
+WHERE f.owner_id IN (%[1]s) AND NOT f.deletion_pending
+UNION ALL
+SELECT 'note', n.note_id, n.title, n.owner_id, n.updated_at
+FROM notes n
+JOIN folders f ON f.folder_id = n.folder_id
+WHERE n.owner_id IN (%[1]s) AND NOT f.deletion_pending AND n.deleted_at IS NULL
+ORDER BY updated_at, asset_id`, owners)
+}
+
+func (s *AssetHygieneService) build(ctx context.Context, teamID uuid.UUID, staleDays int) (HygieneReport, error) {
+	now := time.Now()
+	report := HygieneReport{
+		StaleUnshared:     make([]HygieneAsset, 0),
+		FormerMemberOwned: make([]HygieneAsset, 0),
+		UnresolvedOwner:   make([]HygieneAsset, 0),
+		StaleDays:         staleDays,
+		GeneratedAt:       now,
+	}
+	args := map[string]interface{}{
+		"team":   teamID,
+		"cutoff": now.AddDate(0, 0, -staleDays),
+		"since":  now.Add(-formerMemberWindow),
+	}
+	db := s.db.WithContext(ctx)
+
+	if err := db.Raw(staleUnsharedSQL, args).Scan(&report.StaleUnshared).Error; err != nil {
+		return HygieneReport{}, err
+	}
+
+	if err := db.Raw(ownedAssetsSQL(formerMembersSQL), args).Scan(&report.FormerMemberOwned).Error; err != nil {
+		return HygieneReport{}, err
+	}
+
+	var ownerIDs []uuid.UUID
+	if err := db.Raw(`SELECT owner_id FROM folders WHERE owner_id IN (`+teamOwnersSQL+`) OR owner_id IN (`+formerMembersSQL+`)
+		UNION SELECT owner_id FROM notes WHERE deleted_at IS NULL AND (owner_id IN (`+teamOwnersSQL+`) OR owner_id IN (`+formerMembersSQL+`))`, args).
+		Scan(&ownerIDs).Error; err != nil {
+		return HygieneReport{}, err
+	}
+
+	missing := make([]uuid.UUID, 0)
+	for _, ownerID := range ownerIDs {
+		user, err := s.users.GetUser(ctx, ownerID.String())
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return HygieneReport{}, err
+			}
+			s.log.Warn("Could not resolve asset owners for hygiene report", logging.Fields{
+				logging.FieldError:  err,
+				logging.FieldTeamID: teamID.String(),
+			})
+			report.UnresolvedOwnerError = "user service unavailable"
+			return report, nil
+		}
+		if user == nil {
+			missing = append(missing, ownerID)
+		}
+	}
+
+	if len(missing) > 0 {
+		args["missing"] = missing
+		if err := db.Raw(ownedAssetsSQL("@missing"), args).Scan(&report.UnresolvedOwner).Error; err != nil {
+			return HygieneReport{}, err
+		}
+	}
+
+	return report, nil
+}
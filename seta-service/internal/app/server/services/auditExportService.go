@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/userclient"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// signaturePrefix starts the last line of every export. The HMAC covers every byte
+// before that line.
+const signaturePrefix = "# signature=hmac-sha256:"
+
+var (
+	// ErrExportSigningDisabled is returned when AUDIT_EXPORT_SIGNING_KEY is not set.
+	ErrExportSigningDisabled = errors.New("audit export signing key is not configured")
+	// ErrExportRateLimited is returned when a user exports again within the minimum interval.
+	ErrExportRateLimited = errors.New("an audit export was requested too recently, try again later")
+)
+
+// AuditExportService writes tamper-evident CSV exports of a team's membership history.
+// Exports are signed with AUDIT_EXPORT_SIGNING_KEY, and each user may export once per
+// AUDIT_EXPORT_MIN_INTERVAL_SECONDS (default 60) on this instance.
+type AuditExportService struct {
+	db          *gorm.DB
+	users       *userclient.Client
+	key         []byte
+	minInterval time.Duration
+
+	mu         sync.Mutex
+	lastExport map[uuid.UUID]time.Time
+}
+
+// NewAuditExportService creates a new AuditExportService.
+func NewAuditExportService(db *gorm.DB) *AuditExportService {
+	minInterval := 60 * time.Second
+	if v, _ := strconv.Atoi(os.Getenv("AUDIT_EXPORT_MIN_INTERVAL_SECONDS")); v > 0 {
+		minInterval = time.Duration(v) * time.Second
+	}
+	return &AuditExportService{
+		db:          db,
+		users:       userclient.Shared(),
+		key:         []byte(os.Getenv("AUDIT_EXPORT_SIGNING_KEY")),
+		minInterval: minInterval,
+		lastExport:  make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Reserve checks signing is configured and takes the user's export slot.
+func (s *AuditExportService) Reserve(userID uuid.UUID) error {
+	if len(s.key) == 0 {
+		return ErrExportSigningDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if last, ok := s.lastExport[userID]; ok && now.Sub(last) < s.minInterval {
+		return ErrExportRateLimited
+	}
+	for id, last := range s.lastExport {
+		if now.Sub(last) >= s.minInterval {
+			delete(s.lastExport, id)
+		}
+	}
+	s.lastExport[userID] = now
+	return nil
+}
+
+// WriteCSV streams the team's membership changes in [from, to) to w, oldest first,
+// with usernames resolved through the user service, then appends the signature line.
+// A username the user service cannot resolve is left empty rather than failing the export.
+func (s *AuditExportService) WriteCSV(ctx context.Context, w io.Writer, teamID uuid.UUID, from, to time.Time) error {
+	var changes []models.TeamMembershipChange
+	err := s.db.WithContext(ctx).
+		Where("team_id = ? AND changed_at >= ? AND changed_at < ?", teamID, from, to).
+		Order("changed_at, id").
+		Find(&changes).Error
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	out := csv.NewWriter(io.MultiWriter(w, mac))
+	if err := out.Write([]string{"changed_at", "team_id", "user_id", "username", "change", "changed_by", "changed_by_username"}); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		record := []string{
+			change.ChangedAt.UTC().Format(time.RFC3339Nano),
+			change.TeamID.String(),
+			change.UserID.String(),
+			s.username(ctx, change.UserID),
+			change.Change,
+			change.ChangedBy.String(),
+			s.username(ctx, change.ChangedBy),
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, signaturePrefix+hex.EncodeToString(mac.Sum(nil))+"\n")
+	return err
+}
+
+func (s *AuditExportService) username(ctx context.Context, userID uuid.UUID) string {
+	if userID == uuid.Nil {
+		return ""
+	}
+	user, err := s.users.GetUser(ctx, userID.String())
+	if err != nil || user == nil {
+		return ""
+	}
+	return user.Username
+}
+
+// Verify reports whether export ends with a signature line matching its contents.
+func (s *AuditExportService) Verify(export []byte) (bool, error) {
+	if len(s.key) == 0 {
+		return false, ErrExportSigningDisabled
+	}
+
+	body := bytes.TrimSuffix(export, []byte("\n"))
+	cut := bytes.LastIndexByte(body, '\n') + 1
+	line := string(body[cut:])
+	if len(line) <= len(signaturePrefix) || line[:len(signaturePrefix)] != signaturePrefix {
+		return false, nil
+	}
+	signature, err := hex.DecodeString(line[len(signaturePrefix):])
+	if err != nil {
+		return false, nil
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(body[:cut])
+	return hmac.Equal(signature, mac.Sum(nil)), nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"seta/internal/pkg/userclient"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signExport appends the signature line WriteCSV would to body.
+func signExport(key, body string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(body))
+	return body + signaturePrefix + hex.EncodeToString(mac.Sum(nil)) + "\n"
+}
+
+func TestVerifyAuditExport(t *testing.T) {
+	const key = "export-key"
+	body := "changed_at,team_id,user_id,username,change,changed_by,changed_by_username\n" +
+		"2020-01-02T00:00:00Z,f1f1f1f1-f1f1-f1f1-f1f1-f1f1f1f1f1f1,c3c3c3c3-c3c3-c3c3-c3c3-c3c3c3c3c3c3,carol,added,a1a1a1a1-a1a1-a1a1-a1a1-a1a1a1a1a1a1,alice\n"
+	signed := signExport(key, body)
+
+	tests := []struct {
+		name   string
+		export string
+		want   bool
+	}{
+		{"intact", signed, true},
+		{"intact without the final newline", strings.TrimSuffix(signed, "\n"), true},
+		{"changed row", strings.Replace(signed, ",added,", ",removed,", 1), false},
+		{"changed username", strings.Replace(signed, ",carol,", ",mallory,", 1), false},
+		{"header removed", signed[strings.Index(signed, "\n")+1:], false},
+		{"row appended after the signature", signed + "2020-01-03T00:00:00Z,x,y,z,added,w,v\n", false},
+		{"signature line missing", body, false},
+		{"signature not hex", body + signaturePrefix + "zz\n", false},
+		{"signature of other contents", body + strings.TrimPrefix(signExport(key, body+"x\n"), body+"x\n"), false},
+		{"signed with another key", signExport("other-key", body), false},
+		{"empty", "", false},
+	}
+	s := &AuditExportService{key: []byte(key)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Verify([]byte(tt.export))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&AuditExportService{}).Verify([]byte(signed)); !errors.Is(err, ErrExportSigningDisabled) {
+		t.Errorf("Verify without a key: err = %v, want ErrExportSigningDisabled", err)
+	}
+}
+
+func TestAuditExportReserve(t *testing.T) {
+	t.Setenv("AUDIT_EXPORT_SIGNING_KEY", "export-key")
+	s := NewAuditExportService(nil)
+
+	if err := s.Reserve(seedAliceID); err != nil {
+		t.Fatalf("first export: %v", err)
+	}
+	if err := s.Reserve(seedAliceID); !errors.Is(err, ErrExportRateLimited) {
+		t.Errorf("second export: err = %v, want ErrExportRateLimited", err)
+	}
+	if err := s.Reserve(seedBobID); err != nil {
+		t.Errorf("another user's export: %v", err)
+	}
+
+	t.Setenv("AUDIT_EXPORT_SIGNING_KEY", "")
+	if err := NewAuditExportService(nil).Reserve(seedAliceID); !errors.Is(err, ErrExportSigningDisabled) {
+		t.Errorf("export without a key: err = %v, want ErrExportSigningDisabled", err)
+	}
+}
+
+// newUserServiceStub answers user lookups with usernames from names.
+func newUserServiceStub(t *testing.T, names map[string]string) *userclient.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables struct {
+				UserID string `json:"userId"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var user any
+		if name, ok := names[request.Variables.UserID]; ok {
+			user = userclient.User{UserID: request.Variables.UserID, Username: name}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"user": user}})
+	}))
+	t.Cleanup(srv.Close)
+	return userclient.New(userclient.Config{URL: srv.URL, Timeout: time.Second, MaxRetries: 1})
+}
+
+// An export signs exactly what it wrote: it verifies as written and fails once edited.
+func TestAuditExportWriteCSV(t *testing.T) {
+	db := testdb.Open(t)
+	t.Setenv("AUDIT_EXPORT_SIGNING_KEY", "export-key")
+	s := NewAuditExportService(db)
+	s.users = newUserServiceStub(t, map[string]string{
+		seedAliceID.String(): "alice",
+		seedCarolID.String(): "carol",
+	})
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	changes := []models.TeamMembershipChange{
+		{TeamID: seedEngID, UserID: seedCarolID, Change: "added", ChangedBy: seedAliceID, ChangedAt: from.Add(time.Hour)},
+		{TeamID: seedEngID, UserID: seedDaveID, Change: "removed", ChangedBy: seedAliceID, ChangedAt: from.Add(2 * time.Hour)},
+		// Outside the range.
+		{TeamID: seedEngID, UserID: seedCarolID, Change: "removed", ChangedBy: seedAliceID, ChangedAt: from.AddDate(0, 0, 2)},
+	}
+	if err := db.Create(&changes).Error; err != nil {
+		t.Fatalf("create membership changes: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := s.WriteCSV(context.Background(), &out, seedEngID, from, from.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want a header, 2 rows and the signature:\n%s", len(lines), out.String())
+	}
+	want := []string{
+		"2020-01-01T01:00:00Z," + seedEngID.String() + "," + seedCarolID.String() + ",carol,added," + seedAliceID.String() + ",alice",
+		// A user the user service does not know is exported without a username.
+		"2020-01-01T02:00:00Z," + seedEngID.String() + "," + seedDaveID.String() + ",,removed," + seedAliceID.String() + ",alice",
+	}
+	for i, line := range want {
+		if lines[i+1] != line {
+			t.Errorf("row %d = %q, want %q", i+1, lines[i+1], line)
+		}
+	}
+	if !strings.HasPrefix(lines[3], signaturePrefix) {
+		t.Errorf("last line = %q, want the signature", lines[3])
+	}
+
+	if valid, err := s.Verify(out.Bytes()); err != nil || !valid {
+		t.Errorf("Verify of the export = %v, %v, want true", valid, err)
+	}
+	tampered := bytes.Replace(out.Bytes(), []byte(",removed,"), []byte(",added,"), 1)
+	if valid, err := s.Verify(tampered); err != nil || valid {
+		t.Errorf("Verify of the tampered export = %v, %v, want false", valid, err)
+	}
+}
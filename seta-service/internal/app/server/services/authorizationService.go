@@ -1,108 +1,396 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"seta-pkg/database"
+	"seta-pkg/logging"
 	"seta/internal/pkg/errorHandling"
 	"seta/internal/pkg/models"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// accessPurgeChannel is the Postgres notification channel that carries purges between instances.
+const accessPurgeChannel = "authz_purge"
+
+// accessPurgeRetry is the wait before the purge listener reconnects.
+const accessPurgeRetry = 5 * time.Second
+
+// AuthorizationService answers who may read, write or own an asset. Built with
+// NewCachedAuthorizationService it keeps its note and folder decisions in an accessCache,
+// and whoever changes shares, folder parents or team membership must call Purge once the
+// change is committed.
 type AuthorizationService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	log   logging.Logger
+	cache *accessCache
 }
 
+// NewAuthorizationService returns a service that reads the database on every check.
 func NewAuthorizationService(db *gorm.DB) *AuthorizationService {
 	return &AuthorizationService{db: db}
 }
 
-// IsAssetOwner is now updated to return *errorHandling.CustomError consistently.
-func (s *AuthorizationService) IsAssetOwner(userID uuid.UUID, assetType string, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
-	var ownerID uuid.UUID
-	var err error
+// NewCachedAuthorizationService returns a service that caches its note and folder
+// decisions once ListenForPurges is running. One is built at startup and handed to every
+// middleware and controller, so that their purges reach the same cache.
+func NewCachedAuthorizationService(db *gorm.DB, log logging.Logger) *AuthorizationService {
+	return &AuthorizationService{db: db, log: log, cache: newAccessCache()}
+}
 
+// Purge forgets every cached decision, here and on every other instance. It is a no-op
+// on an uncached service.
+func (s *AuthorizationService) Purge() {
+	if s.cache == nil {
+		return
+	}
+	s.cache.purge()
+	if err := s.db.Exec("SELECT pg_notify(?, '')", accessPurgeChannel).Error; err != nil {
+		s.log.Error("Failed to notify other instances of an access cache purge", logging.Err(err))
+	}
+}
+
+// ListenForPurges purges the cache whenever any instance calls Purge, until ctx is done.
+// The cache is only used while the listener is connected, so a purge is never missed.
+func (s *AuthorizationService) ListenForPurges(ctx context.Context) {
+	if s.cache == nil {
+		return
+	}
+	database.Subscribe(ctx, s.log, accessPurgeChannel, accessPurgeRetry, s.cache.setListening, func(string) {
+		s.cache.purge()
+	})
+}
+
+// cached returns the decision of check on the asset from the cache, or makes it with
+// decide and caches it. Errors are not cached.
+func (s *AuthorizationService) cached(check string, userID, assetID uuid.UUID, decide func() (bool, *errorHandling.CustomError)) (bool, *errorHandling.CustomError) {
+	if s.cache == nil {
+		return decide()
+	}
+	key := accessKey{check: check, userID: userID, assetID: assetID}
+	allowed, ok, generation := s.cache.get(key)
+	if ok {
+		return allowed, nil
+	}
+	allowed, customErr := decide()
+	if customErr == nil {
+		s.cache.put(key, allowed, generation)
+	}
+	return allowed, customErr
+}
+
+func invalidAssetType(assetType string) *errorHandling.CustomError {
+	return &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: fmt.Sprintf("invalid asset type: %s", assetType)}
+}
+
+// IsAssetOwner reports whether the user owns the asset.
+func (s *AuthorizationService) IsAssetOwner(userID uuid.UUID, assetType string, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
+	var owner *gorm.DB
 	switch assetType {
 	case "folder":
-		err = s.db.Model(&models.Folder{}).Where("folder_id = ?", assetID).Pluck("owner_id", &ownerID).Error
+		owner = s.db.Model(&models.Folder{}).Where("folder_id = ?", assetID)
 	case "note":
-		err = s.db.Model(&models.Note{}).Where("note_id = ?", assetID).Pluck("owner_id", &ownerID).Error
+		owner = s.db.Model(&models.Note{}).Where("note_id = ?", assetID)
 	default:
-		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: fmt.Sprintf("invalid asset type: %s", assetType)}
+		return false, invalidAssetType(assetType)
 	}
 
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, &errorHandling.CustomError{Code: http.StatusNotFound, Message: fmt.Sprintf("%s not found", assetType)}
+	return s.cached("own:"+assetType, userID, assetID, func() (bool, *errorHandling.CustomError) {
+		var ownerID uuid.UUID
+		if err := owner.Pluck("owner_id", &ownerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return false, &errorHandling.CustomError{Code: http.StatusNotFound, Message: fmt.Sprintf("%s not found", assetType)}
+			}
+			return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error while checking ownership"}
 		}
-		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error while checking ownership"}
-	}
-
-	return userID == ownerID, nil
+		return userID == ownerID, nil
+	})
 }
 
-// CanAccessAsset is updated to correctly handle the custom error from IsAssetOwner.
+// CanAccessAsset reports whether the user can read the asset.
 func (s *AuthorizationService) CanAccessAsset(userID uuid.UUID, assetType string, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
-	isOwner, err := s.IsAssetOwner(userID, assetType, assetID)
-	if err != nil || isOwner {
-		return isOwner, err
-	}
+	return s.checkAsset(userID, assetType, assetID, false)
+}
+
+// CanWriteAsset reports whether the user can change the asset.
+func (s *AuthorizationService) CanWriteAsset(userID uuid.UUID, assetType string, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
+	return s.checkAsset(userID, assetType, assetID, true)
+}
 
+func (s *AuthorizationService) checkAsset(userID uuid.UUID, assetType string, assetID uuid.UUID, write bool) (bool, *errorHandling.CustomError) {
+	check := "read:"
+	if write {
+		check = "write:"
+	}
 	switch assetType {
 	case "folder":
-		var count int64
-		if dbErr := s.db.Model(&models.FolderShare{}).Where("folder_id = ? AND user_id = ?", assetID, userID).Count(&count).Error; dbErr != nil {
-			return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking folder share"}
+		return s.cached(check+assetType, userID, assetID, func() (bool, *errorHandling.CustomError) {
+			return s.folderAllows(userID, assetID, write)
+		})
+	case "note":
+		return s.cached(check+assetType, userID, assetID, func() (bool, *errorHandling.CustomError) {
+			return s.noteAllows(userID, assetID, write)
+		})
+	}
+	return false, invalidAssetType(assetType)
+}
+
+// folderAccessSQL walks up from a folder like folderAncestorsSQL and reports, for each
+// folder of the chain, its owner, the user's share on it and whether the user is on its
+// team, so that a folder check is a single query.
+const folderAccessSQL = `
+WITH RECURSIVE chain AS (
+	SELECT folder_id, parent_folder_id, owner_id, team_id, 0 AS depth
+	FROM folders WHERE folder_id = @folder
+	UNION ALL
+	SELECT f.folder_id, f.parent_folder_id, f.owner_id, f.team_id, c.depth + 1
+	FROM folders f JOIN chain c ON f.folder_id = c.parent_folder_id
+	WHERE c.depth < @maxDepth
+)
+SELECT c.owner_id,
+	(SELECT fs.access FROM folder_shares fs WHERE fs.folder_id = c.folder_id AND fs.user_id = @user) AS share_access,
+	EXISTS (SELECT 1 FROM team_managers tm WHERE tm.team_id = c.team_id AND tm.user_id = @user) AS team_manager,
+	EXISTS (SELECT 1 FROM team_members tm WHERE tm.team_id = c.team_id AND tm.user_id = @user) AS team_member
+FROM chain c ORDER BY c.depth`
+
+// folderAllows applies the folder rules. Access to a folder carries down to its
+// subfolders, so the user can read it when they own, were shared or are on the team of
+// the folder or one of its ancestors; writing takes ownership, a write share or being
+// a manager of the team.
+func (s *AuthorizationService) folderAllows(userID, folderID uuid.UUID, write bool) (bool, *errorHandling.CustomError) {
+	var chain []struct {
+		OwnerID     uuid.UUID
+		ShareAccess *string
+		TeamManager bool
+		TeamMember  bool
+	}
+	if err := s.db.Raw(folderAccessSQL, map[string]any{"folder": folderID, "user": userID, "maxDepth": MaxFolderDepth}).Scan(&chain).Error; err != nil {
+		message := "Database error checking folder share"
+		if write {
+			message = "Database error checking folder write access"
 		}
-		return count > 0, nil
+		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: message}
+	}
 
-	case "note":
-		var count int64
-		if dbErr := s.db.Model(&models.NoteShare{}).Where("note_id = ? AND user_id = ?", assetID, userID).Count(&count).Error; dbErr != nil {
-			return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking note share"}
+	for _, folder := range chain {
+		if folder.OwnerID == userID || folder.TeamManager {
+			return true, nil
 		}
-		if count > 0 {
+		if write {
+			if folder.ShareAccess != nil && *folder.ShareAccess == models.AccessWrite {
+				return true, nil
+			}
+		} else if folder.ShareAccess != nil || folder.TeamMember {
 			return true, nil
 		}
-
-		var note models.Note
-		s.db.Select("folder_id").First(&note, "note_id = ?", assetID)
-		return s.CanAccessAsset(userID, "folder", note.FolderID)
 	}
-
 	return false, nil
 }
 
-// CanWriteAsset is also updated to correctly handle the custom error.
-func (s *AuthorizationService) CanWriteAsset(userID uuid.UUID, assetType string, assetID uuid.UUID) (bool, *errorHandling.CustomError) {
-	isOwner, err := s.IsAssetOwner(userID, assetType, assetID)
-	if err != nil || isOwner {
-		return isOwner, err
-	}
+// noteAccessSQL loads what a note check needs besides the folder: the owner, the user's
+// share on the note and whether it is an active announcement of one of their teams.
+const noteAccessSQL = `
+SELECT n.owner_id, n.folder_id,
+	(SELECT ns.access FROM note_shares ns WHERE ns.note_id = n.note_id AND ns.user_id = @user) AS share_access,
+	n.is_announcement AND n.active AND (
+		EXISTS (SELECT 1 FROM team_members tm WHERE tm.team_id = n.team_id AND tm.user_id = @user)
+		OR EXISTS (SELECT 1 FROM team_managers tm WHERE tm.team_id = n.team_id AND tm.user_id = @user)
+	) AS announced
+FROM notes n WHERE n.note_id = @note`
 
-	switch assetType {
-	case "folder":
-		var count int64
-		if dbErr := s.db.Model(&models.FolderShare{}).Where("folder_id = ? AND user_id = ? AND access = 'write'", assetID, userID).Count(&count).Error; dbErr != nil {
-			return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking folder write access"}
+// noteAllows applies the note rules: the owner and users the note is shared with can
+// read it, and so can everyone who can read its folder or, for an active announcement,
+// is on its team. Writing takes ownership, a write share or write access to the folder.
+func (s *AuthorizationService) noteAllows(userID, noteID uuid.UUID, write bool) (bool, *errorHandling.CustomError) {
+	var notes []struct {
+		OwnerID     uuid.UUID
+		FolderID    uuid.UUID
+		ShareAccess *string
+		Announced   bool
+	}
+	if err := s.db.Raw(noteAccessSQL, map[string]any{"note": noteID, "user": userID}).Scan(&notes).Error; err != nil {
+		message := "Database error checking note share"
+		if write {
+			message = "Database error checking note write access"
 		}
-		return count > 0, nil
+		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: message}
+	}
+	if len(notes) == 0 {
+		return false, nil
+	}
 
-	case "note":
-		var count int64
-		if dbErr := s.db.Model(&models.NoteShare{}).Where("note_id = ? AND user_id = ? AND access = 'write'", assetID, userID).Count(&count).Error; dbErr != nil {
-			return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking note write access"}
-		}
-		if count > 0 {
+	note := notes[0]
+	if note.OwnerID == userID {
+		return true, nil
+	}
+	if write {
+		if note.ShareAccess != nil && *note.ShareAccess == models.AccessWrite {
 			return true, nil
 		}
+	} else if note.ShareAccess != nil || note.Announced {
+		return true, nil
+	}
+	return s.folderAllows(userID, note.FolderID, write)
+}
 
-		var note models.Note
-		s.db.Select("folder_id").First(&note, "note_id = ?", assetID)
-		return s.CanWriteAsset(userID, "folder", note.FolderID)
+// CanShareNote requires the user to own the note and, when the note sits in someone
+// else's folder, that folder's owner to have turned on AllowNoteSharing. Otherwise a
+// note owner could hand out access to content inside a folder its owner never shared.
+func (s *AuthorizationService) CanShareNote(userID, noteID uuid.UUID) (bool, *errorHandling.CustomError) {
+	var note models.Note
+	if err := s.db.Select("owner_id", "folder_id").First(&note, "note_id = ?", noteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, &errorHandling.CustomError{Code: http.StatusNotFound, Message: "note not found"}
+		}
+		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error while checking ownership"}
+	}
+	if note.OwnerID != userID {
+		return false, nil
 	}
 
-	return false, nil
-}
\ No newline at end of file
+	var folder models.Folder
+	if err := s.db.Select("owner_id", "allow_note_sharing").First(&folder, "folder_id = ?", note.FolderID).Error; err != nil {
+		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking containing folder"}
+	}
+	return folder.OwnerID == userID || folder.AllowNoteSharing, nil
+}
+
+// CanReadTemplate allows the template owner and, for team templates, every manager and member of the team.
+func (s *AuthorizationService) CanReadTemplate(userID, templateID uuid.UUID) (bool, *errorHandling.CustomError) {
+	template, customErr := s.loadTemplate(templateID)
+	if customErr != nil {
+		return false, customErr
+	}
+	if template.OwnerID == userID {
+		return true, nil
+	}
+	if template.TeamID == nil {
+		return false, nil
+	}
+
+	isManager, customErr := s.IsTeamManager(userID, *template.TeamID)
+	if customErr != nil || isManager {
+		return isManager, customErr
+	}
+	return s.IsTeamMember(userID, *template.TeamID)
+}
+
+// CanManageTemplate allows the template owner and, for team templates, the team's managers.
+func (s *AuthorizationService) CanManageTemplate(userID, templateID uuid.UUID) (bool, *errorHandling.CustomError) {
+	template, customErr := s.loadTemplate(templateID)
+	if customErr != nil {
+		return false, customErr
+	}
+	if template.OwnerID == userID {
+		return true, nil
+	}
+	if template.TeamID == nil {
+		return false, nil
+	}
+	return s.IsTeamManager(userID, *template.TeamID)
+}
+
+// IsTeamManager reports whether the user is a manager (lead or not) of the team.
+func (s *AuthorizationService) IsTeamManager(userID, teamID uuid.UUID) (bool, *errorHandling.CustomError) {
+	var count int64
+	if err := s.db.Model(&models.TeamManager{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&count).Error; err != nil {
+		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking team manager"}
+	}
+	return count > 0, nil
+}
+
+// IsTeamMember reports whether the user is a (non-manager) member of the team.
+func (s *AuthorizationService) IsTeamMember(userID, teamID uuid.UUID) (bool, *errorHandling.CustomError) {
+	var count int64
+	if err := s.db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&count).Error; err != nil {
+		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking team member"}
+	}
+	return count > 0, nil
+}
+
+// IsOnTeam reports whether the user is a member or a manager of the team. It reads the
+// membership tables on every call, so removing a member cuts their access to team
+// routes immediately; their cached note and folder decisions go with the Purge that
+// follows the change.
+func (s *AuthorizationService) IsOnTeam(userID, teamID uuid.UUID) (bool, *errorHandling.CustomError) {
+	var count int64
+	err := s.db.Raw("SELECT COUNT(*) FROM (SELECT 1 FROM team_members WHERE team_id = ? AND user_id = ? UNION ALL SELECT 1 FROM team_managers WHERE team_id = ? AND user_id = ?) t",
+		teamID, userID, teamID, userID).Scan(&count).Error
+	if err != nil {
+		return false, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error checking team membership"}
+	}
+	return count > 0, nil
+}
+
+// visibleNotesSQL applies the note read rules of CanAccessAsset to every note of the given
+// folders at once: the note is the user's or shared with them, the folder or one of its
+// ancestors is the user's, shared with them or a team folder of one of their teams, or
+// the note is an active announcement of one of their teams.
+const visibleNotesSQL = `
+WITH RECURSIVE my_teams AS (
+	SELECT team_id FROM team_members WHERE user_id = @user
+	UNION
+	SELECT team_id FROM team_managers WHERE user_id = @user
+),
+chain AS (
+	SELECT folder_id AS target, folder_id, parent_folder_id, 0 AS depth
+	FROM folders WHERE folder_id IN @folders
+	UNION ALL
+	SELECT c.target, f.folder_id, f.parent_folder_id, c.depth + 1
+	FROM folders f JOIN chain c ON f.folder_id = c.parent_folder_id
+	WHERE c.depth < @maxDepth
+),
+readable_folders AS (
+	SELECT DISTINCT c.target AS folder_id
+	FROM chain c
+	JOIN folders f ON f.folder_id = c.folder_id
+	WHERE f.owner_id = @user
+	   OR EXISTS (SELECT 1 FROM folder_shares fs WHERE fs.folder_id = f.folder_id AND fs.user_id = @user)
+	   OR f.team_id IN (SELECT team_id FROM my_teams)
+)
+SELECT n.folder_id, COUNT(*) AS visible
+FROM notes n
+WHERE n.folder_id IN @folders
+  AND n.deleted_at IS NULL
+  AND (n.owner_id = @user
+    OR n.folder_id IN (SELECT folder_id FROM readable_folders)
+    OR EXISTS (SELECT 1 FROM note_shares ns WHERE ns.note_id = n.note_id AND ns.user_id = @user)
+    OR (n.is_announcement AND n.active AND n.team_id IN (SELECT team_id FROM my_teams)))
+GROUP BY n.folder_id`
+
+// VisibleNoteCounts returns, for each folder, how many of its notes the user can read.
+// Folders without any are left out of the map.
+func (s *AuthorizationService) VisibleNoteCounts(userID uuid.UUID, folderIDs []uuid.UUID) (map[uuid.UUID]int64, *errorHandling.CustomError) {
+	counts := make(map[uuid.UUID]int64, len(folderIDs))
+	if len(folderIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		FolderID uuid.UUID
+		Visible  int64
+	}
+	if err := s.db.Raw(visibleNotesSQL, map[string]any{"user": userID, "folders": folderIDs, "maxDepth": MaxFolderDepth}).Scan(&rows).Error; err != nil {
+		return nil, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error counting visible notes"}
+	}
+	for _, row := range rows {
+		counts[row.FolderID] = row.Visible
+	}
+	return counts, nil
+}
+
+func (s *AuthorizationService) loadTemplate(templateID uuid.UUID) (*models.NoteTemplate, *errorHandling.CustomError) {
+	var template models.NoteTemplate
+	if err := s.db.Select("template_id", "owner_id", "team_id").First(&template, "template_id = ?", templateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &errorHandling.CustomError{Code: http.StatusNotFound, Message: "template not found"}
+		}
+		return nil, &errorHandling.CustomError{Code: http.StatusInternalServerError, Message: "Database error while loading template"}
+	}
+	return &template, nil
+}
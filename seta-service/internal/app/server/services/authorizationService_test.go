@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"seta-pkg/logging"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Seed rows of init_db.sql: Bob has a read share on Alice's folder.
+var (
+	seedFolderID = uuid.MustParse("b3b3b3b3-b3b3-b3b3-b3b3-b3b3b3b3b3b3")
+	seedBobID    = uuid.MustParse("b2b2b2b2-b2b2-b2b2-b2b2-b2b2b2b2b2b2")
+)
+
+func TestAccessCacheDropsDecisionsMadeBeforeAPurge(t *testing.T) {
+	cache := newAccessCache()
+	key := accessKey{check: "read:folder", userID: uuid.New(), assetID: uuid.New()}
+
+	_, _, generation := cache.get(key)
+	cache.put(key, true, generation)
+	if _, ok, _ := cache.get(key); ok {
+		t.Fatal("cached a decision while not listening for purges")
+	}
+
+	cache.setListening(true)
+	_, _, generation = cache.get(key)
+	cache.purge()
+	cache.put(key, true, generation)
+	if _, ok, _ := cache.get(key); ok {
+		t.Fatal("cached a decision made before a purge")
+	}
+
+	_, _, generation = cache.get(key)
+	cache.put(key, true, generation)
+	if allowed, ok, _ := cache.get(key); !ok || !allowed {
+		t.Fatalf("get = %v, %v after put, want true, true", allowed, ok)
+	}
+
+	cache.setListening(false)
+	if _, ok, _ := cache.get(key); ok {
+		t.Fatal("kept decisions after the purge listener stopped")
+	}
+}
+
+// countQueries counts the queries run on db from now on.
+func countQueries(t testing.TB, db *gorm.DB) *atomic.Int64 {
+	t.Helper()
+	var queries atomic.Int64
+	count := func(*gorm.DB) { queries.Add(1) }
+	if err := db.Callback().Query().After("gorm:query").Register("test:count_queries", count); err != nil {
+		t.Fatalf("register query counter: %v", err)
+	}
+	if err := db.Callback().Row().After("gorm:row").Register("test:count_rows", count); err != nil {
+		t.Fatalf("register row counter: %v", err)
+	}
+	return &queries
+}
+
+func canReadSeedFolder(t testing.TB, s *AuthorizationService) {
+	t.Helper()
+	allowed, customErr := s.CanAccessAsset(seedBobID, "folder", seedFolderID)
+	if customErr != nil {
+		t.Fatalf("CanAccessAsset: %v", customErr.Message)
+	}
+	if !allowed {
+		t.Fatal("CanAccessAsset denied a read share")
+	}
+}
+
+// BenchmarkFolderCheckQueries reports the queries one folder check costs with and
+// without the cache.
+func BenchmarkFolderCheckQueries(b *testing.B) {
+	db := testdb.Open(b)
+	queries := countQueries(b, db)
+	services := map[string]*AuthorizationService{
+		"uncached": NewAuthorizationService(db),
+		"cached":   NewCachedAuthorizationService(db, logging.Nop()),
+	}
+	services["cached"].cache.setListening(true)
+
+	for name, s := range services {
+		b.Run(name, func(b *testing.B) {
+			queries.Store(0)
+			for i := 0; i < b.N; i++ {
+				canReadSeedFolder(b, s)
+			}
+			b.ReportMetric(float64(queries.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func TestCachedFolderCheckRunsOneQuery(t *testing.T) {
+	db := testdb.Open(t)
+	queries := countQueries(t, db)
+	s := NewCachedAuthorizationService(db, logging.Nop())
+	s.cache.setListening(true)
+
+	for i := 0; i < 10; i++ {
+		canReadSeedFolder(t, s)
+	}
+	if got := queries.Load(); got != 1 {
+		t.Errorf("10 checks ran %d queries, want 1", got)
+	}
+
+	s.Purge()
+	queries.Store(0)
+	canReadSeedFolder(t, s)
+	if got := queries.Load(); got != 1 {
+		t.Errorf("a check after a purge ran %d queries, want 1", got)
+	}
+}
+
+// A purge on one instance must empty the cache of another, or a revoked share would be
+// honoured there until the entry expires.
+func TestPurgeReachesOtherInstances(t *testing.T) {
+	db := testdb.Open(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	purging := NewCachedAuthorizationService(db, logging.Nop())
+	listening := NewCachedAuthorizationService(db, logging.Nop())
+	go purging.ListenForPurges(ctx)
+	go listening.ListenForPurges(ctx)
+	waitFor(t, "the purge listeners to connect", func() bool {
+		return purging.cache.isListening() && listening.cache.isListening()
+	})
+
+	// Purges from tests of other packages sharing the database bump the generation too,
+	// so this waits for a purge rather than for this one in particular.
+	canReadSeedFolder(t, listening)
+	_, _, before := listening.cache.get(accessKey{})
+	purging.Purge()
+	waitFor(t, "the purge to reach the other instance", func() bool {
+		_, _, generation := listening.cache.get(accessKey{})
+		return generation != before && listening.cache.len() == 0
+	})
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (c *accessCache) isListening() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.listening
+}
+
+func (c *accessCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func createFolder(t testing.TB, db *gorm.DB, folder models.Folder) models.Folder {
+	t.Helper()
+	if folder.Name == "" {
+		folder.Name = "Test"
+	}
+	folder.LastModifiedBy = folder.OwnerID
+	if err := db.Omit("Owner").Create(&folder).Error; err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+	return folder
+}
+
+func createNote(t testing.TB, db *gorm.DB, note models.Note) models.Note {
+	t.Helper()
+	if note.Title == "" {
+		note.Title = "Test"
+	}
+	note.LastModifiedBy = note.OwnerID
+	if err := db.Omit("Folder", "Owner").Create(&note).Error; err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	return note
+}
+
+func TestCanShareNote(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewAuthorizationService(db)
+	sharer, other := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name             string
+		noteOwner        uuid.UUID
+		folderOwner      uuid.UUID
+		allowNoteSharing bool
+		want             bool
+	}{
+		{"own note in own folder", sharer, sharer, false, true},
+		{"own note in someone else's folder", sharer, other, false, false},
+		{"own note in someone else's folder allowing note sharing", sharer, other, true, true},
+		{"someone else's note in own folder", other, sharer, false, false},
+		{"someone else's note in their folder", other, other, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder := createFolder(t, db, models.Folder{OwnerID: tt.folderOwner, AllowNoteSharing: tt.allowNoteSharing})
+			note := createNote(t, db, models.Note{FolderID: folder.FolderID, OwnerID: tt.noteOwner})
+			allowed, customErr := s.CanShareNote(sharer, note.NoteID)
+			if customErr != nil {
+				t.Fatalf("CanShareNote: %v", customErr.Message)
+			}
+			if allowed != tt.want {
+				t.Errorf("CanShareNote = %v, want %v", allowed, tt.want)
+			}
+		})
+	}
+
+	if _, customErr := s.CanShareNote(sharer, uuid.New()); customErr == nil || customErr.Code != http.StatusNotFound {
+		t.Errorf("CanShareNote on a missing note = %v, want a 404", customErr)
+	}
+}
+
+// Seed rows of init_db.sql: Alice leads Engineering, whose members are Carol and Dave;
+// Bob leads Marketing, whose member is Eve.
+var (
+	seedAliceID = uuid.MustParse("a1a1a1a1-a1a1-a1a1-a1a1-a1a1a1a1a1a1")
+	seedCarolID = uuid.MustParse("c3c3c3c3-c3c3-c3c3-c3c3-c3c3c3c3c3c3")
+	seedDaveID  = uuid.MustParse("d4d4d4d4-d4d4-d4d4-d4d4-d4d4d4d4d4d4")
+	seedEveID   = uuid.MustParse("e5e5e5e5-e5e5-e5e5-e5e5-e5e5e5e5e5e5")
+	seedEngID   = uuid.MustParse("f1f1f1f1-f1f1-f1f1-f1f1-f1f1f1f1f1f1")
+)
+
+func TestTemplatePermissions(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewAuthorizationService(db)
+	personal := models.NoteTemplate{OwnerID: seedCarolID, Name: "Journal", TitleTemplate: "{{date}}"}
+	team := models.NoteTemplate{OwnerID: seedAliceID, TeamID: &seedEngID, Name: "Standup", TitleTemplate: "Standup {{date}}"}
+	for _, template := range []*models.NoteTemplate{&personal, &team} {
+		if err := db.Create(template).Error; err != nil {
+			t.Fatalf("create template: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		userID     uuid.UUID
+		template   models.NoteTemplate
+		readable   bool
+		manageable bool
+	}{
+		{"owner of a personal template", seedCarolID, personal, true, true},
+		{"teammate of the owner of a personal template", seedDaveID, personal, false, false},
+		{"manager of the owner of a personal template", seedAliceID, personal, false, false},
+		{"manager of the team", seedAliceID, team, true, true},
+		{"member of the team", seedCarolID, team, true, false},
+		{"manager of another team", seedBobID, team, false, false},
+		{"member of another team", seedEveID, team, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readable, customErr := s.CanReadTemplate(tt.userID, tt.template.TemplateID)
+			if customErr != nil {
+				t.Fatalf("CanReadTemplate: %v", customErr.Message)
+			}
+			manageable, customErr := s.CanManageTemplate(tt.userID, tt.template.TemplateID)
+			if customErr != nil {
+				t.Fatalf("CanManageTemplate: %v", customErr.Message)
+			}
+			if readable != tt.readable || manageable != tt.manageable {
+				t.Errorf("read %v, manage %v; want read %v, manage %v", readable, manageable, tt.readable, tt.manageable)
+			}
+		})
+	}
+
+	if _, customErr := s.CanReadTemplate(seedAliceID, uuid.New()); customErr == nil || customErr.Code != http.StatusNotFound {
+		t.Errorf("CanReadTemplate on a missing template = %v, want a 404", customErr)
+	}
+}
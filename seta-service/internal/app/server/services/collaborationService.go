@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const collaborationReportTTL = 15 * time.Minute
+
+// CollaborationPair counts the assets one team member currently shares with another.
+type CollaborationPair struct {
+	SharerID    uuid.UUID `json:"sharerId"`
+	RecipientID uuid.UUID `json:"recipientId"`
+	Folders     int       `json:"folders"`
+	Notes       int       `json:"notes"`
+}
+
+// CollaborationTotals sums a member's pairs in each direction.
+type CollaborationTotals struct {
+	UserID          uuid.UUID `json:"userId"`
+	OutgoingFolders int       `json:"outgoingFolders"`
+	OutgoingNotes   int       `json:"outgoingNotes"`
+	IncomingFolders int       `json:"incomingFolders"`
+	IncomingNotes   int       `json:"incomingNotes"`
+}
+
+// CollaborationReport is the share matrix of a team. Pairs holds at most the busiest
+// COLLABORATION_MAX_PAIRS pairs, with Truncated set when more exist; Members totals are
+// always computed over every pair.
+type CollaborationReport struct {
+	Pairs       []CollaborationPair   `json:"pairs"`
+	Members     []CollaborationTotals `json:"members"`
+	Truncated   bool                  `json:"truncated"`
+	GeneratedAt time.Time             `json:"generatedAt"`
+}
+
+type cachedCollaborationReport struct {
+	report    CollaborationReport
+	expiresAt time.Time
+}
+
+// CollaborationService builds team share matrices and caches them for fifteen minutes.
+type CollaborationService struct {
+	db       *gorm.DB
+	maxPairs int
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]cachedCollaborationReport
+}
+
+// NewCollaborationService reads COLLABORATION_MAX_PAIRS (default 500).
+func NewCollaborationService(db *gorm.DB) *CollaborationService {
+	maxPairs := 500
+	if v, _ := strconv.Atoi(os.Getenv("COLLABORATION_MAX_PAIRS")); v > 0 {
+		maxPairs = v
+	}
+	return &CollaborationService{db: db, maxPairs: maxPairs, cache: make(map[uuid.UUID]cachedCollaborationReport)}
+}
+
+// collaborationSQL counts, for every ordered pair of current team members, the folders and
+// notes the first owns and has shared with the second. Assets in folders being deleted are
+// left out.
+const collaborationSQL = `
+WITH team_users AS (` + teamOwnersSQL + `),
+shares AS (
+	SELECT f.owner_id AS sharer_id, fs.user_id AS recipient_id, 'folder' AS kind
+	FROM folder_shares fs
+	JOIN folders f ON f.folder_id = fs.folder_id
+	WHERE NOT f.deletion_pending
+	  AND f.owner_id IN (SELECT user_id FROM team_users)
+	  AND fs.user_id IN (SELECT user_id FROM team_users)
+	UNION ALL
+	SELECT n.owner_id, ns.user_id, 'note'
+	FROM note_shares ns
+	JOIN notes n ON n.note_id = ns.note_id
+	JOIN folders f ON f.folder_id = n.folder_id
+	WHERE NOT f.deletion_pending
+	  AND n.deleted_at IS NULL
+	  AND n.owner_id IN (SELECT user_id FROM team_users)
+	  AND ns.user_id IN (SELECT user_id FROM team_users)
+)
+SELECT sharer_id, recipient_id,
+	COUNT(*) FILTER (WHERE kind = 'folder') AS folders,
+	COUNT(*) FILTER (WHERE kind = 'note') AS notes
+FROM shares
+WHERE sharer_id <> recipient_id
+GROUP BY sharer_id, recipient_id
+ORDER BY COUNT(*) DESC, sharer_id, recipient_id`
+
+// Report returns the team's collaboration matrix, from cache when fresh enough.
+func (s *CollaborationService) Report(ctx context.Context, teamID uuid.UUID) (CollaborationReport, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[teamID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.report, nil
+	}
+
+	var pairs []CollaborationPair
+	if err := s.db.WithContext(ctx).Raw(collaborationSQL, map[string]any{"team": teamID}).Scan(&pairs).Error; err != nil {
+		return CollaborationReport{}, err
+	}
+
+	report := CollaborationReport{Members: collaborationTotals(pairs), GeneratedAt: time.Now()}
+	if len(pairs) > s.maxPairs {
+		pairs = pairs[:s.maxPairs]
+		report.Truncated = true
+	}
+	report.Pairs = append(make([]CollaborationPair, 0, len(pairs)), pairs...)
+
+	s.mu.Lock()
+	s.cache[teamID] = cachedCollaborationReport{report: report, expiresAt: report.GeneratedAt.Add(collaborationReportTTL)}
+	for k, entry := range s.cache {
+		if !report.GeneratedAt.Before(entry.expiresAt) {
+			delete(s.cache, k)
+		}
+	}
+	s.mu.Unlock()
+
+	return report, nil
+}
+
+func collaborationTotals(pairs []CollaborationPair) []CollaborationTotals {
+	byUser := make(map[uuid.UUID]*CollaborationTotals)
+	totalsOf := func(id uuid.UUID) *CollaborationTotals {
+		if t, ok := byUser[id]; ok {
+			return t
+		}
+		t := &CollaborationTotals{UserID: id}
+		byUser[id] = t
+		return t
+	}
+
+	for _, pair := range pairs {
+		sharer := totalsOf(pair.SharerID)
+		sharer.OutgoingFolders += pair.Folders
+		sharer.OutgoingNotes += pair.Notes
+		recipient := totalsOf(pair.RecipientID)
+		recipient.IncomingFolders += pair.Folders
+		recipient.IncomingNotes += pair.Notes
+	}
+
+	totals := make([]CollaborationTotals, 0, len(byUser))
+	for _, t := range byUser {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].UserID.String() < totals[j].UserID.String() })
+	return totals
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"os"
+	"seta/internal/pkg/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Principal is who a flag is evaluated for. TeamIDs is looked up on demand when nil.
+type Principal struct {
+	UserID  uuid.UUID
+	TeamIDs []uuid.UUID
+}
+
+// FlagRule is a flag with its allowlists, as served by the internal API.
+type FlagRule struct {
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	Enabled           bool        `json:"enabled"`
+	RolloutPercentage int         `json:"rolloutPercentage"`
+	AllowedUserIDs    []uuid.UUID `json:"allowedUserIds"`
+	AllowedTeamIDs    []uuid.UUID `json:"allowedTeamIds"`
+	UpdatedBy         string      `json:"updatedBy"`
+	UpdatedAt         time.Time   `json:"updatedAt"`
+}
+
+// FeatureFlagService evaluates rollout flags. Rules are read from the feature_flags
+// tables and kept in memory for FEATURE_FLAG_CACHE_SECONDS (default 30), so a rule
+// change made on another instance is picked up within that window.
+type FeatureFlagService struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu       sync.Mutex
+	rules    map[string]FlagRule
+	loadedAt time.Time
+}
+
+// NewFeatureFlagService creates a new FeatureFlagService.
+func NewFeatureFlagService(db *gorm.DB) *FeatureFlagService {
+	ttl := 30 * time.Second
+	if v, _ := strconv.Atoi(os.Getenv("FEATURE_FLAG_CACHE_SECONDS")); v > 0 {
+		ttl = time.Duration(v) * time.Second
+	}
+	return &FeatureFlagService{db: db, ttl: ttl}
+}
+
+// Evaluate reports whether flag is on for principal. Precedence: unknown or disabled
+// flags are off, then the user allowlist, then the team allowlist, then the percentage
+// rollout. The rollout bucket is a hash of flag and user, so a user keeps their answer
+// as the percentage grows and different flags roll out to different users.
+func (s *FeatureFlagService) Evaluate(ctx context.Context, flag string, principal Principal) (bool, error) {
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rule, ok := rules[flag]
+	if !ok || !rule.Enabled {
+		return false, nil
+	}
+
+	for _, id := range rule.AllowedUserIDs {
+		if id == principal.UserID {
+			return true, nil
+		}
+	}
+
+	if len(rule.AllowedTeamIDs) > 0 {
+		teamIDs := principal.TeamIDs
+		if teamIDs == nil {
+			if teamIDs, err = s.teamsOf(ctx, principal.UserID); err != nil {
+				return false, err
+			}
+		}
+		for _, allowed := range rule.AllowedTeamIDs {
+			for _, id := range teamIDs {
+				if id == allowed {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return rolloutBucket(flag, principal.UserID) < rule.RolloutPercentage, nil
+}
+
+// rolloutBucket maps a user to a stable bucket in [0, 100) for flag.
+func rolloutBucket(flag string, userID uuid.UUID) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag))
+	h.Write([]byte{':'})
+	h.Write(userID[:])
+	return int(h.Sum32() % 100)
+}
+
+func (s *FeatureFlagService) teamsOf(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var teamIDs []uuid.UUID
+	err := s.db.WithContext(ctx).
+		Raw("SELECT team_id FROM team_members WHERE user_id = ? UNION SELECT team_id FROM team_managers WHERE user_id = ?", userID, userID).
+		Scan(&teamIDs).Error
+	return teamIDs, err
+}
+
+// Rules returns every flag, freshly read from the database.
+func (s *FeatureFlagService) Rules(ctx context.Context) ([]FlagRule, error) {
+	s.invalidate()
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]FlagRule, 0, len(rules))
+	for _, rule := range rules {
+		list = append(list, rule)
+	}
+	return list, nil
+}
+
+// ErrInvalidRollout is returned when a rollout percentage is outside 0-100.
+var ErrInvalidRollout = errors.New("rolloutPercentage must be between 0 and 100")
+
+// SaveRule creates or replaces a flag and its allowlists, recording the change with the
+// previous and new rule for audit.
+func (s *FeatureFlagService) SaveRule(ctx context.Context, rule FlagRule, actor string) (FlagRule, error) {
+	if rule.RolloutPercentage < 0 || rule.RolloutPercentage > 100 {
+		return FlagRule{}, ErrInvalidRollout
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before *string
+		var existing models.FeatureFlag
+		err := tx.Preload("Subjects").First(&existing, "name = ?", rule.Name).Error
+		switch {
+		case err == nil:
+			previous, err := json.Marshal(toFlagRule(existing))
+			if err != nil {
+				return err
+			}
+			encoded := string(previous)
+			before = &encoded
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		flag := models.FeatureFlag{
+			Name:              rule.Name,
+			Description:       rule.Description,
+			Enabled:           rule.Enabled,
+			RolloutPercentage: rule.RolloutPercentage,
+			UpdatedBy:         actor,
+		}
+		if before != nil {
+			flag.CreatedAt = existing.CreatedAt
+		}
+		// Select("*") so false and zero values overwrite the previous rule.
+		if err := tx.Select("*").Omit("Subjects").Save(&flag).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("flag_name = ?", rule.Name).Delete(&models.FeatureFlagSubject{}).Error; err != nil {
+			return err
+		}
+		subjects := make([]models.FeatureFlagSubject, 0, len(rule.AllowedUserIDs)+len(rule.AllowedTeamIDs))
+		for _, id := range rule.AllowedUserIDs {
+			subjects = append(subjects, models.FeatureFlagSubject{FlagName: rule.Name, SubjectType: "user", SubjectID: id})
+		}
+		for _, id := range rule.AllowedTeamIDs {
+			subjects = append(subjects, models.FeatureFlagSubject{FlagName: rule.Name, SubjectType: "team", SubjectID: id})
+		}
+		if len(subjects) > 0 {
+			if err := tx.Create(&subjects).Error; err != nil {
+				return err
+			}
+		}
+
+		flag.Subjects = subjects
+		rule = toFlagRule(flag)
+		after, err := json.Marshal(rule)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.FeatureFlagChange{FlagName: rule.Name, Actor: actor, Before: before, After: string(after)}).Error
+	})
+	if err != nil {
+		return FlagRule{}, err
+	}
+
+	s.invalidate()
+	return rule, nil
+}
+
+func (s *FeatureFlagService) invalidate() {
+	s.mu.Lock()
+	s.rules = nil
+	s.mu.Unlock()
+}
+
+func (s *FeatureFlagService) loadRules(ctx context.Context) (map[string]FlagRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rules != nil && time.Since(s.loadedAt) < s.ttl {
+		return s.rules, nil
+	}
+
+	var flags []models.FeatureFlag
+	if err := s.db.WithContext(ctx).Preload("Subjects").Find(&flags).Error; err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]FlagRule, len(flags))
+	for _, flag := range flags {
+		rules[flag.Name] = toFlagRule(flag)
+	}
+	s.rules = rules
+	s.loadedAt = time.Now()
+	return rules, nil
+}
+
+func toFlagRule(flag models.FeatureFlag) FlagRule {
+	rule := FlagRule{
+		Name:              flag.Name,
+		Description:       flag.Description,
+		Enabled:           flag.Enabled,
+		RolloutPercentage: flag.RolloutPercentage,
+		AllowedUserIDs:    make([]uuid.UUID, 0),
+		AllowedTeamIDs:    make([]uuid.UUID, 0),
+		UpdatedBy:         flag.UpdatedBy,
+		UpdatedAt:         flag.UpdatedAt,
+	}
+	for _, subject := range flag.Subjects {
+		if subject.SubjectType == "team" {
+			rule.AllowedTeamIDs = append(rule.AllowedTeamIDs, subject.SubjectID)
+		} else {
+			rule.AllowedUserIDs = append(rule.AllowedUserIDs, subject.SubjectID)
+		}
+	}
+	return rule
+}
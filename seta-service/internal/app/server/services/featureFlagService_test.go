@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newCachedFlags returns a service that answers from rules until its cache expires.
+func newCachedFlags(rules ...FlagRule) *FeatureFlagService {
+	s := &FeatureFlagService{ttl: time.Hour, rules: make(map[string]FlagRule), loadedAt: time.Now()}
+	for _, rule := range rules {
+		s.rules[rule.Name] = rule
+	}
+	return s
+}
+
+// usersInBuckets returns a user inside and a user outside a percentage rollout of flag.
+func usersInBuckets(t *testing.T, flag string, percentage int) (in, out uuid.UUID) {
+	t.Helper()
+	for i := 0; i < 10000 && (in == uuid.Nil || out == uuid.Nil); i++ {
+		id := uuid.New()
+		if rolloutBucket(flag, id) < percentage {
+			in = id
+		} else {
+			out = id
+		}
+	}
+	if in == uuid.Nil || out == uuid.Nil {
+		t.Fatalf("found no users on both sides of %d%%", percentage)
+	}
+	return in, out
+}
+
+func TestEvaluateRulePrecedence(t *testing.T) {
+	const flag = "fast-read-path"
+	teamID := uuid.New()
+	inRollout, outOfRollout := usersInBuckets(t, flag, 50)
+	allowedUser := outOfRollout
+
+	tests := []struct {
+		name      string
+		rule      *FlagRule
+		principal Principal
+		want      bool
+	}{
+		{"unknown flag", nil, Principal{UserID: allowedUser}, false},
+		{"disabled flag with the user allowlisted", &FlagRule{AllowedUserIDs: []uuid.UUID{allowedUser}, RolloutPercentage: 100}, Principal{UserID: allowedUser}, false},
+		{"allowlisted user outside the rollout", &FlagRule{Enabled: true, AllowedUserIDs: []uuid.UUID{allowedUser}}, Principal{UserID: allowedUser, TeamIDs: []uuid.UUID{}}, true},
+		{"allowlisted team outside the rollout", &FlagRule{Enabled: true, AllowedTeamIDs: []uuid.UUID{teamID}}, Principal{UserID: outOfRollout, TeamIDs: []uuid.UUID{uuid.New(), teamID}}, true},
+		{"another team outside the rollout", &FlagRule{Enabled: true, AllowedTeamIDs: []uuid.UUID{teamID}, RolloutPercentage: 50}, Principal{UserID: outOfRollout, TeamIDs: []uuid.UUID{uuid.New()}}, false},
+		{"user in the rollout", &FlagRule{Enabled: true, RolloutPercentage: 50}, Principal{UserID: inRollout}, true},
+		{"user outside the rollout", &FlagRule{Enabled: true, RolloutPercentage: 50}, Principal{UserID: outOfRollout}, false},
+		{"full rollout", &FlagRule{Enabled: true, RolloutPercentage: 100}, Principal{UserID: outOfRollout}, true},
+		{"no rollout", &FlagRule{Enabled: true}, Principal{UserID: inRollout}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newCachedFlags()
+			if tt.rule != nil {
+				tt.rule.Name = flag
+				s = newCachedFlags(*tt.rule)
+			}
+			got, err := s.Evaluate(context.Background(), flag, tt.principal)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A user's bucket depends only on the flag and the user, so raising the percentage
+// only ever adds users, and flags do not all roll out to the same users.
+func TestRolloutBucketStability(t *testing.T) {
+	users := make([]uuid.UUID, 1000)
+	for i := range users {
+		users[i] = uuid.New()
+	}
+
+	enabled := make(map[uuid.UUID]bool)
+	for _, percentage := range []int{0, 10, 25, 50, 100} {
+		s := newCachedFlags(FlagRule{Name: "manager-read-all", Enabled: true, RolloutPercentage: percentage})
+		count := 0
+		for _, id := range users {
+			on, err := s.Evaluate(context.Background(), "manager-read-all", Principal{UserID: id})
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if again, _ := s.Evaluate(context.Background(), "manager-read-all", Principal{UserID: id}); again != on {
+				t.Fatalf("user %s got %v then %v at %d%%", id, on, again, percentage)
+			}
+			if enabled[id] && !on {
+				t.Fatalf("user %s lost the flag when the rollout grew to %d%%", id, percentage)
+			}
+			enabled[id] = on
+			if on {
+				count++
+			}
+		}
+		// Buckets are uniform enough that 1000 users land within a few dozen of the share.
+		if want := percentage * len(users) / 100; count < want-60 || count > want+60 {
+			t.Errorf("%d%% rollout enabled %d of %d users", percentage, count, len(users))
+		}
+	}
+
+	same := 0
+	for _, id := range users {
+		if (rolloutBucket("manager-read-all", id) < 50) == (rolloutBucket("hide-forbidden", id) < 50) {
+			same++
+		}
+	}
+	if same == len(users) {
+		t.Error("two flags at 50% rolled out to exactly the same users")
+	}
+}
+
+// Saving a rule is seen at once by the instance that saved it. A change made elsewhere
+// is seen once the cache expires.
+func TestFeatureFlagCacheRefresh(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewFeatureFlagService(db)
+	ctx := context.Background()
+	principal := Principal{UserID: seedAliceID, TeamIDs: []uuid.UUID{}}
+
+	evaluate := func(step string, want bool) {
+		t.Helper()
+		got, err := s.Evaluate(ctx, "swr-cache", principal)
+		if err != nil {
+			t.Fatalf("%s: Evaluate: %v", step, err)
+		}
+		if got != want {
+			t.Errorf("%s: Evaluate = %v, want %v", step, got, want)
+		}
+	}
+
+	evaluate("before the flag exists", false)
+	if _, err := s.SaveRule(ctx, FlagRule{Name: "swr-cache", Enabled: true, AllowedUserIDs: []uuid.UUID{seedAliceID}}, "ops"); err != nil {
+		t.Fatalf("SaveRule: %v", err)
+	}
+	evaluate("after the flag is saved", true)
+
+	// Another instance turns the flag off.
+	if err := db.Model(&models.FeatureFlag{}).Where("name = ?", "swr-cache").Update("enabled", false).Error; err != nil {
+		t.Fatalf("disable flag: %v", err)
+	}
+	evaluate("while the rules are cached", true)
+	s.mu.Lock()
+	s.loadedAt = time.Now().Add(-s.ttl)
+	s.mu.Unlock()
+	evaluate("once the cache expired", false)
+
+	if _, err := s.SaveRule(ctx, FlagRule{Name: "swr-cache", Enabled: true, RolloutPercentage: 100}, "ops"); err != nil {
+		t.Fatalf("SaveRule: %v", err)
+	}
+	evaluate("after the full rollout is saved", true)
+
+	var changes []models.FeatureFlagChange
+	if err := db.Where("flag_name = ?", "swr-cache").Order("changed_at").Find(&changes).Error; err != nil {
+		t.Fatalf("list changes: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Before != nil || changes[1].Before == nil || changes[1].Actor != "ops" {
+		t.Errorf("changes = %+v, want the creation then the edit by ops", changes)
+	}
+
+	if _, err := s.SaveRule(ctx, FlagRule{Name: "swr-cache", RolloutPercentage: 101}, "ops"); err != ErrInvalidRollout {
+		t.Errorf("SaveRule of 101%%: err = %v, want ErrInvalidRollout", err)
+	}
+}
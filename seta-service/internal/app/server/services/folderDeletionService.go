@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"seta-pkg/logging"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/models"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFolderDeletionRunning is returned by Start when a live job is already deleting the folder.
+var ErrFolderDeletionRunning = errors.New("folder deletion already in progress")
+
+// folderDeletionStaleAfter is how long a running job may go without finishing a batch
+// before it is taken to have died with its instance. Every batch moves updated_at.
+const folderDeletionStaleAfter = 2 * time.Minute
+
+// errFolderDeletionLost is the error recorded on a job whose instance stopped running it.
+const errFolderDeletionLost = "deletion interrupted: the instance running it stopped"
+
+// errFolderDeletionTakenOver stops a job that was marked failed while it was still running.
+var errFolderDeletionTakenOver = errors.New("folder deletion job is no longer running")
+
+// FolderDeletionService deletes large folders in small batches so no single
+// transaction holds locks on tens of thousands of notes.
+type FolderDeletionService struct {
+	db        *gorm.DB
+	log       logging.Logger
+	threshold int64
+	batchSize int
+}
+
+// NewFolderDeletionService reads FOLDER_DELETE_ASYNC_THRESHOLD (default 1000 notes)
+// and FOLDER_DELETE_BATCH_SIZE (default 1000 notes per transaction).
+func NewFolderDeletionService(db *gorm.DB, log logging.Logger) *FolderDeletionService {
+	threshold := int64(1000)
+	if v, _ := strconv.Atoi(os.Getenv("FOLDER_DELETE_ASYNC_THRESHOLD")); v > 0 {
+		threshold = int64(v)
+	}
+
+	batchSize := 1000
+	if v, _ := strconv.Atoi(os.Getenv("FOLDER_DELETE_BATCH_SIZE")); v > 0 {
+		batchSize = v
+	}
+
+	return &FolderDeletionService{db: db, log: log, threshold: threshold, batchSize: batchSize}
+}
+
+// ShouldRunAsync reports whether a folder with noteCount notes is deleted by a background job.
+func (s *FolderDeletionService) ShouldRunAsync(noteCount int64) bool {
+	return noteCount > s.threshold
+}
+
+// Start marks the folder as pending deletion, records a job and deletes its notes in the background.
+// When a live job is already deleting the folder, that job is returned with ErrFolderDeletionRunning.
+func (s *FolderDeletionService) Start(ctx context.Context, folder models.Folder, noteCount int64, actorID uuid.UUID) (models.FolderDeletionJob, error) {
+	job := models.FolderDeletionJob{
+		FolderID:    folder.FolderID,
+		RequestedBy: actorID,
+		Status:      "running",
+		TotalNotes:  noteCount,
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The folder lock makes concurrent deletes of the same folder check for a running
+		// job one after the other, so only one of them starts a job.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&folder, "folder_id = ?", folder.FolderID).Error; err != nil {
+			return err
+		}
+		running, err := runningFolderDeletion(tx, folder.FolderID)
+		if err != nil {
+			return err
+		}
+		if running != nil {
+			job = *running
+			return ErrFolderDeletionRunning
+		}
+		if err := tx.Model(&folder).Update("deletion_pending", true).Error; err != nil {
+			return err
+		}
+		return tx.Create(&job).Error
+	})
+	if errors.Is(err, ErrFolderDeletionRunning) {
+		return job, err
+	}
+	if err != nil {
+		return models.FolderDeletionJob{}, err
+	}
+
+	// The request context ends with the 202 response, the job must outlive it. It keeps
+	// the request ID, so the events the job publishes can be traced back to the request.
+	go s.run(context.WithoutCancel(ctx), job, folder, actorID)
+
+	return job, nil
+}
+
+func (s *FolderDeletionService) run(ctx context.Context, job models.FolderDeletionJob, folder models.Folder, actorID uuid.UUID) {
+	for {
+		var noteIDs []uuid.UUID
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Unscoped: notes in the trash are removed with the folder too.
+			if err := tx.Unscoped().Model(&models.Note{}).Where("folder_id = ?", folder.FolderID).Limit(s.batchSize).Pluck("note_id", &noteIDs).Error; err != nil {
+				return err
+			}
+			if len(noteIDs) == 0 {
+				return nil
+			}
+			if err := tx.Where("note_id IN ?", noteIDs).Delete(&models.NoteShare{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("note_id IN ?", noteIDs).Delete(&models.Note{}).Error; err != nil {
+				return err
+			}
+			// Updating the row also moves updated_at, which is the heartbeat. A job that was
+			// marked failed while it was still running stops here and leaves the folder to
+			// the job that replaced it.
+			result := tx.Model(&job).Where("status = ?", "running").Update("deleted_notes", gorm.Expr("deleted_notes + ?", len(noteIDs)))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errFolderDeletionTakenOver
+			}
+			return kafka.EnqueueAssetEvent(tx, kafka.NewFolderNotesDeletedEvent(folder, noteIDs, actorID))
+		})
+		if err != nil {
+			s.fail(ctx, job, err)
+			return
+		}
+		if len(noteIDs) == 0 {
+			break
+		}
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("folder_id = ?", folder.FolderID).Delete(&models.FolderShare{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&folder).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&job).Where("status = ?", "running").Update("status", "completed")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errFolderDeletionTakenOver
+		}
+		return kafka.EnqueueAssetEvent(tx, kafka.NewFolderEvent("FOLDER_DELETED", folder, actorID))
+	})
+	if err != nil {
+		s.fail(ctx, job, err)
+	}
+}
+
+// fail records the error on the job. The folder stays pending so a retry can pick it up.
+// A job that was already marked failed is left as it is.
+func (s *FolderDeletionService) fail(ctx context.Context, job models.FolderDeletionJob, cause error) {
+	log := s.log.With(logging.Fields{"job_id": job.JobID.String(), logging.FieldAssetID: job.FolderID.String()})
+	if errors.Is(cause, errFolderDeletionTakenOver) {
+		log.Warn("Folder deletion job stopped: it was marked failed while running")
+		return
+	}
+	log.Error("Folder deletion job failed", logging.Err(cause))
+
+	if err := s.db.WithContext(ctx).Model(&job).Where("status = ?", "running").Updates(map[string]interface{}{
+		"status": "failed",
+		"error":  cause.Error(),
+	}).Error; err != nil {
+		log.Error("Failed to record folder deletion job failure", logging.Err(err))
+	}
+}
+
+// RunningJob returns the job deleting folderID, or nil when there is none. A running job
+// whose heartbeat stopped is marked failed first, so the folder can be deleted again.
+func (s *FolderDeletionService) RunningJob(ctx context.Context, folderID uuid.UUID) (*models.FolderDeletionJob, error) {
+	return runningFolderDeletion(s.db.WithContext(ctx), folderID)
+}
+
+// Job returns a job started by ownerID. A running job whose heartbeat stopped is
+// reported as failed.
+func (s *FolderDeletionService) Job(ctx context.Context, ownerID, jobID uuid.UUID) (models.FolderDeletionJob, error) {
+	db := s.db.WithContext(ctx)
+
+	var job models.FolderDeletionJob
+	if err := db.First(&job, "job_id = ? AND requested_by = ?", jobID, ownerID).Error; err != nil {
+		return job, err
+	}
+	if job.Status == "running" && time.Since(job.UpdatedAt) > folderDeletionStaleAfter {
+		if _, err := failStaleFolderDeletion(db, job); err != nil {
+			return job, err
+		}
+		if err := db.First(&job, "job_id = ?", job.JobID).Error; err != nil {
+			return job, err
+		}
+	}
+	return job, nil
+}
+
+// FailStaleJobs marks failed the running jobs whose heartbeat stopped, which are the
+// ones that died with an instance. Their folders stay pending, so deleting them again
+// starts a new job. It runs once at startup.
+func (s *FolderDeletionService) FailStaleJobs(ctx context.Context) {
+	result := s.db.WithContext(ctx).Model(&models.FolderDeletionJob{}).
+		Where("status = ? AND updated_at < ?", "running", time.Now().Add(-folderDeletionStaleAfter)).
+		Updates(map[string]interface{}{"status": "failed", "error": errFolderDeletionLost})
+	if result.Error != nil {
+		s.log.Error("Failed to fail stale folder deletion jobs", logging.Err(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		s.log.Warn("Failed stale folder deletion jobs", logging.Fields{"jobs": result.RowsAffected})
+	}
+}
+
+func runningFolderDeletion(db *gorm.DB, folderID uuid.UUID) (*models.FolderDeletionJob, error) {
+	var job models.FolderDeletionJob
+	if err := db.Where("folder_id = ? AND status = ?", folderID, "running").First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if time.Since(job.UpdatedAt) <= folderDeletionStaleAfter {
+		return &job, nil
+	}
+	failed, err := failStaleFolderDeletion(db, job)
+	if err != nil {
+		return nil, err
+	}
+	if !failed {
+		return &job, nil
+	}
+	return nil, nil
+}
+
+// failStaleFolderDeletion reports whether the job was marked failed. It is guarded on
+// updated_at, so a job that has just finished a batch is left alone.
+func failStaleFolderDeletion(db *gorm.DB, job models.FolderDeletionJob) (bool, error) {
+	result := db.Model(&models.FolderDeletionJob{}).
+		Where("job_id = ? AND status = ? AND updated_at = ?", job.JobID, "running", job.UpdatedAt).
+		Updates(map[string]interface{}{"status": "failed", "error": errFolderDeletionLost})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"seta-pkg/logging"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func createPendingFolder(t *testing.T, db *gorm.DB) models.Folder {
+	t.Helper()
+	ownerID := uuid.New()
+	folder := models.Folder{Name: "Doomed", OwnerID: ownerID, LastModifiedBy: ownerID, DeletionPending: true}
+	if err := db.Omit("Owner").Create(&folder).Error; err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+	return folder
+}
+
+func createRunningJob(t *testing.T, db *gorm.DB, folder models.Folder, heartbeat time.Time) models.FolderDeletionJob {
+	t.Helper()
+	job := models.FolderDeletionJob{FolderID: folder.FolderID, RequestedBy: folder.OwnerID, Status: "running", CreatedAt: heartbeat, UpdatedAt: heartbeat}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	return job
+}
+
+// Start runs under the folder lock: while another transaction holds it, Start waits and
+// then sees the job that transaction committed instead of starting a second one.
+func TestStartWaitsForTheFolderLock(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewFolderDeletionService(db, logging.Nop())
+	folder := createPendingFolder(t, db)
+
+	tx := db.Begin()
+	defer tx.Rollback()
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Folder{}, "folder_id = ?", folder.FolderID).Error; err != nil {
+		t.Fatalf("lock folder: %v", err)
+	}
+
+	type started struct {
+		job models.FolderDeletionJob
+		err error
+	}
+	result := make(chan started, 1)
+	go func() {
+		job, err := s.Start(context.Background(), folder, 0, folder.OwnerID)
+		result <- started{job, err}
+	}()
+	select {
+	case r := <-result:
+		t.Fatalf("Start returned %v while the folder was locked", r.err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	running := createRunningJob(t, tx, folder, time.Now())
+	if err := tx.Commit().Error; err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	r := <-result
+	if !errors.Is(r.err, ErrFolderDeletionRunning) || r.job.JobID != running.JobID {
+		t.Fatalf("Start = %s, %v; want job %s with ErrFolderDeletionRunning", r.job.JobID, r.err, running.JobID)
+	}
+}
+
+func TestRunningJobFailsJobsWithAStaleHeartbeat(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewFolderDeletionService(db, logging.Nop())
+
+	live := createPendingFolder(t, db)
+	liveJob := createRunningJob(t, db, live, time.Now())
+	if job, err := s.RunningJob(context.Background(), live.FolderID); err != nil || job == nil || job.JobID != liveJob.JobID {
+		t.Errorf("RunningJob = %v, %v for a live job; want it returned", job, err)
+	}
+
+	stale := createPendingFolder(t, db)
+	staleJob := createRunningJob(t, db, stale, time.Now().Add(-2*folderDeletionStaleAfter))
+	if job, err := s.RunningJob(context.Background(), stale.FolderID); err != nil || job != nil {
+		t.Errorf("RunningJob = %v, %v for a stale job; want none", job, err)
+	}
+	var reloaded models.FolderDeletionJob
+	if err := db.First(&reloaded, "job_id = ?", staleJob.JobID).Error; err != nil {
+		t.Fatalf("reload job: %v", err)
+	}
+	if reloaded.Status != "failed" {
+		t.Errorf("stale job status = %q, want failed", reloaded.Status)
+	}
+}
+
+func TestFailStaleJobsAtStartup(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewFolderDeletionService(db, logging.Nop())
+	liveJob := createRunningJob(t, db, createPendingFolder(t, db), time.Now())
+	staleJob := createRunningJob(t, db, createPendingFolder(t, db), time.Now().Add(-2*folderDeletionStaleAfter))
+
+	s.FailStaleJobs(context.Background())
+
+	for _, want := range []struct {
+		job    models.FolderDeletionJob
+		status string
+	}{{liveJob, "running"}, {staleJob, "failed"}} {
+		var job models.FolderDeletionJob
+		if err := db.First(&job, "job_id = ?", want.job.JobID).Error; err != nil {
+			t.Fatalf("reload job: %v", err)
+		}
+		if job.Status != want.status {
+			t.Errorf("job %s status = %q, want %q", job.JobID, job.Status, want.status)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"seta/internal/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxFolderDepth is how many levels of folders a chain from a root folder down may hold.
+// Creating or moving a folder deeper is rejected, so the walks below, which stop at this
+// depth, always see the whole chain.
+const MaxFolderDepth = 16
+
+// folderMoveLockKey is the advisory lock held while a folder changes parent. Moves take it
+// exclusively, so two of them cannot each pass the cycle check and together form a cycle,
+// and new subfolders take it shared, so their depth check sees no move half done.
+const folderMoveLockKey = 75602
+
+var (
+	ErrFolderCycle   = errors.New("folder cannot be moved inside itself")
+	ErrFolderTooDeep = errors.New("folder would be nested too deep")
+)
+
+// folderAncestorsSQL lists a folder and its ancestors, nearest first.
+const folderAncestorsSQL = `
+WITH RECURSIVE chain AS (
+	SELECT folder_id, parent_folder_id, owner_id, team_id, deletion_pending, 0 AS depth
+	FROM folders WHERE folder_id = @folder
+	UNION ALL
+	SELECT f.folder_id, f.parent_folder_id, f.owner_id, f.team_id, f.deletion_pending, c.depth + 1
+	FROM folders f JOIN chain c ON f.folder_id = c.parent_folder_id
+	WHERE c.depth < @maxDepth
+)
+SELECT folder_id, parent_folder_id, owner_id, team_id, deletion_pending FROM chain ORDER BY depth`
+
+// folderDescendantsSQL lists the folders below a folder, deepest first, so they can be
+// deleted in that order without breaking the parent foreign key.
+const folderDescendantsSQL = `
+WITH RECURSIVE subtree AS (
+	SELECT folder_id, deletion_pending, 1 AS depth FROM folders WHERE parent_folder_id = @folder
+	UNION ALL
+	SELECT f.folder_id, f.deletion_pending, s.depth + 1
+	FROM folders f JOIN subtree s ON f.parent_folder_id = s.folder_id
+	WHERE s.depth < @maxDepth
+)
+SELECT folder_id, deletion_pending, depth FROM subtree ORDER BY depth DESC, folder_id`
+
+// FolderDescendant is a folder below another one, Depth levels down.
+type FolderDescendant struct {
+	FolderID        uuid.UUID
+	DeletionPending bool
+	Depth           int
+}
+
+// FolderAncestors returns the folder followed by its parent, grandparent and so on up to
+// the root. Only the ID, parent, owner, team and deletion flag are loaded. It is empty
+// when the folder does not exist.
+func FolderAncestors(db *gorm.DB, folderID uuid.UUID) ([]models.Folder, error) {
+	chain := make([]models.Folder, 0)
+	err := db.Raw(folderAncestorsSQL, map[string]any{"folder": folderID, "maxDepth": MaxFolderDepth}).Scan(&chain).Error
+	return chain, err
+}
+
+// FolderDescendants returns every folder below folderID, deepest first.
+func FolderDescendants(db *gorm.DB, folderID uuid.UUID) ([]FolderDescendant, error) {
+	descendants := make([]FolderDescendant, 0)
+	err := db.Raw(folderDescendantsSQL, map[string]any{"folder": folderID, "maxDepth": MaxFolderDepth}).Scan(&descendants).Error
+	return descendants, err
+}
+
+// LockAndCheckNewSubfolder takes the folder move lock shared for the rest of tx and
+// reports ErrFolderTooDeep when a folder created under parentID would exceed
+// MaxFolderDepth.
+func LockAndCheckNewSubfolder(tx *gorm.DB, parentID uuid.UUID) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock_shared(?)", folderMoveLockKey).Error; err != nil {
+		return err
+	}
+
+	ancestors, err := FolderAncestors(tx, parentID)
+	if err != nil {
+		return err
+	}
+	if len(ancestors) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if len(ancestors)+1 > MaxFolderDepth {
+		return ErrFolderTooDeep
+	}
+	return nil
+}
+
+// LockAndCheckFolderMove takes the folder move lock for the rest of tx and checks that
+// folderID can be moved under parentID: the parent must not be the folder or one of its
+// descendants, and the folder's subtree must still fit within MaxFolderDepth.
+func LockAndCheckFolderMove(tx *gorm.DB, folderID, parentID uuid.UUID) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", folderMoveLockKey).Error; err != nil {
+		return err
+	}
+
+	ancestors, err := FolderAncestors(tx, parentID)
+	if err != nil {
+		return err
+	}
+	if len(ancestors) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.FolderID == folderID {
+			return ErrFolderCycle
+		}
+	}
+
+	descendants, err := FolderDescendants(tx, folderID)
+	if err != nil {
+		return err
+	}
+	height := 1
+	if len(descendants) > 0 {
+		height += descendants[0].Depth
+	}
+	if len(ancestors)+height > MaxFolderDepth {
+		return ErrFolderTooDeep
+	}
+	return nil
+}
+
+// sharedFolderSubtreeSQL lists the folders shared with a user together with everything
+// below them, which the share reaches too.
+const sharedFolderSubtreeSQL = `
+WITH RECURSIVE shared AS (
+	SELECT fs.folder_id, 0 AS depth FROM folder_shares fs WHERE fs.user_id = @user
+	UNION ALL
+	SELECT f.folder_id, s.depth + 1
+	FROM folders f JOIN shared s ON f.parent_folder_id = s.folder_id
+	WHERE s.depth < @maxDepth
+)
+SELECT folder_id FROM shared`
+
+// SharedFolderSubtree is a subquery of the IDs of the folders shared with userID and of
+// their descendants, for use as "folder_id IN (?)".
+func SharedFolderSubtree(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Raw(sharedFolderSubtreeSQL, map[string]any{"user": userID, "maxDepth": MaxFolderDepth})
+}
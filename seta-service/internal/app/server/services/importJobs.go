@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"seta-pkg/logging"
+	"seta/internal/pkg/models"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrImportJobNotFound is returned for a job that does not exist or was started by
+// another user.
+var ErrImportJobNotFound = errors.New("import job not found")
+
+const (
+	// importJobHeartbeat is how often a running job records its progress.
+	importJobHeartbeat = 10 * time.Second
+	// importJobStaleAfter is how long a running job may go without a heartbeat before it
+	// is taken to have died with its instance.
+	importJobStaleAfter = 6 * importJobHeartbeat
+)
+
+// errImportJobLost is the error recorded on a job whose instance stopped running it.
+const errImportJobLost = "import interrupted: the instance running it stopped"
+
+// StartImportJob runs an import in the background and returns its job, which has the
+// import's ID. The upload is copied to a temporary file first since it does not outlive
+// the request, and its rows are counted so a CSV over USER_IMPORT_MAX_ROWS is still
+// rejected up front with ErrTooManyImportRows. release is called when the job ends.
+func (s *UserService) StartImportJob(ctx context.Context, ownerID, importID uuid.UUID, upload io.Reader, release func()) (models.UserImportJob, error) {
+	file, err := os.CreateTemp("", "seta-import-*.csv")
+	if err != nil {
+		return models.UserImportJob{}, err
+	}
+	started := false
+	defer func() {
+		if !started {
+			file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	if _, err := io.Copy(file, upload); err != nil {
+		return models.UserImportJob{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return models.UserImportJob{}, err
+	}
+	total, err := s.countRows(file)
+	if err != nil {
+		return models.UserImportJob{}, err
+	}
+
+	job := models.UserImportJob{JobID: importID, RequestedBy: ownerID, Status: "running", TotalRows: total}
+	if err := s.db.WithContext(ctx).Create(&job).Error; err != nil {
+		return models.UserImportJob{}, err
+	}
+
+	started = true
+	// The request context ends with the 202 response, the job must outlive it.
+	go s.runImportJob(context.Background(), job, file, release)
+
+	return job, nil
+}
+
+func (s *UserService) runImportJob(ctx context.Context, job models.UserImportJob, file *os.File, release func()) {
+	defer release()
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	log := s.log.With(logging.Fields{"import_id": job.JobID.String()})
+	db := s.db.WithContext(ctx)
+
+	var processed atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(importJobHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// Updating the row also moves updated_at, which is the heartbeat.
+				if err := db.Model(&job).Update("processed_rows", processed.Load()).Error; err != nil {
+					log.Warn("Failed to record user import progress", logging.Err(err))
+				}
+			}
+		}
+	}()
+
+	summary, err := s.importUsers(ctx, job.RequestedBy, job.JobID, file, false, &processed)
+	close(done)
+
+	if err != nil {
+		log.Error("User import job failed", logging.Err(err))
+		if err := db.Model(&job).Updates(map[string]interface{}{
+			"status":         "failed",
+			"processed_rows": processed.Load(),
+			"error":          err.Error(),
+		}).Error; err != nil {
+			log.Error("Failed to record user import job failure", logging.Err(err))
+		}
+		return
+	}
+
+	failures, err := json.Marshal(summary.Failures)
+	if err != nil {
+		log.Error("Failed to encode user import failures", logging.Err(err))
+		failures = []byte("[]")
+	}
+	if err := db.Model(&job).Updates(map[string]interface{}{
+		"status":             "completed",
+		"processed_rows":     processed.Load(),
+		"succeeded":          summary.Succeeded,
+		"failed":             summary.Failed,
+		"failures":           string(failures),
+		"failures_truncated": summary.FailuresTruncated,
+	}).Error; err != nil {
+		log.Error("Failed to record user import job completion", logging.Err(err))
+	}
+}
+
+// ImportJob returns a job started by ownerID, with its failures once it has finished.
+// A running job whose heartbeat stopped is marked failed first, so a job lost in a
+// restart does not stay running forever.
+func (s *UserService) ImportJob(ctx context.Context, ownerID, jobID uuid.UUID) (models.UserImportJob, []FailedRecord, error) {
+	db := s.db.WithContext(ctx)
+
+	var job models.UserImportJob
+	if err := db.First(&job, "job_id = ? AND requested_by = ?", jobID, ownerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return job, nil, ErrImportJobNotFound
+		}
+		return job, nil, err
+	}
+
+	if job.Status == "running" && time.Since(job.UpdatedAt) > importJobStaleAfter {
+		// Guarded on updated_at, so a job that has just reported progress is left alone.
+		result := db.Model(&models.UserImportJob{}).
+			Where("job_id = ? AND status = ? AND updated_at = ?", job.JobID, "running", job.UpdatedAt).
+			Updates(map[string]interface{}{"status": "failed", "error": errImportJobLost})
+		if result.Error != nil {
+			return job, nil, result.Error
+		}
+		if err := db.First(&job, "job_id = ?", job.JobID).Error; err != nil {
+			return job, nil, err
+		}
+	}
+
+	failures := make([]FailedRecord, 0)
+	if job.Failures != nil {
+		if err := json.Unmarshal([]byte(*job.Failures), &failures); err != nil {
+			return job, nil, err
+		}
+	}
+	return job, failures, nil
+}
+
+// ImportSlots reports how many imports are running across instances and the cap.
+func (s *UserService) ImportSlots(ctx context.Context) (ImportSlots, error) {
+	return s.importLimiter.Slots(ctx)
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"seta-pkg/logging"
+	"seta/internal/pkg/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// importLeaseLockKey is the advisory lock held while an import slot is reserved, so two
+// instances cannot both take the last free slot, or both start an import for one user.
+const importLeaseLockKey = 75603
+
+// ErrImportCapacity is returned when the import cap of the deployment is reached.
+var ErrImportCapacity = errors.New("too many imports are running, try again later")
+
+// ImportInProgressError is returned when the user already has an import running.
+type ImportInProgressError struct {
+	ImportID uuid.UUID
+}
+
+func (e *ImportInProgressError) Error() string {
+	return fmt.Sprintf("an import is already running (importId: %s)", e.ImportID)
+}
+
+// ImportSlots describes the limiter's current occupancy.
+type ImportSlots struct {
+	Active int `json:"active"`
+	Limit  int `json:"limit"`
+}
+
+// ImportLimiter allows one running import per user and a global cap across every
+// instance, with a lease row per running import in user_import_leases. A running import
+// moves its lease's heartbeat_at every third of the TTL; a lease without a heartbeat for
+// the TTL died with its instance and is reclaimed.
+type ImportLimiter struct {
+	db        *gorm.DB
+	log       logging.Logger
+	maxGlobal int
+	ttl       time.Duration
+	heartbeat time.Duration
+}
+
+// NewImportLimiter creates a limiter configured from USER_IMPORT_MAX_CONCURRENT
+// (default 3) and USER_IMPORT_LOCK_TTL_MINUTES (default 30).
+func NewImportLimiter(db *gorm.DB, log logging.Logger) *ImportLimiter {
+	maxGlobal := 3
+	if v, _ := strconv.Atoi(os.Getenv("USER_IMPORT_MAX_CONCURRENT")); v > 0 {
+		maxGlobal = v
+	}
+
+	ttl := 30 * time.Minute
+	if v, _ := strconv.Atoi(os.Getenv("USER_IMPORT_LOCK_TTL_MINUTES")); v > 0 {
+		ttl = time.Duration(v) * time.Minute
+	}
+
+	return &ImportLimiter{db: db, log: log, maxGlobal: maxGlobal, ttl: ttl, heartbeat: ttl / 3}
+}
+
+// Acquire reserves an import slot for the user. The returned release func must be
+// called when the import finishes, whether it succeeded or not; until then the lease is
+// kept alive.
+func (l *ImportLimiter) Acquire(ctx context.Context, userID uuid.UUID) (uuid.UUID, func(), error) {
+	lease := models.UserImportLease{UserID: userID, ImportID: uuid.New()}
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", importLeaseLockKey).Error; err != nil {
+			return err
+		}
+		if err := l.reclaimExpired(tx); err != nil {
+			return err
+		}
+
+		var held models.UserImportLease
+		result := tx.Where("user_id = ?", userID).Limit(1).Find(&held)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return &ImportInProgressError{ImportID: held.ImportID}
+		}
+
+		var active int64
+		if err := tx.Model(&models.UserImportLease{}).Count(&active).Error; err != nil {
+			return err
+		}
+		if active >= int64(l.maxGlobal) {
+			return ErrImportCapacity
+		}
+		return tx.Create(&lease).Error
+	})
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	// The lease outlives the request that took it when a background job runs the import.
+	heartbeatCtx, stop := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go l.keepAlive(heartbeatCtx, lease, stopped)
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			stop()
+			<-stopped
+			// Only drop our own lease; it may have expired and been taken over since.
+			if err := l.db.Where("user_id = ? AND import_id = ?", lease.UserID, lease.ImportID).Delete(&models.UserImportLease{}).Error; err != nil {
+				l.log.Error("Failed to release user import lease", logging.Err(err), logging.Fields{"import_id": lease.ImportID.String()})
+			}
+		})
+	}
+
+	return lease.ImportID, release, nil
+}
+
+// keepAlive moves the lease's heartbeat until ctx is done, then closes stopped.
+func (l *ImportLimiter) keepAlive(ctx context.Context, lease models.UserImportLease, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	log := l.log.With(logging.Fields{"import_id": lease.ImportID.String()})
+	ticker := time.NewTicker(l.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		result := l.db.WithContext(ctx).Model(&models.UserImportLease{}).
+			Where("user_id = ? AND import_id = ?", lease.UserID, lease.ImportID).
+			Update("heartbeat_at", gorm.Expr("NOW()"))
+		if result.Error != nil {
+			if ctx.Err() == nil {
+				log.Warn("Failed to renew user import lease", logging.Err(result.Error))
+			}
+			continue
+		}
+		if result.RowsAffected == 0 {
+			log.Warn("User import lease was reclaimed while the import was running")
+			return
+		}
+	}
+}
+
+// Slots reports the current number of running imports and the configured cap.
+func (l *ImportLimiter) Slots(ctx context.Context) (ImportSlots, error) {
+	db := l.db.WithContext(ctx)
+	if err := l.reclaimExpired(db); err != nil {
+		return ImportSlots{}, err
+	}
+	var active int64
+	if err := db.Model(&models.UserImportLease{}).Count(&active).Error; err != nil {
+		return ImportSlots{}, err
+	}
+	return ImportSlots{Active: int(active), Limit: l.maxGlobal}, nil
+}
+
+// reclaimExpired deletes the leases whose heartbeat stopped for the TTL. Times are the
+// database's, so clock skew between instances does not matter.
+func (l *ImportLimiter) reclaimExpired(db *gorm.DB) error {
+	return db.Where("heartbeat_at < NOW() - make_interval(secs => ?)", l.ttl.Seconds()).Delete(&models.UserImportLease{}).Error
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"seta-pkg/logging"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func newTestImportLimiter(db *gorm.DB, maxGlobal int) *ImportLimiter {
+	l := NewImportLimiter(db, logging.Nop())
+	l.maxGlobal = maxGlobal
+	l.ttl = time.Minute
+	l.heartbeat = 20 * time.Millisecond
+	return l
+}
+
+func acquire(t *testing.T, l *ImportLimiter, userID uuid.UUID) (uuid.UUID, func()) {
+	t.Helper()
+	importID, release, err := l.Acquire(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	t.Cleanup(release)
+	return importID, release
+}
+
+// backdate makes every lease look like its heartbeat stopped twice the TTL ago.
+func backdate(t *testing.T, db *gorm.DB, l *ImportLimiter) {
+	t.Helper()
+	stale := time.Now().Add(-2 * l.ttl)
+	if err := db.Model(&models.UserImportLease{}).Where("TRUE").
+		Updates(map[string]interface{}{"acquired_at": stale, "heartbeat_at": stale}).Error; err != nil {
+		t.Fatalf("backdate leases: %v", err)
+	}
+}
+
+func TestImportLimiterAllowsOneImportPerUser(t *testing.T) {
+	db := testdb.Open(t)
+	l := newTestImportLimiter(db, 3)
+	userID := uuid.New()
+
+	importID, release := acquire(t, l, userID)
+	_, _, err := l.Acquire(context.Background(), userID)
+	var inProgress *ImportInProgressError
+	if !errors.As(err, &inProgress) || inProgress.ImportID != importID {
+		t.Fatalf("second Acquire = %v, want ImportInProgressError for %s", err, importID)
+	}
+
+	release()
+	acquire(t, l, userID)
+}
+
+// The cap holds across instances: each limiter stands for one.
+func TestImportLimiterCapsImportsAcrossInstances(t *testing.T) {
+	db := testdb.Open(t)
+	first, second := newTestImportLimiter(db, 2), newTestImportLimiter(db, 2)
+
+	_, release := acquire(t, first, uuid.New())
+	acquire(t, second, uuid.New())
+	if _, _, err := second.Acquire(context.Background(), uuid.New()); !errors.Is(err, ErrImportCapacity) {
+		t.Fatalf("Acquire over the cap = %v, want ErrImportCapacity", err)
+	}
+	if slots, err := first.Slots(context.Background()); err != nil || slots != (ImportSlots{Active: 2, Limit: 2}) {
+		t.Errorf("Slots = %+v, %v; want 2 of 2 active", slots, err)
+	}
+
+	release()
+	acquire(t, second, uuid.New())
+}
+
+func TestImportLimiterReclaimsExpiredLeases(t *testing.T) {
+	db := testdb.Open(t)
+	l := newTestImportLimiter(db, 1)
+	l.heartbeat = time.Hour // the import died with its instance
+
+	userID := uuid.New()
+	_, releaseLost := acquire(t, l, userID)
+	backdate(t, db, l)
+
+	importID, _ := acquire(t, l, userID)
+	releaseLost()
+	var lease models.UserImportLease
+	if err := db.First(&lease, "user_id = ?", userID).Error; err != nil || lease.ImportID != importID {
+		t.Errorf("lease = %s, %v after the lost import released; want the new import's kept", lease.ImportID, err)
+	}
+}
+
+// An import running for longer than the TTL keeps its slot, as its heartbeat goes on.
+func TestImportLimiterKeepsLeasesOfRunningImports(t *testing.T) {
+	db := testdb.Open(t)
+	l := newTestImportLimiter(db, 1)
+
+	acquire(t, l, uuid.New())
+	backdate(t, db, l)
+	waitFor(t, "the lease heartbeat", func() bool {
+		var lease models.UserImportLease
+		return db.First(&lease).Error == nil && time.Since(lease.HeartbeatAt) < l.ttl
+	})
+
+	if _, _, err := l.Acquire(context.Background(), uuid.New()); !errors.Is(err, ErrImportCapacity) {
+		t.Errorf("Acquire = %v, want ErrImportCapacity while the running import holds the slot", err)
+	}
+}
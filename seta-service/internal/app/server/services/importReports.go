@@ -0,0 +1,131 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrImportReportNotFound is returned for an import without a failure report, or one
+// that belongs to another user or has expired.
+var ErrImportReportNotFound = errors.New("import failure report not found")
+
+type importReport struct {
+	ownerID   uuid.UUID
+	path      string
+	createdAt time.Time
+}
+
+// ImportReports keeps the full failure lists of imports with more failures than fit in
+// the response, as JSON lines files in the temp directory. A report can only be read by
+// the user who ran the import and is deleted after USER_IMPORT_REPORT_TTL_MINUTES
+// (default 60). Reports are per instance, so one is only found on the instance that ran
+// the import.
+type ImportReports struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	reports map[uuid.UUID]importReport
+	now     func() time.Time
+}
+
+// NewImportReports creates an empty report store.
+func NewImportReports() *ImportReports {
+	ttl := 60 * time.Minute
+	if v, _ := strconv.Atoi(os.Getenv("USER_IMPORT_REPORT_TTL_MINUTES")); v > 0 {
+		ttl = time.Duration(v) * time.Minute
+	}
+	return &ImportReports{ttl: ttl, reports: make(map[uuid.UUID]importReport), now: time.Now}
+}
+
+// Open returns the failure report of an import run by ownerID. The caller closes it.
+func (r *ImportReports) Open(ownerID, importID uuid.UUID) (*os.File, error) {
+	r.mu.Lock()
+	r.reclaimExpired()
+	report, ok := r.reports[importID]
+	r.mu.Unlock()
+
+	if !ok || report.ownerID != ownerID {
+		return nil, ErrImportReportNotFound
+	}
+	return os.Open(report.path)
+}
+
+func (r *ImportReports) add(ownerID, importID uuid.UUID, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reclaimExpired()
+	r.reports[importID] = importReport{ownerID: ownerID, path: path, createdAt: r.now()}
+}
+
+// reclaimExpired must be called with r.mu held.
+func (r *ImportReports) reclaimExpired() {
+	cutoff := r.now().Add(-r.ttl)
+	for importID, report := range r.reports {
+		if report.createdAt.Before(cutoff) {
+			_ = os.Remove(report.path)
+			delete(r.reports, importID)
+		}
+	}
+}
+
+// failureLog collects the failures of one import with bounded memory: the first limit
+// are kept for the response and, once there are more, every failure is written to a
+// report file instead.
+type failureLog struct {
+	limit   int
+	inline  []FailedRecord
+	total   int
+	file    *os.File
+	encoder *json.Encoder
+	err     error
+}
+
+func newFailureLog(limit int) *failureLog {
+	return &failureLog{limit: limit, inline: make([]FailedRecord, 0)}
+}
+
+func (l *failureLog) add(failure FailedRecord) {
+	l.total++
+	if l.file == nil && len(l.inline) < l.limit {
+		l.inline = append(l.inline, failure)
+		return
+	}
+	if l.err != nil {
+		return
+	}
+	if l.file == nil {
+		if l.file, l.err = os.CreateTemp("", "seta-import-failures-*.jsonl"); l.err != nil {
+			return
+		}
+		l.encoder = json.NewEncoder(l.file)
+		for _, earlier := range l.inline {
+			if l.err = l.encoder.Encode(earlier); l.err != nil {
+				return
+			}
+		}
+	}
+	l.err = l.encoder.Encode(failure)
+}
+
+// close finishes the report, if one was started, and returns its path. A report that
+// could not be written completely is removed.
+func (l *failureLog) close() (string, error) {
+	if l.file == nil {
+		return "", l.err
+	}
+	path := l.file.Name()
+	if err := l.file.Close(); err != nil && l.err == nil {
+		l.err = err
+	}
+	if l.err != nil {
+		_ = os.Remove(path)
+		return "", l.err
+	}
+	return path, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"os"
+	"seta-pkg/logging"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/models"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var notesPurged = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "notes_purged_total",
+	Help: "Notes removed for good after their time in the trash.",
+})
+
+// NoteTrashRetention is how long a deleted note stays in its owner's trash before it is
+// purged, NOTE_TRASH_RETENTION_DAYS (default 30).
+func NoteTrashRetention() time.Duration {
+	days := 30
+	if v, _ := strconv.Atoi(os.Getenv("NOTE_TRASH_RETENTION_DAYS")); v > 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// NotePurger removes notes that have been in the trash longer than NoteTrashRetention,
+// with their share rows, and emits NOTE_PURGED for each. It checks every
+// NOTE_PURGE_INTERVAL_MINUTES (default 60). Instances skip the rows another is purging,
+// so running one on every instance is safe.
+type NotePurger struct {
+	db        *gorm.DB
+	log       logging.Logger
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+}
+
+func NewNotePurger(db *gorm.DB, log logging.Logger) *NotePurger {
+	interval := time.Hour
+	if v, _ := strconv.Atoi(os.Getenv("NOTE_PURGE_INTERVAL_MINUTES")); v > 0 {
+		interval = time.Duration(v) * time.Minute
+	}
+	return &NotePurger{db: db, log: log, retention: NoteTrashRetention(), interval: interval, batchSize: 100}
+}
+
+// Run purges until ctx is done.
+func (p *NotePurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if err := p.purgeExpired(ctx); err != nil {
+			p.log.Error("Failed to purge trashed notes", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *NotePurger) purgeExpired(ctx context.Context) error {
+	for {
+		cutoff := time.Now().Add(-p.retention)
+		var purged []models.Note
+		err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Select("note_id", "folder_id", "owner_id", "team_id", "cacheable").
+				Where("deleted_at < ?", cutoff).Limit(p.batchSize).Find(&purged).Error; err != nil {
+				return err
+			}
+			if len(purged) == 0 {
+				return nil
+			}
+			noteIDs := make([]uuid.UUID, len(purged))
+			for i, note := range purged {
+				noteIDs[i] = note.NoteID
+			}
+			if err := tx.Where("note_id IN ?", noteIDs).Delete(&models.NoteShare{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("note_id IN ?", noteIDs).Delete(&models.Note{}).Error; err != nil {
+				return err
+			}
+			for _, note := range purged {
+				// No user acts on a purge; the actor is the nil UUID.
+				if err := kafka.EnqueueAssetEvent(tx, kafka.NewNoteEvent("NOTE_PURGED", note, uuid.Nil)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		notesPurged.Add(float64(len(purged)))
+		if len(purged) < p.batchSize {
+			return nil
+		}
+	}
+}
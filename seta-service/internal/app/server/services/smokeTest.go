@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"seta-pkg/database"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The reserved users the smoke test acts as. They are not in the user service and
+// belong to no team, so their assets never show up in listings or team reports, and
+// every event they cause is marked synthetic.
+var (
+	SmokeOwnerID  = uuid.NewSHA1(uuid.NameSpaceOID, []byte("seta-smoke-owner"))
+	SmokeReaderID = uuid.NewSHA1(uuid.NameSpaceOID, []byte("seta-smoke-reader"))
+)
+
+// smokeEventCount is the number of events one complete run enqueues: folder and note
+// created, note shared and unshared, note and folder deleted.
+const smokeEventCount = 6
+
+// SmokeStep is the outcome of one step of a smoke run.
+type SmokeStep struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SmokeReport is the result of a smoke run. Passed is set only if every step passed.
+type SmokeReport struct {
+	RunID      uuid.UUID   `json:"runId"`
+	Passed     bool        `json:"passed"`
+	DurationMs int64       `json:"durationMs"`
+	Steps      []SmokeStep `json:"steps"`
+}
+
+// SmokeTest runs a synthetic create, read, share, unshare and delete cycle against the
+// database and outbox, the way the API handlers do, for checking a deployment. Access is
+// checked through the router's authorization service, so a share change that does not
+// reach its decision cache fails the run.
+type SmokeTest struct {
+	db            *gorm.DB
+	authorization *AuthorizationService
+}
+
+func NewSmokeTest(db *gorm.DB, authorization *AuthorizationService) *SmokeTest {
+	return &SmokeTest{db: db, authorization: authorization}
+}
+
+// Run performs the cycle and reports every step. Steps after a failure are skipped, but
+// the assets created so far are always deleted.
+func (s *SmokeTest) Run(ctx context.Context) SmokeReport {
+	report := SmokeReport{RunID: uuid.New(), Steps: make([]SmokeStep, 0, 10)}
+	start := time.Now()
+	db := s.db.WithContext(ctx)
+
+	var folder models.Folder
+	var note models.Note
+	ok := true
+	step := func(name string, fn func() error) {
+		if !ok {
+			return
+		}
+		ok = report.record(name, fn)
+	}
+
+	step("create_folder", func() error {
+		folder = models.Folder{Name: "[smoke] " + report.RunID.String(), OwnerID: SmokeOwnerID}
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&folder).Error; err != nil {
+				return err
+			}
+			return kafka.EnqueueAssetEvent(tx, smokeEvent(kafka.NewFolderEvent("FOLDER_CREATED", folder, SmokeOwnerID)))
+		})
+	})
+	step("create_note", func() error {
+		note = models.Note{Title: "[smoke] " + report.RunID.String(), Body: "smoke test", FolderID: folder.FolderID, OwnerID: SmokeOwnerID}
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&note).Error; err != nil {
+				return err
+			}
+			return kafka.EnqueueAssetEvent(tx, smokeEvent(kafka.NewNoteEvent("NOTE_CREATED", note, SmokeOwnerID)))
+		})
+	})
+	step("read_note", func() error {
+		var read models.Note
+		if err := database.Read(ctx, s.db, func(tx *gorm.DB) error {
+			return tx.First(&read, "note_id = ?", note.NoteID).Error
+		}); err != nil {
+			return err
+		}
+		if read.Title != note.Title || read.Body != note.Body {
+			return errors.New("note read back differs from the note written")
+		}
+		return nil
+	})
+	step("check_access_before_share", func() error {
+		return expectAccess(s.authorization, note.NoteID, false)
+	})
+	step("share_note", func() error {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&models.NoteShare{NoteID: note.NoteID, UserID: SmokeReaderID, Access: models.AccessRead}).Error; err != nil {
+				return err
+			}
+			return kafka.EnqueueAssetEvent(tx, smokeEvent(kafka.NewNoteEvent("NOTE_SHARED", note, SmokeOwnerID).WithTarget(SmokeReaderID)))
+		})
+		if err == nil {
+			s.authorization.Purge()
+		}
+		return err
+	})
+	step("check_access_granted", func() error {
+		return expectAccess(s.authorization, note.NoteID, true)
+	})
+	step("unshare_note", func() error {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("note_id = ? AND user_id = ?", note.NoteID, SmokeReaderID).Delete(&models.NoteShare{}).Error; err != nil {
+				return err
+			}
+			return kafka.EnqueueAssetEvent(tx, smokeEvent(kafka.NewNoteEvent("NOTE_UNSHARED", note, SmokeOwnerID).WithTarget(SmokeReaderID)))
+		})
+		if err == nil {
+			s.authorization.Purge()
+		}
+		return err
+	})
+	step("check_access_denied", func() error {
+		return expectAccess(s.authorization, note.NoteID, false)
+	})
+
+	// Cleanup runs whatever happened above; the request context may be gone by now.
+	cleanupDB := s.db.WithContext(context.WithoutCancel(ctx))
+	cleaned := report.record("delete_assets", func() error {
+		return s.cleanup(cleanupDB, folder, note)
+	})
+
+	if ok && cleaned {
+		ok = report.record("check_events_enqueued", func() error {
+			var count int64
+			if err := cleanupDB.Model(&models.OutboxEvent{}).
+				Where("payload->>'assetId' IN ?", []string{folder.FolderID.String(), note.NoteID.String()}).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count != smokeEventCount {
+				return fmt.Errorf("expected %d events in the outbox, found %d", smokeEventCount, count)
+			}
+			return nil
+		})
+	}
+
+	report.Passed = ok && cleaned
+	report.DurationMs = time.Since(start).Milliseconds()
+	return report
+}
+
+// cleanup deletes whatever the run created, emitting the delete events a user delete would.
+func (s *SmokeTest) cleanup(db *gorm.DB, folder models.Folder, note models.Note) error {
+	if folder.FolderID == uuid.Nil {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if note.NoteID != uuid.Nil {
+			if err := tx.Where("note_id = ?", note.NoteID).Delete(&models.NoteShare{}).Error; err != nil {
+				return err
+			}
+			// A step that failed after its insert was rolled back leaves nothing to delete.
+			result := tx.Unscoped().Delete(&models.Note{}, "note_id = ?", note.NoteID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				if err := kafka.EnqueueAssetEvent(tx, smokeEvent(kafka.NewNoteEvent("NOTE_DELETED", note, SmokeOwnerID))); err != nil {
+					return err
+				}
+			}
+		}
+		result := tx.Delete(&models.Folder{}, "folder_id = ?", folder.FolderID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return kafka.EnqueueAssetEvent(tx, smokeEvent(kafka.NewFolderEvent("FOLDER_DELETED", folder, SmokeOwnerID)))
+	})
+}
+
+// record times fn as the step called name and reports whether it passed.
+func (r *SmokeReport) record(name string, fn func() error) bool {
+	start := time.Now()
+	err := fn()
+	step := SmokeStep{Name: name, Passed: err == nil, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	r.Steps = append(r.Steps, step)
+	return step.Passed
+}
+
+func expectAccess(authorization *AuthorizationService, noteID uuid.UUID, want bool) error {
+	allowed, customErr := authorization.CanAccessAsset(SmokeReaderID, "note", noteID)
+	if customErr != nil {
+		return customErr
+	}
+	if allowed != want {
+		return fmt.Errorf("expected read access %t, got %t", want, allowed)
+	}
+	return nil
+}
+
+func smokeEvent(event kafka.EventPayload) kafka.EventPayload {
+	event.Synthetic = true
+	return event
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"seta-pkg/logging"
+	"seta/internal/pkg/testdb"
+	"testing"
+)
+
+// The run checks access before the share, after it and after the unshare, so with a
+// listening cache it only passes if each share change purges the decisions cached before.
+func TestSmokeTestPassesOnTheCachedAuthorizationService(t *testing.T) {
+	db := testdb.Open(t)
+	authorization := NewCachedAuthorizationService(db, logging.Nop())
+	authorization.cache.setListening(true)
+
+	report := NewSmokeTest(db, authorization).Run(context.Background())
+	if !report.Passed {
+		t.Fatalf("smoke run failed: %+v", report.Steps)
+	}
+	for _, name := range []string{"check_access_before_share", "check_access_granted", "check_access_denied"} {
+		found := false
+		for _, step := range report.Steps {
+			found = found || (step.Name == name && step.Passed)
+		}
+		if !found {
+			t.Errorf("step %s did not pass", name)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AssetCounts sums the assets of one or more users. Notes in the trash and assets in
+// folders being deleted are left out. NoteBodyBytes is what the bodies take in the
+// database, so compressed bodies count at their compressed size.
+type AssetCounts struct {
+	OwnedFolders   int64 `json:"ownedFolders"`
+	OwnedNotes     int64 `json:"ownedNotes"`
+	SharedInAssets int64 `json:"sharedInAssets"`
+	NoteBodyBytes  int64 `json:"noteBodyBytes"`
+}
+
+// UserStats are the asset counts of one user.
+type UserStats struct {
+	UserID uuid.UUID `json:"userId"`
+	AssetCounts
+}
+
+// TeamStats are the asset counts of every manager and member of a team, and their sum.
+type TeamStats struct {
+	Members     []UserStats `json:"members"`
+	Totals      AssetCounts `json:"totals"`
+	GeneratedAt time.Time   `json:"generatedAt"`
+}
+
+type cachedTeamStats struct {
+	stats     TeamStats
+	expiresAt time.Time
+}
+
+// StatsService computes asset counts with aggregate queries. Team stats scan every asset
+// of every member, so they are cached per team for TEAM_STATS_CACHE_SECONDS (default 300).
+type StatsService struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]cachedTeamStats
+}
+
+func NewStatsService(db *gorm.DB) *StatsService {
+	ttl := 5 * time.Minute
+	if v, _ := strconv.Atoi(os.Getenv("TEAM_STATS_CACHE_SECONDS")); v > 0 {
+		ttl = time.Duration(v) * time.Second
+	}
+	return &StatsService{db: db, ttl: ttl, cache: make(map[uuid.UUID]cachedTeamStats)}
+}
+
+// statsSQL computes the counts of each user selected by usersSQL, zero included.
+func statsSQL(usersSQL string) string {
+	return `
+WITH users AS (` + usersSQL + `),
+folder_counts AS (
+	SELECT owner_id AS user_id, COUNT(*) AS owned_folders
+	FROM folders
+	WHERE owner_id IN (SELECT user_id FROM users) AND NOT deletion_pending
+	GROUP BY owner_id
+),
+note_counts AS (
+	SELECT n.owner_id AS user_id, COUNT(*) AS owned_notes,
+		SUM(COALESCE(octet_length(n.body), 0) + COALESCE(octet_length(n.body_compressed), 0)) AS note_body_bytes
+	FROM notes n
+	JOIN folders f ON f.folder_id = n.folder_id
+	WHERE n.owner_id IN (SELECT user_id FROM users) AND n.deleted_at IS NULL AND NOT f.deletion_pending
+	GROUP BY n.owner_id
+),
+shared_in AS (
+	SELECT user_id, COUNT(*) AS shared_in_assets FROM (
+		SELECT fs.user_id
+		FROM folder_shares fs
+		JOIN folders f ON f.folder_id = fs.folder_id
+		WHERE fs.user_id IN (SELECT user_id FROM users) AND NOT f.deletion_pending
+		UNION ALL
+		SELECT ns.user_id
+		FROM note_shares ns
+		JOIN notes n ON n.note_id = ns.note_id
+		JOIN folders f ON f.folder_id = n.folder_id
+		WHERE ns.user_id IN (SELECT user_id FROM users) AND n.deleted_at IS NULL AND NOT f.deletion_pending
+	) shares
+	GROUP BY user_id
+)
+SELECT u.user_id,
+	COALESCE(fc.owned_folders, 0) AS owned_folders,
+	COALESCE(nc.owned_notes, 0) AS owned_notes,
+	COALESCE(si.shared_in_assets, 0) AS shared_in_assets,
+	COALESCE(nc.note_body_bytes, 0) AS note_body_bytes
+FROM users u
+LEFT JOIN folder_counts fc ON fc.user_id = u.user_id
+LEFT JOIN note_counts nc ON nc.user_id = u.user_id
+LEFT JOIN shared_in si ON si.user_id = u.user_id
+ORDER BY u.user_id`
+}
+
+var (
+	userStatsSQL = statsSQL(`SELECT CAST(@user AS uuid) AS user_id`)
+	teamStatsSQL = statsSQL(teamOwnersSQL)
+)
+
+// UserStats returns the counts of one user. They are cheap enough not to be cached.
+func (s *StatsService) UserStats(ctx context.Context, userID uuid.UUID) (UserStats, error) {
+	var stats UserStats
+	err := s.db.WithContext(ctx).Raw(userStatsSQL, map[string]any{"user": userID}).Scan(&stats).Error
+	return stats, err
+}
+
+// TeamStats returns the counts of the team, from cache when fresh enough.
+func (s *StatsService) TeamStats(ctx context.Context, teamID uuid.UUID) (TeamStats, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[teamID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.stats, nil
+	}
+
+	members := make([]UserStats, 0)
+	if err := s.db.WithContext(ctx).Raw(teamStatsSQL, map[string]any{"team": teamID}).Scan(&members).Error; err != nil {
+		return TeamStats{}, err
+	}
+
+	stats := TeamStats{Members: members, GeneratedAt: time.Now()}
+	for _, member := range members {
+		stats.Totals.OwnedFolders += member.OwnedFolders
+		stats.Totals.OwnedNotes += member.OwnedNotes
+		stats.Totals.SharedInAssets += member.SharedInAssets
+		stats.Totals.NoteBodyBytes += member.NoteBodyBytes
+	}
+
+	s.mu.Lock()
+	s.cache[teamID] = cachedTeamStats{stats: stats, expiresAt: stats.GeneratedAt.Add(s.ttl)}
+	for k, entry := range s.cache {
+		if !stats.GeneratedAt.Before(entry.expiresAt) {
+			delete(s.cache, k)
+		}
+	}
+	s.mu.Unlock()
+
+	return stats, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserTeam is a team as seen by one of its managers or members.
+type UserTeam struct {
+	TeamID    uuid.UUID `json:"teamId"`
+	TeamName  string    `json:"teamName"`
+	Role      string    `json:"role"` // "manager" or "member"
+	IsLead    bool      `json:"isLead"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TeamService answers queries about teams that span the membership tables.
+type TeamService struct {
+	db *gorm.DB
+}
+
+func NewTeamService(db *gorm.DB) *TeamService {
+	return &TeamService{db: db}
+}
+
+// userTeamsSQL lists every team @user manages or belongs to, once per team: someone who
+// is both a manager and a member is reported as a manager.
+const userTeamsSQL = `
+SELECT t.id AS team_id, t.team_name, r.role, r.is_lead, t.created_at
+FROM (
+	SELECT team_id, 'manager' AS role, is_lead FROM team_managers WHERE user_id = @user
+	UNION ALL
+	SELECT team_id, 'member', FALSE FROM team_members WHERE user_id = @user
+	  AND team_id NOT IN (SELECT team_id FROM team_managers WHERE user_id = @user)
+) r
+JOIN teams t ON t.id = r.team_id`
+
+// FindTeamsByUser returns a page of the user's teams ordered by name, plus how many
+// teams they are on in total.
+func (s *TeamService) FindTeamsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]UserTeam, int64, error) {
+	args := map[string]any{"user": userID, "limit": limit, "offset": offset}
+
+	var total int64
+	if err := s.db.WithContext(ctx).Raw("SELECT COUNT(*) FROM ("+userTeamsSQL+") teams", args).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	teams := make([]UserTeam, 0)
+	if err := s.db.WithContext(ctx).Raw(userTeamsSQL+" ORDER BY t.team_name, t.id LIMIT @limit OFFSET @offset", args).Scan(&teams).Error; err != nil {
+		return nil, 0, err
+	}
+	return teams, total, nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import "strings"
+
+// RenderTemplate substitutes {{name}} placeholders with values from vars in a single pass.
+// Substituted values are never re-scanned, so user-controlled values (a username, a team
+// name) cannot smuggle in further placeholders. Unknown placeholders are kept verbatim and
+// `\{{` renders a literal `{{`.
+func RenderTemplate(tmpl string, vars map[string]string) string {
+	var out strings.Builder
+	out.Grow(len(tmpl))
+
+	for i := 0; i < len(tmpl); {
+		if strings.HasPrefix(tmpl[i:], `\{{`) {
+			out.WriteString("{{")
+			i += 3
+			continue
+		}
+
+		if strings.HasPrefix(tmpl[i:], "{{") {
+			end := strings.Index(tmpl[i+2:], "}}")
+			if end >= 0 {
+				name := strings.TrimSpace(tmpl[i+2 : i+2+end])
+				if value, ok := vars[name]; ok {
+					out.WriteString(value)
+					i += end + 4
+					continue
+				}
+			}
+		}
+
+		out.WriteByte(tmpl[i])
+		i++
+	}
+
+	return out.String()
+}
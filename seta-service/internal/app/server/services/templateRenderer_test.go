@@ -0,0 +1,54 @@
+package services
+
+import "testing"
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{"username": "Bob", "teamName": "Engineering", "date": "2026-10-14"}
+
+	tests := []struct {
+		name string
+		tmpl string
+		vars map[string]string
+		want string
+	}{
+		{"placeholders", "Standup {{date}} by {{username}}", vars, "Standup 2026-10-14 by Bob"},
+		{"spaces inside the braces", "{{ teamName }} notes", vars, "Engineering notes"},
+		{"adjacent placeholders", "{{username}}{{teamName}}", vars, "BobEngineering"},
+		{"no placeholders", "Plain title", vars, "Plain title"},
+		{"empty template", "", vars, ""},
+		{
+			"placeholder in a username is not expanded",
+			"Notes by {{username}}",
+			map[string]string{"username": "{{teamName}}", "teamName": "Secret team"},
+			"Notes by {{teamName}}",
+		},
+		{
+			"placeholder in a team name is not expanded",
+			"{{teamName}} / {{username}}",
+			map[string]string{"username": "Bob", "teamName": "{{username}}{{date}}"},
+			"{{username}}{{date}} / Bob",
+		},
+		{
+			"escape in a value is kept as it is",
+			"{{username}}",
+			map[string]string{"username": `\{{teamName}}`, "teamName": "Engineering"},
+			`\{{teamName}}`,
+		},
+		{"escaped placeholder", `\{{username}} is {{username}}`, vars, "{{username}} is Bob"},
+		{"escaped braces alone", `a \{{ b`, vars, "a {{ b"},
+		{"unknown placeholder", "{{unknown}} by {{username}}", vars, "{{unknown}} by Bob"},
+		{"empty placeholder", "{{}} by {{username}}", vars, "{{}} by Bob"},
+		{"unterminated placeholder", "Notes by {{username", vars, "Notes by {{username"},
+		{"unterminated after a placeholder", "{{username}} and {{teamName", vars, "Bob and {{teamName"},
+		{"braces at the end", "Notes {{", vars, "Notes {{"},
+		{"single closing braces", "{{username}}}}", vars, "Bob}}"},
+		{"no vars", "{{username}}", nil, "{{username}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderTemplate(tt.tmpl, tt.vars); got != tt.want {
+				t.Errorf("RenderTemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
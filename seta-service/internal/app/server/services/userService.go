@@ -1,56 +1,136 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"net/mail"
 	"os"
+	"seta-pkg/logging"
+	"seta/internal/pkg/userclient"
 	"strconv"
+	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // FailedRecord holds information about a CSV record that failed to import.
+// CorrelationID is also sent to the user service, to find the row in its logs.
 type FailedRecord struct {
-	Record []string `json:"record"`
-	Reason string   `json:"reason"`
+	Record        []string `json:"record"`
+	Reason        string   `json:"reason"`
+	CorrelationID string   `json:"correlationId"`
 }
 
-// Summary now includes detailed failure information.
+// Summary now includes detailed failure information. Failures holds at most the first
+// USER_IMPORT_MAX_INLINE_FAILURES (default 1000); FailuresTruncated is set when there
+// were more, and the full list is kept as the import's failure report, see ImportReports.
 type Summary struct {
-	Succeeded int            `json:"succeeded"`
-	Failed    int            `json:"failed"`
-	Failures  []FailedRecord `json:"failures"`
+	Succeeded         int            `json:"succeeded"`
+	Failed            int            `json:"failed"`
+	Failures          []FailedRecord `json:"failures"`
+	FailuresTruncated bool           `json:"failuresTruncated"`
 }
 
 // userJob now includes a line number for better error tracking.
 type userJob struct {
-	lineNumber int
-	record     []string
+	lineNumber    int
+	correlationID string
+	record        []string
 }
 
 // jobResult now contains enough detail to report specific errors.
 type jobResult struct {
-	success    bool
-	lineNumber int
-	record     []string
-	message    string
+	success       bool
+	lineNumber    int
+	correlationID string
+	record        []string
+	message       string
 }
 
+// ErrTooManyImportRows is returned for a CSV with more than USER_IMPORT_MAX_ROWS rows.
+var ErrTooManyImportRows = errors.New("CSV has more rows than an import allows")
+
 // UserService handles the business logic for user-related operations.
-type UserService struct{}
+type UserService struct {
+	db                *gorm.DB
+	importLimiter     *ImportLimiter
+	importReports     *ImportReports
+	users             *userclient.Client
+	log               logging.Logger
+	maxUploadBytes    int64
+	maxRows           int
+	maxInlineFailures int
+}
+
+// NewUserService creates a new instance of UserService. Imports are limited to
+// USER_IMPORT_MAX_UPLOAD_BYTES (default 10MB) and USER_IMPORT_MAX_ROWS (default 10000).
+func NewUserService(db *gorm.DB, log logging.Logger) *UserService {
+	s := &UserService{
+		db:                db,
+		importLimiter:     NewImportLimiter(db, log),
+		importReports:     NewImportReports(),
+		users:             userclient.Shared(),
+		log:               log,
+		maxUploadBytes:    10 << 20,
+		maxRows:           10000,
+		maxInlineFailures: 1000,
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_IMPORT_MAX_UPLOAD_BYTES")); v > 0 {
+		s.maxUploadBytes = int64(v)
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_IMPORT_MAX_ROWS")); v > 0 {
+		s.maxRows = v
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_IMPORT_MAX_INLINE_FAILURES")); v > 0 {
+		s.maxInlineFailures = v
+	}
+	return s
+}
 
-// NewUserService creates a new instance of UserService.
-func NewUserService() *UserService {
-	return &UserService{}
+// MaxUploadBytes is the largest CSV upload an import accepts.
+func (s *UserService) MaxUploadBytes() int64 {
+	return s.maxUploadBytes
 }
 
-// ImportUsers orchestrates the entire CSV import process.
-func (s *UserService) ImportUsers(ctx context.Context, file io.Reader) (Summary, error) {
+// OpenFailureReport returns the full failure list of an import run by userID, as JSON lines.
+func (s *UserService) OpenFailureReport(userID, importID uuid.UUID) (*os.File, error) {
+	return s.importReports.Open(userID, importID)
+}
+
+// rowCorrelationID identifies one CSV line of one import, the same on every retry.
+func rowCorrelationID(importID uuid.UUID, line int) string {
+	return uuid.NewSHA1(importID, []byte(strconv.Itoa(line))).String()
+}
+
+// BeginImport reserves an import slot for the user, see ImportLimiter.Acquire.
+func (s *UserService) BeginImport(ctx context.Context, userID uuid.UUID) (uuid.UUID, func(), error) {
+	return s.importLimiter.Acquire(ctx, userID)
+}
+
+// ImportUsers orchestrates the entire CSV import process. Every row gets a correlation
+// ID derived from importID and its line number, see rowCorrelationID.
+//
+// Rows are checked with validateRecord before any call to the user service, and a row
+// repeating an email from an earlier row fails locally. With dryRun nothing is created:
+// the summary counts the rows that would have been sent as succeeded.
+//
+// The file is read twice, the first time to enforce USER_IMPORT_MAX_ROWS before anything
+// is created.
+func (s *UserService) ImportUsers(ctx context.Context, ownerID, importID uuid.UUID, file io.ReadSeeker, dryRun bool) (Summary, error) {
+	return s.importUsers(ctx, ownerID, importID, file, dryRun, new(atomic.Int64))
+}
+
+// importUsers is ImportUsers, counting the rows done in processed as it goes.
+func (s *UserService) importUsers(ctx context.Context, ownerID, importID uuid.UUID, file io.ReadSeeker, dryRun bool, processed *atomic.Int64) (Summary, error) {
+    if _, err := s.countRows(file); err != nil {
+        return Summary{}, err
+    }
     reader := csv.NewReader(file)
 
     // Read header
@@ -72,7 +152,7 @@ func (s *UserService) ImportUsers(ctx context.Context, file io.Reader) (Summary,
     var wg sync.WaitGroup
     wg.Add(numWorkers)
     for i := 0; i < numWorkers; i++ {
-        go s.worker(ctx, jobs, results, &wg)
+        go s.worker(ctx, importID, jobs, results, &wg)
     }
 
     // Close results when ALL workers are done
@@ -81,7 +161,42 @@ func (s *UserService) ImportUsers(ctx context.Context, file io.Reader) (Summary,
         close(results)
     }()
 
-    summary := Summary{Failures: make([]FailedRecord, 0)}
+    var summary Summary
+    failures := newFailureLog(s.maxInlineFailures)
+    addResult := func(r jobResult) {
+        processed.Add(1)
+        if r.success {
+            summary.Succeeded++
+            return
+        }
+        summary.Failed++
+        failures.add(FailedRecord{
+            Record:        r.record,
+            Reason:        fmt.Sprintf("Line %d: %s", r.lineNumber, r.message),
+            CorrelationID: r.correlationID,
+        })
+    }
+    // Rows rejected before reaching a worker are recorded here (don't send to results)
+    failLocally := func(record []string, line int, correlationID, reason string) {
+        if !dryRun {
+            s.logRowFailure(importID, line, correlationID, reason)
+        }
+        addResult(jobResult{lineNumber: line, correlationID: correlationID, record: record, message: reason})
+    }
+    // finish attaches the failures, keeping the full list as a report when it overflowed
+    finish := func() Summary {
+        summary.Failures = failures.inline
+        summary.FailuresTruncated = failures.total > len(failures.inline)
+        path, err := failures.close()
+        if err != nil {
+            s.log.Error("Failed to write user import failure report", logging.Fields{logging.FieldError: err, "import_id": importID.String()})
+        } else if path != "" {
+            s.importReports.add(ownerID, importID, path)
+        }
+        return summary
+    }
+    emailLines := make(map[string]int)
+
     // Feed jobs in THIS goroutine (no results writes here)
     line := 1 // header
     for {
@@ -90,13 +205,24 @@ func (s *UserService) ImportUsers(ctx context.Context, file io.Reader) (Summary,
         if err == io.EOF {
             break
         }
+        correlationID := rowCorrelationID(importID, line)
         if err != nil {
-            // Malformed CSV row: record failure locally (don't send to results)
-            summary.Failed++
-            summary.Failures = append(summary.Failures, FailedRecord{
-                Record: []string{"malformed row"},
-                Reason: fmt.Sprintf("Line %d: %v", line, err),
-            })
+            failLocally([]string{"malformed row"}, line, correlationID, err.Error())
+            continue
+        }
+        if err := validateRecord(record); err != nil {
+            failLocally(record, line, correlationID, err.Error())
+            continue
+        }
+        email := strings.ToLower(record[1])
+        if first, seen := emailLines[email]; seen {
+            failLocally(record, line, correlationID, fmt.Sprintf("email %s already appears on line %d", record[1], first))
+            continue
+        }
+        emailLines[email] = line
+        if dryRun {
+            processed.Add(1)
+            summary.Succeeded++
             continue
         }
 
@@ -106,139 +232,109 @@ func (s *UserService) ImportUsers(ctx context.Context, file io.Reader) (Summary,
             close(jobs)
             // Drain whatever results are pending before returning
             for r := range results {
-                if r.success {
-                    summary.Succeeded++
-                } else {
-                    summary.Failed++
-                    summary.Failures = append(summary.Failures, FailedRecord{
-                        Record: r.record,
-                        Reason: fmt.Sprintf("Line %d: %s", r.lineNumber, r.message),
-                    })
-                }
+                addResult(r)
             }
-            return summary, ctx.Err()
+            return finish(), ctx.Err()
 
-        case jobs <- userJob{lineNumber: line, record: record}:
+        case jobs <- userJob{lineNumber: line, correlationID: correlationID, record: record}:
         }
     }
     close(jobs)
 
     // Collect worker results until results is closed by the waiter goroutine
     for r := range results {
-        if r.success {
-            summary.Succeeded++
-        } else {
-            summary.Failed++
-            summary.Failures = append(summary.Failures, FailedRecord{
-                Record: r.record,
-                Reason: fmt.Sprintf("Line %d: %s", r.lineNumber, r.message),
-            })
-        }
+        addResult(r)
     }
 
-    return summary, nil
+    return finish(), nil
+}
+
+// countRows returns the number of data rows in the CSV, or ErrTooManyImportRows when
+// there are more than maxRows, then rewinds the file for the import itself.
+func (s *UserService) countRows(file io.ReadSeeker) (int, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows := -1 // header
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return 0, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if rows++; rows > s.maxRows {
+			return 0, ErrTooManyImportRows
+		}
+	}
+	_, err := file.Seek(0, io.SeekStart)
+	return max(rows, 0), err
 }
 
 
 // worker processes jobs from the jobs channel.
-func (s *UserService) worker(ctx context.Context, jobs <-chan userJob, results chan<- jobResult, wg *sync.WaitGroup) {
+func (s *UserService) worker(ctx context.Context, importID uuid.UUID, jobs <-chan userJob, results chan<- jobResult, wg *sync.WaitGroup) {
 	defer wg.Done() 
 	for job := range jobs {
 		if ctx.Err() != nil {
-			results <- jobResult{success: false, lineNumber: job.lineNumber, record: job.record, message: "Request canceled"}
+			results <- jobResult{success: false, lineNumber: job.lineNumber, correlationID: job.correlationID, record: job.record, message: "Request canceled"}
 			continue
 		}
-		err := s.callCreateUserMutation(ctx, job.record)
+		err := s.callCreateUserMutation(userclient.WithCorrelationID(ctx, job.correlationID), job.record)
 		if err != nil {
-			results <- jobResult{success: false, lineNumber: job.lineNumber, record: job.record, message: err.Error()}
+			s.logRowFailure(importID, job.lineNumber, job.correlationID, err.Error())
+			results <- jobResult{success: false, lineNumber: job.lineNumber, correlationID: job.correlationID, record: job.record, message: err.Error()}
 		} else {
-			results <- jobResult{success: true, lineNumber: job.lineNumber, record: job.record, message: "User created"}
+			results <- jobResult{success: true, lineNumber: job.lineNumber, correlationID: job.correlationID, record: job.record, message: "User created"}
 		}
 	}
 }
 
-// callCreateUserMutation sends a GraphQL mutation with retries and context handling.
-func (s *UserService) callCreateUserMutation(ctx context.Context, record []string) error {
-    userServiceURL := os.Getenv("USER_SERVICE_URL")
-    if userServiceURL == "" {
-        userServiceURL = "http://localhost:4000/users"
-    }
-    if len(record) < 4 {
-        return fmt.Errorf("invalid record: not enough columns")
-    }
-
-    payload := map[string]any{
-        "query": `mutation CreateUser($input: CreateUserInput!) {
-                    createUser(input: $input) { success errors }
-                  }`,
-        "variables": map[string]any{
-            "input": map[string]any{
-                "username": record[0],
-                "email":    record[1],
-                "password": record[2],
-                "role":     record[3],
-            },
-        },
-    }
-    jsonData, err := json.Marshal(payload)
-    if err != nil { return fmt.Errorf("failed to marshal query: %w", err) }
-
-    client := &http.Client{ Timeout: 15 * time.Second } // ⬅ timeout
-    maxRetries := 3
-
-    for attempt := 1; attempt <= maxRetries; attempt++ {
-        select {
-        case <-ctx.Done():
-            return ctx.Err()
-        default:
-        }
-
-        req, err := http.NewRequestWithContext(ctx, http.MethodPost, userServiceURL, bytes.NewBuffer(jsonData))
-        if err != nil { return err }
-        req.Header.Set("Content-Type", "application/json")
+func (s *UserService) logRowFailure(importID uuid.UUID, line int, correlationID, reason string) {
+	s.log.Warn("User import row failed", logging.Fields{
+		logging.FieldError: reason,
+		"import_id":        importID.String(),
+		"line":             line,
+		"correlation_id":   correlationID,
+	})
+}
 
-        resp, err := client.Do(req)
-        if err != nil {
-            if attempt == maxRetries { return fmt.Errorf("user service connection error after %d attempts: %w", maxRetries, err) }
-            time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
-            continue
-        }
+// importRoles are the roles an imported user may have; the user service upper-cases them.
+var importRoles = map[string]bool{"MANAGER": true, "MEMBER": true}
 
-        func() {
-            defer resp.Body.Close()
+// minImportPasswordLength mirrors the user service's minimum password length.
+const minImportPasswordLength = 8
 
-            if resp.StatusCode >= 400 {
-                body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
-                err = fmt.Errorf("user service HTTP %d: %s", resp.StatusCode, string(body))
-                return
-            }
-
-            var result struct {
-                Data struct {
-                    CreateUser struct {
-                        Success bool     `json:"success"`
-                        Errors  []string `json:"errors"`
-                    } `json:"createUser"`
-                } `json:"data"`
-                Errors []struct {
-                    Message string `json:"message"`
-                } `json:"errors"`
-            }
-            if de := json.NewDecoder(resp.Body).Decode(&result); de != nil {
-                err = fmt.Errorf("failed to decode response: %w", de); return
-            }
-            if len(result.Errors) > 0 {
-                err = fmt.Errorf("GraphQL error: %s", result.Errors[0].Message); return
-            }
-            if !result.Data.CreateUser.Success {
-                err = fmt.Errorf("API error: %v", result.Data.CreateUser.Errors); return
-            }
-            err = nil
-        }()
+// validateRecord checks a CSV record (username, email, password, role) the way the user
+// service would, so dry runs and real imports reject the same rows.
+func validateRecord(record []string) error {
+	if len(record) < 4 {
+		return fmt.Errorf("invalid record: not enough columns")
+	}
+	if strings.TrimSpace(record[0]) == "" {
+		return fmt.Errorf("invalid record: username is empty")
+	}
+	if address, err := mail.ParseAddress(record[1]); err != nil || address.Address != record[1] {
+		return fmt.Errorf("invalid record: %q is not a valid email", record[1])
+	}
+	if len(record[2]) < minImportPasswordLength {
+		return fmt.Errorf("invalid record: password must be at least %d characters", minImportPasswordLength)
+	}
+	if !importRoles[strings.ToUpper(record[3])] {
+		return fmt.Errorf("invalid record: role must be MANAGER or MEMBER")
+	}
+	return nil
+}
 
-        if err == nil { return nil }
-        if attempt == maxRetries { return err }
-        time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
-    }
-    return fmt.Errorf("unexpected error in retry loop")
+// callCreateUserMutation creates the user described by a record that passed
+// validateRecord. Retries and the circuit breaker are handled by the shared user-service
+// client.
+func (s *UserService) callCreateUserMutation(ctx context.Context, record []string) error {
+	return s.users.CreateUser(ctx, userclient.CreateUserInput{
+		Username: record[0],
+		Email:    record[1],
+		Password: record[2],
+		Role:     record[3],
+	})
 }
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrWebhookAddressBlocked   = errors.New("webhook URL points to a loopback, private, link-local, multicast or reserved address")
+	ErrWebhookHostUnresolvable = errors.New("webhook host could not be resolved")
+)
+
+var (
+	// "This network", which some stacks route to the host itself.
+	thisNetwork = netip.MustParsePrefix("0.0.0.0/8")
+	// Carrier-grade NAT space, used by some clouds and VPNs for internal addresses.
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+	limitedBroadcast   = netip.MustParseAddr("255.255.255.255")
+)
+
+// blockedWebhookAddress reports whether addr is one a webhook must not reach: anything
+// on the host or inside the cluster, including the cloud metadata service, and
+// multicast or broadcast addresses.
+func blockedWebhookAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() ||
+		thisNetwork.Contains(addr) ||
+		sharedAddressSpace.Contains(addr) ||
+		addr == limitedBroadcast
+}
+
+// checkWebhookHost rejects a host that is, or resolves to, a blocked address. It is only
+// a first check: DNS can be re-pointed later, so the dialer checks again on every connection.
+func checkWebhookHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if blockedWebhookAddress(addr) {
+			return ErrWebhookAddressBlocked
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return ErrWebhookHostUnresolvable
+	}
+	for _, addr := range addrs {
+		if blockedWebhookAddress(addr) {
+			return ErrWebhookAddressBlocked
+		}
+	}
+	return nil
+}
+
+// webhookDialControl runs after the host is resolved and before each connection is
+// made, so it sees the address actually dialed.
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if blockedWebhookAddress(addrPort.Addr()) {
+		return ErrWebhookAddressBlocked
+	}
+	return nil
+}
+
+// newWebhookTransport is http.DefaultTransport without a proxy, which would be dialed in
+// place of the receiver, and with blocked addresses refused at dial time.
+func newWebhookTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   webhookDialControl,
+	}).DialContext
+	return transport
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"seta-pkg/logging"
+	"seta-pkg/tracing"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/models"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	kafkago "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// webhookConsumerGroup is shared by every seta-service instance, so each event is fanned
+// out to webhooks once.
+const webhookConsumerGroup = "seta-webhooks"
+
+// webhookRetryBase is the wait before the second attempt; it doubles on every retry.
+const webhookRetryBase = 30 * time.Second
+
+var webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_deliveries_total",
+	Help: "Webhook delivery attempts by result (delivered, retried, failed).",
+}, []string{"result"})
+
+var webhookQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "webhook_delivery_queue_depth",
+	Help: "Webhook deliveries queued, including those waiting for a retry.",
+})
+
+// webhookEvent is the body posted to a webhook. It only carries IDs; receivers fetch the
+// content they need through the API.
+type webhookEvent struct {
+	DeliveryID   string    `json:"deliveryId"`
+	WebhookID    string    `json:"webhookId"`
+	EventType    string    `json:"eventType"`
+	FolderID     string    `json:"folderId"`
+	AssetType    string    `json:"assetType"`
+	AssetID      string    `json:"assetId"`
+	AssetIDs     []string  `json:"assetIds,omitempty"`
+	ActionBy     string    `json:"actionBy"`
+	TargetUserID string    `json:"targetUserId,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// WebhookDispatcher consumes asset.changes, queues a delivery for every enabled webhook of
+// the folder an event belongs to or of a folder above it, and posts the queued deliveries.
+//
+// Each POST carries X-Seta-Timestamp and X-Seta-Signature: "sha256=" followed by the hex
+// HMAC-SHA256 of "<timestamp>.<body>" under the webhook secret. Anything but a 2xx is a
+// failure and is retried with doubling backoff up to WEBHOOK_MAX_ATTEMPTS (default 8)
+// times. A webhook whose last WEBHOOK_DISABLE_AFTER (default 10) deliveries all failed is
+// disabled. Requests time out after WEBHOOK_TIMEOUT_MS (default 5000), redirects are
+// not followed and connections to addresses blockedWebhookAddress refuses are not made,
+// whatever the host resolved to at registration. Deliveries are at least once and not
+// ordered; deliveryId is stable across retries so receivers can deduplicate.
+type WebhookDispatcher struct {
+	db           *gorm.DB
+	log          logging.Logger
+	client       *http.Client
+	interval     time.Duration
+	batchSize    int
+	maxAttempts  int
+	disableAfter int
+}
+
+func NewWebhookDispatcher(db *gorm.DB, log logging.Logger) *WebhookDispatcher {
+	timeout := 5 * time.Second
+	if v, _ := strconv.Atoi(os.Getenv("WEBHOOK_TIMEOUT_MS")); v > 0 {
+		timeout = time.Duration(v) * time.Millisecond
+	}
+	maxAttempts := 8
+	if v, _ := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); v > 0 {
+		maxAttempts = v
+	}
+	disableAfter := 10
+	if v, _ := strconv.Atoi(os.Getenv("WEBHOOK_DISABLE_AFTER")); v > 0 {
+		disableAfter = v
+	}
+	return &WebhookDispatcher{
+		db:  db,
+		log: log,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: newWebhookTransport(),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		interval:     time.Second,
+		batchSize:    20,
+		maxAttempts:  maxAttempts,
+		disableAfter: disableAfter,
+	}
+}
+
+// Run consumes and delivers until ctx is done, and returns once both have stopped.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		d.consume(ctx)
+	}()
+	defer func() { <-consumed }()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		if err := d.deliverDue(ctx); err != nil {
+			d.log.Error("Failed to deliver webhooks", logging.Err(err))
+		}
+		if depth, err := WebhookQueueDepth(ctx, d.db); err == nil {
+			webhookQueueDepth.Set(float64(depth))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WebhookQueueDepth counts the webhook deliveries queued, including those waiting for a retry.
+func WebhookQueueDepth(ctx context.Context, db *gorm.DB) (int64, error) {
+	var depth int64
+	err := db.WithContext(ctx).Model(&models.WebhookDelivery{}).Count(&depth).Error
+	return depth, err
+}
+
+func (d *WebhookDispatcher) consume(ctx context.Context) {
+	producer := kafka.ConfigFromEnv()
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers: producer.Brokers,
+		GroupID: webhookConsumerGroup,
+		Topic:   producer.AssetTopic,
+	})
+	defer reader.Close()
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			d.log.Error("Failed to read asset change for webhooks", logging.Err(err))
+			time.Sleep(d.interval)
+			continue
+		}
+
+		// The offset is only committed once the deliveries are queued.
+		msgCtx, span := tracing.StartProcess(ctx, msg, webhookConsumerGroup)
+		queued := d.queueDeliveries(msgCtx, msg)
+		span.End()
+		if !queued {
+			return
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			d.log.Warn("Failed to commit webhook consumer offset", logging.Err(err))
+		}
+	}
+}
+
+// queueDeliveries fans msg out, retrying until it succeeds. It reports false if ctx ends first.
+func (d *WebhookDispatcher) queueDeliveries(ctx context.Context, msg kafkago.Message) bool {
+	for err := d.fanOut(ctx, msg); err != nil; err = d.fanOut(ctx, msg) {
+		d.log.Error("Failed to queue webhook deliveries, will retry", logging.Fields{
+			logging.FieldError:     err,
+			logging.FieldRequestID: kafka.RequestID(msg),
+			"kafka_offset":         msg.Offset,
+		})
+		trace.SpanFromContext(ctx).RecordError(err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(d.interval):
+		}
+	}
+	return true
+}
+
+// fanOut queues a delivery of msg for each enabled webhook whose filter matches it.
+// Events that fail validation are logged and skipped.
+func (d *WebhookDispatcher) fanOut(ctx context.Context, msg kafkago.Message) error {
+	var event kafka.EventPayload
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		d.log.Warn("Skipping undecodable asset change", logging.Fields{
+			logging.FieldError:     err,
+			logging.FieldRequestID: kafka.RequestID(msg),
+			"kafka_offset":         msg.Offset,
+		})
+		return nil
+	}
+	if err := event.Validate(); err != nil {
+		d.log.Warn("Skipping invalid asset change", logging.Fields{
+			logging.FieldError:     err,
+			logging.FieldEventType: event.EventType,
+			logging.FieldRequestID: kafka.RequestID(msg),
+			"kafka_offset":         msg.Offset,
+		})
+		return nil
+	}
+	if !WebhookEventTypes[event.EventType] || event.Synthetic {
+		return nil
+	}
+	folderID := event.AssetID
+	if event.AssetType == "note" {
+		folderID = event.ParentID // empty on events produced before parentId existed
+	}
+	if folderID == "" {
+		return nil
+	}
+	// Validate does not check ID formats, and a malformed one would fail the query below
+	// on every retry and hold up the consumer group.
+	folderUUID, err := uuid.Parse(folderID)
+	if err != nil {
+		d.log.Warn("Skipping asset change with a malformed folder ID", logging.Fields{
+			logging.FieldError:     err,
+			logging.FieldEventType: event.EventType,
+			logging.FieldRequestID: kafka.RequestID(msg),
+			"folder_id":            folderID,
+			"kafka_offset":         msg.Offset,
+		})
+		return nil
+	}
+
+	folderIDs, err := d.watchingFolders(ctx, event, folderUUID)
+	if err != nil {
+		return err
+	}
+	var webhooks []models.FolderWebhook
+	if err := d.db.WithContext(ctx).Where("folder_id IN ? AND disabled_at IS NULL", folderIDs).Find(&webhooks).Error; err != nil {
+		return err
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if filter := webhookFilter(webhook); len(filter) > 0 && !slices.Contains(filter, event.EventType) {
+			continue
+		}
+		// Derived from the message position, so a redelivered message keeps its IDs.
+		deliveryID := uuid.NewSHA1(webhook.WebhookID, []byte(fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)))
+		body, err := json.Marshal(webhookEvent{
+			DeliveryID:   deliveryID.String(),
+			WebhookID:    webhook.WebhookID.String(),
+			EventType:    event.EventType,
+			FolderID:     folderID,
+			AssetType:    event.AssetType,
+			AssetID:      event.AssetID,
+			AssetIDs:     event.AssetIDs,
+			ActionBy:     event.ActionBy,
+			TargetUserID: event.TargetUserID,
+			Timestamp:    event.Timestamp,
+		})
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{WebhookID: webhook.WebhookID, Payload: string(body)})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).Create(&deliveries).Error
+}
+
+// watchingFolders returns the folders whose webhooks receive an event of folderID: the
+// folder and every folder above it. A deleted folder is no longer in the tree, so a
+// folder event walks up from its parent instead.
+func (d *WebhookDispatcher) watchingFolders(ctx context.Context, event kafka.EventPayload, folderID uuid.UUID) ([]uuid.UUID, error) {
+	db := d.db.WithContext(ctx)
+	chain, err := FolderAncestors(db, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 && event.AssetType == "folder" && event.ParentID != "" {
+		if parentID, err := uuid.Parse(event.ParentID); err == nil {
+			if chain, err = FolderAncestors(db, parentID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	folderIDs := []uuid.UUID{folderID}
+	for _, folder := range chain {
+		if folder.FolderID != folderID {
+			folderIDs = append(folderIDs, folder.FolderID)
+		}
+	}
+	return folderIDs, nil
+}
+
+// claimDueSQL takes due deliveries and pushes their next attempt out past the request
+// timeout, so another instance does not post them at the same time.
+const claimDueSQL = `
+UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => @lease)
+WHERE id IN (
+	SELECT id FROM webhook_deliveries WHERE next_attempt_at <= NOW()
+	ORDER BY id LIMIT @limit FOR UPDATE SKIP LOCKED
+)
+RETURNING *`
+
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) error {
+	var due []models.WebhookDelivery
+	lease := (2 * d.client.Timeout).Seconds()
+	if err := d.db.WithContext(ctx).Raw(claimDueSQL, map[string]any{"lease": lease, "limit": d.batchSize}).Scan(&due).Error; err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(due))
+	for i, delivery := range due {
+		ids[i] = delivery.WebhookID
+	}
+	var webhooks []models.FolderWebhook
+	if err := d.db.WithContext(ctx).Where("webhook_id IN ?", ids).Find(&webhooks).Error; err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]models.FolderWebhook, len(webhooks))
+	for _, webhook := range webhooks {
+		byID[webhook.WebhookID] = webhook
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range due {
+		webhook, ok := byID[delivery.WebhookID]
+		if !ok || webhook.DisabledAt != nil {
+			d.db.WithContext(ctx).Delete(&delivery)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, webhook, delivery)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, webhook models.FolderWebhook, delivery models.WebhookDelivery) {
+	err := d.post(ctx, webhook, delivery)
+	now := time.Now()
+	db := d.db.WithContext(ctx)
+
+	if err == nil {
+		webhookDeliveries.WithLabelValues("delivered").Inc()
+		db.Delete(&delivery)
+		db.Model(&webhook).Updates(map[string]any{
+			"delivered_count":      gorm.Expr("delivered_count + 1"),
+			"consecutive_failures": 0,
+			"last_delivery_at":     now,
+			"last_error":           nil,
+		})
+		return
+	}
+
+	message := err.Error()
+	if delivery.Attempts < d.maxAttempts {
+		webhookDeliveries.WithLabelValues("retried").Inc()
+		backoff := webhookRetryBase << min(delivery.Attempts-1, 20)
+		db.Model(&delivery).Update("next_attempt_at", now.Add(backoff))
+		db.Model(&webhook).Update("last_error", message)
+		return
+	}
+
+	webhookDeliveries.WithLabelValues("failed").Inc()
+	db.Delete(&delivery)
+	db.Model(&webhook).Updates(map[string]any{
+		"failed_count":         gorm.Expr("failed_count + 1"),
+		"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		"last_error":           message,
+		"disabled_at":          gorm.Expr("CASE WHEN consecutive_failures + 1 >= ? THEN NOW() ELSE disabled_at END", d.disableAfter),
+	})
+	fields := logging.Fields{
+		logging.FieldError: message,
+		"webhook_id":       webhook.WebhookID.String(),
+		"attempts":         delivery.Attempts,
+	}
+	if webhook.ConsecutiveFailures+1 >= d.disableAfter {
+		d.log.Warn("Disabled webhook after repeated delivery failures", fields)
+	} else {
+		d.log.Warn("Gave up on webhook delivery", fields)
+	}
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, webhook models.FolderWebhook, delivery models.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Seta-Timestamp", timestamp)
+	req.Header.Set("X-Seta-Signature", "sha256="+signWebhook(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" under secret, as sent in
+// X-Seta-Signature.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
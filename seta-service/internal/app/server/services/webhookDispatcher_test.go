@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"seta-pkg/logging"
+	"seta/internal/pkg/kafka"
+	"seta/internal/pkg/models"
+	"seta/internal/pkg/testdb"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	kafkago "github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
+)
+
+func TestBlockedWebhookAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"fd00::1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"::", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"224.0.0.1", true},
+		{"239.255.255.250", true},
+		{"ff02::1", true},
+		{"ff05::2", true},
+		{"255.255.255.255", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"93.184.216.34", false},
+		{"100.63.255.255", false},
+		{"100.128.0.1", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+	}
+	for _, tt := range tests {
+		if got := blockedWebhookAddress(netip.MustParseAddr(tt.addr)); got != tt.blocked {
+			t.Errorf("blockedWebhookAddress(%s) = %v, want %v", tt.addr, got, tt.blocked)
+		}
+	}
+}
+
+// newTestWebhookDispatcher posts with a plain client: the receivers of these tests listen
+// on loopback, which the dispatcher's own transport refuses.
+func newTestWebhookDispatcher(db *gorm.DB) *WebhookDispatcher {
+	d := NewWebhookDispatcher(db, logging.Nop())
+	d.client = &http.Client{Timeout: time.Second}
+	return d
+}
+
+func createWebhook(t *testing.T, db *gorm.DB, folder models.Folder, url, eventTypes string) models.FolderWebhook {
+	t.Helper()
+	webhook := models.FolderWebhook{FolderID: folder.FolderID, URL: url, Secret: "s3cret", EventTypes: eventTypes, CreatedBy: folder.OwnerID}
+	if err := db.Create(&webhook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	return webhook
+}
+
+var webhookTestOffset atomic.Int64
+
+// fanOutEvent fans event out as if it was read from asset.changes.
+func fanOutEvent(t *testing.T, d *WebhookDispatcher, event kafka.EventPayload) {
+	t.Helper()
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("encode event: %v", err)
+	}
+	msg := kafkago.Message{Topic: kafka.AssetChangesTopic, Offset: webhookTestOffset.Add(1), Value: value}
+	if err := d.fanOut(context.Background(), msg); err != nil {
+		t.Fatalf("fanOut: %v", err)
+	}
+}
+
+func queuedDeliveries(t *testing.T, db *gorm.DB, webhook models.FolderWebhook) []models.WebhookDelivery {
+	t.Helper()
+	var deliveries []models.WebhookDelivery
+	if err := db.Where("webhook_id = ?", webhook.WebhookID).Order("id").Find(&deliveries).Error; err != nil {
+		t.Fatalf("list deliveries: %v", err)
+	}
+	return deliveries
+}
+
+func reloadWebhook(t *testing.T, db *gorm.DB, webhook models.FolderWebhook) models.FolderWebhook {
+	t.Helper()
+	if err := db.First(&webhook, "webhook_id = ?", webhook.WebhookID).Error; err != nil {
+		t.Fatalf("reload webhook: %v", err)
+	}
+	return webhook
+}
+
+func TestWebhookFanOutFiltersEventTypes(t *testing.T) {
+	db := testdb.Open(t)
+	d := newTestWebhookDispatcher(db)
+	owner := uuid.New()
+	folder := createFolder(t, db, models.Folder{OwnerID: owner})
+	note := createNote(t, db, models.Note{FolderID: folder.FolderID, OwnerID: owner})
+
+	everything := createWebhook(t, db, folder, "https://example.com/all", "")
+	updates := createWebhook(t, db, folder, "https://example.com/updates", "NOTE_UPDATED,NOTE_DELETED")
+	shares := createWebhook(t, db, folder, "https://example.com/shares", "NOTE_SHARED")
+	disabled := createWebhook(t, db, folder, "https://example.com/disabled", "")
+	if err := db.Model(&disabled).Update("disabled_at", time.Now()).Error; err != nil {
+		t.Fatalf("disable webhook: %v", err)
+	}
+
+	fanOutEvent(t, d, kafka.NewNoteEvent("NOTE_UPDATED", note, owner))
+
+	for _, want := range []struct {
+		webhook    models.FolderWebhook
+		deliveries int
+	}{{everything, 1}, {updates, 1}, {shares, 0}, {disabled, 0}} {
+		if got := len(queuedDeliveries(t, db, want.webhook)); got != want.deliveries {
+			t.Errorf("webhook %s got %d deliveries, want %d", want.webhook.URL, got, want.deliveries)
+		}
+	}
+}
+
+func TestWebhookFanOutReachesAncestorFolders(t *testing.T) {
+	db := testdb.Open(t)
+	d := newTestWebhookDispatcher(db)
+	owner := uuid.New()
+	root := createFolder(t, db, models.Folder{OwnerID: owner})
+	child := createFolder(t, db, models.Folder{OwnerID: owner, ParentFolderID: &root.FolderID})
+	grandchild := createFolder(t, db, models.Folder{OwnerID: owner, ParentFolderID: &child.FolderID})
+	note := createNote(t, db, models.Note{FolderID: grandchild.FolderID, OwnerID: owner})
+	webhook := createWebhook(t, db, root, "https://example.com/hook", "")
+	sibling := createWebhook(t, db, createFolder(t, db, models.Folder{OwnerID: owner}), "https://example.com/sibling", "")
+
+	fanOutEvent(t, d, kafka.NewNoteEvent("NOTE_CREATED", note, owner))
+
+	deliveries := queuedDeliveries(t, db, webhook)
+	if len(deliveries) != 1 {
+		t.Fatalf("root webhook got %d deliveries for a note two folders down, want 1", len(deliveries))
+	}
+	var payload webhookEvent
+	if err := json.Unmarshal([]byte(deliveries[0].Payload), &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload.FolderID != grandchild.FolderID.String() || payload.AssetID != note.NoteID.String() {
+		t.Errorf("payload folder %s, asset %s; want the note's own folder and the note", payload.FolderID, payload.AssetID)
+	}
+	if got := len(queuedDeliveries(t, db, sibling)); got != 0 {
+		t.Errorf("a webhook outside the chain got %d deliveries", got)
+	}
+
+	// The deleted folder is gone from the tree, the chain starts at its parent.
+	deleted := models.Folder{FolderID: uuid.New(), OwnerID: owner, ParentFolderID: &child.FolderID}
+	fanOutEvent(t, d, kafka.NewFolderEvent("FOLDER_DELETED", deleted, owner))
+	if got := len(queuedDeliveries(t, db, webhook)); got != 2 {
+		t.Errorf("root webhook has %d deliveries after a subfolder was deleted, want 2", got)
+	}
+}
+
+// webhookReceiver answers every delivery with status and keeps what it received.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, status int) (*webhookReceiver, *httptest.Server) {
+	t.Helper()
+	receiver := &webhookReceiver{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		receiver.mu.Unlock()
+		w.WriteHeader(receiver.status)
+	}))
+	t.Cleanup(srv.Close)
+	return receiver, srv
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func deliverDue(t *testing.T, d *WebhookDispatcher) {
+	t.Helper()
+	if err := d.deliverDue(context.Background()); err != nil {
+		t.Fatalf("deliverDue: %v", err)
+	}
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	db := testdb.Open(t)
+	d := newTestWebhookDispatcher(db)
+	receiver, srv := newWebhookReceiver(t, http.StatusNoContent)
+	owner := uuid.New()
+	folder := createFolder(t, db, models.Folder{OwnerID: owner})
+	webhook := createWebhook(t, db, folder, srv.URL, "")
+
+	fanOutEvent(t, d, kafka.NewFolderEvent("FOLDER_UPDATED", folder, owner))
+	deliverDue(t, d)
+
+	if receiver.count() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", receiver.count())
+	}
+	req, body := receiver.requests[0], receiver.bodies[0]
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(req.Header.Get("X-Seta-Timestamp") + "."))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.Header.Get("X-Seta-Signature") != want {
+		t.Errorf("X-Seta-Signature = %q, want %q", req.Header.Get("X-Seta-Signature"), want)
+	}
+	var payload webhookEvent
+	if err := json.Unmarshal(body, &payload); err != nil || payload.EventType != "FOLDER_UPDATED" || payload.WebhookID != webhook.WebhookID.String() {
+		t.Errorf("payload = %+v, %v; want the FOLDER_UPDATED event for the webhook", payload, err)
+	}
+
+	if got := len(queuedDeliveries(t, db, webhook)); got != 0 {
+		t.Errorf("%d deliveries still queued after a 204", got)
+	}
+	if reloaded := reloadWebhook(t, db, webhook); reloaded.DeliveredCount != 1 || reloaded.LastDeliveryAt == nil {
+		t.Errorf("delivered %d, last delivery %v; want the delivery recorded", reloaded.DeliveredCount, reloaded.LastDeliveryAt)
+	}
+}
+
+func TestWebhookDeliveryRetriesWithBackoff(t *testing.T) {
+	db := testdb.Open(t)
+	d := newTestWebhookDispatcher(db)
+	receiver, srv := newWebhookReceiver(t, http.StatusInternalServerError)
+	owner := uuid.New()
+	folder := createFolder(t, db, models.Folder{OwnerID: owner})
+	webhook := createWebhook(t, db, folder, srv.URL, "")
+	fanOutEvent(t, d, kafka.NewFolderEvent("FOLDER_UPDATED", folder, owner))
+
+	for attempt, backoff := range []time.Duration{webhookRetryBase, 2 * webhookRetryBase, 4 * webhookRetryBase} {
+		start := time.Now()
+		deliverDue(t, d)
+		deliveries := queuedDeliveries(t, db, webhook)
+		if len(deliveries) != 1 {
+			t.Fatalf("attempt %d: %d deliveries queued, want the failed one kept", attempt+1, len(deliveries))
+		}
+		delivery := deliveries[0]
+		if delivery.Attempts != attempt+1 {
+			t.Errorf("attempts = %d, want %d", delivery.Attempts, attempt+1)
+		}
+		if wait := delivery.NextAttemptAt.Sub(start); wait < backoff-time.Second || wait > backoff+5*time.Second {
+			t.Errorf("attempt %d: next attempt in %s, want about %s", attempt+1, wait, backoff)
+		}
+		// Make it due for the next round.
+		if err := db.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+			t.Fatalf("make delivery due: %v", err)
+		}
+	}
+	if receiver.count() != 3 {
+		t.Errorf("receiver got %d requests, want 3", receiver.count())
+	}
+	if reloaded := reloadWebhook(t, db, webhook); reloaded.LastError == nil || reloaded.DisabledAt != nil {
+		t.Errorf("last error %v, disabled at %v; want the error recorded and the webhook enabled", reloaded.LastError, reloaded.DisabledAt)
+	}
+}
+
+func TestWebhookDisabledAfterRepeatedFailures(t *testing.T) {
+	db := testdb.Open(t)
+	d := newTestWebhookDispatcher(db)
+	d.maxAttempts, d.disableAfter = 1, 3
+	_, srv := newWebhookReceiver(t, http.StatusBadGateway)
+	owner := uuid.New()
+	folder := createFolder(t, db, models.Folder{OwnerID: owner})
+	webhook := createWebhook(t, db, folder, srv.URL, "")
+
+	for i := 1; i <= 3; i++ {
+		fanOutEvent(t, d, kafka.NewFolderEvent("FOLDER_UPDATED", folder, owner))
+		deliverDue(t, d)
+		reloaded := reloadWebhook(t, db, webhook)
+		if reloaded.ConsecutiveFailures != i || reloaded.FailedCount != int64(i) {
+			t.Fatalf("after %d failures: consecutive %d, failed %d", i, reloaded.ConsecutiveFailures, reloaded.FailedCount)
+		}
+		if disabled := reloaded.DisabledAt != nil; disabled != (i == 3) {
+			t.Fatalf("after %d failures: disabled = %v", i, disabled)
+		}
+	}
+
+	fanOutEvent(t, d, kafka.NewFolderEvent("FOLDER_UPDATED", folder, owner))
+	if got := len(queuedDeliveries(t, db, webhook)); got != 0 {
+		t.Errorf("a disabled webhook got %d deliveries queued", got)
+	}
+}
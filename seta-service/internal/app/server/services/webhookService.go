@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"seta/internal/pkg/models"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTooManyWebhooks      = errors.New("folder already has the maximum number of webhooks")
+	ErrInvalidWebhookURL    = errors.New("webhook URL must be an absolute http or https URL")
+	ErrUnknownWebhookFilter = errors.New("unknown event type in webhook filter")
+)
+
+// WebhookEventTypes are the asset.changes events a folder webhook can receive.
+var WebhookEventTypes = map[string]bool{
+	"FOLDER_UPDATED":       true,
+	"FOLDER_DELETED":       true,
+	"FOLDER_NOTES_DELETED": true,
+	"FOLDER_SHARED":        true,
+	"FOLDER_UNSHARED":      true,
+	"NOTE_CREATED":         true,
+	"NOTE_UPDATED":         true,
+	"NOTE_DELETED":         true,
+	"NOTE_RESTORED":        true,
+	"NOTE_PURGED":          true,
+	"NOTE_SHARED":          true,
+	"NOTE_UNSHARED":        true,
+}
+
+// WebhookStatus is a webhook as shown to the folder owner: its filter, delivery
+// statistics and the number of deliveries still queued. The secret is never returned.
+type WebhookStatus struct {
+	models.FolderWebhook
+	EventTypes        []string `json:"eventTypes"`
+	PendingDeliveries int64    `json:"pendingDeliveries"`
+}
+
+// WebhookService registers and lists folder webhooks; WebhookDispatcher delivers them.
+type WebhookService struct {
+	db           *gorm.DB
+	maxPerFolder int
+}
+
+// NewWebhookService reads WEBHOOK_MAX_PER_FOLDER (default 5).
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	maxPerFolder := 5
+	if v, _ := strconv.Atoi(os.Getenv("WEBHOOK_MAX_PER_FOLDER")); v > 0 {
+		maxPerFolder = v
+	}
+	return &WebhookService{db: db, maxPerFolder: maxPerFolder}
+}
+
+// Register adds a webhook to the folder. An empty eventTypes filter subscribes to every
+// event in WebhookEventTypes. A URL whose host is or resolves to a loopback, private,
+// link-local, multicast or reserved address is refused with ErrWebhookAddressBlocked.
+func (s *WebhookService) Register(ctx context.Context, folderID, createdBy uuid.UUID, rawURL, secret string, eventTypes []string) (WebhookStatus, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return WebhookStatus{}, ErrInvalidWebhookURL
+	}
+	if err := checkWebhookHost(ctx, parsed.Hostname()); err != nil {
+		return WebhookStatus{}, err
+	}
+	for _, eventType := range eventTypes {
+		if !WebhookEventTypes[eventType] {
+			return WebhookStatus{}, ErrUnknownWebhookFilter
+		}
+	}
+
+	webhook := models.FolderWebhook{
+		FolderID:   folderID,
+		URL:        rawURL,
+		Secret:     secret,
+		EventTypes: strings.Join(eventTypes, ","),
+		CreatedBy:  createdBy,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the folder serializes concurrent registrations against the cap.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("folder_id").First(&models.Folder{}, "folder_id = ?", folderID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.FolderWebhook{}).Where("folder_id = ?", folderID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(s.maxPerFolder) {
+			return ErrTooManyWebhooks
+		}
+		return tx.Create(&webhook).Error
+	})
+	if err != nil {
+		return WebhookStatus{}, err
+	}
+	return newWebhookStatus(webhook, 0), nil
+}
+
+// List returns the folder's webhooks, oldest first.
+func (s *WebhookService) List(ctx context.Context, folderID uuid.UUID) ([]WebhookStatus, error) {
+	var webhooks []models.FolderWebhook
+	if err := s.db.WithContext(ctx).Where("folder_id = ?", folderID).Order("created_at, webhook_id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	statuses := make([]WebhookStatus, 0, len(webhooks))
+	if len(webhooks) == 0 {
+		return statuses, nil
+	}
+
+	ids := make([]uuid.UUID, len(webhooks))
+	for i, webhook := range webhooks {
+		ids[i] = webhook.WebhookID
+	}
+	var pending []struct {
+		WebhookID uuid.UUID
+		Count     int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Select("webhook_id, COUNT(*) AS count").
+		Where("webhook_id IN ?", ids).
+		Group("webhook_id").
+		Scan(&pending).Error; err != nil {
+		return nil, err
+	}
+	pendingByID := make(map[uuid.UUID]int64, len(pending))
+	for _, p := range pending {
+		pendingByID[p.WebhookID] = p.Count
+	}
+
+	for _, webhook := range webhooks {
+		statuses = append(statuses, newWebhookStatus(webhook, pendingByID[webhook.WebhookID]))
+	}
+	return statuses, nil
+}
+
+// Delete removes a webhook of the folder along with its queued deliveries. It returns
+// gorm.ErrRecordNotFound when the folder has no such webhook.
+func (s *WebhookService) Delete(ctx context.Context, folderID, webhookID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("folder_id = ? AND webhook_id = ?", folderID, webhookID).Delete(&models.FolderWebhook{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func newWebhookStatus(webhook models.FolderWebhook, pending int64) WebhookStatus {
+	return WebhookStatus{FolderWebhook: webhook, EventTypes: webhookFilter(webhook), PendingDeliveries: pending}
+}
+
+// webhookFilter splits the stored filter; an empty result subscribes to everything.
+func webhookFilter(webhook models.FolderWebhook) []string {
+	if webhook.EventTypes == "" {
+		return []string{}
+	}
+	return strings.Split(webhook.EventTypes, ",")
+}
@@ -0,0 +1,202 @@
+// Package auditclient reads what the auditing-service recorded, through its internal
+// HTTP API.
+package auditclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotConfigured is returned when INTERNAL_API_KEY is not set, without which the
+	// auditing-service answers nothing.
+	ErrNotConfigured = errors.New("audit service key is not configured")
+	// ErrUnavailable is returned when the auditing-service cannot be reached or fails.
+	ErrUnavailable = errors.New("audit service unavailable")
+)
+
+// Config holds the address and credentials of a Client.
+type Config struct {
+	URL            string
+	Timeout        time.Duration
+	InternalAPIKey string
+}
+
+// ConfigFromEnv reads AUDIT_SERVICE_URL (default http://localhost:8081) and
+// AUDIT_SERVICE_TIMEOUT_MS (default 5000), and takes InternalAPIKey from INTERNAL_API_KEY.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		URL:            os.Getenv("AUDIT_SERVICE_URL"),
+		Timeout:        5 * time.Second,
+		InternalAPIKey: os.Getenv("INTERNAL_API_KEY"),
+	}
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:8081"
+	}
+	if v, _ := strconv.Atoi(os.Getenv("AUDIT_SERVICE_TIMEOUT_MS")); v > 0 {
+		cfg.Timeout = time.Duration(v) * time.Millisecond
+	}
+	return cfg
+}
+
+// Client queries the auditing-service. It is safe for concurrent use.
+type Client struct {
+	cfg  Config
+	http *http.Client
+}
+
+// New creates a Client from cfg.
+func New(cfg Config) *Client {
+	return &Client{cfg: cfg, http: &http.Client{Timeout: cfg.Timeout}}
+}
+
+var (
+	shared     *Client
+	sharedOnce sync.Once
+)
+
+// Shared returns the process-wide client configured from the environment.
+func Shared() *Client {
+	sharedOnce.Do(func() {
+		shared = New(ConfigFromEnv())
+	})
+	return shared
+}
+
+// ActivityEvent is one recorded change of an asset. TargetUserID is the user a share or
+// unshare was about.
+type ActivityEvent struct {
+	EventType    string    `json:"eventType"`
+	ActionBy     string    `json:"actionBy"`
+	TargetUserID string    `json:"targetUserId,omitempty"`
+	OccurredAt   time.Time `json:"occurredAt"`
+}
+
+// ActivityPage is a page of an asset's events, newest first.
+type ActivityPage struct {
+	Events  []ActivityEvent `json:"events"`
+	HasMore bool            `json:"hasMore"`
+}
+
+// AssetActivity returns the events recorded for a folder or note, newest first, skipping
+// offset of them and leaving out those before since unless it is zero.
+func (c *Client) AssetActivity(ctx context.Context, assetType string, assetID uuid.UUID, since time.Time, limit, offset int) (ActivityPage, error) {
+	if c.cfg.InternalAPIKey == "" {
+		return ActivityPage{}, ErrNotConfigured
+	}
+
+	query := url.Values{}
+	query.Set("assetType", assetType)
+	query.Set("assetId", assetID.String())
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URL+"/internal/activity?"+query.Encode(), nil)
+	if err != nil {
+		return ActivityPage{}, err
+	}
+	req.Header.Set("X-Internal-API-Key", c.cfg.InternalAPIKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return ActivityPage{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		return ActivityPage{}, fmt.Errorf("%w: HTTP %d: %s", ErrUnavailable, resp.StatusCode, string(msg))
+	}
+
+	var page ActivityPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return ActivityPage{}, fmt.Errorf("%w: failed to decode response: %v", ErrUnavailable, err)
+	}
+	if page.Events == nil {
+		page.Events = make([]ActivityEvent, 0)
+	}
+	return page, nil
+}
+
+// StatsQuery is what an audit stats request counts. Zero From and To leave the range
+// open and a nil TeamID counts every team; TZ defaults to UTC.
+type StatsQuery struct {
+	GroupBy string
+	From    time.Time
+	To      time.Time
+	TeamID  *uuid.UUID
+	TZ      string
+}
+
+// StatsBucket is the number of events recorded for one key.
+type StatsBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// Stats is the event counts per bucket. Truncated is set when there were more buckets
+// than the auditing-service returns.
+type Stats struct {
+	GroupBy   string        `json:"groupBy"`
+	TZ        string        `json:"tz"`
+	Buckets   []StatsBucket `json:"buckets"`
+	Truncated bool          `json:"truncated"`
+}
+
+// AuditStats returns the number of recorded events per event type, actor or day.
+func (c *Client) AuditStats(ctx context.Context, q StatsQuery) (Stats, error) {
+	if c.cfg.InternalAPIKey == "" {
+		return Stats{}, ErrNotConfigured
+	}
+
+	query := url.Values{}
+	query.Set("groupBy", q.GroupBy)
+	if !q.From.IsZero() {
+		query.Set("from", q.From.UTC().Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.UTC().Format(time.RFC3339))
+	}
+	if q.TeamID != nil {
+		query.Set("teamId", q.TeamID.String())
+	}
+	if q.TZ != "" {
+		query.Set("tz", q.TZ)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URL+"/internal/audit/stats?"+query.Encode(), nil)
+	if err != nil {
+		return Stats{}, err
+	}
+	req.Header.Set("X-Internal-API-Key", c.cfg.InternalAPIKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		return Stats{}, fmt.Errorf("%w: HTTP %d: %s", ErrUnavailable, resp.StatusCode, string(msg))
+	}
+
+	var stats Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return Stats{}, fmt.Errorf("%w: failed to decode response: %v", ErrUnavailable, err)
+	}
+	if stats.Buckets == nil {
+		stats.Buckets = make([]StatsBucket, 0)
+	}
+	return stats, nil
+}
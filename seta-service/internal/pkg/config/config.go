@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,3 +15,83 @@ func LoadConfig() {
 		log.Println("No .env file found, using environment variables")
 	}
 }
+
+// defaults mirrors the fallbacks applied where each variable is read.
+var defaults = map[string]string{
+	"DATABASE_URL":                          "",
+	"KAFKA_BROKERS":                         "",
+	"KAFKA_TEAM_TOPIC":                      "team.activity",
+	"KAFKA_ASSET_TOPIC":                     "asset.changes",
+	"KAFKA_PRODUCER_MAX_ATTEMPTS":           "5",
+	"KAFKA_PRODUCE_TIMEOUT_MS":              "10000",
+	"USER_SERVICE_URL":                      "http://localhost:4000/users",
+	"USER_SERVICE_TIMEOUT_MS":               "5000",
+	"USER_SERVICE_MAX_RETRIES":              "3",
+	"USER_SERVICE_BREAKER_THRESHOLD":        "5",
+	"USER_SERVICE_BREAKER_COOLDOWN_SECONDS": "30",
+	"USER_SERVICE_EMAIL_CACHE_SECONDS":      "60",
+	"USER_IMPORT_WORKERS":                   "10",
+	"USER_IMPORT_MAX_UPLOAD_BYTES":          "10485760",
+	"USER_IMPORT_MAX_ROWS":                  "10000",
+	"USER_IMPORT_MAX_INLINE_FAILURES":       "1000",
+	"USER_IMPORT_REPORT_TTL_MINUTES":        "60",
+	"JWT_SECRET":                            "default-secret-key",
+	"JWT_EXPIRATION_HOURS":                  "72",
+	"AUTH_TOKEN_CACHE_SECONDS":              "60",
+	"AUTH_TOKEN_NEGATIVE_CACHE_SECONDS":     "5",
+	"AUTHZ_CACHE_SECONDS":                   "30",
+	"AUTHZ_CACHE_ENTRIES":                   "10000",
+	"INTERNAL_API_KEY":                      "",
+	"AUDIT_SERVICE_URL":                     "http://localhost:8081",
+	"AUDIT_SERVICE_TIMEOUT_MS":              "5000",
+	"AUDIT_EXPORT_SIGNING_KEY":              "",
+	"AUDIT_EXPORT_MIN_INTERVAL_SECONDS":     "60",
+	"OUTBOX_POLL_INTERVAL_MS":               "1000",
+	"OUTBOX_BATCH_SIZE":                     "100",
+	"OUTBOX_MAX_BACKOFF_SECONDS":            "300",
+	"OUTBOX_DRAIN_RATE_PER_SECOND":          "1000",
+	"WEBHOOK_MAX_PER_FOLDER":                "5",
+	"WEBHOOK_TIMEOUT_MS":                    "5000",
+	"WEBHOOK_MAX_ATTEMPTS":                  "8",
+	"WEBHOOK_DISABLE_AFTER":                 "10",
+	"EVENT_MAX_PAYLOAD_BYTES":               "32768",
+	"NOTE_BODY_COMPRESSION":                 "zstd",
+	"NOTE_BODY_COMPRESSION_THRESHOLD_BYTES": "4096",
+	"NOTE_TRASH_RETENTION_DAYS":             "30",
+	"NOTE_PURGE_INTERVAL_MINUTES":           "60",
+	"TEAM_STATS_CACHE_SECONDS":              "300",
+}
+
+// EffectiveSettings returns every environment-driven setting with its effective value.
+// Values are not masked here; callers exposing them must do so.
+func EffectiveSettings() map[string]string {
+	settings := make(map[string]string, len(defaults))
+	for key, fallback := range defaults {
+		if value := os.Getenv(key); value != "" {
+			settings[key] = value
+		} else {
+			settings[key] = fallback
+		}
+	}
+	return settings
+}
+
+// required lists the settings that have no usable default. Optional integrations such
+// as INTERNAL_API_KEY and AUDIT_EXPORT_SIGNING_KEY disable their endpoints instead.
+var required = []string{"DATABASE_URL", "KAFKA_BROKERS"}
+
+// Validate reports every required setting that is unset in one error, so a
+// misconfigured deployment fails at startup with the whole list instead of one
+// variable per restart or as errors at request time.
+func Validate() error {
+	var missing []string
+	for _, key := range required {
+		if os.Getenv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -1,42 +1,131 @@
 package errorHandling
 
 import (
+	"errors"
 	"net/http"
+	"seta-pkg/logging"
+	"seta/internal/pkg/i18n"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 // CustomError represents a custom error structure.
 type CustomError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code        int          `json:"code"`
+	Message     string       `json:"message"`
+	FieldErrors []FieldError `json:"fieldErrors,omitempty"`
+	// Details is returned as is, for clients to act on the error.
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// FieldError describes one invalid field of a request body.
+type FieldError struct {
+	Field   string   `json:"field"`
+	Rule    string   `json:"rule,omitempty"`
+	Message string   `json:"message"`
+	Allowed []string `json:"allowed,omitempty"`
+	// Param is the rule's parameter (a length limit, an expected type) used to translate Message.
+	Param string `json:"-"`
 }
 
 func (e *CustomError) Error() string {
+	if e == nil {
+		return "nil CustomError"
+	}
 	return e.Message
 }
 
-// ErrorHandler is a middleware to handle errors consistently.
-func ErrorHandler() gin.HandlerFunc {
+// ErrorBody is the "error" object of every error response:
+//
+//	{"error": {"code": "not_found", "message": "Note not found", "requestId": "..."}}
+//
+// Code is derived from the HTTP status, so clients can branch on it without parsing the
+// translated message. FieldErrors lists each invalid field of a request, and Details
+// what a client needs to recover, such as the current version of a note edited meanwhile.
+type ErrorBody struct {
+	Code        string         `json:"code"`
+	Message     string         `json:"message"`
+	RequestID   string         `json:"requestId,omitempty"`
+	FieldErrors []FieldError   `json:"fieldErrors,omitempty"`
+	Details     map[string]any `json:"details,omitempty"`
+}
+
+// ErrorHandler is a middleware to handle errors consistently. Handlers only call
+// c.Error; this writes the response as an ErrorBody. Messages are translated into the
+// language the Accept-Language header asks for, English by default. A
+// gorm.ErrRecordNotFound is a 404; any other error that is not a CustomError is a 500
+// whose cause is logged but not returned.
+func ErrorHandler(log logging.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next() // process request
 
 		// This part executes after the handler
 		if len(c.Errors) > 0 {
 			err := c.Errors.Last().Err
+			requestID := c.GetString("requestId")
 
 			// Log the error
-			log.Error().Err(err).Msg("An error occurred")
+			log.Error("An error occurred", logging.Fields{
+				logging.FieldError:     err,
+				logging.FieldRequestID: requestID,
+				"method":               c.Request.Method,
+				"path":                 c.Request.URL.Path,
+			})
 
-			// Check for our custom error type
-			if appErr, ok := err.(*CustomError); ok {
-				c.JSON(appErr.Code, gin.H{"error": appErr.Message})
+			// A streaming handler that failed midway has already sent its status and body.
+			if c.Writer.Written() {
 				return
 			}
 
-			// Handle other generic errors
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred"})
+			lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+			c.Header("Content-Language", lang)
+
+			appErr := asCustomError(err)
+			body := ErrorBody{
+				Code:      errorCode(appErr.Code),
+				Message:   i18n.Message(lang, appErr.Message),
+				RequestID: requestID,
+				Details:   appErr.Details,
+			}
+			if len(appErr.FieldErrors) > 0 {
+				body.FieldErrors = localizeFieldErrors(lang, appErr.FieldErrors)
+			}
+			c.JSON(appErr.Code, gin.H{"error": body})
 		}
 	}
 }
+
+// asCustomError returns the response for err. A nil *CustomError, or one without a
+// status, is treated as an unexpected error rather than dereferenced.
+func asCustomError(err error) *CustomError {
+	var appErr *CustomError
+	switch {
+	case errors.As(err, &appErr) && appErr != nil && appErr.Code != 0:
+		return appErr
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &CustomError{Code: http.StatusNotFound, Message: "Resource not found"}
+	default:
+		return &CustomError{Code: http.StatusInternalServerError, Message: "An unexpected error occurred"}
+	}
+}
+
+// errorCode is the machine-readable code of a status: its text in snake case, such as
+// "not_found" or "too_many_requests".
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(text, "-", "_"), " ", "_"))
+}
+
+func localizeFieldErrors(lang string, fieldErrors []FieldError) []FieldError {
+	localized := make([]FieldError, len(fieldErrors))
+	for i, fe := range fieldErrors {
+		fe.Message = i18n.FieldMessage(lang, fe.Rule, fe.Field, fe.Param, fe.Allowed, fe.Message)
+		localized[i] = fe
+	}
+	return localized
+}
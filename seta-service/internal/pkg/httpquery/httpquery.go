@@ -0,0 +1,204 @@
+// Package httpquery reads typed query parameters. A Query collects every bad parameter
+// instead of stopping at the first, and Err reports them together as one 422 with a
+// field error per parameter, the way utils.BindJSON reports a request body.
+//
+// An empty value (?limit=) is treated like a missing one. Anything else that does not
+// parse or is out of range is an error; nothing silently falls back to the default.
+package httpquery
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"seta/internal/pkg/errorHandling"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Query reads the parameters of one request.
+type Query struct {
+	values url.Values
+	errs   []errorHandling.FieldError
+}
+
+// New reads from values, usually c.Request.URL.Query().
+func New(values url.Values) *Query {
+	return &Query{values: values}
+}
+
+// Err returns nil when every parameter read so far was valid, otherwise a 422 listing
+// each bad parameter.
+func (q *Query) Err() error {
+	if len(q.errs) == 0 {
+		return nil
+	}
+	return &errorHandling.CustomError{
+		Code:        http.StatusUnprocessableEntity,
+		Message:     "Invalid query parameters",
+		FieldErrors: q.errs,
+	}
+}
+
+// Fail records a violation found by the caller, such as one between two parameters, so
+// it is reported with the others. param is the rule's parameter, if any.
+func (q *Query) Fail(name, rule, param, message string) {
+	q.errs = append(q.errs, errorHandling.FieldError{Field: name, Rule: rule, Param: param, Message: message})
+}
+
+func (q *Query) raw(name string) (string, bool) {
+	raw := q.values.Get(name)
+	return raw, raw != ""
+}
+
+func (q *Query) typeError(name, typeName string) {
+	q.errs = append(q.errs, errorHandling.FieldError{
+		Field:   name,
+		Rule:    "type",
+		Param:   typeName,
+		Message: fmt.Sprintf("%s must be of type %s", name, typeName),
+	})
+}
+
+// Int returns the parameter as an int between min and max inclusive, or def when absent.
+func (q *Query) Int(name string, def, min, max int) int {
+	raw, ok := q.raw(name)
+	if !ok {
+		return def
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		q.typeError(name, "integer")
+		return def
+	}
+	switch {
+	case value < min:
+		q.errs = append(q.errs, errorHandling.FieldError{
+			Field: name, Rule: "gte", Param: strconv.Itoa(min),
+			Message: fmt.Sprintf("%s must be at least %d", name, min),
+		})
+		return def
+	case value > max:
+		q.errs = append(q.errs, errorHandling.FieldError{
+			Field: name, Rule: "lte", Param: strconv.Itoa(max),
+			Message: fmt.Sprintf("%s must be at most %d", name, max),
+		})
+		return def
+	}
+	return value
+}
+
+// Bool returns the parameter as a bool (true, false, 1, 0 and the other forms of
+// strconv.ParseBool), or def when absent.
+func (q *Query) Bool(name string, def bool) bool {
+	raw, ok := q.raw(name)
+	if !ok {
+		return def
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		q.typeError(name, "boolean")
+		return def
+	}
+	return value
+}
+
+// Enum returns the parameter when it is one of allowed, or def when absent.
+func (q *Query) Enum(name, def string, allowed ...string) string {
+	raw, ok := q.raw(name)
+	if !ok {
+		return def
+	}
+	if !slices.Contains(allowed, raw) {
+		q.errs = append(q.errs, errorHandling.FieldError{
+			Field: name, Rule: "oneof", Allowed: allowed,
+			Message: fmt.Sprintf("%s must be one of: %s", name, strings.Join(allowed, ", ")),
+		})
+		return def
+	}
+	return raw
+}
+
+// Time returns the parameter as an RFC 3339 time in UTC, also accepting a plain
+// YYYY-MM-DD date for midnight UTC, and reports whether it was given.
+func (q *Query) Time(name string) (time.Time, bool) {
+	raw, ok := q.raw(name)
+	if !ok {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), true
+	}
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, true
+	}
+	q.typeError(name, "RFC 3339 time")
+	return time.Time{}, false
+}
+
+// UUID returns the parameter as a UUID and reports whether it was given.
+func (q *Query) UUID(name string) (uuid.UUID, bool) {
+	raw, ok := q.raw(name)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		q.typeError(name, "UUID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// Required records a violation when the parameter is absent; call it for parameters
+// without a default.
+func (q *Query) Required(name string) {
+	if _, ok := q.raw(name); !ok {
+		q.errs = append(q.errs, errorHandling.FieldError{
+			Field: name, Rule: "required",
+			Message: fmt.Sprintf("%s is required", name),
+		})
+	}
+}
+
+// PaginationParams is an offset page: ?limit and ?offset.
+type PaginationParams struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Pagination reads ?limit, between 1 and maxLimit and defaultLimit when absent, and
+// ?offset, at least 0.
+func (q *Query) Pagination(defaultLimit, maxLimit int) PaginationParams {
+	return PaginationParams{
+		Limit:  q.Int("limit", defaultLimit, 1, maxLimit),
+		Offset: q.Int("offset", 0, 0, math.MaxInt),
+	}
+}
+
+// SortParams is an ordering: ?sort=field for ascending, ?sort=-field for descending.
+type SortParams struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// Sort reads ?sort, whose field must be one of allowed, or returns def when absent.
+func (q *Query) Sort(def SortParams, allowed ...string) SortParams {
+	raw, ok := q.raw("sort")
+	if !ok {
+		return def
+	}
+	sort := SortParams{Field: strings.TrimPrefix(raw, "-"), Desc: strings.HasPrefix(raw, "-")}
+	if !slices.Contains(allowed, sort.Field) {
+		q.errs = append(q.errs, errorHandling.FieldError{
+			Field: "sort", Rule: "oneof", Allowed: allowed,
+			Message: fmt.Sprintf("sort must be one of: %s, optionally prefixed with -", strings.Join(allowed, ", ")),
+		})
+		return def
+	}
+	return sort
+}
@@ -0,0 +1,97 @@
+// Package i18n localizes the human-readable part of error responses. Messages are
+// looked up by their English text, so handlers keep writing plain English and the
+// ErrorHandler middleware translates on the way out.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Default is the language of the messages in the code, used when nothing better matches.
+const Default = "en"
+
+var missingTranslations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "i18n_missing_translations_total",
+	Help: "Error messages served in English because the requested language has no translation.",
+}, []string{"lang"})
+
+// catalogues maps a language to its translations of the English messages.
+var catalogues = map[string]map[string]string{
+	"vi": vi,
+}
+
+// fieldCatalogues maps a language to its templates for validation field errors, keyed by
+// rule, with "*" for any other rule. Templates may use {field}, {rule}, {param} and {allowed}.
+var fieldCatalogues = map[string]map[string]string{
+	"vi": viFields,
+}
+
+// Negotiate picks the supported language the Accept-Language header prefers, falling
+// back to Default. Regional variants match their base language ("vi-VN" is "vi").
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if q > 0 && (base == Default || catalogues[base] != nil) {
+			candidates = append(candidates, candidate{lang: base, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// Message translates an English message into lang. Messages without a translation are
+// returned unchanged and counted.
+func Message(lang, message string) string {
+	if lang == Default {
+		return message
+	}
+	if translated, ok := catalogues[lang][message]; ok {
+		return translated
+	}
+	missingTranslations.WithLabelValues(lang).Inc()
+	return message
+}
+
+// FieldMessage renders a validation error for field in lang from its rule and parameter.
+// fallback, the English message, is used when the language has no template for the rule.
+func FieldMessage(lang, rule, field, param string, allowed []string, fallback string) string {
+	if lang == Default {
+		return fallback
+	}
+	template, ok := fieldCatalogues[lang][rule]
+	if !ok {
+		template, ok = fieldCatalogues[lang]["*"]
+	}
+	if !ok {
+		missingTranslations.WithLabelValues(lang).Inc()
+		return fallback
+	}
+	return strings.NewReplacer(
+		"{field}", field,
+		"{rule}", rule,
+		"{param}", param,
+		"{allowed}", strings.Join(allowed, ", "),
+	).Replace(template)
+}
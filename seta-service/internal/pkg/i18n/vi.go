@@ -0,0 +1,216 @@
+package i18n
+
+// vi translates the error messages written in the handlers. Keys must match the English
+// text exactly; messages built at runtime fall back to English.
+var vi = map[string]string{
+	// Authentication and authorization
+	"Authorization header format must be Bearer {token}": "Header Authorization phải có dạng Bearer {token}",
+	"Authorization header is missing":                    "Thiếu header Authorization",
+	"Invalid token":                                      "Token không hợp lệ",
+	"Invalid user ID format in token":                    "Mã người dùng trong token không đúng định dạng",
+	"User not authenticated":                             "Người dùng chưa được xác thực",
+	"User ID not found in token":                         "Không tìm thấy mã người dùng trong token",
+	"User role not found in token":                       "Không tìm thấy vai trò người dùng trong token",
+	"Internal API is not configured":                     "API nội bộ chưa được cấu hình",
+	"Invalid internal API key":                           "Khóa API nội bộ không hợp lệ",
+	"You are not a manager of this team":                 "Bạn không phải là quản lý của nhóm này",
+	"You are not authorized for this action":             "Bạn không có quyền thực hiện thao tác này",
+	"You are not authorized to perform this action":      "Bạn không có quyền thực hiện hành động này",
+	"You are not authorized to use this template":        "Bạn không có quyền sử dụng mẫu này",
+	"You are not authorized to view these assets":        "Bạn không có quyền xem các tài nguyên này",
+	"You are not authorized to view these stats":         "Bạn không có quyền xem các số liệu này",
+	"You are not authorized to write to this folder":     "Bạn không có quyền ghi vào thư mục này",
+	"You are not on this team":                           "Bạn không thuộc nhóm này",
+	"You must be a lead manager to perform this action":  "Bạn phải là trưởng nhóm để thực hiện thao tác này",
+	"Only team managers can create team templates":       "Chỉ quản lý nhóm mới được tạo mẫu cho nhóm",
+
+	// Requests
+	"Request body is required":                          "Yêu cầu phải có nội dung",
+	"Invalid request body":                              "Nội dung yêu cầu không hợp lệ",
+	"Invalid query parameters":                          "Tham số truy vấn không hợp lệ",
+	"Invalid team ID":                                   "Mã nhóm không hợp lệ",
+	"Invalid cursor":                                    "Con trỏ phân trang không hợp lệ",
+	"Cursor has expired, restart from the first page":   "Con trỏ phân trang đã hết hạn, hãy tải lại từ trang đầu",
+	"Asset type must be folder or note":                 "Loại tài nguyên phải là folder hoặc note",
+	"Access must be read or write":                      "Quyền truy cập phải là read hoặc write",
+	"dryRun must be true or false":                      "dryRun phải là true hoặc false",
+	"async must be true or false":                       "async phải là true hoặc false",
+	"A dry run cannot be asynchronous":                  "Không thể chạy thử ở chế độ bất đồng bộ",
+	"Webhook URL must be an absolute http or https URL": "URL webhook phải là URL http hoặc https đầy đủ",
+	"Webhook URL must not point to an internal address": "URL webhook không được trỏ tới địa chỉ nội bộ",
+	"Webhook host could not be resolved":                "Không phân giải được máy chủ của webhook",
+	"Unknown event type in eventTypes":                  "eventTypes có loại sự kiện không hợp lệ",
+	"title is required":                                 "Tiêu đề là bắt buộc",
+	"tokenSha256 or userId is required":                 "Cần có tokenSha256 hoặc userId",
+	"Flag name must be at most 100 characters":          "Tên cờ tính năng tối đa 100 ký tự",
+	"Too many assets in one replay request (max 100)":   "Quá nhiều tài nguyên trong một yêu cầu phát lại (tối đa 100)",
+	"File not provided in 'file' form field":            "Chưa gửi tệp trong trường 'file' của biểu mẫu",
+	"Export file is too large":                          "Tệp xuất quá lớn",
+	"Failed to open uploaded file":                      "Không mở được tệp đã tải lên",
+	"Failed to read uploaded file":                      "Không đọc được tệp đã tải lên",
+	"An unexpected error occurred":                      "Đã xảy ra lỗi không mong muốn",
+
+	// Not found and conflicts
+	"Announcement not found":        "Không tìm thấy thông báo",
+	"Asset not found":               "Không tìm thấy tài nguyên",
+	"Deletion job not found":        "Không tìm thấy tác vụ xóa",
+	"Folder not found":              "Không tìm thấy thư mục",
+	"Note not found":                "Không tìm thấy ghi chú",
+	"note not found":                "Không tìm thấy ghi chú",
+	"Note not found in this folder": "Không tìm thấy ghi chú trong thư mục này",
+	"Note not found in trash":       "Không tìm thấy ghi chú trong thùng rác",
+	"Resource not found":            "Không tìm thấy tài nguyên được yêu cầu",
+	"Team not found":                "Không tìm thấy nhóm",
+	"Template not found":            "Không tìm thấy mẫu",
+	"Webhook not found":             "Không tìm thấy webhook",
+	"User not found":                "Không tìm thấy người dùng",
+	"template not found":            "Không tìm thấy mẫu",
+	"Sharing record not found for this user and folder": "Thư mục chưa được chia sẻ với người dùng này",
+	"Sharing record not found for this user and note":   "Ghi chú chưa được chia sẻ với người dùng này",
+	"Import failure report not found":                   "Không tìm thấy báo cáo lỗi nhập người dùng",
+	"Import job not found":                              "Không tìm thấy tác vụ nhập người dùng",
+	"Manager not found in this team":                    "Không tìm thấy quản lý này trong nhóm",
+	"Member not found in this team":                     "Không tìm thấy thành viên này trong nhóm",
+	"Folder is being deleted":                           "Thư mục đang được xóa",
+	"Audit export is not configured":                    "Chức năng xuất nhật ký kiểm toán chưa được cấu hình",
+	"User is already a member of this team":             "Người dùng đã là thành viên của nhóm này",
+	"User is already a manager of this team":            "Người dùng đã là quản lý của nhóm này",
+	"Folder already has the maximum number of webhooks": "Thư mục đã có số webhook tối đa",
+	"File exceeds the maximum upload size":              "Tệp vượt quá dung lượng tải lên tối đa",
+	"CSV has more rows than an import allows":           "Tệp CSV có nhiều dòng hơn mức cho phép của một lần nhập",
+
+	// Teams
+	"Exactly one manager must be designated as the lead (isLead: true).": "Phải có đúng một quản lý được chỉ định là trưởng nhóm (isLead: true).",
+	"The user creating the team must be included in the managers list.":  "Người tạo nhóm phải có trong danh sách quản lý.",
+	"Managers cannot leave a team; ask the lead manager to remove you":   "Quản lý không thể tự rời nhóm; hãy nhờ trưởng nhóm xóa bạn",
+	"The new lead must already be a manager of this team":                "Trưởng nhóm mới phải là quản lý của nhóm này",
+	"The last manager of a team cannot be removed":                       "Không thể xóa quản lý cuối cùng của nhóm",
+	"Removing the lead manager requires successorId naming the new lead": "Muốn xóa trưởng nhóm phải truyền successorId chỉ định trưởng nhóm mới",
+	"You are not a member of this team":                                  "Bạn không phải là thành viên của nhóm này",
+	"memberId is not a member of this team":                              "memberId không phải là thành viên của nhóm này",
+
+	// Folder tree
+	"A folder cannot be moved inside itself or its subfolders":      "Không thể chuyển thư mục vào chính nó hoặc thư mục con của nó",
+	"A subfolder is being deleted":                                  "Một thư mục con đang được xóa",
+	"Folder has subfolders; pass recursive=true to delete them too": "Thư mục có thư mục con; hãy truyền recursive=true để xóa cả các thư mục con",
+	"Folder would be nested too deep":                               "Thư mục sẽ bị lồng quá sâu",
+	"Nothing to update: pass name or parentFolderId":                "Không có gì để cập nhật: hãy truyền name hoặc parentFolderId",
+	"Only the folder owner can move it":                             "Chỉ chủ sở hữu thư mục mới được di chuyển thư mục",
+	"Parent folder is being deleted":                                "Thư mục cha đang được xóa",
+	"Parent folder not found":                                       "Không tìm thấy thư mục cha",
+	"You do not have write access to the parent folder":             "Bạn không có quyền ghi vào thư mục cha",
+
+	// Sharing
+	"No user has this email":                   "Không có người dùng nào có email này",
+	"Pass either userId or email, not both":    "Chỉ truyền userId hoặc email, không truyền cả hai",
+	"User service returned an invalid user ID": "Dịch vụ người dùng trả về ID người dùng không hợp lệ",
+	"userId or email is required":              "Cần có userId hoặc email",
+
+	// Note edits
+	"Note was changed by someone else; reload it and merge your edit": "Ghi chú đã được người khác thay đổi; hãy tải lại và gộp chỉnh sửa của bạn",
+	"Nothing to update: pass title or body":                           "Không có gì để cập nhật: hãy truyền title hoặc body",
+	"Send the note ETag or version in If-Match, or the version field": "Hãy gửi ETag hoặc phiên bản ghi chú trong If-Match, hoặc trường version",
+
+	// Activity
+	"Activity is not configured":            "Nhật ký hoạt động chưa được cấu hình",
+	"Activity is unavailable right now":     "Nhật ký hoạt động tạm thời không khả dụng",
+	"Audit stats are not configured":        "Thống kê kiểm toán chưa được cấu hình",
+	"Audit stats are unavailable right now": "Thống kê kiểm toán tạm thời không khả dụng",
+
+	// Server-side failures
+	"Database error checking containing folder":   "Lỗi cơ sở dữ liệu khi kiểm tra thư mục chứa",
+	"Database error counting visible notes":       "Lỗi cơ sở dữ liệu khi đếm ghi chú được xem",
+	"Database error checking folder share":        "Lỗi cơ sở dữ liệu khi kiểm tra chia sẻ thư mục",
+	"Database error checking folder write access": "Lỗi cơ sở dữ liệu khi kiểm tra quyền ghi thư mục",
+	"Database error checking note share":          "Lỗi cơ sở dữ liệu khi kiểm tra chia sẻ ghi chú",
+	"Database error checking note write access":   "Lỗi cơ sở dữ liệu khi kiểm tra quyền ghi ghi chú",
+	"Database error checking team manager":        "Lỗi cơ sở dữ liệu khi kiểm tra quản lý nhóm",
+	"Database error checking team member":         "Lỗi cơ sở dữ liệu khi kiểm tra thành viên nhóm",
+	"Database error checking team membership":     "Lỗi cơ sở dữ liệu khi kiểm tra tư cách thành viên nhóm",
+	"Database error while checking ownership":     "Lỗi cơ sở dữ liệu khi kiểm tra quyền sở hữu",
+	"Database error while loading template":       "Lỗi cơ sở dữ liệu khi tải mẫu",
+	"Failed to add manager to team":               "Không thêm được quản lý vào nhóm",
+	"Failed to add member to team":                "Không thêm được thành viên vào nhóm",
+	"Failed to broadcast token revocation":        "Không thông báo được việc thu hồi token",
+	"Failed to build asset hygiene report":        "Không tạo được báo cáo tài nguyên",
+	"Failed to build collaboration report":        "Không tạo được báo cáo cộng tác",
+	"Failed to create webhook":                    "Không tạo được webhook",
+	"Failed to delete webhook":                    "Không xóa được webhook",
+	"Failed to list webhooks":                     "Không liệt kê được webhook",
+	"Failed to check folder state":                "Không kiểm tra được trạng thái thư mục",
+	"Failed to commit transaction":                "Không lưu được giao dịch",
+	"Failed to compute team stats":                "Không tính được số liệu của nhóm",
+	"Failed to compute user stats":                "Không tính được số liệu của người dùng",
+	"Failed to connect to user service":           "Không kết nối được dịch vụ người dùng",
+	"Failed to count folder notes":                "Không đếm được ghi chú trong thư mục",
+	"Failed to create announcement":               "Không tạo được thông báo",
+	"Failed to create folder":                     "Không tạo được thư mục",
+	"Failed to create note":                       "Không tạo được ghi chú",
+	"Failed to create template":                   "Không tạo được mẫu",
+	"Failed to delete associated notes":           "Không xóa được các ghi chú liên quan",
+	"Failed to delete associated shares":          "Không xóa được các lượt chia sẻ liên quan",
+	"Failed to delete folder":                     "Không xóa được thư mục",
+	"Failed to delete note":                       "Không xóa được ghi chú",
+	"Failed to delete team":                       "Không xóa được nhóm",
+	"Failed to delete template":                   "Không xóa được mẫu",
+	"Failed to evaluate feature flag":             "Không đánh giá được cờ tính năng",
+	"Failed to leave team":                        "Không rời được nhóm",
+	"Failed to list associated notes":             "Không liệt kê được các ghi chú liên quan",
+	"Failed to list folder shares":                "Không liệt kê được các lượt chia sẻ thư mục",
+	"Failed to list note shares":                  "Không liệt kê được các lượt chia sẻ ghi chú",
+	"Failed to list subfolders":                   "Không liệt kê được thư mục con",
+	"Failed to list trash":                        "Không liệt kê được thùng rác",
+	"Failed to load asset state":                  "Không tải được trạng thái tài nguyên",
+	"Failed to load deletion job":                 "Không tải được tác vụ xóa",
+	"Failed to load feature flags":                "Không tải được cờ tính năng",
+	"Failed to parse user ID":                     "Không đọc được mã người dùng",
+	"Failed to pause dispatch":                    "Không tạm dừng được việc phát sự kiện",
+	"Failed to read dispatch status":              "Không đọc được trạng thái phát sự kiện",
+	"Failed to record folder event":               "Không ghi nhận được sự kiện thư mục",
+	"Failed to record note event":                 "Không ghi nhận được sự kiện ghi chú",
+	"Failed to remove manager from team":          "Không xóa được quản lý khỏi nhóm",
+	"Failed to rename team":                       "Không đổi được tên nhóm",
+	"Failed to remove member from team":           "Không xóa được thành viên khỏi nhóm",
+	"Failed to reserve an import slot":            "Không giữ được lượt nhập người dùng",
+	"Failed to restore note":                      "Không khôi phục được ghi chú",
+	"Failed to resume dispatch":                   "Không tiếp tục được việc phát sự kiện",
+	"Failed to retrieve announcements":            "Không tải được thông báo",
+	"Failed to retrieve assets for the user":      "Không tải được tài nguyên của người dùng",
+	"Failed to retrieve folder":                   "Không tải được thư mục",
+	"Failed to retrieve import job":               "Không tải được tác vụ nhập người dùng",
+	"Failed to retrieve note":                     "Không tải được ghi chú",
+	"Failed to retrieve team assets":              "Không tải được tài nguyên của nhóm",
+	"Failed to retrieve team managers":            "Không tải được danh sách quản lý nhóm",
+	"Failed to retrieve team members":             "Không tải được danh sách thành viên nhóm",
+	"Failed to retrieve teams":                    "Không tải được danh sách nhóm",
+	"Failed to transfer lead manager role":        "Không chuyển được vai trò trưởng nhóm",
+	"Failed to retrieve templates":                "Không tải được mẫu",
+	"Failed to revoke folder share":               "Không thu hồi được chia sẻ thư mục",
+	"Failed to revoke note share":                 "Không thu hồi được chia sẻ ghi chú",
+	"Failed to save feature flag":                 "Không lưu được cờ tính năng",
+	"Failed to share folder":                      "Không chia sẻ được thư mục",
+	"Failed to share note":                        "Không chia sẻ được ghi chú",
+	"Failed to start folder deletion":             "Không bắt đầu xóa thư mục được",
+	"Failed to start user import":                 "Không bắt đầu nhập người dùng được",
+	"Failed to update announcement":               "Không cập nhật được thông báo",
+	"Failed to update folder":                     "Không cập nhật được thư mục",
+	"Failed to update folder settings":            "Không cập nhật được cài đặt thư mục",
+	"Failed to update note":                       "Không cập nhật được ghi chú",
+	"Failed to update note settings":              "Không cập nhật được cài đặt ghi chú",
+	"Failed to update template":                   "Không cập nhật được mẫu",
+	"Failed to verify team manager status":        "Không kiểm tra được quyền quản lý nhóm",
+}
+
+// viFields are the validation field error templates, see fieldCatalogues.
+var viFields = map[string]string{
+	"required": "{field} là bắt buộc",
+	"oneof":    "{field} phải là một trong: {allowed}",
+	"min":      "{field} phải có ít nhất {param} phần tử hoặc ký tự",
+	"max":      "{field} chỉ được có tối đa {param} phần tử hoặc ký tự",
+	"type":     "{field} phải có kiểu {param}",
+	"gte":      "{field} phải lớn hơn hoặc bằng {param}",
+	"lte":      "{field} phải nhỏ hơn hoặc bằng {param}",
+	"ltfield":  "{field} phải trước {param}",
+	"unknown":  "{field} không phải là trường hợp lệ",
+	"*":        "{field} không thỏa quy tắc {rule}",
+}
@@ -0,0 +1,197 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"math"
+	"seta/internal/pkg/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxDispatcherName is the dispatch_pauses key of the OutboxDispatcher.
+const outboxDispatcherName = "outbox"
+
+// publishSamples is how many recent Kafka writes the status latency and error rate cover.
+const publishSamples = 256
+
+var (
+	outboxOldestPendingAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_oldest_pending_age_seconds",
+		Help: "Age of the oldest outbox event not yet published to Kafka.",
+	})
+	outboxPaused = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_paused",
+		Help: "1 while outbox publishing is paused by an operator.",
+	})
+	outboxPublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "outbox_publish_duration_seconds",
+		Help:    "Duration of Kafka writes of outbox events, by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+	outboxPublishErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_publish_errors_total",
+		Help: "Failed Kafka writes of outbox events, by topic.",
+	}, []string{"topic"})
+)
+
+// PauseOutbox stops every instance's OutboxDispatcher from publishing until ResumeOutbox.
+// Events keep being written to the outbox meanwhile. Pausing again keeps the first pause.
+func PauseOutbox(ctx context.Context, db *gorm.DB, pausedBy, reason string) (models.DispatchPause, error) {
+	pause := models.DispatchPause{Dispatcher: outboxDispatcherName, PausedBy: pausedBy, Reason: reason, PausedAt: time.Now().UTC()}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&pause).Error; err != nil {
+			return err
+		}
+		return tx.First(&pause, "dispatcher = ?", outboxDispatcherName).Error
+	})
+	if err != nil {
+		return models.DispatchPause{}, err
+	}
+	outboxPaused.Set(1)
+	return pause, nil
+}
+
+// ResumeOutbox lifts a pause and reports whether there was one. The backlog built up
+// meanwhile drains at the dispatcher's OUTBOX_DRAIN_RATE_PER_SECOND.
+func ResumeOutbox(ctx context.Context, db *gorm.DB) (bool, error) {
+	result := db.WithContext(ctx).Delete(&models.DispatchPause{}, "dispatcher = ?", outboxDispatcherName)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	outboxPaused.Set(0)
+	return result.RowsAffected > 0, nil
+}
+
+// outboxPause returns the current pause, or nil when publishing.
+func outboxPause(db *gorm.DB) (*models.DispatchPause, error) {
+	var pause models.DispatchPause
+	if err := db.First(&pause, "dispatcher = ?", outboxDispatcherName).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &pause, nil
+}
+
+// PublishLatency summarizes the recent Kafka writes to one topic, in milliseconds.
+type PublishLatency struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+}
+
+// OutboxStatus is the state of outbox publishing. Backlog, the oldest pending event and
+// the pause are shared by every instance; the publish error rate and latencies cover the
+// last writes made by this one.
+type OutboxStatus struct {
+	Paused                  *models.DispatchPause     `json:"paused"`
+	Backlog                 int64                     `json:"backlog"`
+	OldestPendingAgeSeconds float64                   `json:"oldestPendingAgeSeconds"`
+	RecentPublishes         int                       `json:"recentPublishes"`
+	RecentErrorRate         float64                   `json:"recentErrorRate"`
+	PublishLatencyMs        map[string]PublishLatency `json:"publishLatencyMs"`
+}
+
+// ReadOutboxStatus reports the state of outbox publishing.
+func ReadOutboxStatus(ctx context.Context, db *gorm.DB) (OutboxStatus, error) {
+	db = db.WithContext(ctx)
+	status := OutboxStatus{}
+
+	pause, err := outboxPause(db)
+	if err != nil {
+		return status, err
+	}
+	status.Paused = pause
+
+	var pending struct {
+		Count  int64
+		Oldest *time.Time
+	}
+	if err := db.Model(&models.OutboxEvent{}).Select("COUNT(*) AS count, MIN(created_at) AS oldest").
+		Where("sent_at IS NULL").Scan(&pending).Error; err != nil {
+		return status, err
+	}
+	status.Backlog = pending.Count
+	if pending.Oldest != nil {
+		status.OldestPendingAgeSeconds = time.Since(*pending.Oldest).Seconds()
+	}
+
+	status.RecentPublishes, status.RecentErrorRate, status.PublishLatencyMs = recentPublishes.summary()
+	return status, nil
+}
+
+// publishLog keeps the outcome and duration of the last publishSamples Kafka writes.
+type publishLog struct {
+	mu      sync.Mutex
+	samples [publishSamples]publishSample
+	next    int
+	count   int
+}
+
+type publishSample struct {
+	topic    string
+	duration time.Duration
+	failed   bool
+}
+
+var recentPublishes = &publishLog{}
+
+func (l *publishLog) record(topic string, duration time.Duration, err error) {
+	outboxPublishDuration.WithLabelValues(topic).Observe(duration.Seconds())
+	if err != nil {
+		outboxPublishErrors.WithLabelValues(topic).Inc()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples[l.next] = publishSample{topic: topic, duration: duration, failed: err != nil}
+	l.next = (l.next + 1) % publishSamples
+	l.count = min(l.count+1, publishSamples)
+}
+
+func (l *publishLog) summary() (int, float64, map[string]PublishLatency) {
+	l.mu.Lock()
+	samples := append([]publishSample(nil), l.samples[:l.count]...)
+	l.mu.Unlock()
+
+	failed := 0
+	durations := make(map[string][]float64)
+	for _, sample := range samples {
+		if sample.failed {
+			failed++
+		}
+		durations[sample.topic] = append(durations[sample.topic], float64(sample.duration.Microseconds())/1000)
+	}
+
+	latencies := make(map[string]PublishLatency, len(durations))
+	for topic, values := range durations {
+		sort.Float64s(values)
+		latencies[topic] = PublishLatency{
+			Samples: len(values),
+			P50:     percentile(values, 0.50),
+			P90:     percentile(values, 0.90),
+			P99:     percentile(values, 0.99),
+		}
+	}
+
+	rate := 0.0
+	if len(samples) > 0 {
+		rate = float64(failed) / float64(len(samples))
+	}
+	return len(samples), rate, latencies
+}
+
+// percentile is the nearest-rank percentile of sorted, which must not be empty.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"seta-pkg/events"
+	"seta/internal/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// NewTeamEvent builds a team event. Set TargetUserID with WithTarget on membership events.
+func NewTeamEvent(eventType string, teamID, actorID uuid.UUID) EventPayload {
+	return events.NewTeamEvent(eventType, teamID.String(), actorID.String())
+}
+
+// NewFolderEvent builds an asset event for a folder. OwnerID always comes from the
+// folder row and ActionBy from the authenticated requester, so handlers cannot mix them up.
+// Team folders carry their team and subfolders their parent as ParentID.
+func NewFolderEvent(eventType string, folder models.Folder, actorID uuid.UUID) EventPayload {
+	event := events.NewAssetEvent(eventType, "folder", folder.FolderID.String(), actorID.String())
+	event.OwnerID = folder.OwnerID.String()
+	if folder.TeamID != nil {
+		event.TeamID = folder.TeamID.String()
+	}
+	if folder.ParentFolderID != nil {
+		event.ParentID = folder.ParentFolderID.String()
+	}
+	return event
+}
+
+// NewFolderNotesDeletedEvent builds the FOLDER_NOTES_DELETED event listing notes removed
+// along with their folder, so consumers can drop them without one event per note.
+func NewFolderNotesDeletedEvent(folder models.Folder, noteIDs []uuid.UUID, actorID uuid.UUID) EventPayload {
+	event := NewFolderEvent("FOLDER_NOTES_DELETED", folder, actorID)
+	event.AssetIDs = make([]string, len(noteIDs))
+	for i, id := range noteIDs {
+		event.AssetIDs[i] = id.String()
+	}
+	return event
+}
+
+// NewNoteEvent builds an asset event for a note, see NewFolderEvent. It carries the
+// note's folder as ParentID, the note's cache opt-out, and the team of announcements.
+func NewNoteEvent(eventType string, note models.Note, actorID uuid.UUID) EventPayload {
+	cacheable := note.Cacheable
+	event := events.NewAssetEvent(eventType, "note", note.NoteID.String(), actorID.String())
+	event.OwnerID = note.OwnerID.String()
+	event.ParentID = note.FolderID.String()
+	event.Cacheable = &cacheable
+	if note.TeamID != nil {
+		event.TeamID = note.TeamID.String()
+	}
+	return event
+}
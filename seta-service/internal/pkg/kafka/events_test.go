@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"seta-pkg/events"
+	"seta/internal/pkg/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewFolderEvent(t *testing.T) {
+	ownerID, actorID, targetID := uuid.New(), uuid.New(), uuid.New()
+	teamID, parentID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name         string
+		folder       models.Folder
+		wantTeam     string
+		wantParentID string
+	}{
+		{"top-level folder", models.Folder{FolderID: uuid.New(), OwnerID: ownerID}, "", ""},
+		{"team folder", models.Folder{FolderID: uuid.New(), OwnerID: ownerID, TeamID: &teamID}, teamID.String(), ""},
+		{"subfolder", models.Folder{FolderID: uuid.New(), OwnerID: ownerID, ParentFolderID: &parentID}, "", parentID.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := NewFolderEvent("FOLDER_SHARED", tt.folder, actorID).WithTarget(targetID)
+			want := events.Payload{
+				SchemaVersion: events.SchemaVersion,
+				EventType:     "FOLDER_SHARED",
+				AssetType:     "folder",
+				AssetID:       tt.folder.FolderID.String(),
+				OwnerID:       ownerID.String(),
+				ActionBy:      actorID.String(),
+				TargetUserID:  targetID.String(),
+				TeamID:        tt.wantTeam,
+				ParentID:      tt.wantParentID,
+				Timestamp:     event.Timestamp,
+			}
+			if event.Timestamp.IsZero() {
+				t.Error("event has no timestamp")
+			}
+			if event.EventType != want.EventType || event.AssetType != want.AssetType || event.AssetID != want.AssetID ||
+				event.OwnerID != want.OwnerID || event.ActionBy != want.ActionBy || event.TargetUserID != want.TargetUserID ||
+				event.TeamID != want.TeamID || event.ParentID != want.ParentID || event.SchemaVersion != want.SchemaVersion {
+				t.Errorf("event = %+v, want %+v", event, want)
+			}
+			if err := event.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewNoteEvent(t *testing.T) {
+	ownerID, actorID, teamID := uuid.New(), uuid.New(), uuid.New()
+	note := models.Note{NoteID: uuid.New(), FolderID: uuid.New(), OwnerID: ownerID, Cacheable: false, TeamID: &teamID}
+
+	event := NewNoteEvent("NOTE_UPDATED", note, actorID)
+	if event.AssetType != "note" || event.AssetID != note.NoteID.String() {
+		t.Errorf("event names %s %s, want the note", event.AssetType, event.AssetID)
+	}
+	if event.OwnerID != ownerID.String() || event.ActionBy != actorID.String() {
+		t.Errorf("OwnerID = %s, ActionBy = %s, want the owner and the actor", event.OwnerID, event.ActionBy)
+	}
+	if event.TargetUserID != "" {
+		t.Errorf("TargetUserID = %q, want none on an update", event.TargetUserID)
+	}
+	if event.ParentID != note.FolderID.String() {
+		t.Errorf("ParentID = %q, want the note's folder", event.ParentID)
+	}
+	if event.TeamID != teamID.String() {
+		t.Errorf("TeamID = %q, want the announcement's team", event.TeamID)
+	}
+	if event.Cacheable == nil || *event.Cacheable {
+		t.Errorf("Cacheable = %v, want false", event.Cacheable)
+	}
+}
+
+func TestNewFolderNotesDeletedEvent(t *testing.T) {
+	folder := models.Folder{FolderID: uuid.New(), OwnerID: uuid.New()}
+	noteIDs := []uuid.UUID{uuid.New(), uuid.New()}
+	actorID := uuid.New()
+
+	event := NewFolderNotesDeletedEvent(folder, noteIDs, actorID)
+	if event.EventType != "FOLDER_NOTES_DELETED" || event.AssetID != folder.FolderID.String() {
+		t.Errorf("event = %+v, want FOLDER_NOTES_DELETED of the folder", event)
+	}
+	if event.OwnerID != folder.OwnerID.String() || event.ActionBy != actorID.String() {
+		t.Errorf("OwnerID = %s, ActionBy = %s, want the owner and the actor", event.OwnerID, event.ActionBy)
+	}
+	if len(event.AssetIDs) != 2 || event.AssetIDs[0] != noteIDs[0].String() || event.AssetIDs[1] != noteIDs[1].String() {
+		t.Errorf("AssetIDs = %v, want %v", event.AssetIDs, noteIDs)
+	}
+}
+
+func TestNewTeamEvent(t *testing.T) {
+	teamID, actorID, memberID := uuid.New(), uuid.New(), uuid.New()
+
+	event := NewTeamEvent("MEMBER_ADDED", teamID, actorID).WithTarget(memberID)
+	if event.TeamID != teamID.String() || event.ActionBy != actorID.String() || event.TargetUserID != memberID.String() {
+		t.Errorf("event = %+v, want the team, actor and member", event)
+	}
+	if event.AssetType != "" || event.OwnerID != "" {
+		t.Errorf("team event carries asset fields: %+v", event)
+	}
+	if err := event.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
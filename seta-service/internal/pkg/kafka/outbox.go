@@ -0,0 +1,274 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"seta-pkg/logging"
+	"seta-pkg/requestid"
+	"seta-pkg/tracing"
+	"seta/internal/pkg/models"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxLockKey is the advisory lock held by the dispatcher publishing a batch, so with
+// several instances running only one publishes at a time and rows go out in ID order.
+const outboxLockKey = 75601
+
+// traceParentHeader is the W3C trace context header an outbox row keeps the span of the
+// request that enqueued it in.
+const traceParentHeader = "traceparent"
+
+// outboxRetention is how long sent rows are kept before being pruned.
+const outboxRetention = 24 * time.Hour
+
+var outboxBacklog = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "outbox_backlog_events",
+	Help: "Outbox events written but not yet published to Kafka.",
+})
+
+// EnqueueTeamEvent writes a team event to the outbox through tx. Call it inside
+// the transaction that makes the change, so the event exists if and only if it commits.
+// The request ID and span carried by the context of tx are published with the event.
+func EnqueueTeamEvent(tx *gorm.DB, payload EventPayload) error {
+	return enqueue(tx, producerConfig.TeamTopic, payload.TeamID, payload)
+}
+
+// EnqueueAssetEvent is EnqueueTeamEvent for asset events.
+func EnqueueAssetEvent(tx *gorm.DB, payload EventPayload) error {
+	return enqueue(tx, producerConfig.AssetTopic, payload.AssetID, payload)
+}
+
+func enqueue(tx *gorm.DB, topic, key string, payload EventPayload) error {
+	payload = payload.Stamped()
+	msg, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxEvent{
+		Topic:         topic,
+		MessageKey:    key,
+		Payload:       string(msg),
+		RequestID:     requestid.From(tx.Statement.Context),
+		TraceParent:   tracing.Inject(tx.Statement.Context)[traceParentHeader],
+		NextAttemptAt: payload.Timestamp,
+	}).Error
+}
+
+// OutboxDispatcher publishes outbox rows to Kafka. It polls every OUTBOX_POLL_INTERVAL_MS
+// (default 1000) for up to OUTBOX_BATCH_SIZE rows (default 100). A failed batch is retried
+// with exponential backoff capped at OUTBOX_MAX_BACKOFF_SECONDS (default 300); later rows
+// wait behind it so consumers still see each key's events in order.
+//
+// Nothing is published while an operator has paused the outbox, see PauseOutbox. A
+// backlog, such as the one left by a pause, drains at up to OUTBOX_DRAIN_RATE_PER_SECOND
+// events (default 1000) so Kafka and consumers are not hit by the whole of it at once.
+type OutboxDispatcher struct {
+	db         *gorm.DB
+	log        logging.Logger
+	interval   time.Duration
+	batchSize  int
+	maxBackoff time.Duration
+	drainRate  int
+}
+
+func NewOutboxDispatcher(db *gorm.DB, log logging.Logger) *OutboxDispatcher {
+	interval := time.Second
+	if v, _ := strconv.Atoi(os.Getenv("OUTBOX_POLL_INTERVAL_MS")); v > 0 {
+		interval = time.Duration(v) * time.Millisecond
+	}
+	batchSize := 100
+	if v, _ := strconv.Atoi(os.Getenv("OUTBOX_BATCH_SIZE")); v > 0 {
+		batchSize = v
+	}
+	maxBackoff := 5 * time.Minute
+	if v, _ := strconv.Atoi(os.Getenv("OUTBOX_MAX_BACKOFF_SECONDS")); v > 0 {
+		maxBackoff = time.Duration(v) * time.Second
+	}
+	drainRate := 1000
+	if v, _ := strconv.Atoi(os.Getenv("OUTBOX_DRAIN_RATE_PER_SECOND")); v > 0 {
+		drainRate = v
+	}
+	return &OutboxDispatcher{db: db, log: log, interval: interval, batchSize: batchSize, maxBackoff: maxBackoff, drainRate: drainRate}
+}
+
+// Run dispatches until ctx is done. A full batch is followed by the next one as soon as
+// the drain rate allows, so a backlog drains without waiting for the poll interval. Once
+// a minute it logs the backlog and prunes rows sent more than a day ago.
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	housekeeping := time.NewTicker(time.Minute)
+	defer housekeeping.Stop()
+
+	for {
+		sent, err := d.dispatch(ctx)
+		if err != nil {
+			d.log.Error("Failed to dispatch outbox events", logging.Err(err))
+		}
+		d.updateBacklog(ctx)
+		if err == nil && sent == d.batchSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(sent) * time.Second / time.Duration(d.drainRate)):
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-housekeeping.C:
+			d.housekeep(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch publishes the oldest unsent rows and returns how many were sent.
+func (d *OutboxDispatcher) dispatch(ctx context.Context) (int, error) {
+	sent := 0
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil // another instance is dispatching
+		}
+		if pause, err := outboxPause(tx); err != nil || pause != nil {
+			return err
+		}
+
+		var rows []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("sent_at IS NULL").Order("id").Limit(d.batchSize).
+			Find(&rows).Error; err != nil {
+			return err
+		}
+
+		// Stop at the first row still backing off; publishing past it would reorder events.
+		now := time.Now()
+		for i, row := range rows {
+			if row.NextAttemptAt.After(now) {
+				rows = rows[:i]
+				break
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if err := publish(ctx, rows); err != nil {
+			d.retryLater(tx, rows, err)
+			return nil
+		}
+
+		ids := make([]int64, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		if err := tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("sent_at", time.Now().UTC()).Error; err != nil {
+			// Already published; the rows go out again and consumers see a duplicate.
+			return err
+		}
+		sent = len(rows)
+		return nil
+	})
+	return sent, err
+}
+
+// publish writes rows to the topic each was enqueued for, one WriteMessages call per run
+// of the same topic. A row enqueued by a traced request is published in a span of that
+// request's trace.
+func publish(ctx context.Context, rows []models.OutboxEvent) error {
+	for start := 0; start < len(rows); {
+		end := start
+		msgs := make([]kafka.Message, 0, len(rows)-start)
+		spans := make([]trace.Span, 0, len(rows)-start)
+		for end < len(rows) && rows[end].Topic == rows[start].Topic {
+			msg := kafka.Message{Topic: rows[end].Topic, Key: []byte(rows[end].MessageKey), Value: []byte(rows[end].Payload)}
+			msg = withRequestID(msg, rows[end].RequestID)
+			if rows[end].TraceParent != "" {
+				parent := tracing.Extract(ctx, map[string]string{traceParentHeader: rows[end].TraceParent})
+				spans = append(spans, tracing.StartPublish(parent, &msg))
+			}
+			msgs = append(msgs, msg)
+			end++
+		}
+
+		started := time.Now()
+		err := writer.WriteMessages(ctx, msgs...)
+		recentPublishes.record(rows[start].Topic, time.Since(started), err)
+		for _, span := range spans {
+			tracing.End(span, err)
+		}
+		if err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// retryLater pushes back the head row of a failed batch. Only the head is rescheduled:
+// the rows behind it wait for it anyway and are tried together on the next attempt.
+func (d *OutboxDispatcher) retryLater(tx *gorm.DB, rows []models.OutboxEvent, cause error) {
+	head := rows[0]
+	attempts := head.Attempts + 1
+	backoff := d.interval << min(attempts, 20)
+	if backoff > d.maxBackoff || backoff <= 0 {
+		backoff = d.maxBackoff
+	}
+	message := cause.Error()
+
+	d.log.Warn("Failed to publish outbox events, will retry", logging.Fields{
+		logging.FieldError: cause,
+		"outbox_id":        head.ID,
+		"attempts":         attempts,
+		"retry_in":         backoff.String(),
+	})
+
+	if err := tx.Model(&head).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"next_attempt_at": time.Now().Add(backoff),
+		"last_error":      message,
+	}).Error; err != nil {
+		d.log.Error("Failed to record outbox retry", logging.Err(err))
+	}
+}
+
+func (d *OutboxDispatcher) updateBacklog(ctx context.Context) {
+	status, err := ReadOutboxStatus(ctx, d.db)
+	if err != nil {
+		return
+	}
+	outboxBacklog.Set(float64(status.Backlog))
+	outboxOldestPendingAge.Set(status.OldestPendingAgeSeconds)
+	if status.Paused != nil {
+		outboxPaused.Set(1)
+	} else {
+		outboxPaused.Set(0)
+	}
+}
+
+func (d *OutboxDispatcher) housekeep(ctx context.Context) {
+	var backlog int64
+	if err := d.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("sent_at IS NULL").Count(&backlog).Error; err == nil && backlog > 0 {
+		d.log.Info("Outbox backlog", logging.Fields{"pending": backlog})
+	}
+
+	if err := d.db.WithContext(ctx).Where("sent_at < ?", time.Now().Add(-outboxRetention)).Delete(&models.OutboxEvent{}).Error; err != nil {
+		d.log.Warn("Failed to prune sent outbox events", logging.Err(err))
+	}
+}
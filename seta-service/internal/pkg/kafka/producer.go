@@ -3,64 +3,190 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"seta-pkg/events"
+	"seta-pkg/logging"
+	"seta-pkg/requestid"
+	"seta-pkg/tracing"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/segmentio/kafka-go"
 )
 
-type EventPayload struct {
-	EventType    string    `json:"eventType"`
-	TeamID       string    `json:"teamId,omitempty"`
-	AssetType    string    `json:"assetType,omitempty"`
-	AssetID      string    `json:"assetId,omitempty"`
-	OwnerID      string    `json:"ownerId,omitempty"`
-	ActionBy     string    `json:"actionBy"`
-	TargetUserID string    `json:"targetUserId,omitempty"`
-	Timestamp    time.Time `json:"timestamp"`
+// EventPayload is the event put on both topics, see events.Payload.
+type EventPayload = events.Payload
+
+// Default topic names, used unless KAFKA_TEAM_TOPIC or KAFKA_ASSET_TOPIC is set.
+const (
+	TeamActivityTopic = "team.activity"
+	AssetChangesTopic = "asset.changes"
+)
+
+var produceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "kafka_produce_errors_total",
+	Help: "Events produced directly, outside the outbox, that Kafka did not accept, by topic.",
+}, []string{"topic"})
+
+// Config is where events are published. Brokers come from KAFKA_BROKERS, a comma
+// separated list, and each write is tried up to KAFKA_PRODUCER_MAX_ATTEMPTS times
+// (default 5) before it fails. An event produced outside the outbox is given
+// KAFKA_PRODUCE_TIMEOUT_MS (default 10000) to be written, whatever its caller's context.
+type Config struct {
+	Brokers        []string
+	TeamTopic      string
+	AssetTopic     string
+	MaxAttempts    int
+	ProduceTimeout time.Duration
 }
 
-var teamWriter *kafka.Writer
-var assetWriter *kafka.Writer
+// ConfigFromEnv reads the Kafka settings, falling back to the default topics.
+func ConfigFromEnv() Config {
+	cfg := Config{TeamTopic: TeamActivityTopic, AssetTopic: AssetChangesTopic, MaxAttempts: 5, ProduceTimeout: 10 * time.Second}
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			cfg.Brokers = append(cfg.Brokers, broker)
+		}
+	}
+	if topic := os.Getenv("KAFKA_TEAM_TOPIC"); topic != "" {
+		cfg.TeamTopic = topic
+	}
+	if topic := os.Getenv("KAFKA_ASSET_TOPIC"); topic != "" {
+		cfg.AssetTopic = topic
+	}
+	if v, _ := strconv.Atoi(os.Getenv("KAFKA_PRODUCER_MAX_ATTEMPTS")); v > 0 {
+		cfg.MaxAttempts = v
+	}
+	if v, _ := strconv.Atoi(os.Getenv("KAFKA_PRODUCE_TIMEOUT_MS")); v > 0 {
+		cfg.ProduceTimeout = time.Duration(v) * time.Millisecond
+	}
+	return cfg
+}
 
-func InitProducers() {
-	brokers := []string{os.Getenv("KAFKA_BROKERS")}
+var (
+	// producerConfig holds the topics events are enqueued for; InitProducers replaces it.
+	producerConfig = Config{TeamTopic: TeamActivityTopic, AssetTopic: AssetChangesTopic, ProduceTimeout: 10 * time.Second}
+	producerLog    = logging.Nop()
+	writer         *kafka.Writer
+)
 
-	teamWriter = &kafka.Writer{
-		Addr:     kafka.TCP(brokers...),
-		Topic:    "team.activity",
-		Balancer: &kafka.LeastBytes{},
+// InitProducers sets up the writer shared by both topics. It waits for every in-sync
+// replica to acknowledge a write, and hashes message keys to pick the partition, so the
+// events of one team or asset stay in order.
+func InitProducers(cfg Config, log logging.Logger) {
+	producerConfig = cfg
+	producerLog = log
+	writer = &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		MaxAttempts:  cfg.MaxAttempts,
+		ErrorLogger: kafka.LoggerFunc(func(msg string, args ...interface{}) {
+			log.Warn("Kafka writer error", logging.Fields{"detail": fmt.Sprintf(msg, args...)})
+		}),
 	}
+}
 
-	assetWriter = &kafka.Writer{
-		Addr:     kafka.TCP(brokers...),
-		Topic:    "asset.changes",
-		Balancer: &kafka.LeastBytes{},
+// CloseProducers flushes pending messages and closes the writer.
+func CloseProducers() error {
+	if writer == nil {
+		return nil
 	}
+	return writer.Close()
 }
 
+// ProduceTeamEvent publishes a team event straight to Kafka, bypassing the outbox. The
+// write is detached from ctx, so a caller that goes away does not cut it short, and
+// bounded by the produce timeout instead. The request ID ctx carries goes in a header,
+// and the write is traced as a child of the span in ctx.
 func ProduceTeamEvent(ctx context.Context, payload EventPayload) error {
-	payload.Timestamp = time.Now().UTC()
-	msg, err := json.Marshal(payload)
+	msg, err := NewTeamMessage(payload)
+	if err != nil {
+		return err
+	}
+	msg.Topic = producerConfig.TeamTopic
+	return produce(ctx, msg, logging.Fields{logging.FieldTeamID: payload.TeamID, logging.FieldEventType: payload.EventType})
+}
+
+// ProduceAssetEvent is ProduceTeamEvent for asset events.
+func ProduceAssetEvent(ctx context.Context, payload EventPayload) error {
+	msg, err := NewAssetMessage(payload)
 	if err != nil {
 		return err
 	}
+	msg.Topic = producerConfig.AssetTopic
+	return produce(ctx, msg, logging.Fields{logging.FieldAssetID: payload.AssetID, logging.FieldEventType: payload.EventType})
+}
+
+// produce writes one message, logging and counting it when Kafka does not take it.
+func produce(ctx context.Context, msg kafka.Message, fields logging.Fields) error {
+	id := requestid.From(ctx)
+	msg = withRequestID(msg, id)
+	ctx, cancel := requestid.Detached(ctx, producerConfig.ProduceTimeout)
+	defer cancel()
+
+	span := tracing.StartPublish(ctx, &msg)
+	err := writer.WriteMessages(ctx, msg)
+	tracing.End(span, err)
+	if err != nil {
+		if id != "" {
+			fields[logging.FieldRequestID] = id
+		}
+		produceErrors.WithLabelValues(msg.Topic).Inc()
+		fields[logging.FieldError] = err
+		fields["topic"] = msg.Topic
+		producerLog.Error("Failed to produce event", fields)
+	}
+	return err
+}
 
-	return teamWriter.WriteMessages(ctx, kafka.Message{
+// withRequestID adds the request ID header to msg, unless id is empty.
+func withRequestID(msg kafka.Message, id string) kafka.Message {
+	if id != "" {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: requestid.Header, Value: []byte(id)})
+	}
+	return msg
+}
+
+// RequestID returns the ID of the request that caused msg, or "" when it has none.
+func RequestID(msg kafka.Message) string {
+	for _, header := range msg.Headers {
+		if header.Key == requestid.Header {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// NewTeamMessage stamps and encodes a team event the way ProduceTeamEvent sends it,
+// for tools that bring their own writer.
+func NewTeamMessage(payload EventPayload) (kafka.Message, error) {
+	payload = payload.Stamped()
+	msg, err := json.Marshal(payload)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+
+	return kafka.Message{
 		Key:   []byte(payload.TeamID), // Key ensures messages for the same team go to the same partition
 		Value: msg,
-	})
+	}, nil
 }
 
-func ProduceAssetEvent(ctx context.Context, payload EventPayload) error {
-	payload.Timestamp = time.Now().UTC()
+// NewAssetMessage is NewTeamMessage for asset events.
+func NewAssetMessage(payload EventPayload) (kafka.Message, error) {
+	payload = payload.Stamped()
 	msg, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return kafka.Message{}, err
 	}
 
-	return assetWriter.WriteMessages(ctx, kafka.Message{
+	return kafka.Message{
 		Key:   []byte(payload.AssetID), // Key ensures messages for the same asset go to the same partition
 		Value: msg,
-	})
-}
\ No newline at end of file
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"seta-pkg/logging"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,12 +28,12 @@ func New() *zerolog.Logger {
 
 	// Use MultiLevelWriter to log to both console and file
 	writer := io.MultiWriter(os.Stdout, logFile)
-	log := zerolog.New(writer).With().Timestamp().Logger()
+	log := zerolog.New(writer).With().Timestamp().Str(logging.FieldService, "seta-service").Logger()
 
 	return &log
 }
 
-func RequestLogger(log *zerolog.Logger) gin.HandlerFunc {
+func RequestLogger(log logging.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 
@@ -41,12 +42,19 @@ func RequestLogger(log *zerolog.Logger) gin.HandlerFunc {
 
 		// This will now output in the desired format:
 		// {"level":"info","time":"...Z","message":"Request handled","method":"GET",...}
-		log.Info().
-			Str("method", c.Request.Method).
-			Str("path", c.Request.URL.Path).
-			Int("status", c.Writer.Status()).
-			Dur("latency", time.Since(start)).
-			Str("client_ip", c.ClientIP()).
-			Msg("Request handled")
+		fields := logging.Fields{
+			"method":    c.Request.Method,
+			"path":      c.Request.URL.Path,
+			"status":    c.Writer.Status(),
+			"latency":   time.Since(start),
+			"client_ip": c.ClientIP(),
+		}
+		if requestID := c.GetString("requestId"); requestID != "" {
+			fields[logging.FieldRequestID] = requestID
+		}
+		if userID := c.GetString("userId"); userID != "" {
+			fields[logging.FieldUserID] = userID
+		}
+		log.Info("Request handled", fields)
 	}
 }
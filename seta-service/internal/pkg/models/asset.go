@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Folder represents a folder in the system.
@@ -14,6 +15,25 @@ type Folder struct {
 	Owner     User      `gorm:"foreignKey:OwnerID" json:"owner"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	// LastModifiedBy is who made the change recorded in UpdatedAt, the owner until
+	// someone else edits the folder.
+	LastModifiedBy uuid.UUID `gorm:"type:uuid;not null" json:"lastModifiedBy"`
+
+	// DeletionPending is set while a background job removes the folder's notes.
+	DeletionPending bool `gorm:"not null;default:false" json:"deletionPending"`
+
+	// AllowNoteSharing lets owners of notes inside this folder share them even when
+	// they don't own the folder.
+	AllowNoteSharing bool `gorm:"not null;default:false" json:"allowNoteSharing"`
+
+	// TeamID marks a team folder: every manager and member of the team can read it and
+	// the team's managers can write to it, without share rows.
+	TeamID *uuid.UUID `gorm:"type:uuid" json:"teamId,omitempty"`
+
+	// ParentFolderID nests the folder inside another one. Whoever can read or write the
+	// parent can do the same on the folder and everything below it.
+	ParentFolderID *uuid.UUID `gorm:"type:uuid" json:"parentFolderId,omitempty"`
 }
 
 func (Folder) TableName() string {
@@ -31,12 +51,51 @@ type Note struct {
 	Owner     User      `gorm:"foreignKey:OwnerID" json:"owner"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	// LastModifiedBy is who made the change recorded in UpdatedAt, the owner until
+	// someone else edits the note.
+	LastModifiedBy uuid.UUID `gorm:"type:uuid;not null" json:"lastModifiedBy"`
+
+	// Cacheable is cleared by the owner for notes that must never be held in a cache.
+	Cacheable bool `gorm:"not null;default:true" json:"cacheable"`
+
+	// Announcements are pinned team notes, readable by every member of TeamID without
+	// share rows. Active is toggled by managers; inactive announcements are hidden from members.
+	IsAnnouncement bool       `gorm:"not null;default:false" json:"isAnnouncement"`
+	TeamID         *uuid.UUID `gorm:"type:uuid" json:"teamId,omitempty"`
+	Active         bool       `gorm:"not null;default:true" json:"active"`
+
+	// Version counts edits of the title and body. An edit names the version it was made
+	// on and is refused once another edit has bumped it.
+	Version int `gorm:"not null;default:1" json:"version"`
+
+	// DeletedAt is set while the note is in its owner's trash. GORM leaves trashed notes
+	// out of every query unless Unscoped; raw SQL over notes must filter them itself.
+	DeletedAt gorm.DeletedAt `json:"deletedAt"`
+
+	// BodyCompressed holds a large Body compressed, with the body column left empty. The
+	// hooks in noteBody.go keep it out of sight: Body is always the plain text.
+	BodyCompressed []byte `json:"-"`
+	plainBody      string
 }
 
 func (Note) TableName() string {
 	return "notes"
 }
 
+// Share access levels. A write share also grants read. AccessOwner is only reported, for
+// assets a user owns; no share is granted with it.
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+	AccessOwner = "owner"
+)
+
+// ValidAccess reports whether access is a level a share can be granted with.
+func ValidAccess(access string) bool {
+	return access == AccessRead || access == AccessWrite
+}
+
 // FolderShare represents the sharing of a folder with a user.
 type FolderShare struct {
 	FolderID uuid.UUID `gorm:"type:uuid;primaryKey" json:"folderId"`
@@ -57,4 +116,21 @@ type NoteShare struct {
 
 func (NoteShare) TableName() string {
 	return "note_shares"
-}
\ No newline at end of file
+}
+
+// FolderDeletionJob tracks the chunked deletion of a folder with many notes.
+type FolderDeletionJob struct {
+	JobID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"jobId"`
+	FolderID     uuid.UUID `gorm:"type:uuid;not null" json:"folderId"`
+	RequestedBy  uuid.UUID `gorm:"type:uuid;not null" json:"requestedBy"`
+	Status       string    `gorm:"not null" json:"status"` // "running", "completed" or "failed"
+	TotalNotes   int64     `gorm:"not null" json:"totalNotes"`
+	DeletedNotes int64     `gorm:"not null;default:0" json:"deletedNotes"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func (FolderDeletionJob) TableName() string {
+	return "folder_deletion_jobs"
+}
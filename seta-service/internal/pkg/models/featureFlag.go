@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeatureFlag gates a behaviour for a subset of users during a rollout.
+type FeatureFlag struct {
+	Name        string `gorm:"primaryKey" json:"name"`
+	Description string `json:"description"`
+	// Enabled is the kill switch: a disabled flag is off for everyone, allowlists included.
+	Enabled bool `gorm:"not null;default:false" json:"enabled"`
+	// RolloutPercentage turns the flag on for a stable share (0-100) of users.
+	RolloutPercentage int                  `gorm:"not null;default:0" json:"rolloutPercentage"`
+	Subjects          []FeatureFlagSubject `gorm:"foreignKey:FlagName;references:Name" json:"-"`
+	UpdatedBy         string               `json:"updatedBy"`
+	CreatedAt         time.Time            `json:"createdAt"`
+	UpdatedAt         time.Time            `json:"updatedAt"`
+}
+
+func (FeatureFlag) TableName() string {
+	return "feature_flags"
+}
+
+// FeatureFlagSubject allowlists a user or a whole team for a flag.
+type FeatureFlagSubject struct {
+	FlagName    string    `gorm:"primaryKey" json:"flagName"`
+	SubjectType string    `gorm:"primaryKey" json:"subjectType"` // "user" or "team"
+	SubjectID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"subjectId"`
+}
+
+func (FeatureFlagSubject) TableName() string {
+	return "feature_flag_subjects"
+}
+
+// FeatureFlagChange is the audit trail of rule edits made through the internal API.
+type FeatureFlagChange struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	FlagName  string    `gorm:"not null" json:"flagName"`
+	Actor     string    `gorm:"not null" json:"actor"`
+	Before    *string   `gorm:"type:jsonb" json:"before"` // nil when the flag was created
+	After     string    `gorm:"type:jsonb;not null" json:"after"`
+	ChangedAt time.Time `gorm:"autoCreateTime" json:"changedAt"`
+}
+
+func (FeatureFlagChange) TableName() string {
+	return "feature_flag_changes"
+}
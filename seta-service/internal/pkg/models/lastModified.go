@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BeforeCreate attributes a new folder to its owner until someone edits it.
+func (f *Folder) BeforeCreate(tx *gorm.DB) error {
+	if f.LastModifiedBy == uuid.Nil {
+		f.LastModifiedBy = f.OwnerID
+	}
+	return nil
+}
+
+// BeforeCreate attributes a new note to its owner until someone edits it.
+func (n *Note) BeforeCreate(tx *gorm.DB) error {
+	if n.LastModifiedBy == uuid.Nil {
+		n.LastModifiedBy = n.OwnerID
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+// Note body compression algorithms, set with NOTE_BODY_COMPRESSION.
+const (
+	BodyCompressionZstd = "zstd"
+	BodyCompressionNone = "none"
+)
+
+var (
+	noteBodyCompressionRatio = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "note_body_compression_ratio",
+		Help:    "Plain size over stored size of note bodies compressed on write.",
+		Buckets: []float64{1, 1.5, 2, 3, 4, 5, 6, 8, 10},
+	})
+	noteBodyCompressionSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "note_body_compression_seconds_total",
+		Help: "CPU time spent compressing and decompressing note bodies, by operation.",
+	}, []string{"operation"})
+)
+
+// noteBodyCodec compresses note bodies of at least threshold bytes.
+type noteBodyCodec struct {
+	algorithm string
+	threshold int
+	encoder   *zstd.Encoder
+	decoder   *zstd.Decoder
+}
+
+var (
+	bodyCodec     *noteBodyCodec
+	bodyCodecOnce sync.Once
+)
+
+// noteBodies returns the codec configured by NOTE_BODY_COMPRESSION (zstd or none, default
+// zstd) and NOTE_BODY_COMPRESSION_THRESHOLD_BYTES (default 4096). Rows already
+// compressed are still read with none; they are stored plain on their next write.
+func noteBodies() *noteBodyCodec {
+	bodyCodecOnce.Do(func() {
+		codec := &noteBodyCodec{algorithm: BodyCompressionZstd, threshold: 4096}
+		switch algorithm := os.Getenv("NOTE_BODY_COMPRESSION"); algorithm {
+		case "", BodyCompressionZstd:
+		case BodyCompressionNone:
+			codec.algorithm = BodyCompressionNone
+		default:
+			log.Printf("Unknown NOTE_BODY_COMPRESSION %q, using %s", algorithm, BodyCompressionZstd)
+		}
+		if v, err := strconv.Atoi(os.Getenv("NOTE_BODY_COMPRESSION_THRESHOLD_BYTES")); err == nil && v > 0 {
+			codec.threshold = v
+		}
+
+		// Both are safe for concurrent EncodeAll and DecodeAll calls.
+		var err error
+		if codec.encoder, err = zstd.NewWriter(nil); err != nil {
+			panic(fmt.Sprintf("zstd encoder: %v", err))
+		}
+		if codec.decoder, err = zstd.NewReader(nil); err != nil {
+			panic(fmt.Sprintf("zstd decoder: %v", err))
+		}
+		bodyCodec = codec
+	})
+	return bodyCodec
+}
+
+// encode returns the compressed form of body, or nil when it is stored plain: below the
+// threshold, with compression off, or when compressing does not make it smaller.
+func (c *noteBodyCodec) encode(body string) []byte {
+	if c.algorithm == BodyCompressionNone || len(body) < c.threshold {
+		return nil
+	}
+	start := time.Now()
+	compressed := c.encoder.EncodeAll([]byte(body), nil)
+	noteBodyCompressionSeconds.WithLabelValues("compress").Add(time.Since(start).Seconds())
+	if len(compressed) >= len(body) {
+		return nil
+	}
+	noteBodyCompressionRatio.Observe(float64(len(body)) / float64(len(compressed)))
+	return compressed
+}
+
+func (c *noteBodyCodec) decode(compressed []byte) (string, error) {
+	start := time.Now()
+	body, err := c.decoder.DecodeAll(compressed, nil)
+	noteBodyCompressionSeconds.WithLabelValues("decompress").Add(time.Since(start).Seconds())
+	if err != nil {
+		return "", fmt.Errorf("decompress note body: %w", err)
+	}
+	return string(body), nil
+}
+
+// BeforeSave stores a large body compressed in body_compressed, with body left empty.
+// Every write re-encodes the body, so rows written before compression, or under other
+// settings, move to the current format the next time they are saved.
+func (n *Note) BeforeSave(tx *gorm.DB) error {
+	n.BodyCompressed = noteBodies().encode(n.Body)
+	if n.BodyCompressed != nil {
+		n.plainBody, n.Body = n.Body, ""
+	}
+	return nil
+}
+
+// AfterSave puts the plain body back, so callers never see the stored form.
+func (n *Note) AfterSave(tx *gorm.DB) error {
+	if n.BodyCompressed != nil {
+		n.Body, n.plainBody = n.plainBody, ""
+	}
+	return nil
+}
+
+// AfterFind decompresses a compressed body into Body. Plain rows are left as read.
+func (n *Note) AfterFind(tx *gorm.DB) error {
+	if n.BodyCompressed == nil {
+		return nil
+	}
+	body, err := noteBodies().decode(n.BodyCompressed)
+	if err != nil {
+		return err
+	}
+	n.Body = body
+	return nil
+}
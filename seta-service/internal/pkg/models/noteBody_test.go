@@ -0,0 +1,126 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+// useNoteBodyCodec configures note body compression for the test.
+func useNoteBodyCodec(t *testing.T, algorithm string, threshold int) {
+	t.Helper()
+	previous := noteBodies()
+	codec := *previous
+	codec.algorithm, codec.threshold = algorithm, threshold
+	bodyCodec = &codec
+	t.Cleanup(func() { bodyCodec = previous })
+}
+
+var largeBody = strings.Repeat("# Sprint notes\n\nDiscussed the release timeline and open questions.\n", 100)
+
+// save runs the hooks of a write and returns the row as stored.
+func save(t *testing.T, n *Note) Note {
+	t.Helper()
+	if err := n.BeforeSave(nil); err != nil {
+		t.Fatalf("BeforeSave: %v", err)
+	}
+	stored := Note{Body: n.Body, BodyCompressed: n.BodyCompressed}
+	if err := n.AfterSave(nil); err != nil {
+		t.Fatalf("AfterSave: %v", err)
+	}
+	return stored
+}
+
+// find runs the hooks of a read of row.
+func find(t *testing.T, row Note) Note {
+	t.Helper()
+	if err := row.AfterFind(nil); err != nil {
+		t.Fatalf("AfterFind: %v", err)
+	}
+	return row
+}
+
+func TestNoteBodyRoundTrip(t *testing.T) {
+	useNoteBodyCodec(t, BodyCompressionZstd, 4096)
+
+	note := Note{Body: largeBody}
+	stored := save(t, &note)
+	if stored.Body != "" || len(stored.BodyCompressed) == 0 || len(stored.BodyCompressed) >= len(largeBody) {
+		t.Fatalf("stored body %d bytes, compressed %d bytes; want only a smaller compressed body", len(stored.Body), len(stored.BodyCompressed))
+	}
+	if note.Body != largeBody {
+		t.Error("the saved note no longer has its plain body")
+	}
+	if got := find(t, stored); got.Body != largeBody {
+		t.Error("reading the stored row did not give back the body")
+	}
+}
+
+func TestNoteBodyBelowThresholdIsStoredPlain(t *testing.T) {
+	useNoteBodyCodec(t, BodyCompressionZstd, 4096)
+
+	note := Note{Body: "Discussed project timelines."}
+	if stored := save(t, &note); stored.Body != note.Body || stored.BodyCompressed != nil {
+		t.Errorf("stored %q, %d compressed bytes; want the body plain", stored.Body, len(stored.BodyCompressed))
+	}
+}
+
+// A row written before compression has its large body plain in body: it is read as it
+// is and compressed on its next write.
+func TestNoteBodyLegacyRow(t *testing.T) {
+	useNoteBodyCodec(t, BodyCompressionZstd, 4096)
+
+	note := find(t, Note{Body: largeBody})
+	if note.Body != largeBody {
+		t.Fatal("a legacy row was not read as stored")
+	}
+	if stored := save(t, &note); stored.Body != "" || stored.BodyCompressed == nil {
+		t.Error("a legacy row was not compressed on its next write")
+	}
+}
+
+func TestNoteBodyMixedRows(t *testing.T) {
+	useNoteBodyCodec(t, BodyCompressionZstd, 4096)
+	compressed := save(t, &Note{Body: largeBody})
+	small := "Discussed project timelines."
+
+	rows := []struct {
+		name string
+		row  Note
+		want string
+	}{
+		{"compressed", compressed, largeBody},
+		{"small plain", Note{Body: small}, small},
+		{"large plain", Note{Body: largeBody}, largeBody},
+		{"empty", Note{}, ""},
+	}
+	for _, tt := range rows {
+		if got := find(t, tt.row); got.Body != tt.want {
+			t.Errorf("%s row read as %d bytes, want %d", tt.name, len(got.Body), len(tt.want))
+		}
+	}
+}
+
+// With compression turned off, compressed rows are still read and move back to plain on
+// their next write.
+func TestNoteBodyCompressionOff(t *testing.T) {
+	useNoteBodyCodec(t, BodyCompressionZstd, 4096)
+	compressed := save(t, &Note{Body: largeBody})
+
+	useNoteBodyCodec(t, BodyCompressionNone, 4096)
+	note := find(t, compressed)
+	if note.Body != largeBody {
+		t.Fatal("a compressed row was not read with compression off")
+	}
+	if stored := save(t, &note); stored.Body != largeBody || stored.BodyCompressed != nil {
+		t.Error("a compressed row was not stored plain on its next write with compression off")
+	}
+}
+
+func TestNoteBodyCorruptRow(t *testing.T) {
+	useNoteBodyCodec(t, BodyCompressionZstd, 4096)
+
+	row := Note{BodyCompressed: []byte("not zstd")}
+	if err := row.AfterFind(nil); err == nil {
+		t.Error("AfterFind accepted a corrupt compressed body")
+	}
+}
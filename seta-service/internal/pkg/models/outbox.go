@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// OutboxEvent is a Kafka message written in the same transaction as the change it
+// describes. The outbox dispatcher publishes unsent rows in ID order and sets SentAt.
+// RequestID is the ID of the request that made the change and TraceParent the W3C trace
+// context of its span, both empty for background work.
+type OutboxEvent struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Topic         string     `gorm:"not null" json:"topic"`
+	MessageKey    string     `gorm:"not null" json:"messageKey"`
+	Payload       string     `gorm:"type:jsonb;not null" json:"payload"`
+	RequestID     string     `gorm:"not null;default:''" json:"requestId"`
+	TraceParent   string     `gorm:"not null;default:''" json:"traceParent"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;default:now()" json:"nextAttemptAt"`
+	LastError     *string    `json:"lastError"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	SentAt        *time.Time `json:"sentAt"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// DispatchPause halts a dispatcher while its row exists. It lives in the database so every
+// instance observes it; events keep accumulating in the outbox meanwhile.
+type DispatchPause struct {
+	Dispatcher string    `gorm:"primaryKey" json:"dispatcher"`
+	PausedBy   string    `gorm:"not null" json:"pausedBy"`
+	Reason     string    `gorm:"not null;default:''" json:"reason"`
+	PausedAt   time.Time `gorm:"not null;default:now()" json:"pausedAt"`
+}
+
+func (DispatchPause) TableName() string {
+	return "dispatch_pauses"
+}
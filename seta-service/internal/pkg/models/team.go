@@ -1,12 +1,17 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Team represents a team in the system.
 type Team struct {
-	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey;column:id"`
-	TeamName string
-
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey;column:id"`
+	TeamName  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type TeamManager struct {
@@ -27,4 +32,18 @@ type TeamMember struct {
 
 func (TeamMember) TableName() string {
     return "team_members"
-}
\ No newline at end of file
+}
+// TeamMembershipChange records a member joining or leaving a team, so reports can
+// tell who left recently.
+type TeamMembershipChange struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TeamID    uuid.UUID `gorm:"type:uuid;not null" json:"teamId"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"userId"`
+	Change    string    `gorm:"not null" json:"change"` // "added" or "removed"
+	ChangedBy uuid.UUID `gorm:"type:uuid" json:"changedBy"`
+	ChangedAt time.Time `gorm:"autoCreateTime" json:"changedAt"`
+}
+
+func (TeamMembershipChange) TableName() string {
+	return "team_membership_changes"
+}
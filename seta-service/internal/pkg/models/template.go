@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NoteTemplate is a reusable title/body skeleton for new notes.
+// Templates with a TeamID are readable by every member and manager of that team.
+type NoteTemplate struct {
+	TemplateID    uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"templateId"`
+	OwnerID       uuid.UUID  `gorm:"type:uuid;not null" json:"ownerId"`
+	TeamID        *uuid.UUID `gorm:"type:uuid" json:"teamId,omitempty"`
+	Name          string     `gorm:"not null" json:"name"`
+	TitleTemplate string     `gorm:"not null" json:"titleTemplate"`
+	BodyTemplate  string     `json:"bodyTemplate"`
+	UsageCount    int        `gorm:"not null;default:0" json:"usageCount"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+func (NoteTemplate) TableName() string {
+	return "note_templates"
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -12,3 +14,38 @@ type User struct {
 	Role         string `gorm:"type:enum('manager','member')"`
 	PasswordHash string
 }
+
+// UserImportJob tracks a user import running in the background. JobID is the import ID,
+// so the failure report of the import is found under it too. A running job touches
+// UpdatedAt as it goes; one that stops doing so was lost with its instance.
+type UserImportJob struct {
+	JobID             uuid.UUID `gorm:"type:uuid;primaryKey" json:"jobId"`
+	RequestedBy       uuid.UUID `gorm:"type:uuid;not null" json:"requestedBy"`
+	Status            string    `gorm:"not null" json:"status"` // "running", "completed" or "failed"
+	TotalRows         int       `gorm:"not null" json:"totalRows"`
+	ProcessedRows     int       `gorm:"not null;default:0" json:"processedRows"`
+	Succeeded         int       `gorm:"not null;default:0" json:"succeeded"`
+	Failed            int       `gorm:"not null;default:0" json:"failed"`
+	Failures          *string   `gorm:"type:jsonb" json:"-"` // set once the job has finished
+	FailuresTruncated bool      `gorm:"not null;default:false" json:"failuresTruncated"`
+	Error             string    `json:"error,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+func (UserImportJob) TableName() string {
+	return "user_import_jobs"
+}
+
+// UserImportLease holds an import slot for one running import, see
+// services.ImportLimiter. HeartbeatAt is moved while the import runs.
+type UserImportLease struct {
+	UserID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ImportID    uuid.UUID `gorm:"type:uuid;not null"`
+	AcquiredAt  time.Time `gorm:"not null;default:now()"`
+	HeartbeatAt time.Time `gorm:"not null;default:now()"`
+}
+
+func (UserImportLease) TableName() string {
+	return "user_import_leases"
+}
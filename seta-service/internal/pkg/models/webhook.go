@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FolderWebhook posts the changes to a folder and its notes to a third-party URL, signed
+// with Secret. EventTypes is a comma-separated filter; empty means every event.
+type FolderWebhook struct {
+	WebhookID  uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"webhookId"`
+	FolderID   uuid.UUID `gorm:"type:uuid;not null" json:"folderId"`
+	URL        string    `gorm:"not null" json:"url"`
+	Secret     string    `gorm:"not null" json:"-"`
+	EventTypes string    `gorm:"not null;default:''" json:"-"`
+	CreatedBy  uuid.UUID `gorm:"type:uuid;not null" json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+
+	// Delivery statistics. ConsecutiveFailures counts deliveries given up on since the
+	// last success; the webhook is disabled once it reaches the configured limit.
+	DeliveredCount      int64      `gorm:"not null;default:0" json:"deliveredCount"`
+	FailedCount         int64      `gorm:"not null;default:0" json:"failedCount"`
+	ConsecutiveFailures int        `gorm:"not null;default:0" json:"consecutiveFailures"`
+	LastDeliveryAt      *time.Time `json:"lastDeliveryAt"`
+	LastError           *string    `json:"lastError"`
+	DisabledAt          *time.Time `json:"disabledAt"`
+}
+
+func (FolderWebhook) TableName() string {
+	return "folder_webhooks"
+}
+
+// WebhookDelivery is one event waiting to be posted to a webhook. Rows are deleted once
+// delivered or given up on.
+type WebhookDelivery struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement"`
+	WebhookID     uuid.UUID `gorm:"type:uuid;not null"`
+	Payload       string    `gorm:"type:jsonb;not null"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null;default:now()"`
+	CreatedAt     time.Time
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,111 @@
+// Package testdb gives a test a Postgres database of its own: a fresh schema of the
+// database named by TEST_DATABASE_URL, set up with init_db.sql and dropped when the test
+// ends. Tests using it are skipped when TEST_DATABASE_URL is unset.
+package testdb
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// initLock is the advisory lock held while init_db.sql runs, so packages tested in
+// parallel do not race to create the pgcrypto extension.
+const initLock = 7724401
+
+// Open returns a connection to a new schema holding the tables and seed data of
+// init_db.sql. DATABASE_URL is pointed at the same schema for the test, for code that
+// opens connections of its own.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	base := os.Getenv("TEST_DATABASE_URL")
+	if base == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgx.Connect(ctx, base)
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close(ctx)
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+		admin.Close(ctx)
+	})
+
+	dsn, err := withSearchPath(base, schema)
+	if err != nil {
+		t.Fatalf("TEST_DATABASE_URL: %v", err)
+	}
+	if err := runInitScript(ctx, dsn); err != nil {
+		t.Fatalf("run init_db.sql: %v", err)
+	}
+	t.Setenv("DATABASE_URL", dsn)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+// withSearchPath returns dsn, a URL or a keyword/value connection string, with the
+// schema searched first. Public stays on the path for extensions installed there.
+func withSearchPath(dsn, schema string) (string, error) {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " search_path=" + schema + ",public", nil
+	}
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema+",public")
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// runInitScript runs init_db.sql in one go. Without arguments pgx sends it over the
+// simple protocol, which takes many statements at once.
+func runInitScript(ctx context.Context, dsn string) error {
+	_, file, _, _ := runtime.Caller(0)
+	script, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "..", "init_db.sql"))
+	if err != nil {
+		return err
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", initLock); err != nil {
+		return err
+	}
+	defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", initLock)
+	_, err = conn.Exec(ctx, string(script))
+	return err
+}
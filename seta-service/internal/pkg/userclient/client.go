@@ -0,0 +1,237 @@
+package userclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned when the user service cannot be reached, answered with a
+// server error on every attempt, or the circuit breaker is open.
+var ErrUnavailable = errors.New("user service unavailable")
+
+// GraphQLError is returned when the user service answers with a GraphQL errors array.
+type GraphQLError struct {
+	Message string
+}
+
+func (e *GraphQLError) Error() string {
+	return "GraphQL error: " + e.Message
+}
+
+// Config holds the connection, retry and breaker settings of a Client.
+type Config struct {
+	URL        string
+	Timeout    time.Duration
+	MaxRetries int
+	// RetryBackoff is multiplied by the attempt number between retries.
+	RetryBackoff time.Duration
+	// BreakerThreshold consecutive failed calls open the breaker for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// InternalAPIKey is sent as X-Internal-API-Key, which the user service requires on
+	// queries only managers may otherwise make, such as listing users.
+	InternalAPIKey string
+	// EmailCacheTTL is how long GetUserByEmail remembers the user found for an email.
+	EmailCacheTTL time.Duration
+	// HTTPClient overrides the client built from Timeout.
+	HTTPClient *http.Client
+}
+
+// ConfigFromEnv reads USER_SERVICE_URL, USER_SERVICE_TIMEOUT_MS (default 5000),
+// USER_SERVICE_MAX_RETRIES (default 3), USER_SERVICE_BREAKER_THRESHOLD (default 5)
+// USER_SERVICE_BREAKER_COOLDOWN_SECONDS (default 30) and USER_SERVICE_EMAIL_CACHE_SECONDS
+// (default 60), and takes InternalAPIKey from INTERNAL_API_KEY.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		URL:              os.Getenv("USER_SERVICE_URL"),
+		InternalAPIKey:   os.Getenv("INTERNAL_API_KEY"),
+		Timeout:          5 * time.Second,
+		MaxRetries:       3,
+		RetryBackoff:     500 * time.Millisecond,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+		EmailCacheTTL:    60 * time.Second,
+	}
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:4000/users" // Default for local dev
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_SERVICE_TIMEOUT_MS")); v > 0 {
+		cfg.Timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_SERVICE_MAX_RETRIES")); v > 0 {
+		cfg.MaxRetries = v
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_SERVICE_BREAKER_THRESHOLD")); v > 0 {
+		cfg.BreakerThreshold = v
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_SERVICE_BREAKER_COOLDOWN_SECONDS")); v > 0 {
+		cfg.BreakerCooldown = time.Duration(v) * time.Second
+	}
+	if v, _ := strconv.Atoi(os.Getenv("USER_SERVICE_EMAIL_CACHE_SECONDS")); v > 0 {
+		cfg.EmailCacheTTL = time.Duration(v) * time.Second
+	}
+	return cfg
+}
+
+// Client talks to the user-service GraphQL API. It is safe for concurrent use and
+// should be shared so the breaker sees every call.
+type Client struct {
+	cfg     Config
+	http    *http.Client
+	breaker *breaker
+	emails  *emailCache
+}
+
+// New creates a Client from cfg.
+func New(cfg Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: cfg.Timeout}
+	}
+	if cfg.MaxRetries < 1 {
+		cfg.MaxRetries = 1
+	}
+	return &Client{
+		cfg:     cfg,
+		http:    httpClient,
+		breaker: &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown, now: time.Now},
+		emails:  &emailCache{ttl: cfg.EmailCacheTTL, entries: make(map[string]emailCacheEntry)},
+	}
+}
+
+var (
+	shared     *Client
+	sharedOnce sync.Once
+)
+
+// Shared returns the process-wide client configured from the environment.
+func Shared() *Client {
+	sharedOnce.Do(func() {
+		shared = New(ConfigFromEnv())
+	})
+	return shared
+}
+
+type gqlRequest struct {
+	Query      string         `json:"query"`
+	Variables  any            `json:"variables"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// do posts a GraphQL request and decodes its data into out. Transport errors and 5xx
+// responses are retried; 4xx responses and GraphQL errors are returned immediately.
+func (c *Client) do(ctx context.Context, query string, variables any, out any) (http.Header, error) {
+	request := gqlRequest{Query: query, Variables: variables}
+	if id := correlationID(ctx); id != "" {
+		request.Extensions = map[string]any{"correlationId": id}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	if !c.breaker.allow() {
+		return nil, ErrUnavailable
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				c.breaker.abort()
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt-1) * c.cfg.RetryBackoff):
+			}
+		}
+
+		header, retry, err := c.post(ctx, body, out)
+		if err == nil || !retry {
+			// A 4xx or GraphQL error still proves the service is up.
+			c.breaker.record(true)
+			return header, err
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			// The caller gave up, that says nothing about the user service.
+			c.breaker.abort()
+			return nil, ctx.Err()
+		}
+	}
+
+	c.breaker.record(false)
+	return nil, fmt.Errorf("%w after %d attempts: %v", ErrUnavailable, c.cfg.MaxRetries, lastErr)
+}
+
+// post performs a single attempt. retry reports whether the failure is transient.
+func (c *Client) post(ctx context.Context, body []byte, out any) (http.Header, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.InternalAPIKey != "" {
+		req.Header.Set("X-Internal-API-Key", c.cfg.InternalAPIKey)
+	}
+	if id := correlationID(ctx); id != "" {
+		req.Header.Set(CorrelationHeader, id)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		return nil, resp.StatusCode >= 500, fmt.Errorf("user service HTTP %d: %s", resp.StatusCode, string(msg))
+	}
+
+	var result gqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, false, &GraphQLError{Message: result.Errors[0].Message}
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return nil, false, fmt.Errorf("failed to decode response data: %w", err)
+	}
+	return resp.Header, false, nil
+}
+
+// maxAge returns the max-age of a Cache-Control header, or zero when the response
+// must not be cached or carries no hint.
+func maxAge(header http.Header) time.Duration {
+	var age time.Duration
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			if v, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && v > 0 {
+				age = time.Duration(v) * time.Second
+			}
+		}
+	}
+	return age
+}
@@ -0,0 +1,318 @@
+package userclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubUserService answers every GraphQL request with handle and counts the requests.
+type stubUserService struct {
+	*httptest.Server
+	calls atomic.Int64
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []gqlRequest
+}
+
+func newStubUserService(t *testing.T, handle func(w http.ResponseWriter, body gqlRequest)) *stubUserService {
+	t.Helper()
+	stub := &stubUserService{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.calls.Add(1)
+		var body gqlRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stub.mu.Lock()
+		stub.requests = append(stub.requests, r)
+		stub.bodies = append(stub.bodies, body)
+		stub.mu.Unlock()
+		handle(w, body)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *stubUserService) client(cfg Config) *Client {
+	cfg.URL = s.URL
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	return New(cfg)
+}
+
+func answer(w http.ResponseWriter, data any) {
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func TestVerifyToken(t *testing.T) {
+	user := User{UserID: "a1a1a1a1-a1a1-a1a1-a1a1-a1a1a1a1a1a1", Username: "alice", Role: "MANAGER"}
+	stub := newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+		vars, _ := body.Variables.(map[string]any)
+		switch vars["token"] {
+		case "cached":
+			w.Header().Set("Cache-Control", "private, max-age=30")
+			answer(w, map[string]any{"verifyToken": map[string]any{"success": true, "user": user}})
+		case "uncached":
+			w.Header().Set("Cache-Control", "no-store")
+			answer(w, map[string]any{"verifyToken": map[string]any{"success": true, "user": user}})
+		default:
+			answer(w, map[string]any{"verifyToken": map[string]any{"success": false}})
+		}
+	})
+	c := stub.client(Config{MaxRetries: 1})
+
+	tests := []struct {
+		token string
+		want  Verification
+	}{
+		{"cached", Verification{Valid: true, User: user, TTL: 30 * time.Second}},
+		{"uncached", Verification{Valid: true, User: user}},
+		{"invalid", Verification{}},
+	}
+	for _, tt := range tests {
+		got, err := c.VerifyToken(context.Background(), tt.token)
+		if err != nil {
+			t.Fatalf("VerifyToken(%s): %v", tt.token, err)
+		}
+		if got != tt.want {
+			t.Errorf("VerifyToken(%s) = %+v, want %+v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	stub := newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+		answer(w, map[string]any{"user": nil})
+	})
+	c := stub.client(Config{MaxRetries: 1, InternalAPIKey: "internal-key"})
+
+	ctx := WithCorrelationID(context.Background(), "corr-1")
+	if _, err := c.GetUser(ctx, "a1a1a1a1-a1a1-a1a1-a1a1-a1a1a1a1a1a1"); err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	r, body := stub.requests[0], stub.bodies[0]
+	if got := r.Header.Get("X-Internal-API-Key"); got != "internal-key" {
+		t.Errorf("X-Internal-API-Key = %q, want internal-key", got)
+	}
+	if got := r.Header.Get(CorrelationHeader); got != "corr-1" {
+		t.Errorf("%s = %q, want corr-1", CorrelationHeader, got)
+	}
+	if got := body.Extensions["correlationId"]; got != "corr-1" {
+		t.Errorf("correlationId extension = %v, want corr-1", got)
+	}
+}
+
+// Server errors are retried, client errors and GraphQL errors are not.
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int // answered with status before succeeding
+		status    int
+		wantCalls int64
+		wantErr   error
+	}{
+		{"recovers after server errors", 2, http.StatusBadGateway, 3, nil},
+		{"gives up after MaxRetries", 5, http.StatusInternalServerError, 3, ErrUnavailable},
+		{"client error", 5, http.StatusBadRequest, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stub *stubUserService
+			stub = newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+				if stub.calls.Load() <= int64(tt.failures) {
+					http.Error(w, "failing", tt.status)
+					return
+				}
+				answer(w, map[string]any{"user": map[string]any{"userId": "u1", "username": "alice"}})
+			})
+			c := stub.client(Config{MaxRetries: 3, RetryBackoff: time.Millisecond})
+
+			user, err := c.GetUser(context.Background(), "u1")
+			if got := stub.calls.Load(); got != tt.wantCalls {
+				t.Errorf("made %d calls, want %d", got, tt.wantCalls)
+			}
+			switch {
+			case tt.status < 500:
+				if err == nil || errors.Is(err, ErrUnavailable) {
+					t.Errorf("err = %v, want the client error", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if err != nil || user == nil || user.Username != "alice" {
+					t.Errorf("GetUser = %+v, %v, want alice", user, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphQLError(t *testing.T) {
+	stub := newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": []map[string]any{{"message": "Forbidden"}}})
+	})
+	c := stub.client(Config{MaxRetries: 3, RetryBackoff: time.Millisecond})
+
+	_, err := c.GetUsers(context.Background(), "MEMBER")
+	var gqlErr *GraphQLError
+	if !errors.As(err, &gqlErr) || gqlErr.Message != "Forbidden" {
+		t.Fatalf("err = %v, want the GraphQL error", err)
+	}
+	if got := stub.calls.Load(); got != 1 {
+		t.Errorf("made %d calls, want 1", got)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	stub := newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+		<-release
+	})
+	defer close(release)
+	c := stub.client(Config{Timeout: 20 * time.Millisecond, MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+	if _, err := c.VerifyToken(context.Background(), "slow"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	if got := stub.calls.Load(); got != 2 {
+		t.Errorf("made %d calls, want 2", got)
+	}
+}
+
+// The breaker opens after BreakerThreshold failed calls, lets one probe through once
+// the cooldown has passed, and closes again when it succeeds.
+func TestBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	stub := newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+		if failing.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		answer(w, map[string]any{"user": nil})
+	})
+	c := stub.client(Config{MaxRetries: 1, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetUser(context.Background(), "u1"); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("call %d: err = %v, want ErrUnavailable", i, err)
+		}
+	}
+	if _, err := c.GetUser(context.Background(), "u1"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("call with the breaker open: err = %v, want ErrUnavailable", err)
+	}
+	if got := stub.calls.Load(); got != 2 {
+		t.Fatalf("made %d calls, want the open breaker to skip the third", got)
+	}
+
+	failing.Store(false)
+	now = now.Add(time.Minute)
+	if _, err := c.GetUser(context.Background(), "u1"); err != nil {
+		t.Fatalf("probe after the cooldown: %v", err)
+	}
+	if _, err := c.GetUser(context.Background(), "u1"); err != nil {
+		t.Fatalf("call once the breaker closed: %v", err)
+	}
+	if got := stub.calls.Load(); got != 4 {
+		t.Errorf("made %d calls, want 4", got)
+	}
+}
+
+func TestGetUserRequestMemo(t *testing.T) {
+	stub := newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+		vars, _ := body.Variables.(map[string]any)
+		if vars["userId"] == "missing" {
+			answer(w, map[string]any{"user": nil})
+			return
+		}
+		answer(w, map[string]any{"user": map[string]any{"userId": vars["userId"], "username": "alice"}})
+	})
+	c := stub.client(Config{MaxRetries: 1})
+
+	ctx := WithRequestMemo(context.Background())
+	for i := 0; i < 3; i++ {
+		if user, err := c.GetUser(ctx, "u1"); err != nil || user == nil || user.Username != "alice" {
+			t.Fatalf("GetUser = %+v, %v, want alice", user, err)
+		}
+		if user, err := c.GetUser(ctx, "missing"); err != nil || user != nil {
+			t.Fatalf("GetUser(missing) = %+v, %v, want nil", user, err)
+		}
+	}
+	if got := stub.calls.Load(); got != 2 {
+		t.Errorf("made %d calls, want one per user", got)
+	}
+
+	// Another request looks the user up again.
+	if _, err := c.GetUser(WithRequestMemo(context.Background()), "u1"); err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got := stub.calls.Load(); got != 3 {
+		t.Errorf("made %d calls, want the new request to ask again", got)
+	}
+}
+
+func TestGetUsersPages(t *testing.T) {
+	const total = usersPageSize + 20
+	stub := newStubUserService(t, func(w http.ResponseWriter, body gqlRequest) {
+		vars, _ := body.Variables.(map[string]any)
+		offset := int(vars["offset"].(float64))
+		page := make([]User, 0, usersPageSize)
+		for i := offset; i < total && len(page) < usersPageSize; i++ {
+			page = append(page, User{UserID: "u", Role: "MEMBER"})
+		}
+		answer(w, map[string]any{"users": map[string]any{"totalCount": total, "users": page}})
+	})
+	c := stub.client(Config{MaxRetries: 1})
+
+	users, err := c.GetUsers(context.Background(), "MEMBER")
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	if len(users) != total {
+		t.Errorf("got %d users, want %d", len(users), total)
+	}
+	if got := stub.calls.Load(); got != 2 {
+		t.Errorf("made %d calls, want 2 pages", got)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{"", 0},
+		{"max-age=60", time.Minute},
+		{"private, max-age=15", 15 * time.Second},
+		{"Private, Max-Age=15", 15 * time.Second},
+		{"max-age=0", 0},
+		{"max-age=-5", 0},
+		{"max-age=soon", 0},
+		{"no-store", 0},
+		{"max-age=60, no-store", 0},
+		{"no-cache, max-age=60", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.cacheControl != "" {
+			header.Set("Cache-Control", tt.cacheControl)
+		}
+		if got := maxAge(header); got != tt.want {
+			t.Errorf("maxAge(%q) = %v, want %v", tt.cacheControl, got, tt.want)
+		}
+	}
+}
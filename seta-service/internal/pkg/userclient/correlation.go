@@ -0,0 +1,20 @@
+package userclient
+
+import "context"
+
+// CorrelationHeader carries a caller-chosen ID that the user service logs and echoes.
+const CorrelationHeader = "X-Correlation-ID"
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a context whose requests carry id, both in CorrelationHeader
+// and as the correlationId GraphQL extension, so a call can be found in the user
+// service's logs.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
@@ -0,0 +1,57 @@
+package userclient
+
+import (
+	"sync"
+	"time"
+)
+
+// maxEmailCacheEntries bounds memory use; expired entries are swept once it is exceeded.
+const maxEmailCacheEntries = 10000
+
+type emailCacheEntry struct {
+	user      User
+	expiresAt time.Time
+}
+
+// emailCache keeps the users found by email for Config.EmailCacheTTL, so a batch share
+// naming the same colleagues as the last one does not look them all up again. Emails
+// that match no user are not kept: the user may be created a moment later.
+type emailCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]emailCacheEntry
+}
+
+func (ec *emailCache) get(email string) (User, bool) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	entry, ok := ec.entries[email]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return User{}, false
+	}
+	return entry.user, true
+}
+
+func (ec *emailCache) put(email string, user User) {
+	if ec.ttl <= 0 {
+		return
+	}
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	now := time.Now()
+	if len(ec.entries) >= maxEmailCacheEntries {
+		for k, entry := range ec.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(ec.entries, k)
+			}
+		}
+		if len(ec.entries) >= maxEmailCacheEntries {
+			return
+		}
+	}
+	ec.entries[email] = emailCacheEntry{user: user, expiresAt: now.Add(ec.ttl)}
+}
@@ -0,0 +1,66 @@
+package userclient
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxMemoEntries bounds a request's memo; lookups past it go to the user service.
+const maxMemoEntries = 100
+
+var memoHits = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "userclient_request_memo_hits_total",
+	Help: "User lookups answered by the request-scoped memo instead of the user service.",
+})
+
+type memoKey struct{}
+
+// memo remembers the users looked up while serving one request. Misses (nil users) are
+// remembered too so a repeated "does this user exist" check is also free.
+type memo struct {
+	mu    sync.Mutex
+	users map[string]*User
+}
+
+// WithRequestMemo returns a context whose GetUser lookups are memoized until the context
+// is dropped. Call it once per request; the memo is never shared between requests.
+func WithRequestMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, memoKey{}, &memo{users: make(map[string]*User)})
+}
+
+// Remember records a user already known to the caller, such as the one a token was just
+// verified for, in the request memo. It is a no-op without one.
+func Remember(ctx context.Context, user User) {
+	store(ctx, user.UserID, &user)
+}
+
+func recall(ctx context.Context, userID string) (*User, bool) {
+	m, _ := ctx.Value(memoKey{}).(*memo)
+	if m == nil {
+		return nil, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[userID]
+	if ok {
+		memoHits.Inc()
+	}
+	return user, ok
+}
+
+func store(ctx context.Context, userID string, user *User) {
+	m, _ := ctx.Value(memoKey{}).(*memo)
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; ok || len(m.users) < maxMemoEntries {
+		m.users[userID] = user
+	}
+}
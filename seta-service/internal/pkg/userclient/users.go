@@ -0,0 +1,156 @@
+package userclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// User is the subset of the user-service User type seta-service relies on.
+type User struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+}
+
+// Verification is the result of VerifyToken. TTL is the Cache-Control max-age the user
+// service attached to the answer; zero means the result must not be cached.
+type Verification struct {
+	Valid bool
+	User  User
+	TTL   time.Duration
+}
+
+// CreateUserInput mirrors the user-service CreateUserInput.
+type CreateUserInput struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// VerifyToken asks the user service whether token is a valid access token.
+// An invalid token is not an error: it is reported with Valid set to false.
+func (c *Client) VerifyToken(ctx context.Context, token string) (Verification, error) {
+	var data struct {
+		VerifyToken struct {
+			Success bool `json:"success"`
+			User    User `json:"user"`
+		} `json:"verifyToken"`
+	}
+
+	header, err := c.do(ctx, `
+		query VerifyToken($token: String!) {
+			verifyToken(token: $token) {
+				success
+				user { userId username email role }
+			}
+		}`, map[string]any{"token": token}, &data)
+	if err != nil {
+		return Verification{}, err
+	}
+
+	return Verification{
+		Valid: data.VerifyToken.Success,
+		User:  data.VerifyToken.User,
+		TTL:   maxAge(header),
+	}, nil
+}
+
+// GetUser fetches a single user. It returns nil when the user does not exist.
+// Answers are memoized for the request when ctx carries a WithRequestMemo memo.
+func (c *Client) GetUser(ctx context.Context, userID string) (*User, error) {
+	if user, ok := recall(ctx, userID); ok {
+		return user, nil
+	}
+
+	var data struct {
+		User *User `json:"user"`
+	}
+
+	if _, err := c.do(ctx, `
+		query User($userId: ID!) {
+			user(userId: $userId) { userId username email role }
+		}`, map[string]any{"userId": userID}, &data); err != nil {
+		return nil, err
+	}
+	store(ctx, userID, data.User)
+	return data.User, nil
+}
+
+// GetUserByEmail finds the user with exactly this email. It returns nil when there is
+// none. Users found are kept for EmailCacheTTL and put in the request memo.
+func (c *Client) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if user, ok := c.emails.get(email); ok {
+		return &user, nil
+	}
+
+	var data struct {
+		User *User `json:"userByEmail"`
+	}
+
+	if _, err := c.do(ctx, `
+		query UserByEmail($email: String!) {
+			userByEmail(email: $email) { userId username email role }
+		}`, map[string]any{"email": email}, &data); err != nil {
+		return nil, err
+	}
+	if data.User != nil {
+		c.emails.put(email, *data.User)
+		Remember(ctx, *data.User)
+	}
+	return data.User, nil
+}
+
+// usersPageSize is the most users the user service returns per page.
+const usersPageSize = 100
+
+// GetUsers lists every user with the given role (MANAGER or MEMBER), a page at a time.
+// The user service only lists users for managers, so this needs InternalAPIKey.
+func (c *Client) GetUsers(ctx context.Context, role string) ([]User, error) {
+	var users []User
+	for {
+		var data struct {
+			Users struct {
+				TotalCount int    `json:"totalCount"`
+				Users      []User `json:"users"`
+			} `json:"users"`
+		}
+
+		if _, err := c.do(ctx, `
+			query Users($role: UserType!, $limit: Int!, $offset: Int!) {
+				users(role: $role, limit: $limit, offset: $offset) {
+					totalCount
+					users { userId username email role }
+				}
+			}`, map[string]any{"role": role, "limit": usersPageSize, "offset": len(users)}, &data); err != nil {
+			return nil, err
+		}
+		users = append(users, data.Users.Users...)
+		if len(data.Users.Users) == 0 || len(users) >= data.Users.TotalCount {
+			return users, nil
+		}
+	}
+}
+
+// CreateUser creates a user and returns the user-service validation errors, if any, as an error.
+func (c *Client) CreateUser(ctx context.Context, input CreateUserInput) error {
+	var data struct {
+		CreateUser struct {
+			Success bool     `json:"success"`
+			Errors  []string `json:"errors"`
+		} `json:"createUser"`
+	}
+
+	if _, err := c.do(ctx, `
+		mutation CreateUser($input: CreateUserInput!) {
+			createUser(input: $input) { success errors }
+		}`, map[string]any{"input": input}, &data); err != nil {
+		return err
+	}
+	if !data.CreateUser.Success {
+		return fmt.Errorf("API error: %v", data.CreateUser.Errors)
+	}
+	return nil
+}
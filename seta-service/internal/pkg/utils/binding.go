@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"seta/internal/pkg/errorHandling"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+var registerJSONNames sync.Once
+
+// BindJSON strictly decodes the request body into obj: unknown fields and wrong types
+// are rejected, then the `binding` tags are validated. Every problem is reported as a
+// field error so clients can fix all of them in one round trip.
+func BindJSON(c *gin.Context, obj any) error {
+	registerJSONNames.Do(useJSONFieldNames)
+
+	if c.Request.Body == nil {
+		return &errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Request body is required"}
+	}
+
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return decodeError(err)
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return validationError(err)
+	}
+
+	return nil
+}
+
+// useJSONFieldNames makes validator report `accessLevel` rather than `AccessLevel`.
+func useJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+}
+
+func decodeError(err error) *errorHandling.CustomError {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &typeErr):
+		return invalidBody(errorHandling.FieldError{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Param:   typeErr.Type.String(),
+			Message: fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type.String()),
+		})
+	case errors.As(err, &syntaxErr):
+		return &errorHandling.CustomError{Code: http.StatusBadRequest, Message: fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return invalidBody(errorHandling.FieldError{
+			Field:   field,
+			Rule:    "unknown",
+			Message: fmt.Sprintf("%s is not a known field", field),
+		})
+	case errors.Is(err, io.EOF):
+		return &errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Request body is required"}
+	default:
+		return &errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+}
+
+func validationError(err error) *errorHandling.CustomError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return &errorHandling.CustomError{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	fieldErrors := make([]errorHandling.FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fieldErrors = append(fieldErrors, fieldError(fe))
+	}
+	return invalidBody(fieldErrors...)
+}
+
+func fieldError(fe validator.FieldError) errorHandling.FieldError {
+	// Namespace is "Struct.field.nested"; drop the root struct name.
+	field := fe.Namespace()
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+
+	out := errorHandling.FieldError{Field: field, Rule: fe.Tag(), Param: fe.Param()}
+	switch fe.Tag() {
+	case "required":
+		out.Message = fmt.Sprintf("%s is required", field)
+	case "oneof":
+		out.Allowed = strings.Fields(fe.Param())
+		out.Message = fmt.Sprintf("%s must be one of: %s", field, strings.Join(out.Allowed, ", "))
+	case "min":
+		out.Message = fmt.Sprintf("%s must have at least %s item(s) or characters", field, fe.Param())
+	case "max":
+		out.Message = fmt.Sprintf("%s must have at most %s item(s) or characters", field, fe.Param())
+	default:
+		out.Message = fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+	}
+	return out
+}
+
+func invalidBody(fieldErrors ...errorHandling.FieldError) *errorHandling.CustomError {
+	return &errorHandling.CustomError{
+		Code:        http.StatusBadRequest,
+		Message:     "Invalid request body",
+		FieldErrors: fieldErrors,
+	}
+}
+
+// OptionalUUID is a body field that may be left out, set to null or set to an ID, for
+// updates where null has a meaning of its own such as "move to the top level".
+type OptionalUUID struct {
+	Set   bool
+	Value *uuid.UUID
+}
+
+func (o *OptionalUUID) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"seta-pkg/logging"
+	"seta/internal/pkg/errorHandling"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// shareInput mirrors the share DTOs, with a nested list like the batch variant.
+type shareInput struct {
+	UserID uuid.UUID `json:"userId" binding:"required"`
+	Access string    `json:"access" binding:"required,oneof=read write"`
+	Notify bool      `json:"notify"`
+	Items  []struct {
+		Access string `json:"access" binding:"oneof=read write"`
+	} `json:"items" binding:"dive"`
+}
+
+func bindBody(t *testing.T, body string) error {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	var input shareInput
+	return BindJSON(c, &input)
+}
+
+func TestBindJSON(t *testing.T) {
+	userID := uuid.NewString()
+	tests := []struct {
+		name string
+		body string
+		want []errorHandling.FieldError // nil when the body binds
+	}{
+		{"valid", `{"userId":"` + userID + `","access":"write"}`, nil},
+		{"unknown field", `{"userId":"` + userID + `","acess":"write","access":"read"}`, []errorHandling.FieldError{
+			{Field: "acess", Rule: "unknown"},
+		}},
+		{"wrong type", `{"userId":"` + userID + `","access":"read","notify":"yes"}`, []errorHandling.FieldError{
+			{Field: "notify", Rule: "type", Param: "bool"},
+		}},
+		{"several invalid fields", `{"access":"admin","items":[{"access":"read"},{"access":"owner"}]}`, []errorHandling.FieldError{
+			{Field: "userId", Rule: "required"},
+			{Field: "access", Rule: "oneof", Param: "read write", Allowed: []string{"read", "write"}},
+			{Field: "items[1].access", Rule: "oneof", Param: "read write", Allowed: []string{"read", "write"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bindBody(t, tt.body)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("BindJSON: %v", err)
+				}
+				return
+			}
+			var customErr *errorHandling.CustomError
+			if !errors.As(err, &customErr) || customErr.Code != http.StatusBadRequest {
+				t.Fatalf("err = %v, want a 400", err)
+			}
+			if len(customErr.FieldErrors) != len(tt.want) {
+				t.Fatalf("field errors = %+v, want %+v", customErr.FieldErrors, tt.want)
+			}
+			for i, want := range tt.want {
+				got := customErr.FieldErrors[i]
+				if got.Field != want.Field || got.Rule != want.Rule || got.Param != want.Param || !slices.Equal(got.Allowed, want.Allowed) {
+					t.Errorf("field error %d = %+v, want %+v", i, got, want)
+				}
+				if !strings.HasPrefix(got.Message, want.Field+" ") {
+					t.Errorf("message %q does not name %s", got.Message, want.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestBindJSONMalformedBody(t *testing.T) {
+	for _, body := range []string{"", `{"access":`, `["read"]`} {
+		var customErr *errorHandling.CustomError
+		if err := bindBody(t, body); !errors.As(err, &customErr) || customErr.Code != http.StatusBadRequest {
+			t.Errorf("BindJSON(%q) = %v, want a 400", body, err)
+		}
+	}
+}
+
+// The error handler returns the field errors in the envelope, with the messages naming
+// the field and the values it allows.
+func TestBindJSONErrorEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(errorHandling.ErrorHandler(logging.Nop()))
+	r.POST("/share", func(c *gin.Context) {
+		var input shareInput
+		if err := BindJSON(c, &input); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/share", strings.NewReader(`{"access":"admin"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+	var body struct {
+		Error errorHandling.ErrorBody `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	fieldErrors := body.Error.FieldErrors
+	if len(fieldErrors) != 2 || fieldErrors[0].Field != "userId" || fieldErrors[1].Field != "access" {
+		t.Fatalf("fieldErrors = %+v, want userId and access", fieldErrors)
+	}
+	if msg := fieldErrors[1].Message; !strings.Contains(msg, "access") || !strings.Contains(msg, "read") || !strings.Contains(msg, "write") {
+		t.Errorf("access message = %q, want the field and its allowed values", msg)
+	}
+}
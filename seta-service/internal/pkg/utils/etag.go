@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSONWithETag writes obj as JSON with a strong ETag hashed from the body. When the
+// request's If-None-Match already names that ETag it answers 304 without a body. Every
+// write that changes the resource changes the body, and so the ETag.
+func JSONWithETag(c *gin.Context, code int, obj any) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	etag := bodyETag(body)
+	c.Header("ETag", etag)
+	if ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(code, "application/json; charset=utf-8", body)
+}
+
+// ETag returns the ETag JSONWithETag sends for obj, so a write can check an If-Match
+// against the resource as it is now.
+func ETag(obj any) (string, error) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return bodyETag(body), nil
+}
+
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETagMatches reports whether an If-None-Match or If-Match header names etag, with the
+// weak comparison RFC 9110 prescribes for If-None-Match.
+func ETagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
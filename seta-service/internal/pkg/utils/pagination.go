@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"seta/internal/pkg/errorHandling"
+	"seta/internal/pkg/httpquery"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultAssetPageSize = 50
+	MaxAssetPageSize     = 200
+
+	// assetCursorTTL limits how long a cursor is honoured, so clients restart from the
+	// first page instead of resuming an ordering that has drifted far from the data.
+	assetCursorTTL = time.Hour
+)
+
+// CursorPosition is the sort key of the last row a page returned.
+type CursorPosition struct {
+	At time.Time `json:"t"`
+	ID uuid.UUID `json:"id"`
+}
+
+// AssetCursor is where the next page of a folders+notes listing starts. Each list is
+// paged on its own; once one is exhausted later pages return it empty.
+type AssetCursor struct {
+	Folders     *CursorPosition `json:"f,omitempty"`
+	Notes       *CursorPosition `json:"n,omitempty"`
+	FoldersDone bool            `json:"fd,omitempty"`
+	NotesDone   bool            `json:"nd,omitempty"`
+	IssuedAt    time.Time       `json:"iat"`
+}
+
+// IsFirstPage reports whether the cursor is the zero cursor of a request without ?cursor.
+func (cur AssetCursor) IsFirstPage() bool {
+	return cur.IssuedAt.IsZero()
+}
+
+// Next encodes the cursor following a page whose lists ended at folders and notes (nil
+// when that list has no more rows). It returns "" on the last page.
+func (cur AssetCursor) Next(folders, notes *CursorPosition) string {
+	next := AssetCursor{
+		Folders:     folders,
+		Notes:       notes,
+		FoldersDone: cur.FoldersDone || folders == nil,
+		NotesDone:   cur.NotesDone || notes == nil,
+		IssuedAt:    time.Now().UTC(),
+	}
+	if next.FoldersDone && next.NotesDone {
+		return ""
+	}
+	encoded, _ := json.Marshal(next)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// GetAssetPageParams reads ?limit (default 50, max 200) and ?cursor. A cursor that does
+// not decode or has expired is a 400 so clients restart from the first page.
+func GetAssetPageParams(c *gin.Context) (int, AssetCursor, error) {
+	query := httpquery.New(c.Request.URL.Query())
+	limit := query.Int("limit", DefaultAssetPageSize, 1, MaxAssetPageSize)
+	if err := query.Err(); err != nil {
+		return 0, AssetCursor{}, err
+	}
+
+	var cursor AssetCursor
+	raw := c.Query("cursor")
+	if raw == "" {
+		return limit, cursor, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || json.Unmarshal(decoded, &cursor) != nil || cursor.IssuedAt.IsZero() {
+		return 0, AssetCursor{}, &errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Invalid cursor"}
+	}
+	if time.Since(cursor.IssuedAt) > assetCursorTTL {
+		return 0, AssetCursor{}, &errorHandling.CustomError{Code: http.StatusBadRequest, Message: "Cursor has expired, restart from the first page"}
+	}
+	return limit, cursor, nil
+}
+
+// KeysetPage orders query newest first by timeColumn then idColumn, resumes after the
+// given position and fetches one row past limit so TrimPage can tell if more remain.
+func KeysetPage(query *gorm.DB, timeColumn, idColumn string, after *CursorPosition, limit int) *gorm.DB {
+	if after != nil {
+		query = query.Where("("+timeColumn+", "+idColumn+") < (?, ?)", after.At, after.ID)
+	}
+	return query.Order(timeColumn + " DESC, " + idColumn + " DESC").Limit(limit + 1)
+}
+
+// TrimPage cuts the extra row fetched by KeysetPage and returns the position to resume
+// from, or nil when this was the list's last page.
+func TrimPage[T any](rows []T, limit int, key func(T) CursorPosition) ([]T, *CursorPosition) {
+	if len(rows) <= limit {
+		return rows, nil
+	}
+	rows = rows[:limit]
+	last := key(rows[limit-1])
+	return rows, &last
+}
@@ -1,3 +1,14 @@
+-- Databases created before accounts could be deactivated lack these columns; sync()
+-- only creates missing tables.
+ALTER TABLE "Users" ADD COLUMN IF NOT EXISTS "active" BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE "Users" ADD COLUMN IF NOT EXISTS "deactivatedAt" TIMESTAMP WITH TIME ZONE;
+
+-- The users query searches both columns with ILIKE '%term%', which only trigram indexes
+-- can serve.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS "users_username_trgm" ON "Users" USING gin (username gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS "users_email_trgm" ON "Users" USING gin (email gin_trgm_ops);
+
 -- Insert Users
 INSERT INTO "Users" ("userId", username, email, password, role, "createdAt", "updatedAt") VALUES
 ('a1a1a1a1-a1a1-a1a1-a1a1-a1a1a1a1a1a1', 'Alice', 'alice@example.com', '$2b$10$f.2V43IZDKoq3YtB2fu4IOhK762U0BVydmntsnvOMlrJeygLpzZuW', 'MANAGER', NOW(), NOW()),
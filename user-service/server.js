@@ -1,6 +1,7 @@
 import { ApolloServer } from "@apollo/server";
 import { expressMiddleware } from "@apollo/server/express4";
 import { ApolloServerPluginDrainHttpServer } from "@apollo/server/plugin/drainHttpServer";
+import { ApolloServerPluginCacheControl } from "@apollo/server/plugin/cacheControl";
 import chalk from "chalk";
 import express from "express";
 import http from "http";
@@ -10,6 +11,7 @@ import { fileURLToPath } from "url";
 import path from "path";
 import fs from "fs";
 import resolvers from "./src/resolvers/resolvers.js";
+import { depthLimitRule, queryGuardsPlugin } from "./src/utils/queryGuards.js";
 import db from "./src/config/sequelize.js";
 import dotenv from "dotenv";
 
@@ -43,7 +45,13 @@ const server = new ApolloServer({
     "utf8"
   ),
   resolvers,
-  plugins: [ApolloServerPluginDrainHttpServer({ httpServer })],
+  validationRules: [depthLimitRule],
+  plugins: [
+    ApolloServerPluginDrainHttpServer({ httpServer }),
+    // Only emit Cache-Control for cacheable responses so resolvers can set their own hint.
+    ApolloServerPluginCacheControl({ calculateHttpHeaders: "if-cacheable" }),
+    queryGuardsPlugin,
+  ],
 });
 await server.start();
 
@@ -59,6 +67,13 @@ const corsOptions = {
 //   credentials: true,
 // };
 
+// Echo the caller's correlation ID so both sides of a call can be matched up in logs.
+app.use((req, res, next) => {
+  const correlationId = req.get("X-Correlation-ID");
+  if (correlationId) res.set("X-Correlation-ID", correlationId);
+  next();
+});
+
 // to deal with Apollo Server's built-in Express middleware not being compatible with express 5
 app.use((req, res, next) => {
   req.body = req.body || {};
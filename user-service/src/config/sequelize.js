@@ -3,6 +3,8 @@ import { Sequelize, DataTypes } from "sequelize";
 import userModel from "../models/userModel.js";
 import teamModel from "../models/teamModel.js";
 import rosterModel from "../models/rosterModel.js";
+import revokedTokenModel from "../models/revokedTokenModel.js";
+import sessionCutoffModel from "../models/sessionCutoffModel.js";
 
 import dotenv from "dotenv";
 import { fileURLToPath } from "url";
@@ -16,6 +18,8 @@ dotenv.config({ path: path.resolve(__dirname, "../../../.env") });
 const conn =
   process.env.NODE_ENV === "development"
     ? dbConfig.development
+    : process.env.NODE_ENV === "test"
+    ? dbConfig.test
     : dbConfig.production;
 
 const sequelize = new Sequelize(conn.database, conn.username, conn.password, {
@@ -41,6 +45,10 @@ db.Sequelize = Sequelize; // refer to the library
 db.sequelize = sequelize; // refer to an instance of Sequelize
 
 db.User = userModel(sequelize, DataTypes);
+db.Team = teamModel(sequelize, DataTypes);
+db.Roster = rosterModel(sequelize, DataTypes);
+db.RevokedToken = revokedTokenModel(sequelize, DataTypes);
+db.SessionCutoff = sessionCutoffModel(sequelize, DataTypes);
 
 // set up associations
 Object.keys(db).forEach((modelName) => {
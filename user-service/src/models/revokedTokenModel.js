@@ -0,0 +1,32 @@
+// One row per token revoked by logout. Rows are kept until the token would have expired
+// anyway, then pruned.
+const revokedTokenModel = (sequelize, DataTypes) => {
+  const RevokedToken = sequelize.define(
+    "RevokedToken",
+    {
+      jti: {
+        type: DataTypes.UUID,
+        primaryKey: true,
+        allowNull: false,
+      },
+      userId: {
+        type: DataTypes.UUID,
+        allowNull: false,
+      },
+      expiresAt: {
+        type: DataTypes.DATE,
+        allowNull: false,
+      },
+    },
+    {
+      tableName: "RevokedTokens",
+      timestamps: true,
+      updatedAt: false,
+      indexes: [{ fields: ["expiresAt"] }],
+    }
+  );
+
+  return RevokedToken;
+};
+
+export default revokedTokenModel;
@@ -13,7 +13,8 @@ const rosterModel = (sequelize, DataTypes) => {
         onDelete: "CASCADE",
       },
       userId: {
-        type: DataTypes.INTEGER,
+        // Users are keyed by UUID.
+        type: DataTypes.UUID,
         references: { model: "Users", key: "userId" },
         onDelete: "CASCADE",
       },
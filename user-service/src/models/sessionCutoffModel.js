@@ -0,0 +1,26 @@
+// Set by "log out of all sessions": every token the user was issued before invalidBefore
+// is rejected, whether or not it carries a jti.
+const sessionCutoffModel = (sequelize, DataTypes) => {
+  const SessionCutoff = sequelize.define(
+    "SessionCutoff",
+    {
+      userId: {
+        type: DataTypes.UUID,
+        primaryKey: true,
+        allowNull: false,
+      },
+      invalidBefore: {
+        type: DataTypes.DATE,
+        allowNull: false,
+      },
+    },
+    {
+      tableName: "SessionCutoffs",
+      timestamps: false,
+    }
+  );
+
+  return SessionCutoff;
+};
+
+export default sessionCutoffModel;
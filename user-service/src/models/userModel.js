@@ -34,6 +34,16 @@ const userModel = (sequelize, DataTypes) => {
         type: DataTypes.ENUM("MANAGER", "MEMBER"),
         allowNull: false,
       },
+      // Deactivated users can no longer log in and their tokens stop verifying.
+      active: {
+        type: DataTypes.BOOLEAN,
+        allowNull: false,
+        defaultValue: true,
+      },
+      deactivatedAt: {
+        type: DataTypes.DATE,
+        allowNull: true,
+      },
     },
     {
       tableName: "Users",
@@ -1,11 +1,20 @@
 import db from "../config/sequelize.js";
 import jwt from "jsonwebtoken";
+import { timingSafeEqual } from "crypto";
+import { GraphQLError } from "graphql";
+import { Op } from "sequelize";
 import bcrypt from "bcryptjs";
 import { DateTimeResolver } from "graphql-scalars";
 import {
   generateAccessToken,
   generateRefreshToken,
 } from "../utils/generateTokens.js";
+import {
+  isRevoked,
+  notifyRevocation,
+  revokeAllSessions,
+  revokeToken,
+} from "../utils/revocation.js";
 import dotenv from "dotenv";
 import { fileURLToPath } from "url";
 import path from "path";
@@ -19,12 +28,72 @@ const user = db.User;
 const team = db.Team;
 const roster = db.Roster;
 
+// Returns the decoded access token from the Authorization header, or null.
+const verifyBearer = (req) => {
+  const [scheme, token] = (req.headers.authorization || "").split(" ");
+  if (scheme !== "Bearer" || !token) return null;
+  try {
+    return jwt.verify(token, process.env.ACCESS_TOKEN_SECRET);
+  } catch {
+    return null;
+  }
+};
+
+// Returns the user the request's bearer token belongs to, or null when the token is
+// missing, invalid or revoked or the user is gone or deactivated. The role is read from
+// that user rather than from the token, so it is never older than the last update.
+const authenticatedCaller = async (req) => {
+  const decoded = verifyBearer(req);
+  if (!decoded || (await isRevoked(decoded))) return null;
+  const caller = await user.findByPk(decoded.userId);
+  return caller && caller.active ? caller : null;
+};
+
+// Reports whether the request carries INTERNAL_API_KEY in X-Internal-API-Key, as
+// seta-service's calls do; they have no user token to go by.
+const hasInternalKey = (req) => {
+  const expected = Buffer.from(process.env.INTERNAL_API_KEY || "");
+  const provided = Buffer.from(req.get("X-Internal-API-Key") || "");
+  return (
+    expected.length > 0 &&
+    provided.length === expected.length &&
+    timingSafeEqual(provided, expected)
+  );
+};
+
+const forbidden = (message) =>
+  new GraphQLError(message, { extensions: { code: "FORBIDDEN" } });
+
+const unauthenticated = () =>
+  new GraphQLError("Invalid or expired token", {
+    extensions: { code: "UNAUTHENTICATED" },
+  });
+
+const defaultUsersLimit = 20;
+const maxUsersLimit = 100;
+
+// Escapes the LIKE wildcards of a search term, so that it only matches literally.
+const likePattern = (search) => `%${search.replace(/[\\%_]/g, "\\$&")}%`;
+
+const validationErrorCode = (err) =>
+  err.name === "SequelizeUniqueConstraintError" ||
+  err.name === "SequelizeValidationError"
+    ? "400"
+    : "500";
+
 const resolvers = {
   DateTime: DateTimeResolver,
   Query: {
-    verifyToken: async (_, { token }) => {
+    verifyToken: async (_, { token }, { res }) => {
       try {
         const decoded = jwt.verify(token, process.env.ACCESS_TOKEN_SECRET);
+        if (await isRevoked(decoded)) {
+          return {
+            code: "401",
+            success: false,
+            message: "Token has been revoked",
+          };
+        }
         const user = await db.User.findByPk(decoded.userId);
 
         if (!user) {
@@ -34,9 +103,25 @@ const resolvers = {
             message: "User not found",
           };
         }
+        if (!user.active) {
+          return {
+            code: "403",
+            success: false,
+            message: "This account has been deactivated",
+          };
+        }
 
         const { password: _, ...safeUser } = user.get({ plain: true });
 
+        // Let callers cache the answer, but never past the token's own expiry.
+        const maxAge = Math.min(
+          Number(process.env.VERIFY_TOKEN_CACHE_SECONDS) || 60,
+          decoded.exp - Math.floor(Date.now() / 1000)
+        );
+        if (maxAge > 0) {
+          res.setHeader("Cache-Control", `private, max-age=${maxAge}`);
+        }
+
         return {
           code: "200",
           success: true,
@@ -52,9 +137,46 @@ const resolvers = {
         };
       }
     },
-    users: async (_, { role }) => {
-      return await user.findAll({
-        where: { role },
+    // Members cannot list users, only look one up by email with userByEmail.
+    users: async (_, { search, role, limit, offset }, { req }) => {
+      if (!hasInternalKey(req)) {
+        const caller = await authenticatedCaller(req);
+        if (!caller) throw unauthenticated();
+        if (caller.role !== "MANAGER") {
+          throw forbidden("Only managers can list users");
+        }
+      }
+
+      const where = {};
+      if (role) where.role = role;
+      if (search) {
+        // Both columns have trigram indexes, see init_db.sql.
+        const pattern = likePattern(search);
+        where[Op.or] = [
+          { username: { [Op.iLike]: pattern } },
+          { email: { [Op.iLike]: pattern } },
+        ];
+      }
+
+      const { count, rows } = await user.findAndCountAll({
+        where,
+        attributes: { exclude: ["password"] },
+        order: [
+          ["username", "ASC"],
+          ["userId", "ASC"],
+        ],
+        limit: Math.min(Math.max(limit ?? defaultUsersLimit, 1), maxUsersLimit),
+        offset: Math.max(offset ?? 0, 0),
+      });
+      return { totalCount: count, users: rows };
+    },
+    userByEmail: async (_, { email }, { req }) => {
+      if (!hasInternalKey(req) && !(await authenticatedCaller(req))) {
+        throw unauthenticated();
+      }
+      return await user.findOne({
+        where: { email },
+        attributes: { exclude: ["password"] },
       });
     },
     user: async (_, { userId }) => {
@@ -167,10 +289,32 @@ const resolvers = {
         teamName: roster.team.teamName,
       }));
     },
+    isTeamManager: async (_, { teamId, userId }, { req }) => {
+      if (!hasInternalKey(req)) {
+        const caller = await authenticatedCaller(req);
+        if (!caller) throw unauthenticated();
+        if (caller.role !== "MANAGER" && String(caller.userId) !== userId) {
+          throw forbidden("Only managers can ask about other users");
+        }
+      }
+
+      const count = await roster.count({
+        where: { teamId, userId },
+        include: [
+          {
+            model: user,
+            as: "user",
+            attributes: [],
+            where: { role: "MANAGER" },
+          },
+        ],
+      });
+      return count > 0;
+    },
   },
 
   Mutation: {
-    createUser: async (_, args) => {
+    createUser: async (_, args, context) => {
       const { username, email, password, role } = args.input;
       try {
         const userRes = await user.create({
@@ -186,6 +330,11 @@ const resolvers = {
           user: userRes,
         };
       } catch (err) {
+        console.warn("createUser failed", {
+          correlationId: context.req.get("X-Correlation-ID") ?? context.req.body?.extensions?.correlationId ?? null,
+          email,
+          error: err.message,
+        });
         return {
           code:
             err.name === "SequelizeUniqueConstraintError" ||
@@ -199,34 +348,144 @@ const resolvers = {
       }
     },
 
-    updateUser: async (_, { userId, username, email }) => {
+    // Users may change their own username and email; managers may also change those of
+    // other users and their role. Nobody changes their own role.
+    updateUser: async (_, { userId, input }, context) => {
+      const caller = await authenticatedCaller(context.req);
+      if (!caller) {
+        return {
+          code: "401",
+          success: false,
+          message: "Invalid or expired token",
+          user: null,
+        };
+      }
+
+      const isManager = caller.role === "MANAGER";
+      const isSelf = caller.userId === userId;
+      if (!isSelf && !isManager) {
+        return {
+          code: "403",
+          success: false,
+          message: "You can only update your own profile",
+          user: null,
+        };
+      }
+      if (input.role != null && (isSelf || !isManager)) {
+        return {
+          code: "403",
+          success: false,
+          message: isSelf
+            ? "You cannot change your own role"
+            : "Only managers can change roles",
+          user: null,
+        };
+      }
+
+      const changes = {};
+      if (input.username != null) changes.username = input.username;
+      if (input.email != null) changes.email = input.email;
+      if (input.role != null) changes.role = input.role;
+      if (Object.keys(changes).length === 0) {
+        return {
+          code: "400",
+          success: false,
+          message: "Nothing to update: pass username, email or role",
+          user: null,
+        };
+      }
+
       try {
-        await user.update(
-          {
-            username,
-            email,
-          },
-          {
-            where: {
-              userId,
-            },
-          }
-        );
+        const target = await user.findByPk(userId);
+        if (!target) {
+          return {
+            code: "404",
+            success: false,
+            message: "User not found",
+            user: null,
+          };
+        }
+        await target.update(changes);
+        // seta-service caches the user with each verified token; make it look again.
+        await notifyRevocation({ userId });
+
+        const { password: _, ...safeUser } = target.get({ plain: true });
         return {
           code: "200",
           success: true,
-          message: `Updated ${username}'s profile`,
+          message: `Updated ${target.username}'s profile`,
+          user: safeUser,
+        };
+      } catch (err) {
+        return {
+          code: validationErrorCode(err),
+          success: false,
+          errors: err.errors ? err.errors.map((error) => error.message) : [err.message],
           user: null,
         };
+      }
+    },
+
+    // Managers deactivate other users' accounts. Every token of the user is revoked, so
+    // they are logged out everywhere, and Login and verifyToken reject them from now on.
+    deactivateUser: async (_, { userId }, context) => {
+      const caller = await authenticatedCaller(context.req);
+      if (!caller) {
+        return {
+          code: "401",
+          success: false,
+          message: "Invalid or expired token",
+          user: null,
+        };
+      }
+      if (caller.role !== "MANAGER") {
+        return {
+          code: "403",
+          success: false,
+          message: "Only managers can deactivate accounts",
+          user: null,
+        };
+      }
+      if (caller.userId === userId) {
+        return {
+          code: "403",
+          success: false,
+          message: "You cannot deactivate your own account",
+          user: null,
+        };
+      }
+
+      try {
+        const target = await user.findByPk(userId);
+        if (!target) {
+          return {
+            code: "404",
+            success: false,
+            message: "User not found",
+            user: null,
+          };
+        }
+
+        if (target.active) {
+          await target.update({ active: false, deactivatedAt: new Date() });
+          await revokeAllSessions(userId);
+          await notifyRevocation({ userId });
+        }
+
+        const { password: _, ...safeUser } = target.get({ plain: true });
+        return {
+          code: "200",
+          success: true,
+          message: `Deactivated ${target.username}`,
+          user: safeUser,
+        };
       } catch (err) {
+        console.error(err);
         return {
-          code:
-            err.name === "SequelizeUniqueConstraintError" ||
-            "SequelizeValidationError"
-              ? "400"
-              : "500",
+          code: "500",
           success: false,
-          errors: err.errors ? err.errors.map((error) => error.message) : null,
+          message: "Deactivation failed",
+          errors: [err.message],
           user: null,
         };
       }
@@ -248,6 +507,16 @@ const resolvers = {
         }
 
         const isVerified = await bcrypt.compare(password, result.password);
+        if (isVerified && !result.active) {
+          return {
+            code: "403",
+            success: false,
+            message: "This account has been deactivated",
+            accessToken: null,
+            refreshToken: null,
+            user: null,
+          };
+        }
 
         if (result && isVerified) {
           const refreshToken = generateRefreshToken(result);
@@ -296,6 +565,86 @@ const resolvers = {
       }
     },
 
+    // Revokes the bearer token of the request and, when the cookie is sent, the refresh
+    // token it came with.
+    logout: async (_, __, context) => {
+      const decoded = verifyBearer(context.req);
+      if (!decoded) {
+        return {
+          code: "401",
+          success: false,
+          message: "Invalid or expired token",
+        };
+      }
+
+      try {
+        if (!(await revokeToken(decoded))) {
+          return {
+            code: "400",
+            success: false,
+            message: "This token cannot be revoked on its own, use logoutAllSessions",
+          };
+        }
+        await notifyRevocation({ token: context.req.headers.authorization.split(" ")[1] });
+
+        const refreshToken = context.req.cookies?.refreshToken;
+        if (refreshToken) {
+          try {
+            const refresh = jwt.verify(refreshToken, process.env.REFRESH_TOKEN_SECRET);
+            if (refresh.userId === decoded.userId) await revokeToken(refresh);
+          } catch {
+            // An invalid refresh token cannot be used anyway.
+          }
+          context.res.clearCookie("refreshToken");
+        }
+
+        return {
+          code: "200",
+          success: true,
+          message: "Logged out",
+        };
+      } catch (err) {
+        console.error(err);
+        return {
+          code: "500",
+          success: false,
+          message: "Logout failed",
+          errors: [err.message],
+        };
+      }
+    },
+
+    // Revokes every access and refresh token issued to the caller so far.
+    logoutAllSessions: async (_, __, context) => {
+      const decoded = verifyBearer(context.req);
+      if (!decoded) {
+        return {
+          code: "401",
+          success: false,
+          message: "Invalid or expired token",
+        };
+      }
+
+      try {
+        await revokeAllSessions(decoded.userId);
+        await notifyRevocation({ userId: decoded.userId });
+        context.res.clearCookie("refreshToken");
+        return {
+          code: "200",
+          success: true,
+          message: "Logged out of all sessions",
+        };
+      } catch (err) {
+        console.error(err);
+        return {
+          code: "500",
+          success: false,
+          message: "Logout failed",
+          errors: [err.message],
+        };
+      }
+    },
+
     renewToken: async (_, { userId }, context) => {
       const refreshToken = context.req.cookies.refreshToken;
       //console.log(refreshToken);
@@ -316,7 +665,7 @@ const resolvers = {
           refreshToken,
           process.env.REFRESH_TOKEN_SECRET
         );
-        if (decoded.userId !== userId) {
+        if (decoded.userId !== userId || (await isRevoked(decoded))) {
           return {
             code: "401",
             success: false,
@@ -0,0 +1,233 @@
+import { after, before, describe, test } from "node:test";
+import assert from "node:assert/strict";
+import { randomUUID } from "crypto";
+import dotenv from "dotenv";
+import { fileURLToPath } from "url";
+import path from "path";
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = path.dirname(__filename);
+
+dotenv.config({ path: path.resolve(__dirname, "../../../.env") });
+
+// These run against the database named by DB_NAME_TEST, with `npm test`; they are
+// skipped when it is not set. The modules are loaded only then, as loading them
+// connects Sequelize.
+const skip = !process.env.DB_NAME_TEST && "DB_NAME_TEST is not set";
+
+process.env.ACCESS_TOKEN_SECRET ||= "test-access-token-secret";
+process.env.REFRESH_TOKEN_SECRET ||= "test-refresh-token-secret";
+
+describe("users", { skip }, () => {
+  const suffix = randomUUID();
+  let db;
+  let resolvers;
+  let requestAs;
+  let member;
+  let manager;
+
+  before(async () => {
+    db = (await import("../config/sequelize.js")).default;
+    resolvers = (await import("./resolvers.js")).default;
+    const { generateAccessToken } = await import("../utils/generateTokens.js");
+
+    // A request as express hands it to the resolvers, carrying the user's access token.
+    requestAs = (user) => {
+      const headers = { authorization: `Bearer ${generateAccessToken(user)}` };
+      return { headers, get: (name) => headers[name.toLowerCase()] };
+    };
+
+    await db.sequelize.sync();
+    [member, manager] = await Promise.all(
+      ["MEMBER", "MANAGER"].map((role) =>
+        db.User.create({
+          username: `${role.toLowerCase()}_${suffix}`,
+          email: `${role.toLowerCase()}.${suffix}@example.com`,
+          password: "JohnDoe01@",
+          role,
+        })
+      )
+    );
+  });
+
+  after(async () => {
+    await db.User.destroy({ where: { userId: [member.userId, manager.userId] } });
+    await db.sequelize.close();
+  });
+
+  test("a MEMBER cannot enumerate users", async () => {
+    await assert.rejects(
+      resolvers.Query.users(null, { search: suffix }, { req: requestAs(member) }),
+      (err) => err.extensions.code === "FORBIDDEN"
+    );
+  });
+
+  test("a MANAGER can list users", async () => {
+    const { totalCount, users } = await resolvers.Query.users(
+      null,
+      { search: suffix },
+      { req: requestAs(manager) }
+    );
+    assert.equal(totalCount, 2);
+    assert.deepEqual(users.map((u) => u.role).sort(), ["MANAGER", "MEMBER"]);
+    assert.ok(users.every((u) => u.password === undefined));
+  });
+
+  test("an anonymous caller cannot enumerate users", async () => {
+    const req = { headers: {}, get: () => undefined };
+    await assert.rejects(
+      resolvers.Query.users(null, {}, { req }),
+      (err) => err.extensions.code === "UNAUTHENTICATED"
+    );
+  });
+});
+
+describe("updating and deactivating users", { skip }, () => {
+  const suffix = randomUUID();
+  const password = "JohnDoe01@";
+  let db;
+  let jwt;
+  let resolvers;
+  let requestAs;
+  let member;
+  let other;
+  let manager;
+
+  // The response express would hand the resolvers; the cookie and headers are dropped.
+  const res = { cookie: () => {}, setHeader: () => {} };
+
+  before(async () => {
+    db = (await import("../config/sequelize.js")).default;
+    jwt = (await import("jsonwebtoken")).default;
+    resolvers = (await import("./resolvers.js")).default;
+    const { generateAccessToken } = await import("../utils/generateTokens.js");
+
+    requestAs = (user) => {
+      const headers = { authorization: `Bearer ${generateAccessToken(user)}` };
+      return { headers, get: (name) => headers[name.toLowerCase()] };
+    };
+
+    await db.sequelize.sync();
+    [member, other, manager] = await Promise.all(
+      [
+        ["member", "MEMBER"],
+        ["other", "MEMBER"],
+        ["manager", "MANAGER"],
+      ].map(([name, role]) =>
+        db.User.create({
+          username: `${name}_${suffix}`,
+          email: `${name}.${suffix}@example.com`,
+          password,
+          role,
+        })
+      )
+    );
+  });
+
+  after(async () => {
+    const userId = [member.userId, other.userId, manager.userId];
+    await db.SessionCutoff.destroy({ where: { userId } });
+    await db.User.destroy({ where: { userId } });
+    await db.sequelize.close();
+  });
+
+  const updateUser = (caller, userId, input) =>
+    resolvers.Mutation.updateUser(
+      null,
+      { userId, input },
+      { req: requestAs(caller), res }
+    );
+
+  test("only managers change roles, and nobody their own", async () => {
+    let result = await updateUser(member, other.userId, { role: "MANAGER" });
+    assert.equal(result.code, "403");
+    result = await updateUser(member, member.userId, { role: "MANAGER" });
+    assert.equal(result.code, "403");
+    result = await updateUser(manager, manager.userId, { role: "MEMBER" });
+    assert.equal(result.code, "403");
+    await other.reload();
+    assert.equal(other.role, "MEMBER");
+
+    result = await updateUser(manager, other.userId, { role: "MANAGER" });
+    assert.equal(result.code, "200");
+    assert.equal(result.user.role, "MANAGER");
+    await updateUser(manager, other.userId, { role: "MEMBER" });
+    await other.reload();
+  });
+
+  test("a MEMBER edits only their own username and email", async () => {
+    let result = await updateUser(member, member.userId, {
+      username: `renamed_${suffix}`,
+      email: `renamed.${suffix}@example.com`,
+    });
+    assert.equal(result.code, "200");
+    assert.equal(result.user.username, `renamed_${suffix}`);
+    assert.equal(result.user.email, `renamed.${suffix}@example.com`);
+    assert.equal(result.user.password, undefined);
+
+    result = await updateUser(member, other.userId, {
+      username: `taken_${suffix}`,
+    });
+    assert.equal(result.code, "403");
+    await other.reload();
+    assert.equal(other.username, `other_${suffix}`);
+  });
+
+  test("a deactivated user fails login and token verification", async () => {
+    const loggedIn = await resolvers.Mutation.login(
+      null,
+      { input: { email: other.email, password } },
+      { req: requestAs(other), res }
+    );
+    assert.equal(loggedIn.code, "200");
+
+    const refused = await resolvers.Mutation.deactivateUser(
+      null,
+      { userId: other.userId },
+      { req: requestAs(member), res }
+    );
+    assert.equal(refused.code, "403");
+
+    const result = await resolvers.Mutation.deactivateUser(
+      null,
+      { userId: other.userId },
+      { req: requestAs(manager), res }
+    );
+    assert.equal(result.code, "200");
+    assert.equal(result.user.active, false);
+
+    const login = await resolvers.Mutation.login(
+      null,
+      { input: { email: other.email, password } },
+      { req: requestAs(other), res }
+    );
+    assert.equal(login.code, "403");
+    assert.equal(login.accessToken, null);
+
+    // The token issued before is revoked; one issued afterwards is turned down too.
+    const revoked = await resolvers.Query.verifyToken(
+      null,
+      { token: loggedIn.accessToken },
+      { res }
+    );
+    assert.equal(revoked.success, false);
+    assert.equal(revoked.code, "401");
+
+    const later = jwt.sign(
+      {
+        userId: other.userId,
+        role: other.role,
+        iat: Math.floor(Date.now() / 1000) + 2,
+      },
+      process.env.ACCESS_TOKEN_SECRET,
+      { expiresIn: "30m" }
+    );
+    const deactivated = await resolvers.Query.verifyToken(
+      null,
+      { token: later },
+      { res }
+    );
+    assert.equal(deactivated.success, false);
+    assert.equal(deactivated.code, "403");
+  });
+});
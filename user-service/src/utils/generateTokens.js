@@ -1,4 +1,5 @@
 import jwt from "jsonwebtoken";
+import { randomUUID } from "crypto";
 import dotenv from "dotenv";
 import { fileURLToPath } from "url";
 import path from "path";
@@ -14,6 +15,7 @@ export const generateAccessToken = (user) => {
     process.env.ACCESS_TOKEN_SECRET,
     {
       expiresIn: "30m",
+      jwtid: randomUUID(),
     }
   );
 };
@@ -24,6 +26,7 @@ export const generateRefreshToken = (user) => {
     process.env.REFRESH_TOKEN_SECRET,
     {
       expiresIn: "1d",
+      jwtid: randomUUID(),
     }
   );
 };
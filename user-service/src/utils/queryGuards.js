@@ -0,0 +1,102 @@
+import { GraphQLError, Kind } from "graphql";
+
+const timeoutMs = Number(process.env.GRAPHQL_TIMEOUT_MS) || 15000;
+const maxDepth = Number(process.env.GRAPHQL_MAX_DEPTH) || 8;
+
+const SENSITIVE_VARIABLE = /pass|token|secret/i;
+
+// Rejects queries nested deeper than GRAPHQL_MAX_DEPTH (default 8) before they run.
+// Fragments count towards the depth of the selection that spreads them.
+export function depthLimitRule(context) {
+  const fragments = {};
+  for (const definition of context.getDocument().definitions) {
+    if (definition.kind === Kind.FRAGMENT_DEFINITION) {
+      fragments[definition.name.value] = definition;
+    }
+  }
+
+  const depthOf = (selectionSet, depth, seen) => {
+    if (!selectionSet) return depth;
+    let deepest = depth;
+    for (const selection of selectionSet.selections) {
+      if (selection.kind === Kind.FIELD) {
+        deepest = Math.max(deepest, depthOf(selection.selectionSet, depth + 1, seen));
+      } else if (selection.kind === Kind.INLINE_FRAGMENT) {
+        deepest = Math.max(deepest, depthOf(selection.selectionSet, depth, seen));
+      } else if (selection.kind === Kind.FRAGMENT_SPREAD) {
+        const name = selection.name.value;
+        if (seen.has(name) || !fragments[name]) continue;
+        deepest = Math.max(deepest, depthOf(fragments[name].selectionSet, depth, new Set(seen).add(name)));
+      }
+    }
+    return deepest;
+  };
+
+  return {
+    OperationDefinition(operation) {
+      const depth = depthOf(operation.selectionSet, 0, new Set());
+      if (depth > maxDepth) {
+        context.reportError(
+          new GraphQLError(`Query depth ${depth} exceeds the maximum of ${maxDepth}`, {
+            nodes: [operation],
+            extensions: { code: "QUERY_TOO_DEEP" },
+          })
+        );
+      }
+    },
+  };
+}
+
+// Callers send their correlation ID as a header and as the correlationId extension.
+function correlationIdOf(request) {
+  return request.http?.headers.get("x-correlation-id") ?? request.extensions?.correlationId ?? null;
+}
+
+function redact(variables) {
+  if (!variables) return variables;
+  return Object.fromEntries(
+    Object.entries(variables).map(([key, value]) => {
+      if (SENSITIVE_VARIABLE.test(key)) return [key, "[REDACTED]"];
+      if (value && typeof value === "object" && !Array.isArray(value)) return [key, redact(value)];
+      return [key, value];
+    })
+  );
+}
+
+// Enforces GRAPHQL_TIMEOUT_MS (default 15000) per request. The deadline is checked before
+// every field resolves, so a slow resolver fails the fields after it instead of holding
+// the connection, and logs resolver failures with the operation they belonged to.
+export const queryGuardsPlugin = {
+  async requestDidStart() {
+    const deadline = Date.now() + timeoutMs;
+
+    return {
+      async executionDidStart() {
+        return {
+          willResolveField() {
+            if (Date.now() > deadline) {
+              throw new GraphQLError(`Query exceeded the ${timeoutMs}ms time limit`, {
+                extensions: { code: "TIMEOUT" },
+              });
+            }
+          },
+        };
+      },
+
+      async didEncounterErrors({ operationName, request, errors }) {
+        for (const error of errors) {
+          // Errors raised on purpose carry a code; anything else is an unexpected throw.
+          if (error.extensions?.code && !error.originalError) continue;
+          console.error("GraphQL resolver error", {
+            operationName: operationName ?? request.operationName ?? null,
+            correlationId: correlationIdOf(request),
+            path: error.path,
+            variables: redact(request.variables),
+            message: error.message,
+            stack: error.originalError?.stack ?? error.stack,
+          });
+        }
+      },
+    };
+  },
+};
@@ -0,0 +1,59 @@
+import { createHash } from "crypto";
+import { Op } from "sequelize";
+import db from "../config/sequelize.js";
+
+// Revokes one decoded token until its own expiry. Returns false for tokens issued without
+// a jti, which can only be cut off with revokeAllSessions.
+export async function revokeToken(decoded) {
+  if (!decoded.jti) return false;
+
+  await db.RevokedToken.upsert({
+    jti: decoded.jti,
+    userId: decoded.userId,
+    expiresAt: new Date(decoded.exp * 1000),
+  });
+  // Expired tokens fail verification on their own, so their rows are no longer needed.
+  await db.RevokedToken.destroy({ where: { expiresAt: { [Op.lt]: new Date() } } });
+  return true;
+}
+
+// Rejects every token issued to the user up to now. iat only has second precision, so
+// tokens issued during the rest of the current second are rejected as well.
+export async function revokeAllSessions(userId) {
+  await db.SessionCutoff.upsert({ userId, invalidBefore: new Date() });
+}
+
+export async function isRevoked(decoded) {
+  if (decoded.jti && (await db.RevokedToken.findByPk(decoded.jti))) {
+    return true;
+  }
+  const cutoff = await db.SessionCutoff.findByPk(decoded.userId);
+  return Boolean(cutoff) && decoded.iat * 1000 <= cutoff.invalidBefore.getTime();
+}
+
+// Asks seta-service to drop its cached verifyToken answers for a revoked token, or for
+// every token of a user, instead of serving them until they expire. Best effort: when
+// SETA_SERVICE_URL is unset or the call fails, the cache runs out on its own within
+// VERIFY_TOKEN_CACHE_SECONDS.
+export async function notifyRevocation({ token, userId }) {
+  const baseUrl = process.env.SETA_SERVICE_URL;
+  if (!baseUrl) return;
+
+  const body = userId
+    ? { userId }
+    : { tokenSha256: createHash("sha256").update(token).digest("hex") };
+  try {
+    const res = await fetch(`${baseUrl}/internal/auth/revocations`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "X-Internal-API-Key": process.env.INTERNAL_API_KEY || "",
+      },
+      body: JSON.stringify(body),
+      signal: AbortSignal.timeout(2000),
+    });
+    if (!res.ok) console.error(`seta-service revocation notice failed: ${res.status}`);
+  } catch (err) {
+    console.error(`seta-service revocation notice failed: ${err.message}`);
+  }
+}